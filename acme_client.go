@@ -0,0 +1,103 @@
+package letsdebug
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// acmeClientFingerprint recognizes a web server or ACME client from its response to the
+// validation request, so that advice specific to it can be given.
+type acmeClientFingerprint struct {
+	Client string
+	// A match of the Server header, or of the response body, identifies the client
+	Server  *regexp.Regexp
+	Content []byte
+	// Whether the Server header must be absent, for clients which don't send one
+	NoServer bool
+	Advice   string
+	Severity SeverityLevel
+}
+
+var acmeClientFingerprints = []acmeClientFingerprint{
+	{
+		Client: "Caddy",
+		Server: regexp.MustCompile(`^Caddy\b`),
+		Advice: "Caddy obtains and renews certificates for the sites in its configuration by itself. Rather than running " +
+			"certbot or another ACME client beside it (which competes with Caddy for port 80 and for rate limits), add the " +
+			"domain to your Caddyfile and let Caddy manage its certificate. If Caddy is failing to, its logs will say why.",
+		Severity: SeverityWarning,
+	},
+	{
+		Client:   "Traefik",
+		Content:  []byte("404 page not found"),
+		NoServer: true,
+		Advice: "This looks like Traefik (or another server written in Go) answering with its default 404 page, which means " +
+			"no router matched the request. Traefik obtains certificates by itself through a certificate resolver: rather " +
+			"than running another ACME client beside it, configure certResolver on the router for this domain, and make " +
+			"sure the entrypoint on port 80 is the one the resolver's httpChallenge uses.",
+		Severity: SeverityWarning,
+	},
+	{
+		Client: "certbot --standalone",
+		Server: regexp.MustCompile(`^BaseHTTP/[\d.]+ Python/`),
+		Advice: "A Python web server answered, which is what certbot's standalone mode (and the acme library's standalone " +
+			"server) looks like. It only serves challenges while certbot is running, so another web server should normally " +
+			"be answering on port 80. If a certbot process is left over from an earlier run, stop it. If you also run a " +
+			"web server, use certbot's --webroot or web server plugins instead of --standalone so they don't compete for port 80.",
+		Severity: SeverityWarning,
+	},
+	{
+		Client:  "IIS (e.g. win-acme)",
+		Server:  regexp.MustCompile(`^Microsoft-IIS/`),
+		Content: []byte("404.3"),
+		Advice: "IIS refused to serve the challenge file because it has no file extension and there is no MIME map for it " +
+			"(HTTP Error 404.3). win-acme normally places a web.config in /.well-known/acme-challenge/ which adds one: make " +
+			"sure it is there and that the site allows configuration to be overridden, or add a MIME map for \".\" " +
+			"(extensionless files) as text/plain to that folder yourself.",
+		Severity: SeverityError,
+	},
+}
+
+// matches reports whether the response came from the client. Every criterion which is set must match.
+func (f acmeClientFingerprint) matches(res httpCheckResult) bool {
+	if f.Server != nil && !f.Server.MatchString(res.ServerHeader) {
+		return false
+	}
+	if f.NoServer && res.ServerHeader != "" {
+		return false
+	}
+	if f.Content != nil && !bytes.Contains(res.Content, f.Content) {
+		return false
+	}
+	return true
+}
+
+// analyzeACMEClients looks for traces of web servers which manage certificates themselves, or of
+// ACME clients, in the responses to the validation request.
+func analyzeACMEClients(domain string, results []httpCheckResult) []Problem {
+	var probs []Problem
+	for _, f := range acmeClientFingerprints {
+		var addresses []string
+		for _, res := range results {
+			if !res.IsZero() && f.matches(res) {
+				addresses = append(addresses, res.IP.String())
+			}
+		}
+		if len(addresses) > 0 {
+			probs = append(probs, acmeClientDetected(domain, f, addresses))
+		}
+	}
+	return probs
+}
+
+func acmeClientDetected(domain string, f acmeClientFingerprint, addresses []string) Problem {
+	return Problem{
+		Name: "ACMEClientDetected",
+		Explanation: fmt.Sprintf(`The response to the validation request for %s looks like it came from %s. %s`,
+			domain, f.Client, f.Advice),
+		Detail:   fmt.Sprintf("Seen at: %s", strings.Join(addresses, ", ")),
+		Severity: f.Severity,
+	}
+}
@@ -0,0 +1,41 @@
+package letsdebug
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeACMEClients(t *testing.T) {
+	result := func(ip, server, content string) httpCheckResult {
+		return httpCheckResult{IP: net.ParseIP(ip), StatusCode: 404, ServerHeader: server, Content: []byte(content)}
+	}
+
+	if probs := analyzeACMEClients("example.org", []httpCheckResult{
+		result("192.0.2.1", "nginx", "404 page not found"),
+		result("192.0.2.2", "Microsoft-IIS/10.0", "HTTP Error 404.0 - Not Found"),
+		{IP: net.ParseIP("192.0.2.3")},
+	}); len(probs) != 0 {
+		t.Fatalf("expected no clients to be recognized, got: %v", probs)
+	}
+
+	probs := analyzeACMEClients("example.org", []httpCheckResult{
+		result("192.0.2.1", "Caddy", ""),
+		result("192.0.2.2", "Caddy", ""),
+		result("192.0.2.3", "Microsoft-IIS/10.0", "HTTP Error 404.3 - Not Found"),
+	})
+	if len(probs) != 2 {
+		t.Fatalf("expected Caddy and IIS to be recognized, got: %v", probs)
+	}
+	if !strings.Contains(probs[0].Explanation, "Caddy") || probs[0].Detail != "Seen at: 192.0.2.1, 192.0.2.2" {
+		t.Errorf("unexpected Caddy problem: %+v", probs[0])
+	}
+	if !strings.Contains(probs[1].Explanation, "MIME map") || probs[1].Severity != SeverityError {
+		t.Errorf("unexpected IIS problem: %+v", probs[1])
+	}
+
+	probs = analyzeACMEClients("example.org", []httpCheckResult{result("192.0.2.1", "BaseHTTP/0.6 Python/3.11.2", "")})
+	if len(probs) != 1 || !strings.Contains(probs[0].Explanation, "certbot --standalone") {
+		t.Fatalf("expected certbot standalone to be recognized, got: %v", probs)
+	}
+}
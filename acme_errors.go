@@ -0,0 +1,43 @@
+package letsdebug
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// What to do with an error type returned by the staging service, in acme_errors.json.
+const (
+	// Report the error as a problem with the domain
+	acmeErrorReport = "report"
+	// Report the error as a possible issue with the staging service, which makes its results unreliable
+	acmeErrorStagingBroken = "staging_broken"
+	// The error is expected (e.g. unauthorized, as Let's Debug can't complete the challenge)
+	acmeErrorIgnore = "ignore"
+)
+
+// acmeErrorsJSON maps the ACME error types (without the urn:ietf:params:acme:error: prefix) returned
+// by the staging service to what translateAcmeError does with them. Unlisted types are ignored.
+//
+//go:embed acme_errors.json
+var acmeErrorsJSON []byte
+
+var acmeErrorsData = &dataset[map[string]string]{
+	name:     "acme_errors",
+	embedded: acmeErrorsJSON,
+	url:      bundledDataURL + "acme_errors.json",
+	parse: func(buf []byte) (map[string]string, error) {
+		var actions map[string]string
+		if err := json.Unmarshal(buf, &actions); err != nil {
+			return nil, err
+		}
+		for urn, action := range actions {
+			switch action {
+			case acmeErrorReport, acmeErrorStagingBroken, acmeErrorIgnore:
+			default:
+				return nil, fmt.Errorf("unknown action %q for %s", action, urn)
+			}
+		}
+		return actions, nil
+	},
+}
@@ -0,0 +1,215 @@
+package letsdebug
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// anycastChecker looks for DNS providers which serve different answers depending on where they are
+// queried from. Large providers run their nameservers from many points of presence behind anycast
+// addresses, and a record which has not yet propagated to every one of them is only seen by some of
+// Let's Encrypt's validation perspectives, which fails multi-perspective validation even though the
+// record is visible from everywhere else. Each authoritative nameserver is queried with an EDNS
+// client subnet in each region Let's Encrypt validates from, which providers use to pick the answer.
+type anycastChecker struct{}
+
+// ecsRegions are client subnets in each region Let's Encrypt validates from.
+var ecsRegions = []struct {
+	Name   string
+	Subnet string
+}{
+	{"us-east", "3.208.0.0/24"},
+	{"us-west", "34.208.0.0/24"},
+	{"eu-central", "3.120.0.0/24"},
+	{"ap-southeast", "13.228.0.0/24"},
+}
+
+const anycastQueryTimeout = 3 * time.Second
+
+type regionalAnswer struct {
+	Server nameserverAddress
+	Region string
+	Name   string
+	Type   uint16
+	Rcode  int
+	Answer []string
+	Error  error
+}
+
+// Signature is what must be the same for every region for the answers to agree.
+func (a regionalAnswer) Signature() string {
+	if len(a.Answer) == 0 {
+		return fmt.Sprintf("%s, no records", dns.RcodeToString[a.Rcode])
+	}
+	return fmt.Sprintf("%s, %s", dns.RcodeToString[a.Rcode], strings.Join(a.Answer, " | "))
+}
+
+func (a regionalAnswer) String() string {
+	prefix := fmt.Sprintf("%s/%s @ %s from %s:", a.Name, dns.TypeToString[a.Type], a.Server, a.Region)
+	if a.Error != nil {
+		return fmt.Sprintf("%s error: %v", prefix, a.Error)
+	}
+	return fmt.Sprintf("%s %s", prefix, a.Signature())
+}
+
+// anycastProvider returns the name of the anycast DNS provider serving the nameservers, if any.
+func anycastProvider(servers []nameserverAddress) string {
+	for _, server := range servers {
+		host := "." + strings.ToLower(normalizeFqdn(server.Host))
+		for _, p := range dnsProvidersData.Get() {
+			if p.Anycast && strings.Contains(host, p.Pattern) {
+				return p.Name
+			}
+		}
+	}
+	return ""
+}
+
+func (c anycastChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	domain = strings.TrimPrefix(domain, "*.")
+
+	servers := authoritativeServers(ctx, domain)
+	provider := anycastProvider(servers)
+	if provider == "" {
+		return nil, errNotApplicable
+	}
+
+	queries := []dnsQuery{{domain, dns.TypeA}, {domain, dns.TypeAAAA}}
+	if method == DNS01 {
+		queries = []dnsQuery{{"_acme-challenge." + domain, dns.TypeTXT}}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var answers []regionalAnswer
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server nameserverAddress) {
+			defer wg.Done()
+			for _, q := range queries {
+				for _, region := range ecsRegions {
+					a := queryWithClientSubnet(server, q.name, q.rrType, region.Subnet)
+					a.Region = region.Name
+					mu.Lock()
+					answers = append(answers, a)
+					mu.Unlock()
+				}
+			}
+		}(server)
+	}
+	wg.Wait()
+
+	return analyzeRegionalAnswers(provider, answers), nil
+}
+
+func queryWithClientSubnet(server nameserverAddress, name string, rrType uint16, subnet string) regionalAnswer {
+	a := regionalAnswer{Server: server, Name: name, Type: rrType}
+
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		a.Error = err
+		return a
+	}
+	ones, _ := ipNet.Mask.Size()
+	ecs := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        1,
+		SourceNetmask: uint8(ones),
+		Address:       ipNet.IP,
+	}
+	if ipNet.IP.To4() == nil {
+		ecs.Family = 2
+	}
+
+	q := &dns.Msg{}
+	q.SetQuestion(dns.Fqdn(name), rrType)
+	q.SetEdns0(caResolverBufferSize, true)
+	opt := q.IsEdns0()
+	opt.Option = append(opt.Option, ecs)
+
+	cl := &dns.Client{Net: "udp", UDPSize: caResolverBufferSize, Timeout: anycastQueryTimeout}
+	r, _, err := cl.Exchange(q, net.JoinHostPort(server.IP.String(), "53"))
+	if err == nil && r.Truncated {
+		cl.Net = "tcp"
+		r, _, err = cl.Exchange(q, net.JoinHostPort(server.IP.String(), "53"))
+	}
+	if err != nil {
+		a.Error = err
+		return a
+	}
+	a.Rcode, a.Answer = r.Rcode, canonicalAnswer(r)
+	return a
+}
+
+func analyzeRegionalAnswers(provider string, answers []regionalAnswer) []Problem {
+	sort.SliceStable(answers, func(i, j int) bool {
+		if answers[i].Name != answers[j].Name {
+			return answers[i].Name < answers[j].Name
+		}
+		if answers[i].Type != answers[j].Type {
+			return answers[i].Type < answers[j].Type
+		}
+		return answers[i].Server.String() < answers[j].Server.String()
+	})
+
+	var lines []string
+	signatures := map[dnsQuery]map[string][]string{}
+	var order []dnsQuery
+	for _, a := range answers {
+		lines = append(lines, a.String())
+		if a.Error != nil {
+			continue
+		}
+		q := dnsQuery{a.Name, a.Type}
+		if signatures[q] == nil {
+			signatures[q] = map[string][]string{}
+			order = append(order, q)
+		}
+		sig := a.Signature()
+		signatures[q][sig] = append(signatures[q][sig], fmt.Sprintf("%s @ %s", a.Region, a.Server))
+	}
+
+	var probs []Problem
+	for _, q := range order {
+		if len(signatures[q]) < 2 {
+			continue
+		}
+		var sigs []string
+		for sig := range signatures[q] {
+			sigs = append(sigs, sig)
+		}
+		sort.Strings(sigs)
+		var detail []string
+		for _, sig := range sigs {
+			detail = append(detail, fmt.Sprintf("%s: seen from %s", sig, strings.Join(signatures[q][sig], ", ")))
+		}
+		probs = append(probs, dnsRegionalDivergence(provider, q.name, dns.TypeToString[q.rrType], detail))
+	}
+
+	if len(lines) > 0 {
+		probs = append(probs, debugProblem("DNSRegionalAnswers",
+			fmt.Sprintf("The answers %s gave to clients in each region Let's Encrypt validates from", provider),
+			strings.Join(lines, "\n")))
+	}
+	return probs
+}
+
+func dnsRegionalDivergence(provider, name, rrType string, detail []string) Problem {
+	return Problem{
+		Name: "DNSRegionalDivergence",
+		Explanation: fmt.Sprintf(`The nameservers of %s gave different answers to the %s lookup for %s depending on the region `+
+			`the query came from. %s serves DNS from many locations, and changes can take a while to reach all of them. `+
+			`Let's Encrypt validates from several regions and requires most of them to succeed, so a record which is only visible `+
+			`in some regions can cause validation to fail even though it appears correct to you. Wait for the change to propagate `+
+			`everywhere (or increase your ACME client's propagation delay) before requesting the certificate, and check for `+
+			`geographic routing rules which apply to this name.`, provider, rrType, name, provider),
+		Detail:   strings.Join(detail, "\n"),
+		Severity: SeverityWarning,
+	}
+}
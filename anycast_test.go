@@ -0,0 +1,60 @@
+package letsdebug
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAnycastProvider(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"kate.ns.cloudflare.com", "Cloudflare"},
+		{"ns-1234.awsdns-12.org", "Amazon Route 53"},
+		{"dns1.p01.nsone.net", "NS1"},
+		{"ns1.example.org", ""},
+		{"notns.cloudflare.com.example.org", ""},
+	}
+	for _, tt := range tests {
+		got := anycastProvider([]nameserverAddress{{tt.host, net.ParseIP("192.0.2.1")}})
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.host, tt.want, got)
+		}
+	}
+}
+
+func TestAnalyzeRegionalAnswers(t *testing.T) {
+	server := nameserverAddress{"kate.ns.cloudflare.com", net.ParseIP("192.0.2.1")}
+	answer := func(region string, records ...string) regionalAnswer {
+		return regionalAnswer{Server: server, Region: region, Name: "_acme-challenge.example.org", Type: dns.TypeTXT, Answer: records}
+	}
+	names := func(probs []Problem) map[string]bool {
+		out := map[string]bool{}
+		for _, p := range probs {
+			out[p.Name] = true
+		}
+		return out
+	}
+
+	consistent := analyzeRegionalAnswers("Cloudflare", []regionalAnswer{
+		answer("us-east", "token"),
+		answer("eu-central", "token"),
+		{Server: server, Region: "ap-southeast", Name: "_acme-challenge.example.org", Type: dns.TypeTXT, Error: errors.New("i/o timeout")},
+	})
+	if got := names(consistent); got["DNSRegionalDivergence"] || !got["DNSRegionalAnswers"] {
+		t.Errorf("consistent answers: unexpected problems: %v", got)
+	}
+
+	divergent := analyzeRegionalAnswers("Cloudflare", []regionalAnswer{
+		answer("us-east", "token"),
+		answer("eu-central"),
+		answer("ap-southeast", "token"),
+	})
+	if got := names(divergent); !got["DNSRegionalDivergence"] {
+		t.Errorf("divergent answers: expected DNSRegionalDivergence, got: %v", got)
+	}
+}
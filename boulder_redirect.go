@@ -0,0 +1,202 @@
+package letsdebug
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	psl "github.com/weppos/publicsuffix-go/publicsuffix"
+)
+
+// maxRedirectPathLength is the longest path of a redirect target which Boulder will follow.
+const maxRedirectPathLength = 2000
+
+// boulderRedirectTarget returns the host and port which Boulder would connect to when following a
+// redirect to u, or the reason it would refuse to. It mirrors extractRequestTarget in Boulder's VA,
+// which is stricter than a browser: only ports 80 and 443 are allowed, and only hostnames under a
+// public TLD.
+func boulderRedirectTarget(u *url.URL) (string, int, error) {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", 0, redirectError(fmt.Sprintf("Bad scheme provided when fetching %s: %s", u.String(), scheme))
+	}
+
+	host, port := u.Hostname(), 80
+	if scheme == "https" {
+		port = 443
+	}
+	if p := u.Port(); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || (n != 80 && n != 443) {
+			return "", 0, redirectError(fmt.Sprintf("Bad port number provided when fetching %s: %s", u.String(), p))
+		}
+		port = n
+	}
+
+	if host == "" {
+		return "", 0, redirectError(fmt.Sprintf("The redirect to %s has an empty hostname", u.String()))
+	}
+	if net.ParseIP(host) != nil {
+		return "", 0, redirectError(fmt.Sprintf("The redirect to %s is to an IP address, but Let's Encrypt only follows redirects "+
+			"to domain names", u.String()))
+	}
+	// Also check for domain.tld.well-known/acme-challenge
+	if strings.HasSuffix(host, ".well-known") {
+		return "", 0, redirectError(fmt.Sprintf("It appears that a redirect was generated by your web server that is missing a trailing "+
+			"slash after your domain name: %v. Check your web server configuration and .htaccess for Redirect/RedirectMatch/RewriteRule.",
+			u.String()))
+	}
+	if publicSuffixList().Find(host, &psl.FindOptions{IgnorePrivate: true, DefaultRule: nil}) == nil {
+		return "", 0, redirectError(fmt.Sprintf("The redirect to %s is to a hostname which doesn't end in a public TLD", u.String()))
+	}
+	if len(u.EscapedPath()) > maxRedirectPathLength {
+		return "", 0, redirectError(fmt.Sprintf("The path of the redirect to %s is longer than %d characters", u.String(), maxRedirectPathLength))
+	}
+
+	return host, port, nil
+}
+
+// narrateRedirects describes what Boulder would do at each request made while following the redirects
+// of the validation request to address, to show where its behavior differs from a browser's.
+func narrateRedirects(domain string, address net.IP, res httpCheckResult) []string {
+	var lines []string
+	visited := map[string]bool{}
+	for i, exchange := range res.Exchanges {
+		u, err := url.Parse(exchange.URL)
+		if err != nil {
+			continue
+		}
+		visited[u.String()] = true
+
+		if i == 0 {
+			lines = append(lines, fmt.Sprintf("%d. %s %s: Boulder would connect to %s, one of the addresses of %s, with Host: %s.",
+				i+1, exchange.Method, exchange.URL, net.JoinHostPort(address.String(), "80"), domain, u.Host))
+		}
+		if exchange.Error != nil {
+			lines = append(lines, fmt.Sprintf("   The request failed (%v), which would fail validation.", exchange.Error))
+			continue
+		}
+
+		location := exchange.ResponseHeader.Get("Location")
+		status, _ := strconv.Atoi(strings.SplitN(exchange.Status, " ", 2)[0])
+		switch {
+		case status == http.StatusOK:
+			lines = append(lines, fmt.Sprintf("   HTTP %s: Boulder would check this response for the key authorization.", exchange.Status))
+			continue
+		case !isFollowedRedirect(status) || location == "":
+			lines = append(lines, fmt.Sprintf("   HTTP %s: Boulder would fail validation, as it only accepts HTTP 200 responses.", exchange.Status))
+			continue
+		}
+
+		target, err := u.Parse(location)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("   HTTP %s to %q, which is not a valid URL, so Boulder would fail validation.", exchange.Status, location))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("   HTTP %s redirect to %s", exchange.Status, target))
+
+		host, port, err := boulderRedirectTarget(target)
+		switch {
+		case err != nil:
+			lines = append(lines, fmt.Sprintf("%d. Boulder would refuse to follow it, failing validation: %v", i+2, err))
+			return lines
+		case visited[target.String()]:
+			lines = append(lines, fmt.Sprintf("%d. Boulder would detect a redirect loop, failing validation.", i+2))
+			return lines
+		case i+1 >= 10:
+			lines = append(lines, fmt.Sprintf("%d. Boulder would refuse to follow more than 10 redirects, failing validation.", i+2))
+			return lines
+		}
+
+		var how []string
+		if strings.EqualFold(normalizeFqdn(host), domain) {
+			how = append(how, fmt.Sprintf("resolve %s again rather than reusing %s, so it may connect to a different address of the "+
+				"domain than this test, which stays on %s to test each address on its own", host, address, address))
+		} else {
+			how = append(how, fmt.Sprintf("resolve %s, trying its IPv6 addresses before IPv4", host))
+		}
+		scheme := strings.ToLower(target.Scheme)
+		switch {
+		case scheme == "https" && port == 80:
+			how = append(how, "speak TLS to port 80, as the redirect asks")
+		case scheme == "http" && port == 443:
+			how = append(how, "speak plain HTTP to port 443, as the redirect asks")
+		default:
+			how = append(how, fmt.Sprintf("connect to port %d", port))
+		}
+		if scheme == "https" {
+			how = append(how, fmt.Sprintf("send %s as the SNI without verifying the certificate", host))
+		}
+		how = append(how, fmt.Sprintf("send Host: %s", target.Host))
+		if target.RawQuery != "" {
+			how = append(how, "keep the query string")
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s %s: Boulder would %s.", i+2, http.MethodGet, target, strings.Join(how, ", ")))
+	}
+	return lines
+}
+
+// isFollowedRedirect is whether a response with the status code is a redirect which Boulder (like Go's
+// HTTP client) follows.
+func isFollowedRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// browserRedirectRegexp matches the redirects which browsers follow but Boulder doesn't: HTML meta
+// refresh tags, and JavaScript which assigns the location.
+var browserRedirectRegexp = regexp.MustCompile(`(?i)<meta[^>]+http-equiv\s*=\s*["']?refresh|(?:window|document)\.location(?:\.href)?\s*=|location\.(?:replace|assign)\s*\(`)
+
+// analyzeRedirectSimulation narrates what Boulder would do at each step of the validation requests,
+// and warns about servers which rely on a browser to follow redirects that Boulder doesn't.
+func analyzeRedirectSimulation(domain string, results []httpCheckResult) []Problem {
+	var narratives, browserOnly []string
+	for _, res := range results {
+		if len(res.Exchanges) == 0 {
+			continue
+		}
+		narratives = append(narratives, fmt.Sprintf("Starting with %s:\n%s", res.IP,
+			strings.Join(narrateRedirects(domain, res.IP, res), "\n")))
+
+		last := res.Exchanges[len(res.Exchanges)-1]
+		if last.Error != nil || res.StatusCode != http.StatusOK {
+			continue
+		}
+		switch {
+		case last.ResponseHeader.Get("Refresh") != "":
+			browserOnly = append(browserOnly, fmt.Sprintf("%s (from %s): Refresh: %s", last.URL, res.IP, last.ResponseHeader.Get("Refresh")))
+		case browserRedirectRegexp.Match(res.Content):
+			browserOnly = append(browserOnly, fmt.Sprintf("%s (from %s): %s", last.URL, res.IP,
+				browserRedirectRegexp.FindString(string(res.Content))))
+		}
+	}
+
+	if len(narratives) == 0 {
+		return nil
+	}
+	probs := []Problem{debugProblem("RedirectSimulation", "What the Let's Encrypt validation server (Boulder) would do at each "+
+		"step of the validation request", strings.Join(narratives, "\n\n"))}
+	if len(browserOnly) > 0 {
+		probs = append(probs, browserOnlyRedirect(domain, browserOnly))
+	}
+	return probs
+}
+
+func browserOnlyRedirect(domain string, responses []string) Problem {
+	return Problem{
+		Name: "BrowserOnlyRedirect",
+		Explanation: fmt.Sprintf(`The response to the validation request to %s appears to redirect elsewhere in a way that only `+
+			`browsers follow: with a Refresh header, an HTML meta refresh tag or JavaScript. Let's Encrypt only follows HTTP redirects `+
+			`(301, 302, 303, 307 and 308 responses with a Location header), and will check this page itself for the key authorization, `+
+			`so validation will fail. Serve the challenge files directly, or redirect with an HTTP redirect.`, domain),
+		Detail:   strings.Join(responses, "\n"),
+		Severity: SeverityWarning,
+	}
+}
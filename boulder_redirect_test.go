@@ -0,0 +1,77 @@
+package letsdebug
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBoulderRedirectTarget(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+		port int
+	}{
+		{"http://example.org/.well-known/acme-challenge/x", "example.org", 80},
+		{"https://www.example.org/.well-known/acme-challenge/x", "www.example.org", 443},
+		{"http://example.org:443/x", "example.org", 443},
+		{"https://example.org:80/x", "example.org", 80},
+		{"https://example.org:8443/x", "", 0},
+		{"ftp://example.org/x", "", 0},
+		{"http://192.0.2.1/x", "", 0},
+		{"http://[2001:db8::1]/x", "", 0},
+		{"http://example.org.well-known/acme-challenge/x", "", 0},
+		{"http://example.org/" + strings.Repeat("a", maxRedirectPathLength), "", 0},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		host, port, err := boulderRedirectTarget(u)
+		if host != tt.host || port != tt.port || (err == nil) != (tt.host != "") {
+			t.Errorf("%s: expected %s:%d, got %s:%d (%v)", tt.url, tt.host, tt.port, host, port, err)
+		}
+	}
+}
+
+func TestAnalyzeRedirectSimulation(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+	redirect := func(from, to string) httpExchange {
+		return httpExchange{Method: "GET", URL: from, Status: "301 Moved Permanently", ResponseHeader: http.Header{"Location": {to}}}
+	}
+	ok := httpExchange{Method: "GET", URL: "https://www.example.org/.well-known/acme-challenge/x", Status: "200 OK", ResponseHeader: http.Header{}}
+
+	res := httpCheckResult{IP: ip, StatusCode: 200, Exchanges: []httpExchange{
+		redirect("http://example.org/.well-known/acme-challenge/x", "https://www.example.org/.well-known/acme-challenge/x"),
+		ok,
+	}}
+	probs := analyzeRedirectSimulation("example.org", []httpCheckResult{res})
+	if len(probs) != 1 || probs[0].Name != "RedirectSimulation" ||
+		!strings.Contains(probs[0].Detail, "send www.example.org as the SNI") ||
+		!strings.Contains(probs[0].Detail, "check this response for the key authorization") {
+		t.Fatalf("expected a narrative of the redirect, got: %v", probs)
+	}
+
+	res = httpCheckResult{IP: ip, StatusCode: 301, Exchanges: []httpExchange{
+		redirect("http://example.org/.well-known/acme-challenge/x", "http://example.org/.well-known/acme-challenge/x"),
+	}}
+	if probs := analyzeRedirectSimulation("example.org", []httpCheckResult{res}); !strings.Contains(probs[0].Detail, "redirect loop") {
+		t.Fatalf("expected the loop to be narrated, got: %v", probs)
+	}
+
+	res = httpCheckResult{IP: ip, StatusCode: 301, Exchanges: []httpExchange{
+		redirect("http://example.org/.well-known/acme-challenge/x", "http://192.0.2.2/.well-known/acme-challenge/x"),
+	}}
+	if probs := analyzeRedirectSimulation("example.org", []httpCheckResult{res}); !strings.Contains(probs[0].Detail, "refuse to follow it") {
+		t.Fatalf("expected the redirect to an address to be refused, got: %v", probs)
+	}
+
+	res = httpCheckResult{IP: ip, StatusCode: 200, Content: []byte(`<meta http-equiv="refresh" content="0; url=/challenge">`), Exchanges: []httpExchange{ok}}
+	probs = analyzeRedirectSimulation("example.org", []httpCheckResult{res})
+	if len(probs) != 2 || probs[1].Name != "BrowserOnlyRedirect" {
+		t.Fatalf("expected the meta refresh to be reported, got: %v", probs)
+	}
+}
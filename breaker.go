@@ -0,0 +1,82 @@
+package letsdebug
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	breakerFailureThreshold = 3
+	breakerCooldown         = time.Minute
+)
+
+// Circuit breakers for the external services consulted during a scan. They are shared by
+// every scan in the process, so that when a service is down, workers stop timing out against
+// it one after another and skip it instead.
+var (
+	crtshBreaker    = newCircuitBreaker("crt.sh", breakerFailureThreshold, breakerCooldown)
+	statusioBreaker = newCircuitBreaker("status.io", breakerFailureThreshold, breakerCooldown)
+	stagingBreaker  = newCircuitBreaker("the Let's Encrypt staging service", breakerFailureThreshold, breakerCooldown)
+)
+
+// circuitBreaker tracks consecutive failures of an external service. Once threshold
+// consecutive failures have been seen, the breaker opens and Allow refuses requests until
+// cooldown has elapsed. After that, a single trial request is let through: if it succeeds
+// the breaker closes again, and if it fails the breaker stays open for another cooldown.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	lastErr   error
+}
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports whether a request to the service should be attempted. When it should not,
+// the returned Problem explains why the check was skipped.
+func (b *circuitBreaker) Allow() (Problem, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return Problem{}, true
+	}
+	if now := time.Now(); now.After(b.openUntil) {
+		// Let this request through as the trial, and hold off everyone else until it
+		// reports back (or, should it never do so, until another cooldown has elapsed).
+		b.openUntil = now.Add(b.cooldown)
+		return Problem{}, true
+	}
+
+	return internalProblem(fmt.Sprintf("Skipped because %s has failed %d times in a row (last error: %v), "+
+		"it will be retried after %v", b.name, b.failures, b.lastErr, b.openUntil.Format(time.RFC3339)), SeverityDebug), false
+}
+
+// Success closes the breaker.
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.lastErr = nil
+}
+
+// Failure records a failed request, opening the breaker once the threshold is reached.
+func (b *circuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastErr = err
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		debug("[*] circuit breaker for %s is open until %v: %v\n", b.name, b.openUntil, err)
+	}
+}
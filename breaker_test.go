@@ -0,0 +1,39 @@
+package letsdebug
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker("test", 2, time.Hour)
+
+	b.Failure(errors.New("timeout"))
+	if _, ok := b.Allow(); !ok {
+		t.Fatal("expected breaker to stay closed below the threshold")
+	}
+
+	b.Failure(errors.New("timeout"))
+	p, ok := b.Allow()
+	if ok {
+		t.Fatal("expected breaker to open at the threshold")
+	}
+	if p.Name != "InternalProblem" || p.Severity != SeverityDebug {
+		t.Fatalf("expected a debug internal problem, got: %v", p)
+	}
+
+	// Once the cooldown has elapsed, a single trial request is let through
+	b.openUntil = time.Now().Add(-time.Second)
+	if _, ok := b.Allow(); !ok {
+		t.Fatal("expected a trial request after the cooldown")
+	}
+	if _, ok := b.Allow(); ok {
+		t.Fatal("expected only a single trial request")
+	}
+
+	b.Success()
+	if _, ok := b.Allow(); !ok {
+		t.Fatal("expected breaker to close after a success")
+	}
+}
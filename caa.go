@@ -0,0 +1,155 @@
+package letsdebug
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// caaIssuanceTags are the CAA tags which Let's Encrypt acts upon.
+var caaIssuanceTags = []string{"issue", "issuewild", "iodef"}
+
+var (
+	caaTagSyntax      = regexp.MustCompile(`^[a-zA-Z0-9]{1,15}$`)
+	caaPhoneSyntax    = regexp.MustCompile(`^\+[0-9]{1,15}$`)
+	caaPhoneSeparator = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+)
+
+// caaTagMistake returns why a non-critical CAA record with a tag that Let's Encrypt doesn't
+// act upon is probably a mistake, or "" if it looks intentional. Such records are silently
+// ignored by the CA, so a mistyped "issue" tag quietly removes a restriction the user meant to add.
+func caaTagMistake(rr *dns.CAA) string {
+	normalized := strings.ToLower(strings.TrimSpace(rr.Tag))
+	for _, known := range caaIssuanceTags {
+		if normalized == known {
+			return fmt.Sprintf("the tag %q differs from %q by case or whitespace, so it is ignored", rr.Tag, known)
+		}
+		if editDistance(normalized, known) <= 2 {
+			return fmt.Sprintf("the tag %q looks like a misspelling of %q, so it is ignored", rr.Tag, known)
+		}
+	}
+
+	if !caaTagSyntax.MatchString(rr.Tag) {
+		return fmt.Sprintf("the tag %q is not valid: tags may only contain letters and digits, and be at most 15 characters long", rr.Tag)
+	}
+
+	switch rr.Tag {
+	case "contactemail":
+		if _, err := mail.ParseAddress(rr.Value); err != nil || strings.ContainsAny(rr.Value, "<>") {
+			return fmt.Sprintf("the contactemail value %q is not a bare email address", rr.Value)
+		}
+	case "contactphone":
+		if !caaPhoneSyntax.MatchString(caaPhoneSeparator.Replace(rr.Value)) {
+			return fmt.Sprintf("the contactphone value %q is not a phone number in international (E.164) format, e.g. +15555551234", rr.Value)
+		}
+	}
+
+	return ""
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func caaUnrecognizedTag(domain string, mistakes []string) Problem {
+	return Problem{
+		Name: "CAAUnrecognizedTag",
+		Explanation: fmt.Sprintf(`CAA record(s) on %s have a tag which Let's Encrypt does not recognize. Because they are not marked `+
+			`as critical, these records are silently ignored, and any restriction they were intended to express has no effect. `+
+			`The tag appears to be mistyped or malformed, as shown in the detail.`, domain),
+		Detail:   strings.Join(mistakes, "\n"),
+		Severity: SeverityWarning,
+	}
+}
+
+// caaGoverningRecords returns the CAA records which decide whether the requested identifier may be
+// issued for, and the records of the other issuance tag, which don't. issuewild governs wildcard
+// identifiers when present, and is otherwise ignored. issue governs everything else.
+func caaGoverningRecords(wildcard bool, issue, issuewild []*dns.CAA) (governing, other []*dns.CAA) {
+	if wildcard && len(issuewild) > 0 {
+		return issuewild, issue
+	}
+	return issue, issuewild
+}
+
+func caaAllowsLetsEncrypt(records []*dns.CAA) bool {
+	for _, r := range records {
+		if extractIssuerDomain(r.Value) == "letsencrypt.org" {
+			return true
+		}
+	}
+	return false
+}
+
+// caaDecisionTable shows which of the issue and issuewild records governs the requested identifier,
+// and whether each of them would allow Let's Encrypt to issue.
+func caaDecisionTable(domain string, wildcard bool, issue, issuewild []*dns.CAA) string {
+	requested := domain
+	if wildcard {
+		requested = "*." + domain
+	}
+	governing, _ := caaGoverningRecords(wildcard, issue, issuewild)
+
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	lines := []string{
+		fmt.Sprintf("Requested identifier: %s (wildcard=%t)", requested, wildcard),
+		"",
+		fmt.Sprintf("%-10s %-8s %-23s %s", "Tag", "Records", "Allows letsencrypt.org", "Governs this identifier"),
+	}
+	for _, row := range []struct {
+		tag     string
+		records []*dns.CAA
+	}{{"issue", issue}, {"issuewild", issuewild}} {
+		governs := len(row.records) > 0 && len(governing) > 0 && row.records[0] == governing[0]
+		allows := "-"
+		if len(row.records) > 0 {
+			allows = yesNo(caaAllowsLetsEncrypt(row.records))
+		}
+		lines = append(lines, fmt.Sprintf("%-10s %-8d %-23s %s", row.tag, len(row.records), allows, yesNo(governs)))
+	}
+	lines = append(lines, "", collateRecords(append(append([]*dns.CAA(nil), issue...), issuewild...)))
+	return strings.Join(lines, "\n")
+}
+
+func caaIssueWildConflict(domain string, wildcard bool, issue, issuewild []*dns.CAA) Problem {
+	explanation := fmt.Sprintf(`The "issue" CAA records on %s allow Let's Encrypt, but the "issuewild" records do not. `+
+		`For a wildcard identifier like *.%s, "issuewild" records take precedence over "issue" records whenever any are present, `+
+		`so issuance is denied. Add "letsencrypt.org" to the "issuewild" records, or remove them to fall back to the "issue" records.`,
+		domain, domain)
+	if !wildcard {
+		explanation = fmt.Sprintf(`The "issuewild" CAA records on %s allow Let's Encrypt, but the "issue" records do not. `+
+			`"issuewild" records only apply to wildcard identifiers, so issuance for %s is governed by the "issue" records alone, `+
+			`and is denied. Add "letsencrypt.org" to the "issue" records.`, domain, domain)
+	}
+	return Problem{
+		Name:        "CAAIssueWildConflict",
+		Explanation: explanation,
+		Detail:      caaDecisionTable(domain, wildcard, issue, issuewild),
+		Severity:    SeverityFatal,
+	}
+}
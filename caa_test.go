@@ -0,0 +1,70 @@
+package letsdebug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCAATagMistake(t *testing.T) {
+	tests := []struct {
+		tag, value string
+		mistake    bool
+	}{
+		{"contactemail", "hostmaster@example.org", false},
+		{"contactemail", "Hostmaster <hostmaster@example.org>", true},
+		{"contactphone", "+1 (555) 555-1234", false},
+		{"contactphone", "555 1234", true},
+		{"issuevmc", "digicert.com", false},
+		{"tbs", "unknown", false},
+		{"issuewild ", "letsencrypt.org", true},
+		{"Issue", "letsencrypt.org", true},
+		{"issu", "letsencrypt.org", true},
+		{"isseuwild", "letsencrypt.org", true},
+		{"iodfe", "mailto:security@example.org", true},
+		{"contact-email", "hostmaster@example.org", true},
+		{"averyveryverylongtag", "", true},
+	}
+	for _, tc := range tests {
+		mistake := caaTagMistake(&dns.CAA{Tag: tc.tag, Value: tc.value})
+		if (mistake != "") != tc.mistake {
+			t.Errorf("%q %q: expected mistake=%t, got %q", tc.tag, tc.value, tc.mistake, mistake)
+		}
+	}
+}
+
+func TestCAAIssueWildConflict(t *testing.T) {
+	allow := &dns.CAA{Tag: "issue", Value: "letsencrypt.org"}
+	deny := &dns.CAA{Tag: "issue", Value: ";"}
+	allowWild := &dns.CAA{Tag: "issuewild", Value: "letsencrypt.org"}
+	denyWild := &dns.CAA{Tag: "issuewild", Value: ";"}
+
+	tests := []struct {
+		name             string
+		wildcard         bool
+		issue, issuewild []*dns.CAA
+		allowed          bool
+		conflict         bool
+	}{
+		{"wildcard falls back to issue", true, []*dns.CAA{allow}, nil, true, false},
+		{"wildcard denied by issuewild", true, []*dns.CAA{allow}, []*dns.CAA{denyWild}, false, true},
+		{"wildcard allowed by issuewild", true, []*dns.CAA{deny}, []*dns.CAA{allowWild}, true, false},
+		{"non-wildcard ignores issuewild", false, []*dns.CAA{deny}, []*dns.CAA{allowWild}, false, true},
+		{"non-wildcard denied", false, []*dns.CAA{deny}, nil, false, false},
+	}
+	for _, tc := range tests {
+		governing, other := caaGoverningRecords(tc.wildcard, tc.issue, tc.issuewild)
+		if allowed := caaAllowsLetsEncrypt(governing); allowed != tc.allowed {
+			t.Errorf("%s: expected allowed=%t, got %t", tc.name, tc.allowed, allowed)
+		}
+		if conflict := !tc.allowed && len(other) > 0 && caaAllowsLetsEncrypt(other); conflict != tc.conflict {
+			t.Errorf("%s: expected conflict=%t, got %t", tc.name, tc.conflict, conflict)
+		}
+	}
+
+	p := caaIssueWildConflict("example.org", true, []*dns.CAA{allow}, []*dns.CAA{denyWild})
+	if !strings.Contains(p.Detail, "issuewild  1        no                      yes") {
+		t.Errorf("expected issuewild to govern the wildcard identifier, got:\n%s", p.Detail)
+	}
+}
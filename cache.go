@@ -0,0 +1,85 @@
+package letsdebug
+
+import (
+	"sync"
+	"time"
+)
+
+// problemCache memoizes checker results across scans within the same process.
+// It is used for checkers which consult external services whose answers do not
+// change from one scan to the next, so that busy deployments don't hammer them.
+type problemCache struct {
+	mu      sync.Mutex
+	entries map[string]problemCacheEntry
+}
+
+type problemCacheEntry struct {
+	Problems []Problem
+	// Challenge is the staging challenge which produced Problems, if any, so that
+	// a cached staging result still contributes evidence to analyzeDiscrepancies.
+	Challenge *stagingChallenge
+	Stored    time.Time
+	Expires   time.Time
+}
+
+func newProblemCache() *problemCache {
+	return &problemCache{entries: map[string]problemCacheEntry{}}
+}
+
+// Get returns a copy of the cached problems for key, if they are present and have
+// not yet expired.
+func (c *problemCache) Get(key string) ([]Problem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.Expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return append([]Problem(nil), entry.Problems...), true
+}
+
+// GetFresh is like Get, but additionally requires that the entry was stored no
+// longer than maxAge ago. The whole entry is returned, including any challenge.
+func (c *problemCache) GetFresh(key string, maxAge time.Duration) (problemCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.Expires) || time.Since(entry.Stored) > maxAge {
+		return problemCacheEntry{}, false
+	}
+
+	entry.Problems = append([]Problem(nil), entry.Problems...)
+	return entry, true
+}
+
+// Set stores a copy of probs under key for the duration of ttl.
+func (c *problemCache) Set(key string, probs []Problem, ttl time.Duration) {
+	c.SetEntry(key, problemCacheEntry{Problems: probs}, ttl)
+}
+
+// SetEntry is like Set, but stores the whole entry. Its timestamps are overwritten.
+func (c *problemCache) SetEntry(key string, entry problemCacheEntry, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Opportunistically evict anything that has expired, so that
+	// per-domain keys don't accumulate forever.
+	now := time.Now()
+	for k, v := range c.entries {
+		if now.After(v.Expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	entry.Problems = append([]Problem(nil), entry.Problems...)
+	entry.Stored = now
+	entry.Expires = now.Add(ttl)
+	c.entries[key] = entry
+}
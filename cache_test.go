@@ -0,0 +1,44 @@
+package letsdebug
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProblemCache(t *testing.T) {
+	c := newProblemCache()
+
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("expected miss for missing key")
+	}
+
+	c.Set("a", []Problem{{Name: "A"}}, time.Minute)
+	probs, ok := c.Get("a")
+	if !ok || len(probs) != 1 || probs[0].Name != "A" {
+		t.Fatalf("expected cached problem, got: %v (%t)", probs, ok)
+	}
+
+	// Mutating the returned slice should not affect the cache
+	probs[0].Name = "B"
+	if probs, _ := c.Get("a"); probs[0].Name != "A" {
+		t.Fatalf("cache entry was mutated: %v", probs)
+	}
+
+	if _, ok := c.GetFresh("a", 30*time.Second); !ok {
+		t.Fatal("expected fresh entry to be returned")
+	}
+	if _, ok := c.GetFresh("a", -time.Second); ok {
+		t.Fatal("expected entry older than maxAge to be a miss")
+	}
+
+	chal := &stagingChallenge{Type: "dns-01", Status: "invalid"}
+	c.SetEntry("staging", problemCacheEntry{Problems: []Problem{{Name: "A"}}, Challenge: chal}, time.Minute)
+	if entry, ok := c.GetFresh("staging", time.Minute); !ok || entry.Challenge != chal || entry.Stored.IsZero() {
+		t.Fatalf("expected challenge to be cached with the problems, got: %+v (%t)", entry, ok)
+	}
+
+	c.Set("expired", []Problem{{Name: "A"}}, -time.Second)
+	if _, ok := c.Get("expired"); ok {
+		t.Fatal("expected expired entry to be a miss")
+	}
+}
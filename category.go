@@ -0,0 +1,157 @@
+package letsdebug
+
+// Category groups problems by the part of the setup they concern, so that results can be filtered.
+type Category string
+
+const (
+	CategoryDNS         Category = "DNS"         // Records, nameservers and resolution
+	CategoryHTTP        Category = "HTTP"        // The web server and the http-01 validation request
+	CategoryTLS         Category = "TLS"         // Certificates and TLS connections
+	CategoryCAA         Category = "CAA"         // CAA records
+	CategoryNetwork     Category = "Network"     // Addresses, routing and firewalls
+	CategoryLetsEncrypt Category = "LetsEncrypt" // Rate limits, policy, service status and the staging service
+	CategoryGeneral     Category = "General"     // Everything else, including internal problems
+)
+
+// Categories lists every category, in the order in which they should be presented.
+var Categories = []Category{
+	CategoryDNS, CategoryHTTP, CategoryTLS, CategoryCAA, CategoryNetwork, CategoryLetsEncrypt, CategoryGeneral,
+}
+
+// problemCategories maps Problem.Name to its category. Problems which aren't listed are CategoryGeneral.
+var problemCategories = map[string]Category{
+	"DNSLookupFailed":                 CategoryDNS,
+	"DNSLookups":                      CategoryDNS,
+	"DNSLookupSkipped":                CategoryDNS,
+	"DNSResponses":                    CategoryDNS,
+	"DNSContacts":                     CategoryDNS,
+	"DNSHostingSuspended":             CategoryDNS,
+	"DNSProviderMismatch":             CategoryDNS,
+	"DNSRegionalDivergence":           CategoryDNS,
+	"DNSRegionalAnswers":              CategoryDNS,
+	"DNSResponseSourceMismatch":       CategoryDNS,
+	"DNSResponseSource":               CategoryDNS,
+	"DNSResponseTooLarge":             CategoryDNS,
+	"DNSResponseSize":                 CategoryDNS,
+	"DNSTCPFallbackFailed":            CategoryDNS,
+	"DNSTransportMismatch":            CategoryDNS,
+	"NoRecords":                       CategoryDNS,
+	"ReservedAddress":                 CategoryDNS,
+	"TXTDoubleLabel":                  CategoryDNS,
+	"TXTRecordError":                  CategoryDNS,
+	"ChallengeTXTMismatch":            CategoryDNS,
+	"DNS01Delegation":                 CategoryDNS,
+	"DNS01DelegationDangling":         CategoryDNS,
+	"DNS01DelegationNoNameservers":    CategoryDNS,
+	"DNS01DelegatedServerUnreachable": CategoryDNS,
+	"DNS01DelegatedServersDisagree":   CategoryDNS,
+	"DNS01DelegationManaged":          CategoryDNS,
+	"ChallengeZoneDelegated":          CategoryDNS,
+	"ChallengeZone":                   CategoryDNS,
+	"DynamicIPAddress":                CategoryDNS,
+	"InvalidDomain":                   CategoryDNS,
+	"PublicSuffix":                    CategoryDNS,
+	"HTTPRecords":                     CategoryDNS,
+	"DNSSECChainBroken":               CategoryDNS,
+	"DNSSECAlgorithmUnsupported":      CategoryDNS,
+	"DNSSECChain":                     CategoryDNS,
+
+	"ANotWorking":                  CategoryHTTP,
+	"AAAANotWorking":               CategoryHTTP,
+	"BadRedirect":                  CategoryHTTP,
+	"HTTPSRedirectNotWorking":      CategoryHTTP,
+	"BrowserOnlyRedirect":          CategoryHTTP,
+	"RedirectSimulation":           CategoryHTTP,
+	"HTTPCheck":                    CategoryHTTP,
+	"HTTPHeaders":                  CategoryHTTP,
+	"HTTPProbingRefused":           CategoryHTTP,
+	"HTTP3":                        CategoryHTTP,
+	"HTTP3Unreachable":             CategoryHTTP,
+	"UnexpectedHttpResponse":       CategoryHTTP,
+	"WebserverMisconfiguration":    CategoryHTTP,
+	"MultipleIPAddressDiscrepancy": CategoryHTTP,
+	"InconsistentBackends":         CategoryHTTP,
+	"SlowHTTPResponse":             CategoryHTTP,
+	"RegistrarForwarding":          CategoryHTTP,
+	"BlockedByNginxTestCookie":     CategoryHTTP,
+	"CloudflareCDN":                CategoryHTTP,
+	"CloudflareSSLNotProvisioned":  CategoryHTTP,
+	"ProxyBlocksValidation":        CategoryHTTP,
+	"OriginWouldFail":              CategoryHTTP,
+	"OriginCheck":                  CategoryHTTP,
+	"StandalonePortConflict":       CategoryHTTP,
+	"ACMEClientDetected":           CategoryHTTP,
+	"HttpOnHttpsPort":              CategoryHTTP,
+
+	"TLS":                         CategoryTLS,
+	"TLSInterception":             CategoryTLS,
+	"InconsistentTLS":             CategoryTLS,
+	"TLSOnHTTPPort":               CategoryTLS,
+	"PlaintextOnHTTPSPort":        CategoryTLS,
+	"IncompleteCertificateChain":  CategoryTLS,
+	"InstalledCertificate":        CategoryTLS,
+	"InstalledCertificateExpired": CategoryTLS,
+	"ExpiredRootCrossSign":        CategoryTLS,
+	"RedirectCertificates":        CategoryTLS,
+	"RedirectCertificateInvalid":  CategoryTLS,
+	"TLSALPNNotWorking":           CategoryTLS,
+	"TLSALPNNotNegotiated":        CategoryTLS,
+	"TLSALPNBadCertificate":       CategoryTLS,
+	"TLSALPNRedirectIgnored":      CategoryTLS,
+	"TLSALPNCheck":                CategoryTLS,
+
+	"CAA":                   CategoryCAA,
+	"CAACriticalUnknown":    CategoryCAA,
+	"CAAIssuanceNotAllowed": CategoryCAA,
+	"CAAIssueWildConflict":  CategoryCAA,
+	"CAAUnrecognizedTag":    CategoryCAA,
+	"CAAUnrecognizedTags":   CategoryCAA,
+
+	"BlockedByFirewall":           CategoryNetwork,
+	"CGNATAddress":                CategoryNetwork,
+	"PathMTUBlackhole":            CategoryNetwork,
+	"PortForwarding":              CategoryNetwork,
+	"SourceBlockingSuspected":     CategoryNetwork,
+	"UnreachableFromNorthAmerica": CategoryNetwork,
+	"PerspectiveUnreachable":      CategoryNetwork,
+	"SourceAddressFiltered":       CategoryNetwork,
+	"PerspectiveDNSMismatch":      CategoryDNS,
+
+	"RateLimit":                   CategoryLetsEncrypt,
+	"IssueFromLetsEncrypt":        CategoryLetsEncrypt,
+	"StagingDiscrepancy":          CategoryLetsEncrypt,
+	"LetsEncryptStaging":          CategoryLetsEncrypt,
+	"LetsEncryptStagingAuthz":     CategoryLetsEncrypt,
+	"LetsEncryptStagingCached":    CategoryLetsEncrypt,
+	"StatusNotOperational":        CategoryLetsEncrypt,
+	"StatusIO":                    CategoryLetsEncrypt,
+	"PossibleLetsEncryptIncident": CategoryLetsEncrypt,
+	"CompetingACMEClients":        CategoryLetsEncrypt,
+	"SiblingCertificates":         CategoryLetsEncrypt,
+	"MethodNotSuitable":           CategoryLetsEncrypt,
+	"OrderMethodNotSuitable":      CategoryLetsEncrypt,
+	"OrderBlockedByIdentifier":    CategoryLetsEncrypt,
+	"TooManyIdentifiers":          CategoryLetsEncrypt,
+	"UnsafeDomain":                CategoryLetsEncrypt,
+	"SafeBrowsing":                CategoryLetsEncrypt,
+	"SanctionedDomain":            CategoryLetsEncrypt,
+}
+
+// CategoryOf returns the category of problems with the name, which is CategoryGeneral for those
+// which aren't known, e.g. problems stored by an older version.
+func CategoryOf(name string) Category {
+	if c, ok := problemCategories[name]; ok {
+		return c
+	}
+	return CategoryGeneral
+}
+
+// withCategories populates Problem.Category for every problem which doesn't have one.
+func withCategories(probs []Problem) []Problem {
+	for i := range probs {
+		if probs[i].Category == "" {
+			probs[i].Category = CategoryOf(probs[i].Name)
+		}
+	}
+	return probs
+}
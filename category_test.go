@@ -0,0 +1,27 @@
+package letsdebug
+
+import "testing"
+
+func TestCategoryOf(t *testing.T) {
+	if got := CategoryOf("ANotWorking"); got != CategoryHTTP {
+		t.Errorf("ANotWorking: got %s, want %s", got, CategoryHTTP)
+	}
+	if got := CategoryOf("NoSuchProblem"); got != CategoryGeneral {
+		t.Errorf("NoSuchProblem: got %s, want %s", got, CategoryGeneral)
+	}
+
+	probs := withCategories([]Problem{{Name: "CAA"}, {Name: "CAA", Category: CategoryGeneral}})
+	if probs[0].Category != CategoryCAA || probs[1].Category != CategoryGeneral {
+		t.Errorf("unexpected categories: %s, %s", probs[0].Category, probs[1].Category)
+	}
+
+	for _, c := range problemCategories {
+		found := false
+		for _, listed := range Categories {
+			found = found || c == listed
+		}
+		if !found {
+			t.Errorf("%s is not in Categories", c)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package letsdebug
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// cdnRangesJSON maps the names of CDN and reverse proxy providers to the address ranges they
+// publish for their edge servers.
+//
+//go:embed cdn_ranges.json
+var cdnRangesJSON []byte
+
+// cdnRangesUpdated is the date cdn_ranges.json was last refreshed from the providers' published lists.
+// It is reported in ResultMetadata, and must be updated along with the file.
+const cdnRangesUpdated = "2026-10-16"
+
+var cdnRangesData = &dataset[map[string][]*net.IPNet]{
+	name:            "cdn_ranges",
+	embedded:        cdnRangesJSON,
+	embeddedVersion: func() string { return cdnRangesUpdated },
+	url:             bundledDataURL + "cdn_ranges.json",
+	parse:           parseCDNRanges,
+}
+
+func parseCDNRanges(buf []byte) (map[string][]*net.IPNet, error) {
+	var raw map[string][]string
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return nil, err
+	}
+	ranges := map[string][]*net.IPNet{}
+	for provider, cidrs := range raw {
+		for _, cidr := range cidrs {
+			_, n, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", provider, err)
+			}
+			ranges[provider] = append(ranges[provider], n)
+		}
+	}
+	return ranges, nil
+}
+
+// cdnForAddress returns the name of the CDN which operates the address, if it is a known one.
+func cdnForAddress(ip net.IP) (string, bool) {
+	ranges := cdnRangesData.Get()
+	providers := make([]string, 0, len(ranges))
+	for provider := range ranges {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		for _, n := range ranges[provider] {
+			if n.Contains(ip) {
+				return provider, true
+			}
+		}
+	}
+	return "", false
+}
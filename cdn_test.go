@@ -0,0 +1,35 @@
+package letsdebug
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCDNForAddress(t *testing.T) {
+	tests := []struct {
+		ip       string
+		provider string
+	}{
+		{"104.16.0.1", "Cloudflare"},
+		{"2606:4700::6810:1", "Cloudflare"},
+		{"151.101.1.1", "Fastly"},
+		{"192.0.2.1", ""},
+	}
+	for _, tc := range tests {
+		provider, ok := cdnForAddress(net.ParseIP(tc.ip))
+		if provider != tc.provider || ok != (tc.provider != "") {
+			t.Errorf("%s: expected %q, got %q (%v)", tc.ip, tc.provider, provider, ok)
+		}
+	}
+}
+
+func TestOriginCheckerNotApplicable(t *testing.T) {
+	ctx := newScanContext()
+	if _, err := (originChecker{}).Check(ctx, "example.org", HTTP01); err != errNotApplicable {
+		t.Errorf("expected the check to be skipped without origin options, got: %v", err)
+	}
+	ctx.probeOriginHints = true
+	if _, err := (originChecker{}).Check(ctx, "example.org", DNS01); err != errNotApplicable {
+		t.Errorf("expected the check to be skipped for dns-01, got: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package letsdebug
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	certwatchDSN = "user=guest dbname=certwatch host=crt.sh sslmode=disable connect_timeout=5"
+
+	certwatchMinBackoff = time.Second
+	certwatchMaxBackoff = time.Minute
+)
+
+// CertwatchConfig sizes the pool of connections to the certwatch database of crt.sh, which is shared
+// by the rate limit check of every scan in the process (and the certwatch gateway of the web server).
+// Zero values leave the defaults in place.
+type CertwatchConfig struct {
+	// MaxOpenConns is the most connections open at once (default 5)
+	MaxOpenConns int
+	// MaxIdleConns is the most connections kept open while unused (default 2)
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection is used for before it is replaced (default 5 minutes)
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is how long an unused connection is kept open for (default 1 minute)
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout asks the server to abandon statements which run for longer (default none)
+	StatementTimeout time.Duration
+}
+
+// certwatchPool opens the connection pool on first use, and replaces it after a query fails in a way
+// which leaves the connections suspect, e.g. a timeout against a wedged connection. Attempts to
+// reconnect after a failure back off exponentially, so that workers don't queue up behind crt.sh.
+type certwatchPool struct {
+	dsn string
+
+	mu       sync.Mutex
+	cfg      CertwatchConfig
+	db       *sql.DB
+	failures int
+	retryAt  time.Time
+}
+
+var certwatch = &certwatchPool{dsn: certwatchDSN}
+
+// ConfigureCertwatch replaces the configuration of the pool of connections to the certwatch database.
+// Connections already open are closed once the queries using them complete.
+func ConfigureCertwatch(cfg CertwatchConfig) {
+	certwatch.configure(cfg)
+}
+
+// CertwatchDB returns the pool of connections to the certwatch database, connecting to it first if
+// necessary. The pool must not be closed. Failed queries should be reported to CertwatchQueryFailed.
+func CertwatchDB(ctx context.Context) (*sql.DB, error) {
+	return certwatch.get(ctx)
+}
+
+// CertwatchQueryFailed reports a query made with db which failed with err. Unless the error was reported
+// by the server, the pool is discarded, and the next call to CertwatchDB reconnects.
+func CertwatchQueryFailed(db *sql.DB, err error) {
+	certwatch.queryFailed(db, err)
+}
+
+// CertwatchStats returns the statistics of the pool of connections to the certwatch database, which are
+// zero while it isn't connected.
+func CertwatchStats() sql.DBStats {
+	return certwatch.stats()
+}
+
+func (p *certwatchPool) configure(cfg CertwatchConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cfg = cfg
+	if p.db != nil {
+		p.db.Close()
+		p.db = nil
+	}
+}
+
+func (p *certwatchPool) get(ctx context.Context) (*sql.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db != nil {
+		return p.db, nil
+	}
+	if wait := time.Until(p.retryAt); wait > 0 {
+		return nil, fmt.Errorf("not reconnecting to the certwatch database for another %v, after %d failed attempts",
+			wait.Truncate(time.Second), p.failures)
+	}
+
+	dsn := p.dsn
+	if p.cfg.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", p.cfg.StatementTimeout.Milliseconds())
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err == nil {
+		if err = db.PingContext(ctx); err != nil {
+			db.Close()
+		}
+	}
+	if err != nil {
+		p.failures++
+		p.retryAt = time.Now().Add(certwatchBackoff(p.failures))
+		currentMetrics().Count(metricDataSourceReconnects, map[string]string{"source": crtshBreaker.name, "outcome": "failure"}, 1)
+		return nil, fmt.Errorf("Failed to connect to the certwatch database: %w", err)
+	}
+
+	db.SetMaxOpenConns(orDefault(p.cfg.MaxOpenConns, 5))
+	db.SetMaxIdleConns(orDefault(p.cfg.MaxIdleConns, 2))
+	db.SetConnMaxLifetime(orDefault(p.cfg.ConnMaxLifetime, 5*time.Minute))
+	db.SetConnMaxIdleTime(orDefault(p.cfg.ConnMaxIdleTime, time.Minute))
+
+	p.failures = 0
+	p.retryAt = time.Time{}
+	p.db = db
+	currentMetrics().Count(metricDataSourceReconnects, map[string]string{"source": crtshBreaker.name, "outcome": "success"}, 1)
+	return db, nil
+}
+
+func (p *certwatchPool) queryFailed(db *sql.DB, err error) {
+	// The server answered, so the connection is fine
+	var pqErr *pq.Error
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.As(err, &pqErr) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Another query may already have replaced the pool
+	if p.db != db {
+		return
+	}
+	debug("[*] discarding the connections to the certwatch database: %v\n", err)
+	p.db.Close()
+	p.db = nil
+}
+
+func (p *certwatchPool) stats() sql.DBStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.db == nil {
+		return sql.DBStats{}
+	}
+	return p.db.Stats()
+}
+
+// certwatchBackoff is how long to wait before reconnecting after the given number of consecutive failures.
+func certwatchBackoff(failures int) time.Duration {
+	d := certwatchMinBackoff
+	for i := 1; i < failures && d < certwatchMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > certwatchMaxBackoff {
+		d = certwatchMaxBackoff
+	}
+	return d
+}
+
+func orDefault[T int | time.Duration](v, fallback T) T {
+	if v > 0 {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,70 @@
+package letsdebug
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestCertwatchBackoff(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		7:  time.Minute,
+		50: time.Minute,
+	} {
+		if got := certwatchBackoff(failures); got != want {
+			t.Errorf("%d failures: expected %v, got %v", failures, want, got)
+		}
+	}
+}
+
+func TestCertwatchPoolReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	p := &certwatchPool{dsn: "host=127.0.0.1 port=" + port + " sslmode=disable connect_timeout=1"}
+	if _, err := p.get(context.Background()); err == nil {
+		t.Fatal("expected connecting to a closed port to fail")
+	}
+	if p.failures != 1 || p.retryAt.IsZero() {
+		t.Fatalf("expected the failure to be backed off, got %d failures until %v", p.failures, p.retryAt)
+	}
+	// Within the backoff, nothing is dialed
+	if _, err := p.get(context.Background()); err == nil || p.failures != 1 {
+		t.Errorf("expected to wait out the backoff, got %v after %d failures", err, p.failures)
+	}
+}
+
+func TestCertwatchQueryFailed(t *testing.T) {
+	db, err := sql.Open("postgres", "host=127.0.0.1 sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &certwatchPool{db: db}
+
+	p.queryFailed(db, &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"})
+	if p.db == nil {
+		t.Fatal("expected an error reported by the server to keep the pool")
+	}
+
+	p.queryFailed(&sql.DB{}, context.DeadlineExceeded)
+	if p.db == nil {
+		t.Fatal("expected a failure of an older pool to keep the current one")
+	}
+
+	p.queryFailed(db, errors.Join(errors.New("read tcp"), context.DeadlineExceeded))
+	if p.db != nil {
+		t.Fatal("expected a timeout to discard the pool")
+	}
+}
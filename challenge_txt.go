@@ -0,0 +1,138 @@
+package letsdebug
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// A dns-01 TXT value is the unpadded base64url encoding of a SHA-256 digest, which is always 43
+// characters. A key authorization is a token and an account key thumbprint (also an unpadded
+// base64url SHA-256 digest) joined with a period.
+var (
+	regexChallengeDigest     = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+	regexBase64Digest        = regexp.MustCompile(`^[A-Za-z0-9+/_-]{43}=?$`)
+	regexKeyAuthorization    = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]{43}$`)
+	regexMalformedThumbprint = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9+/_=-]+$`)
+)
+
+type challengeTXTKind int
+
+const (
+	// A well-formed value, which is stale or belongs to another order, client or account
+	challengeTXTStale challengeTXTKind = iota
+	// The key authorization itself, rather than its digest
+	challengeTXTKeyAuthorization
+	// A key authorization whose thumbprint is not a SHA-256 digest in unpadded base64url
+	challengeTXTBadThumbprint
+	// A digest in standard or padded base64, rather than unpadded base64url
+	challengeTXTBadEncoding
+	// Not an ACME value at all
+	challengeTXTUnrelated
+)
+
+func (k challengeTXTKind) String() string {
+	switch k {
+	case challengeTXTStale:
+		return "a well-formed value, but not for this challenge (left over, or from another client or account)"
+	case challengeTXTKeyAuthorization:
+		return "the key authorization itself, rather than its SHA-256 digest"
+	case challengeTXTBadThumbprint:
+		return "a key authorization with a malformed account key thumbprint"
+	case challengeTXTBadEncoding:
+		return "a SHA-256 digest encoded as standard or padded base64, rather than unpadded base64url"
+	default:
+		return "not an ACME challenge value"
+	}
+}
+
+func classifyChallengeTXT(value string) challengeTXTKind {
+	switch {
+	case regexChallengeDigest.MatchString(value):
+		return challengeTXTStale
+	case regexBase64Digest.MatchString(value):
+		return challengeTXTBadEncoding
+	case regexKeyAuthorization.MatchString(value):
+		return challengeTXTKeyAuthorization
+	case regexMalformedThumbprint.MatchString(value):
+		return challengeTXTBadThumbprint
+	default:
+		return challengeTXTUnrelated
+	}
+}
+
+// analyzeChallengeTXT is run once every checker has completed. When the staging dns-01 validation
+// rejected the TXT records already present at _acme-challenge, it works out what those records are,
+// since the value in Let's Encrypt's error message is easily mistaken for the one it expected.
+func analyzeChallengeTXT(ctx *scanContext, domain string, method ValidationMethod) []Problem {
+	if method != DNS01 {
+		return nil
+	}
+	ctx.evidenceMu.Lock()
+	chal := ctx.stagingChallenge
+	ctx.evidenceMu.Unlock()
+	if chal == nil || chal.Error == nil {
+		return nil
+	}
+
+	name := "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+	rrs, err := ctx.Lookup(name, dns.TypeTXT)
+	if err != nil {
+		return nil
+	}
+	var values []string
+	for _, rr := range rrs {
+		if txt, ok := rr.(*dns.TXT); ok {
+			values = append(values, strings.Join(txt.Txt, ""))
+		}
+	}
+
+	if p, ok := analyzeChallengeTXTValues(name, values); ok {
+		return []Problem{p}
+	}
+	return nil
+}
+
+func analyzeChallengeTXTValues(name string, values []string) (Problem, bool) {
+	if len(values) == 0 {
+		return Problem{}, false
+	}
+
+	kinds := map[challengeTXTKind]bool{}
+	var lines []string
+	for _, v := range values {
+		kind := classifyChallengeTXT(v)
+		kinds[kind] = true
+		lines = append(lines, fmt.Sprintf("%q: %s", v, kind))
+	}
+
+	var explanations []string
+	severity := SeverityWarning
+	if kinds[challengeTXTKeyAuthorization] || kinds[challengeTXTBadThumbprint] || kinds[challengeTXTBadEncoding] {
+		severity = SeverityError
+		explanations = append(explanations, `Some of the records are not in the form Let's Encrypt requires: the TXT value must be `+
+			`the SHA-256 digest of the key authorization, encoded as unpadded base64url (43 characters). This usually means that `+
+			`a script or DNS plugin computes the value incorrectly, and validation will keep failing until it is fixed.`)
+	}
+	if kinds[challengeTXTStale] {
+		explanations = append(explanations, `Some of the records are well-formed challenge values, but none can match the challenge `+
+			`Let's Debug just requested, so they are either left over from previous attempts or were created by a different ACME client `+
+			`or account, such as an old client which is still installed. Stale values are harmless alongside the correct one, but if `+
+			`your client's own value is missing, Let's Encrypt reports one of these instead, which is easily mistaken for the value it expected. `+
+			`Remove the stale records, and make sure only one client manages this domain.`)
+	}
+	if kinds[challengeTXTUnrelated] && len(explanations) == 0 {
+		explanations = append(explanations, `The records present are not ACME challenge values. Only records created by your ACME client `+
+			`for the current order can satisfy the challenge.`)
+	}
+
+	return Problem{
+		Name: "ChallengeTXTMismatch",
+		Explanation: fmt.Sprintf(`The Let's Encrypt staging service rejected the TXT records already present at %s. %s`,
+			name, strings.Join(explanations, " ")),
+		Detail:   strings.Join(lines, "\n"),
+		Severity: severity,
+	}, true
+}
@@ -0,0 +1,43 @@
+package letsdebug
+
+import "testing"
+
+func TestClassifyChallengeTXT(t *testing.T) {
+	const digest = "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"
+	tests := []struct {
+		value string
+		want  challengeTXTKind
+	}{
+		{digest, challengeTXTStale},
+		{"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0=", challengeTXTBadEncoding},
+		{"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjE+X0", challengeTXTBadEncoding},
+		{"evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA." + digest, challengeTXTKeyAuthorization},
+		{"evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA.abc123", challengeTXTBadThumbprint},
+		{"google-site-verification=abc", challengeTXTUnrelated},
+		{"v=spf1 -all", challengeTXTUnrelated},
+	}
+	for _, tt := range tests {
+		if got := classifyChallengeTXT(tt.value); got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestAnalyzeChallengeTXTValues(t *testing.T) {
+	if _, ok := analyzeChallengeTXTValues("_acme-challenge.example.org", nil); ok {
+		t.Fatal("expected nothing without any records")
+	}
+
+	p, ok := analyzeChallengeTXTValues("_acme-challenge.example.org", []string{"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"})
+	if !ok || p.Severity != SeverityWarning {
+		t.Fatalf("expected a warning about a stale value, got: %+v", p)
+	}
+
+	p, ok = analyzeChallengeTXTValues("_acme-challenge.example.org", []string{
+		"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0",
+		"evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA.LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0",
+	})
+	if !ok || p.Severity != SeverityError {
+		t.Fatalf("expected an error about a key authorization, got: %+v", p)
+	}
+}
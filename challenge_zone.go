@@ -0,0 +1,66 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+)
+
+// challengeZoneChecker works out which DNS zone the _acme-challenge record of the domain belongs
+// in. When a subdomain is delegated to other nameservers, or _acme-challenge is a CNAME, records
+// created in the zone of the registered domain are never seen, which is a frequent source of confusion.
+type challengeZoneChecker struct{}
+
+func (c challengeZoneChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method != DNS01 {
+		return nil, errNotApplicable
+	}
+
+	name := "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+	chain, err := followCNAMEs(ctx, name)
+	if err != nil {
+		// Broken chains are reported by txtRecordChecker
+		return nil, nil
+	}
+	target := chain[len(chain)-1]
+
+	cut, err := findZoneCut(ctx, target)
+	if err != nil {
+		// Lookup failures are reported by the other checkers
+		return nil, nil
+	}
+
+	registeredDomain, _ := effectiveTLDPlusOne(strings.TrimPrefix(domain, "*."))
+	return []Problem{challengeZone(name, target, cut.Zone, registeredDomain, cut.Nameservers)}, nil
+}
+
+func challengeZone(name, target, zone, registeredDomain string, nameservers []string) Problem {
+	servedBy := "its nameservers"
+	if len(nameservers) > 0 {
+		servedBy = strings.Join(nameservers, ", ")
+	}
+	detail := fmt.Sprintf("Record: %s\nZone: %s\nNameservers: %s", target, zone, servedBy)
+	if target != name {
+		detail = fmt.Sprintf("%s is a CNAME to %s\n%s", name, target, detail)
+	}
+
+	if target == name && zone == registeredDomain {
+		return debugProblem("ChallengeZone", fmt.Sprintf("The zone which %s must be created in", name), detail)
+	}
+
+	var where string
+	if target != name {
+		where = fmt.Sprintf(`%s is a CNAME, so the TXT record must be created at its target, %s, which is in the zone %s`,
+			name, target, zone)
+	} else {
+		where = fmt.Sprintf(`%s is in the zone %s, rather than %s`, name, zone, registeredDomain)
+	}
+	return Problem{
+		Name: "ChallengeZoneDelegated",
+		Explanation: fmt.Sprintf(`%s. The TXT record for the dns-01 challenge must be created in the zone %s, which is served by %s. `+
+			`Records created in the zone of %s (for example, in the DNS control panel of your domain registrar) will not be seen by `+
+			`Let's Encrypt. Make sure your ACME client or DNS plugin is configured with credentials for the DNS provider hosting %s.`,
+			where, zone, servedBy, registeredDomain, zone),
+		Detail:   detail,
+		Severity: SeverityWarning,
+	}
+}
@@ -0,0 +1,79 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestFindZoneCut(t *testing.T) {
+	soa := func(name string) []dns.RR {
+		rr, err := dns.NewRR(name + ". 300 IN SOA ns1.example.net. hostmaster.example.net. 1 7200 3600 1209600 300")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []dns.RR{rr}
+	}
+
+	ctx := newScanContext()
+	answer := func(name string, rrs []dns.RR) {
+		ctx.rrs[name] = map[uint16]lookupResult{dns.TypeSOA: {RRs: rrs}, dns.TypeNS: {}}
+	}
+	answer("_acme-challenge.www.sub.example.org", nil)
+	answer("www.sub.example.org", nil)
+	// A CNAME leads to the SOA of a different zone, which must not be mistaken for a zone cut
+	answer("_acme-challenge.alias.example.org", soa("other.example"))
+	answer("alias.example.org", nil)
+	answer("sub.example.org", soa("sub.example.org"))
+	answer("example.org", soa("example.org"))
+
+	if cut, err := findZoneCut(ctx, "_acme-challenge.www.sub.example.org"); err != nil || cut.Zone != "sub.example.org" {
+		t.Fatalf("expected the delegated zone, got %q (%v)", cut.Zone, err)
+	}
+	if cut, err := findZoneCut(ctx, "_acme-challenge.alias.example.org"); err != nil || cut.Zone != "example.org" {
+		t.Fatalf("expected the parent zone, got %q (%v)", cut.Zone, err)
+	}
+
+	if p := challengeZone("_acme-challenge.www.example.org", "_acme-challenge.www.example.org", "example.org", "example.org", nil); p.Severity != SeverityDebug {
+		t.Fatalf("expected only debug output for the registered domain's zone, got: %+v", p)
+	}
+	if p := challengeZone("_acme-challenge.www.sub.example.org", "_acme-challenge.www.sub.example.org", "sub.example.org", "example.org",
+		[]string{"ns1.example.net"}); p.Name != "ChallengeZoneDelegated" {
+		t.Fatalf("expected the delegated zone to be pointed out, got: %+v", p)
+	}
+}
+
+func TestFollowCNAMEs(t *testing.T) {
+	cname := func(name, target string) map[uint16]lookupResult {
+		rr, err := dns.NewRR(name + ". 300 IN CNAME " + target + ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[uint16]lookupResult{dns.TypeCNAME: {RRs: []dns.RR{rr}}}
+	}
+
+	ctx := newScanContext()
+	ctx.rrs["_acme-challenge.example.org"] = cname("_acme-challenge.example.org", "example.org.acme.example.net")
+	ctx.rrs["example.org.acme.example.net"] = cname("example.org.acme.example.net", "d420c923.auth.acme-dns.io")
+	ctx.rrs["d420c923.auth.acme-dns.io"] = map[uint16]lookupResult{dns.TypeCNAME: {}}
+	ctx.rrs["_acme-challenge.loop.example.org"] = cname("_acme-challenge.loop.example.org", "a.example.net")
+	ctx.rrs["a.example.net"] = cname("a.example.net", "_acme-challenge.loop.example.org")
+
+	chain, err := followCNAMEs(ctx, "_acme-challenge.example.org")
+	if err != nil || len(chain) != 3 || chain[2] != "d420c923.auth.acme-dns.io" {
+		t.Fatalf("expected the chain to end at the acme-dns record, got %v (%v)", chain, err)
+	}
+	if _, err := followCNAMEs(ctx, "_acme-challenge.loop.example.org"); err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Fatalf("expected the loop to be detected, got: %v", err)
+	}
+
+	for i := 0; i <= maxCNAMEChain; i++ {
+		name := fmt.Sprintf("%d.long.example", i)
+		ctx.rrs[name] = cname(name, fmt.Sprintf("%d.long.example", i+1))
+	}
+	if _, err := followCNAMEs(ctx, "0.long.example"); err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Fatalf("expected the chain to be too long, got: %v", err)
+	}
+}
@@ -4,9 +4,8 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"reflect"
+	runtimedebug "runtime/debug"
 	"time"
 )
 
@@ -23,21 +22,6 @@ var (
 	validMethods     = map[ValidationMethod]bool{HTTP01: true, DNS01: true, TLSALPN01: true}
 	errNotApplicable = errors.New("Checker not applicable for this domain and method")
 	checkers         []checker
-
-	problemsPerChecker = promauto.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Namespace: "letsdebug",
-			Name:      "checker_problems_summary",
-			Help:      "Problems found by each checker",
-		},
-		[]string{"checker", "method"})
-	durationPerChecker = promauto.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Namespace: "letsdebug",
-			Name:      "checker_duration_summary",
-			Help:      "Run durations of each checker",
-		},
-		[]string{"checker", "method"})
 )
 
 func init() {
@@ -57,18 +41,38 @@ func init() {
 		},
 
 		asyncCheckerBlock{
-			domainExistsChecker{},    // depends on valid*Checker
-			caaChecker{},             // depends on valid*Checker
-			&rateLimitChecker{},      // depends on valid*Checker
-			dnsAChecker{},            // depends on valid*Checker
-			txtRecordChecker{},       // depends on valid*Checker
-			txtDoubledLabelChecker{}, // depends on valid*Checker
+			domainExistsChecker{},         // depends on valid*Checker
+			caaChecker{},                  // depends on valid*Checker
+			&rateLimitChecker{},           // depends on valid*Checker
+			&siblingCertificatesChecker{}, // depends on valid*Checker
+			dnsAChecker{},                 // depends on valid*Checker
+			txtRecordChecker{},            // depends on valid*Checker
+			txtDoubledLabelChecker{},      // depends on valid*Checker
+			safeBrowsingChecker{},         // depends on valid*Checker
+			dnsSizeChecker{},              // depends on valid*Checker
+			dnsResponseSourceChecker{},    // depends on valid*Checker
+			anycastChecker{},              // depends on valid*Checker
+			dnsSuspensionChecker{},        // depends on valid*Checker
+			dnsProviderMismatchChecker{},  // depends on valid*Checker
+			contactsChecker{},             // depends on valid*Checker
+			challengeZoneChecker{},        // depends on valid*Checker
+			dnssecChainChecker{},          // depends on valid*Checker
+			dns01DelegationChecker{},      // depends on valid*Checker
 		},
 
 		asyncCheckerBlock{
-			httpAccessibilityChecker{}, // depends on dnsAChecker
-			cloudflareChecker{},        // depends on dnsAChecker to some extent
-			&acmeStagingChecker{},      // Gets the final word
+			httpAccessibilityChecker{},    // depends on dnsAChecker
+			cloudflareChecker{},           // depends on dnsAChecker to some extent
+			http3Checker{},                // depends on dnsAChecker
+			tlsInterceptionChecker{},      // depends on dnsAChecker
+			installedCertificateChecker{}, // depends on dnsAChecker
+			perspectiveChecker{},          // depends on dnsAChecker
+			vaFirewallChecker{},           // depends on dnsAChecker
+			tlsALPNChecker{},              // depends on dnsAChecker
+			originChecker{},               // depends on dnsAChecker
+			loadBalancerChecker{},         // depends on dnsAChecker
+			competingClientsChecker{},     // depends on rateLimitChecker
+			&acmeStagingChecker{},         // Gets the final word
 		},
 	}
 }
@@ -85,48 +89,75 @@ type asyncResult struct {
 	Error    error
 }
 
+// Check runs every checker in the block concurrently, and waits for all of them to complete.
+// The problems from every checker are returned, even if some of them fail. A checker that panics
+// is reported as an InternalProblem, rather than aborting the whole block.
 func (c asyncCheckerBlock) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
 	resultCh := make(chan asyncResult, len(c))
 
 	id := fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%d", time.Now().UnixNano()))))[:4]
-	debug("[%s] Launching async\n", id)
+	ctx.logf("[%s] Launching async\n", id)
 
 	for _, task := range c {
 		go func(task checker, ctx *scanContext, domain string, method ValidationMethod) {
-			defer func() {
-				if r := recover(); r != nil {
-					resultCh <- asyncResult{nil, fmt.Errorf("check %T paniced: %v", task, r)}
-				}
-			}()
 			t := reflect.TypeOf(task)
 			if t.Kind() == reflect.Ptr {
 				t = t.Elem()
 			}
-			debug("[%s] async: + %v\n", id, t)
 			start := time.Now()
+			defer func() {
+				if r := recover(); r != nil {
+					ctx.logf("[%s] async: ! %v panicked: %v\n%s\n", id, t, r, runtimedebug.Stack())
+					probs := []Problem{
+						internalProblem(fmt.Sprintf("The %v check panicked and its results are incomplete: %v", t, r), SeverityWarning),
+					}
+					ctx.checkerFinished(t.String(), time.Since(start), len(probs), fmt.Errorf("panic: %v", r))
+					ctx.emitProblems(domain, method, probs)
+					resultCh <- asyncResult{Problems: probs}
+				}
+			}()
+			ctx.logf("[%s] async: + %v\n", id, t)
+			ctx.checkerStarted(t.String())
 			probs, err := task.Check(ctx, domain, method)
+			ctx.emitProblems(domain, method, probs)
 			duration := time.Since(start)
-			labels := prometheus.Labels{"checker": t.String(), "method": string(method)}
-			problemsPerChecker.With(labels).Observe(float64(len(probs)))
-			durationPerChecker.With(labels).Observe(duration.Seconds())
-			debug("[%s] async: - %v in %v\n", id, t, duration)
+			labels := map[string]string{"checker": t.String(), "method": string(method)}
+			m := currentMetrics()
+			m.Time(metricCheckerDuration, labels, duration)
+			m.Count(metricCheckerProblems, labels, float64(len(probs)))
+			if err != nil && !errors.Is(err, errNotApplicable) {
+				m.Count(metricCheckerErrors, labels, 1)
+				ctx.checkerFinished(t.String(), duration, len(probs), err)
+			} else {
+				if err != nil {
+					ctx.recordSkipped(t, err)
+				}
+				ctx.checkerFinished(t.String(), duration, len(probs), nil)
+			}
+			ctx.logf("[%s] async: - %v in %v\n", id, t, duration)
 			resultCh <- asyncResult{probs, err}
 		}(task, ctx, domain, method)
 	}
 
 	var probs []Problem
+	var errs []error
 
+	// Drain every result, so that no checker is abandoned mid-flight
 	for i := 0; i < len(c); i++ {
 		result := <-resultCh
 		if result.Error != nil && !errors.Is(result.Error, errNotApplicable) {
-			debug("[%s] Exiting async via error\n", id)
-			return nil, result.Error
+			errs = append(errs, result.Error)
 		}
 		if len(result.Problems) > 0 {
 			probs = append(probs, result.Problems...)
 		}
 	}
 
-	debug("[%s] Exiting async gracefully\n", id)
+	if len(errs) > 0 {
+		ctx.logf("[%s] Exiting async with %d error(s)\n", id, len(errs))
+		return probs, errors.Join(errs...)
+	}
+
+	ctx.logf("[%s] Exiting async gracefully\n", id)
 	return probs, nil
 }
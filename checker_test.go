@@ -53,11 +53,30 @@ func TestAsyncCheckerBlock_Check(t *testing.T) {
 		t.Fatal("expected error, got none")
 	}
 
+	// check that every result is drained, even when a checker fails
+	a = asyncCheckerBlock{
+		checkerFail{},
+		checkerSucceedWithProblem{},
+		checkerFail{},
+	}
+	probs, err = a.Check(nil, "", "")
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+	if len(probs) != 1 {
+		t.Fatalf("expected 1 problem, got: %d", len(probs))
+	}
+
 	// check panic recovery
 	a = asyncCheckerBlock{
 		checkerPanic{},
+		checkerSucceedWithProblem{},
 	}
-	if _, err := a.Check(nil, "", ""); err == nil {
-		t.Fatal("expected error, got none")
+	probs, err = a.Check(nil, "", "")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(probs) != 2 || !hasInternalProblem(probs) {
+		t.Fatalf("expected an internal problem for the panic, got: %v", probs)
 	}
 }
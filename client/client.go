@@ -0,0 +1,301 @@
+// Package client is a client for the JSON API of the Let's Debug web server, such as letsdebug.net.
+// Results are decoded into the types of the letsdebug package, so they follow the same schema.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/letsdebug/letsdebug"
+)
+
+// DefaultBaseURL is the hosted Let's Debug service.
+const DefaultBaseURL = "https://letsdebug.net"
+
+// maxResponseBytes limits how much of a response is read.
+const maxResponseBytes = 16 << 20
+
+// Client calls the API of a Let's Debug web server. Its zero value is not usable, see New.
+type Client struct {
+	// BaseURL is the URL of the web server, without a trailing slash
+	BaseURL string
+	// HTTPClient makes the requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// APIKey is sent as a bearer token if set, which the server may use to apply per-key settings
+	APIKey string
+	// UserAgent identifies the caller to the server
+	UserAgent string
+	// PollInterval is how long Wait waits between requests for the status of a test
+	PollInterval time.Duration
+}
+
+// New returns a Client for the web server at baseURL, or letsdebug.net if it is empty.
+func New(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		UserAgent:    "letsdebug-go-client",
+		PollInterval: 3 * time.Second,
+	}
+}
+
+// Options are the options of a test. See the README for their meaning.
+type Options struct {
+	HTTPRequestPath    string   `json:"http_request_path,omitempty"`
+	HTTPExpectResponse string   `json:"http_expect_response,omitempty"`
+	SuppressProblems   []string `json:"suppress_problems,omitempty"`
+	Standalone         bool     `json:"standalone,omitempty"`
+}
+
+// TestRef identifies a submitted test.
+type TestRef struct {
+	Domain string `json:"Domain"`
+	ID     uint64 `json:"ID"`
+}
+
+// Test is a test and, once it is complete, its result.
+type Test struct {
+	ID          uint64            `json:"id"`
+	Domain      string            `json:"domain"`
+	Method      string            `json:"method"`
+	Status      string            `json:"status"`
+	CreatedAt   time.Time         `json:"created_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	Result      *letsdebug.Result `json:"result,omitempty"`
+	Annotations []Annotation      `json:"annotations,omitempty"`
+}
+
+// Annotation is a note attached to a test by a helper, such as a community forum moderator.
+type Annotation struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Helper    string    `json:"helper"`
+	Body      string    `json:"body"`
+}
+
+// Ref identifies the test.
+func (t Test) Ref() TestRef {
+	return TestRef{Domain: t.Domain, ID: t.ID}
+}
+
+// Done is whether the test is no longer queued or running.
+func (t Test) Done() bool {
+	return t.Status == "Complete" || t.Status == "Cancelled"
+}
+
+// Batch is a list of domains uploaded together through the web interface, and the test of each.
+type Batch struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Method    string    `json:"method"`
+	Tests     []Test    `json:"tests"`
+}
+
+// Error is an unsuccessful response from the server.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("letsdebug: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// RateLimitError is returned when a submission exceeded a rate limit of the server.
+type RateLimitError struct {
+	Message    string
+	RetryAfter time.Duration
+	ResetAt    time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("letsdebug: rate limited, retry after %s: %s", e.RetryAfter, e.Message)
+}
+
+// Submit creates a test of domain with method, which is run asynchronously. opts may be nil.
+func (c *Client) Submit(ctx context.Context, domain string, method letsdebug.ValidationMethod, opts *Options) (TestRef, error) {
+	body := struct {
+		Domain  string   `json:"domain"`
+		Method  string   `json:"method"`
+		Options *Options `json:"options,omitempty"`
+	}{domain, string(method), opts}
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return TestRef{}, err
+	}
+	var ref TestRef
+	err = c.do(ctx, http.MethodPost, "/", bytes.NewReader(buf), &ref)
+	return ref, err
+}
+
+// Get fetches the current status of a test, including its debug problems.
+func (c *Client) Get(ctx context.Context, ref TestRef) (Test, error) {
+	var t Test
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/%s/%d?debug=y", url.PathEscape(ref.Domain), ref.ID), nil, &t)
+	return t, err
+}
+
+// Tests fetches the recent tests of domain, without their results.
+func (c *Client) Tests(ctx context.Context, domain string) ([]Test, error) {
+	var tests []Test
+	err := c.do(ctx, http.MethodGet, "/"+url.PathEscape(domain), nil, &tests)
+	return tests, err
+}
+
+// Batch fetches a batch and the current status of each of its tests.
+func (c *Client) Batch(ctx context.Context, id string) (Batch, error) {
+	var b Batch
+	err := c.do(ctx, http.MethodGet, "/batch/"+url.PathEscape(id), nil, &b)
+	return b, err
+}
+
+// Wait polls a test until it is done, and returns it. If onUpdate is not nil, it is called whenever
+// the status of the test changes, so that progress can be shown while waiting.
+func (c *Client) Wait(ctx context.Context, ref TestRef, onUpdate func(Test)) (Test, error) {
+	var status string
+	for {
+		t, err := c.Get(ctx, ref)
+		if err != nil {
+			return t, err
+		}
+		if onUpdate != nil && t.Status != status {
+			onUpdate(t)
+		}
+		status = t.Status
+		if t.Done() {
+			return t, nil
+		}
+		select {
+		case <-ctx.Done():
+			return t, ctx.Err()
+		case <-time.After(c.PollInterval):
+		}
+	}
+}
+
+// Check submits a test of domain and waits for it to be done.
+func (c *Client) Check(ctx context.Context, domain string, method letsdebug.ValidationMethod, opts *Options) (Test, error) {
+	ref, err := c.submitWithRetry(ctx, domain, method, opts)
+	if err != nil {
+		return Test{}, err
+	}
+	return c.Wait(ctx, ref, nil)
+}
+
+// BulkResult is the outcome of testing one of the domains given to CheckAll.
+type BulkResult struct {
+	Domain string
+	Test   Test
+	Err    error
+}
+
+// CheckAll tests each of domains with method and waits for all of them to be done. Submissions are made
+// one at a time, waiting as long as the server asks whenever a rate limit is exceeded, while the
+// tests already submitted are waited for concurrently. The results are in the order of domains.
+func (c *Client) CheckAll(ctx context.Context, domains []string, method letsdebug.ValidationMethod, opts *Options) []BulkResult {
+	results := make([]BulkResult, len(domains))
+	var wg sync.WaitGroup
+	for i, domain := range domains {
+		results[i].Domain = domain
+		ref, err := c.submitWithRetry(ctx, domain, method, opts)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ref TestRef) {
+			defer wg.Done()
+			results[i].Test, results[i].Err = c.Wait(ctx, ref, nil)
+		}(i, ref)
+	}
+	wg.Wait()
+	return results
+}
+
+// submitWithRetry submits a test, waiting and trying again for as long as the server is rate limiting.
+func (c *Client) submitWithRetry(ctx context.Context, domain string, method letsdebug.ValidationMethod, opts *Options) (TestRef, error) {
+	for {
+		ref, err := c.Submit(ctx, domain, method, opts)
+		var rateLimited *RateLimitError
+		if !errors.As(err, &rateLimited) {
+			return ref, err
+		}
+		select {
+		case <-ctx.Done():
+			return ref, ctx.Err()
+		case <-time.After(max(rateLimited.RetryAfter, time.Second)):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	cl := c.HTTPClient
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return parseRateLimitError(resp, buf)
+	case resp.StatusCode != http.StatusOK:
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(buf))}
+	}
+	if err := json.Unmarshal(buf, out); err != nil {
+		return fmt.Errorf("letsdebug: invalid response: %w", err)
+	}
+	return nil
+}
+
+func parseRateLimitError(resp *http.Response, buf []byte) error {
+	var body struct {
+		Error      string    `json:"error"`
+		RetryAfter int       `json:"retry_after"`
+		ResetAt    time.Time `json:"reset_at"`
+	}
+	if err := json.Unmarshal(buf, &body); err != nil {
+		body.Error = strings.TrimSpace(string(buf))
+		body.RetryAfter, _ = strconv.Atoi(resp.Header.Get("Retry-After"))
+	}
+	return &RateLimitError{
+		Message:    body.Error,
+		RetryAfter: time.Duration(body.RetryAfter) * time.Second,
+		ResetAt:    body.ResetAt,
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/letsdebug/letsdebug"
+)
+
+func TestCheck(t *testing.T) {
+	var mu sync.Mutex
+	submissions, polls := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Accept") != "application/json" {
+			http.Error(w, "unexpected headers", http.StatusBadRequest)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/":
+			submissions++
+			if submissions == 1 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(`{"error":"Too many tests","retry_after":0,"reset_at":"2026-10-16T09:30:14Z"}`))
+				return
+			}
+			var req struct {
+				Domain  string  `json:"domain"`
+				Method  string  `json:"method"`
+				Options Options `json:"options"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Domain != "example.org" ||
+				req.Method != "http-01" || !req.Options.Standalone {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"Domain":"example.org","ID":42}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/example.org/42":
+			polls++
+			if polls == 1 {
+				_, _ = w.Write([]byte(`{"id":42,"domain":"example.org","method":"http-01","status":"Processing"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":42,"domain":"example.org","method":"http-01","status":"Complete",` +
+				`"result":{"schema_version":"1.2.0","problems":[{"name":"CloudflareCDN","explanation":"","detail":"","severity":"Warning"}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL + "/")
+	c.APIKey = "secret"
+	c.PollInterval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	test, err := c.Check(ctx, "example.org", letsdebug.HTTP01, &Options{Standalone: true})
+	if err != nil {
+		t.Fatalf("expected the test to complete, got: %v", err)
+	}
+	if !test.Done() || test.Result == nil || len(test.Result.Problems) != 1 ||
+		test.Result.Problems[0].Severity != letsdebug.SeverityWarning {
+		t.Fatalf("unexpected test: %+v", test)
+	}
+	if submissions != 2 || polls != 2 {
+		t.Fatalf("expected the rate limited submission to be retried, and the test to be polled until complete, "+
+			"got %d submissions and %d polls", submissions, polls)
+	}
+
+	_, err = c.Get(ctx, TestRef{Domain: "example.org", ID: 1})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a not found error, got: %v", err)
+	}
+}
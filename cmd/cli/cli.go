@@ -1,25 +1,186 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/letsdebug/letsdebug"
 )
 
 func main() {
+	// "letsdebug-cli preflight [flags]" prints a verdict for each validation method instead
+	args := os.Args[1:]
+	preflight := len(args) > 0 && args[0] == "preflight"
+	if preflight {
+		args = args[1:]
+	}
+	// "letsdebug-cli probe-agent [-listen addr]" serves as a perspective for tests run elsewhere
+	if len(args) > 0 && args[0] == "probe-agent" {
+		runProbeAgent(args[1:])
+		return
+	}
+
 	var domain string
 	var validationMethod string
 	var showDebug bool
+	var showDNS bool
+	var asJSON bool
+	var originIP string
+	var originHints bool
+	var redirectCerts bool
+	var refreshData bool
+	var offline bool
+	var suppress string
+	var severities string
+	var standalone bool
+	var userAgent, contactURL string
+	var methods string
+	var vantagesFile, perspectivesFile string
+	var httpPath, expect string
+	var extraOpts optionFlags
+	var acmeDirectory, stagingAccounts string
+	var acmeInsecure bool
+	var httpPort, tlsPort int
 
 	flag.StringVar(&domain, "domain", "example.org", "What domain to check")
 	flag.StringVar(&validationMethod, "method", "http-01", "Which validation method to assume (http-01,dns-01)")
 	flag.BoolVar(&showDebug, "debug", false, "Whether to show debug problems")
-	flag.Parse()
+	flag.BoolVar(&showDNS, "dns-responses", false, "Whether to include full DNS responses in debug problems")
+	flag.BoolVar(&asJSON, "json", false, "Whether to print the result as JSON (see schema/result.schema.json)")
+	flag.StringVar(&originIP, "origin-ip", "", "Comma-separated addresses of the origin server behind the domain's CDN, if any")
+	flag.BoolVar(&originHints, "origin-hints", false, "Whether to look for the origin server behind the domain's CDN at common subdomains")
+	flag.BoolVar(&redirectCerts, "redirect-certs", false, "Whether to report the certificate presented at each HTTPS redirect of the http-01 request")
+	flag.BoolVar(&refreshData, "refresh-data", false, "Whether to fetch up to date copies of the bundled datasets (e.g. the Public Suffix List) before checking")
+	flag.BoolVar(&offline, "offline", false, "Whether to skip the checks which depend on third-party services, for networks which only allow DNS and connections to the domain")
+	flag.StringVar(&suppress, "suppress", "", "Comma-separated names of problems to leave out of the results (e.g. CloudflareCDN), which are listed in a debug problem instead")
+	flag.StringVar(&severities, "severity", "", "Comma-separated overrides of the severity of problems, e.g. CloudflareCDN=Info,AAAANotWorking=Warning")
+	flag.BoolVar(&standalone, "standalone", false, "Whether the ACME client will be run in standalone mode, in which case anything answering on port 80 is a conflict")
+	flag.StringVar(&userAgent, "user-agent", "", "How to identify yourself in the User-Agent of the requests sent to the domain (default Let's Debug)")
+	flag.StringVar(&contactURL, "contact-url", "", "Where the operator of the domain can reach you, included in the User-Agent (default https://letsdebug.net)")
+	flag.StringVar(&httpPath, "http-path", "", "What path within /.well-known/acme-challenge/ to request instead of letsdebug-test")
+	flag.StringVar(&expect, "expect", "", "What exact response to expect from each server during the HTTP check")
+	flag.StringVar(&acmeDirectory, "acme-directory", "", "Directory URL of the ACME server used by the staging check, e.g. a local Pebble (default Let's Encrypt staging)")
+	flag.StringVar(&stagingAccounts, "staging-account", "", "Comma-separated paths of the account files used by the staging check, registered with the ACME server of -acme-directory")
+	flag.BoolVar(&acmeInsecure, "acme-insecure", false, "Whether to skip verifying the certificate of the ACME server, e.g. Pebble's. Never use with a public ACME server")
+	flag.IntVar(&httpPort, "http-port", 80, "Which port the informational HTTP checks connect to, e.g. for a staging environment (validation requests always use port 80)")
+	flag.IntVar(&tlsPort, "tls-port", 443, "Which port the informational TLS checks connect to, e.g. for a staging environment")
+	flag.Var(&extraOpts, "opt", "Set any field of letsdebug.Options as key=value (e.g. dns_query_budget=500), lists are comma-separated. May be repeated")
+	flag.StringVar(&vantagesFile, "vantages", "", "Path to a JSON array of the results of requests made to the domain from other network locations (see letsdebug.VantageResult)")
+	flag.StringVar(&perspectivesFile, "perspectives", "", "Path to a JSON array of probe agents which repeat the checks from other network locations (see letsdebug.Perspective)")
+	flag.StringVar(&methods, "methods", "http-01,dns-01,tls-alpn-01", "With preflight, which validation methods to test, in order of preference")
+	_ = flag.CommandLine.Parse(args)
+
+	if refreshData && !offline {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := letsdebug.RefreshData(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Some datasets could not be refreshed, using the bundled copies: %v\n", err)
+		}
+		cancel()
+	}
 
-	probs, err := letsdebug.Check(domain, letsdebug.ValidationMethod(validationMethod))
+	var origins []net.IP
+	for _, s := range strings.Split(originIP, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+			origins = append(origins, ip)
+		}
+	}
+
+	var suppressed []string
+	for _, s := range strings.Split(suppress, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			suppressed = append(suppressed, s)
+		}
+	}
+
+	overrides, err := letsdebug.ParseSeverityOverrides(severities)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -severity: %v\n", err)
+		os.Exit(2)
+	}
+
+	var vantages []letsdebug.VantageResult
+	if vantagesFile != "" {
+		buf, err := os.ReadFile(vantagesFile)
+		if err == nil {
+			err = json.Unmarshal(buf, &vantages)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read the vantage results from %s: %v\n", vantagesFile, err)
+			os.Exit(1)
+		}
+	}
+
+	var perspectives []letsdebug.Perspective
+	if perspectivesFile != "" {
+		buf, err := os.ReadFile(perspectivesFile)
+		if err == nil {
+			err = json.Unmarshal(buf, &perspectives)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read the perspectives from %s: %v\n", perspectivesFile, err)
+			os.Exit(1)
+		}
+	}
+
+	var accountFiles []string
+	for _, s := range strings.Split(stagingAccounts, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			accountFiles = append(accountFiles, s)
+		}
+	}
+
+	opts := letsdebug.Options{
+		RecordDNSResponses:     showDNS,
+		OriginAddresses:        origins,
+		ProbeOriginHints:       originHints,
+		RedirectCertificates:   redirectCerts,
+		Offline:                offline,
+		SuppressProblems:       suppressed,
+		SeverityOverrides:      overrides,
+		Standalone:             standalone,
+		UserAgent:              userAgent,
+		ContactURL:             contactURL,
+		Vantages:               vantages,
+		Perspectives:           perspectives,
+		HTTPRequestPath:        httpPath,
+		HTTPExpectResponse:     expect,
+		ACMEDirectory:          acmeDirectory,
+		ACMEInsecureSkipVerify: acmeInsecure,
+		StagingAccountFiles:    accountFiles,
+		HTTPPort:               httpPort,
+		TLSPort:                tlsPort,
+	}
+	for _, kv := range extraOpts {
+		key, value, _ := strings.Cut(kv, "=")
+		if err := setOption(&opts, key, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -opt %s: %v\n", kv, err)
+			os.Exit(2)
+		}
+	}
+
+	if preflight {
+		runPreflight(domain, methods, opts)
+		return
+	}
+
+	probs, err := letsdebug.CheckWithOptions(domain, letsdebug.ValidationMethod(validationMethod), opts)
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(letsdebug.NewResult(probs, err))
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "A fatal error was experienced: %s", err)
 		os.Exit(1)
@@ -34,7 +195,61 @@ func main() {
 		if prob.Severity == letsdebug.SeverityDebug && !showDebug {
 			continue
 		}
-		fmt.Printf("%s\nPROBLEM:\n  %s\n\nSEVERITY:\n  %s\n\nEXPLANATION:\n  %s\n\nDETAIL:\n  %s\n%s\n",
-			strings.Repeat("-", 50), prob.Name, prob.Severity, prob.Explanation, prob.Detail, strings.Repeat("-", 50))
+		var refs string
+		if len(prob.References) > 0 {
+			refs = fmt.Sprintf("\nREFERENCES:\n  %s\n", strings.Join(prob.References, "\n  "))
+		}
+		fmt.Printf("%s\nPROBLEM:\n  %s\n\nSEVERITY:\n  %s\n\nEXPLANATION:\n  %s\n\nDETAIL:\n  %s\n%s%s\n",
+			strings.Repeat("-", 50), prob.Name, prob.Severity, prob.Explanation, prob.Detail, refs, strings.Repeat("-", 50))
+	}
+}
+
+// runPreflight tests the domain with each validation method at the same time, and prints a
+// letsdebug.Preflight as JSON. It exits with status 1 if no method passed, so that it can be
+// used directly in a shell script.
+func runPreflight(domain, methods string, opts letsdebug.Options) {
+	var tested []letsdebug.ValidationMethod
+	for _, s := range strings.Split(methods, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			tested = append(tested, letsdebug.ValidationMethod(s))
+		}
+	}
+
+	verdicts := make([]letsdebug.Verdict, len(tested))
+	var wg sync.WaitGroup
+	for i, method := range tested {
+		wg.Add(1)
+		go func(i int, method letsdebug.ValidationMethod) {
+			defer wg.Done()
+			probs, err := letsdebug.CheckWithOptions(domain, method, opts)
+			verdicts[i] = letsdebug.NewVerdict(method, letsdebug.NewResult(probs, err))
+		}(i, method)
+	}
+	wg.Wait()
+
+	pf := letsdebug.NewPreflight(domain, verdicts)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(pf)
+	if pf.Recommended == "" {
+		os.Exit(1)
+	}
+}
+
+// runProbeAgent serves letsdebug.NewProbeAgent until it fails. Requests must present the bearer token
+// in LETSDEBUG_PROBE_AGENT_TOKEN, if it is set.
+func runProbeAgent(args []string) {
+	fs := flag.NewFlagSet("probe-agent", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:9152", "Address to accept the requests of tests on")
+	_ = fs.Parse(args)
+
+	token := os.Getenv("LETSDEBUG_PROBE_AGENT_TOKEN")
+	if token == "" {
+		fmt.Fprintln(os.Stderr, "LETSDEBUG_PROBE_AGENT_TOKEN is not set, so anyone who can reach the agent may use it")
+	}
+	server := &http.Server{Addr: *listen, Handler: letsdebug.NewProbeAgent(token), ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "Probe agent stopped: %v\n", err)
+		os.Exit(1)
 	}
 }
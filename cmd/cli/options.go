@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/letsdebug/letsdebug"
+)
+
+// optionFlags collects the repeatable -opt key=value flag.
+type optionFlags []string
+
+func (o *optionFlags) String() string {
+	return strings.Join(*o, ", ")
+}
+
+func (o *optionFlags) Set(s string) error {
+	if !strings.Contains(s, "=") {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	*o = append(*o, s)
+	return nil
+}
+
+// setOption sets the field of opts named by key, which is the name of a field of letsdebug.Options
+// in any case, with or without underscores (e.g. http_request_path or HTTPRequestPath). Lists are
+// comma-separated.
+func setOption(opts *letsdebug.Options, key, value string) error {
+	name := strings.ReplaceAll(key, "_", "")
+	v := reflect.ValueOf(opts).Elem()
+	var field reflect.Value
+	for i := 0; i < v.NumField(); i++ {
+		if strings.EqualFold(v.Type().Field(i).Name, name) {
+			field = v.Field(i)
+		}
+	}
+	if !field.IsValid() {
+		return fmt.Errorf("unknown option %q", key)
+	}
+
+	var list []string
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("option %s: %w", key, err)
+		}
+		field.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("option %s: %w", key, err)
+		}
+		field.SetInt(int64(n))
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("option %s: %w", key, err)
+		}
+		field.SetInt(int64(d))
+	case []string:
+		field.Set(reflect.ValueOf(list))
+	case []net.IP:
+		var ips []net.IP
+		for _, s := range list {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return fmt.Errorf("option %s: invalid address %q", key, s)
+			}
+			ips = append(ips, ip)
+		}
+		field.Set(reflect.ValueOf(ips))
+	default:
+		return fmt.Errorf("option %s can't be set from the command line", key)
+	}
+	return nil
+}
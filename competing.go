@@ -0,0 +1,114 @@
+package letsdebug
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// competingClientsChecker looks for signs that more than one ACME client is obtaining certificates
+// for the domain, such as two installations of certbot, or a hosting control panel alongside the
+// user's own client. The clients race each other for challenges and rate limits, and whichever
+// loses sees failures which seem to make no sense.
+type competingClientsChecker struct{}
+
+// competingIssuanceWindow is how close together two certificates for the same name issued to
+// different keys must be to suggest that two clients are involved, rather than a renewal.
+const competingIssuanceWindow = 48 * time.Hour
+
+func (c competingClientsChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if os.Getenv("LETSDEBUG_DISABLE_CERTWATCH") != "" {
+		return nil, skipReason("Disabled by LETSDEBUG_DISABLE_CERTWATCH")
+	}
+
+	registeredDomain, _ := effectiveTLDPlusOne(strings.TrimPrefix(domain, "*."))
+	certs, _, err := ctx.recentCertificates(registeredDomain)
+	if errors.Is(err, errNotApplicable) {
+		return nil, err
+	} else if err != nil {
+		// Already reported by rateLimitChecker
+		return nil, nil
+	}
+
+	var served *x509.Certificate
+	if !strings.HasPrefix(domain, "*.") {
+		if ip, err := ctx.LookupRandomHTTPRecord(domain); err == nil {
+			if obs := observeTLS(domain, ip, ctx.tlsPort); obs.Error == nil && len(obs.Chain) > 0 {
+				served = obs.Chain[0]
+			}
+		}
+	}
+
+	return analyzeCompetingClients(domain, certs, served), nil
+}
+
+func analyzeCompetingClients(domain string, certs crtList, served *x509.Certificate) []Problem {
+	var relevant sortedCertificates
+	for _, cert := range certs {
+		for _, name := range cert.DNSNames {
+			if strings.EqualFold(name, domain) {
+				relevant = append(relevant, cert)
+				break
+			}
+		}
+	}
+	// Newest first
+	sort.Sort(relevant)
+
+	var evidence []string
+
+	for i := 0; i+1 < len(relevant); i++ {
+		newer, older := relevant[i], relevant[i+1]
+		if publicKeyFingerprint(newer) == publicKeyFingerprint(older) || newer.NotBefore.Sub(older.NotBefore) > competingIssuanceWindow {
+			continue
+		}
+		evidence = append(evidence, fmt.Sprintf("Certificates %s and %s were issued %v apart, for different keys",
+			older.SerialNumber.Text(16), newer.SerialNumber.Text(16), newer.NotBefore.Sub(older.NotBefore).Round(time.Minute)))
+	}
+
+	if served != nil && len(relevant) > 0 && isLetsEncryptCertificate(served) {
+		newest := relevant[0]
+		if newest.SerialNumber.Cmp(served.SerialNumber) != 0 && newest.NotBefore.After(served.NotBefore) &&
+			publicKeyFingerprint(newest) != publicKeyFingerprint(served) {
+			evidence = append(evidence, fmt.Sprintf("The web server presents certificate %s (issued %v), but a newer certificate %s "+
+				"(issued %v) for a different key is not being served", served.SerialNumber.Text(16), served.NotBefore,
+				newest.SerialNumber.Text(16), newest.NotBefore))
+		}
+	}
+
+	if len(evidence) == 0 {
+		return nil
+	}
+
+	return []Problem{competingACMEClients(domain, evidence)}
+}
+
+func publicKeyFingerprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.RawSubjectPublicKeyInfo))
+}
+
+func isLetsEncryptCertificate(cert *x509.Certificate) bool {
+	for _, org := range cert.Issuer.Organization {
+		if org == "Let's Encrypt" {
+			return true
+		}
+	}
+	return false
+}
+
+func competingACMEClients(domain string, evidence []string) Problem {
+	return Problem{
+		Name: "CompetingACMEClients",
+		Explanation: fmt.Sprintf(`Certificate Transparency logs and the certificate served by %s suggest that more than one ACME client `+
+			`is obtaining certificates for this domain, such as a second installation of certbot, or a hosting control panel `+
+			`alongside your own client. Competing clients can overwrite each other's challenge responses, use up rate limits, `+
+			`and install certificates which the other client doesn't know about. Ensure that only one client manages this domain.`, domain),
+		Detail:   strings.Join(evidence, "\n"),
+		Severity: SeverityWarning,
+	}
+}
@@ -0,0 +1,46 @@
+package letsdebug
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeCompetingClients(t *testing.T) {
+	now := time.Now()
+	issuer := pkix.Name{Organization: []string{"Let's Encrypt"}, CommonName: "R11"}
+	cert := func(serial int64, key string, age time.Duration, names ...string) *x509.Certificate {
+		return &x509.Certificate{SerialNumber: big.NewInt(serial), RawSubjectPublicKeyInfo: []byte(key),
+			NotBefore: now.Add(-age), DNSNames: names, Issuer: issuer}
+	}
+
+	renewal := crtList{
+		"1": cert(1, "a", 6*24*time.Hour, "example.org"),
+		"2": cert(2, "b", time.Hour, "example.org"),
+	}
+	if probs := analyzeCompetingClients("example.org", renewal, renewal["2"]); len(probs) != 0 {
+		t.Errorf("expected a renewal days apart to be accepted, got: %+v", probs)
+	}
+
+	racing := crtList{
+		"1": cert(1, "a", 10*time.Hour, "example.org", "www.example.org"),
+		"2": cert(2, "b", 2*time.Hour, "example.org"),
+		"3": cert(3, "c", time.Hour, "other.example.org"),
+	}
+	probs := analyzeCompetingClients("example.org", racing, nil)
+	if len(probs) != 1 || probs[0].Name != "CompetingACMEClients" || strings.Count(probs[0].Detail, "\n") != 0 {
+		t.Errorf("expected one piece of evidence of racing clients, got: %+v", probs)
+	}
+
+	stale := crtList{
+		"1": cert(1, "a", 20*24*time.Hour, "example.org"),
+		"2": cert(2, "b", time.Hour, "example.org"),
+	}
+	probs = analyzeCompetingClients("example.org", stale, stale["1"])
+	if len(probs) != 1 || !strings.Contains(probs[0].Detail, "is not being served") {
+		t.Errorf("expected the undeployed certificate to be reported, got: %+v", probs)
+	}
+}
@@ -0,0 +1,185 @@
+package letsdebug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// contactsChecker gathers hints about who administers the DNS of the domain, from the responsible
+// mailbox in the SOA record of its zone and the registration data of its registered domain. Users
+// asking for help frequently don't know who controls their DNS, and helpers can use these to point
+// them at the right people.
+type contactsChecker struct{}
+
+// rdapLookupURL is a variable so that it may be pointed at a test server. The rdap.org bootstrap
+// service redirects to the RDAP server of the registry of the domain.
+var rdapLookupURL = "https://rdap.org/domain/"
+
+// rdapMaxResponseBytes limits how much of an RDAP response is read.
+const rdapMaxResponseBytes = 1 << 20
+
+type rdapContact struct {
+	Roles []string
+	Name  string
+	Email string
+}
+
+func (c rdapContact) String() string {
+	var parts []string
+	if c.Name != "" {
+		parts = append(parts, c.Name)
+	}
+	if c.Email != "" {
+		parts = append(parts, "<"+c.Email+">")
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(c.Roles, ", "), strings.Join(parts, " "))
+}
+
+func (c contactsChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	domain = strings.TrimPrefix(domain, "*.")
+
+	var lines []string
+	if cut, err := findZoneCut(ctx, domain); err == nil {
+		rrs, _ := ctx.Lookup(cut.Zone, dns.TypeSOA)
+		for _, rr := range rrs {
+			if soa, ok := rr.(*dns.SOA); ok {
+				lines = append(lines, fmt.Sprintf("SOA of %s: primary nameserver %s, responsible mailbox %s",
+					cut.Zone, normalizeFqdn(soa.Ns), soaMailbox(soa.Mbox)))
+			}
+		}
+	}
+
+	if registeredDomain, err := effectiveTLDPlusOne(domain); err == nil && !ctx.offline {
+		contacts, err := lookupRDAPContacts(registeredDomain)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("Registration data of %s could not be retrieved: %v", registeredDomain, err))
+		}
+		for _, c := range contacts {
+			lines = append(lines, fmt.Sprintf("Registration data of %s: %s", registeredDomain, c))
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, errNotApplicable
+	}
+	return []Problem{debugProblem("DNSContacts",
+		"Who administers the DNS of the domain, according to its SOA record and registration data. "+
+			"If you don't know who controls your DNS, these may help you find out.",
+		strings.Join(lines, "\n"))}, nil
+}
+
+// soaMailbox converts the RNAME of an SOA record to an email address. The first unescaped
+// period separates the local part from the domain.
+func soaMailbox(rname string) string {
+	rname = strings.TrimSuffix(rname, ".")
+	for i := 0; i < len(rname); i++ {
+		switch rname[i] {
+		case '\\':
+			i++
+		case '.':
+			return strings.ReplaceAll(rname[:i], `\.`, ".") + "@" + rname[i+1:]
+		}
+	}
+	return rname
+}
+
+func lookupRDAPContacts(registeredDomain string) ([]rdapContact, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, rdapLookupURL+registeredDomain, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, rdapMaxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	return parseRDAPContacts(body)
+}
+
+type rdapEntity struct {
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	Entities   []rdapEntity      `json:"entities"`
+}
+
+// parseRDAPContacts extracts the name and email address of each entity of an RDAP domain response,
+// including nested ones such as the abuse contact of the registrar. Redacted entities are skipped.
+func parseRDAPContacts(body []byte) ([]rdapContact, error) {
+	var resp struct {
+		Entities []rdapEntity `json:"entities"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid RDAP response: %w", err)
+	}
+
+	var contacts []rdapContact
+	var walk func(entities []rdapEntity)
+	walk = func(entities []rdapEntity) {
+		for _, e := range entities {
+			c := rdapContact{Roles: e.Roles}
+			c.Name, c.Email = parseVCard(e.VCardArray)
+			if len(c.Roles) > 0 && (c.Name != "" || c.Email != "") {
+				sort.Strings(c.Roles)
+				contacts = append(contacts, c)
+			}
+			walk(e.Entities)
+		}
+	}
+	walk(resp.Entities)
+	return contacts, nil
+}
+
+// parseVCard returns the full name and email address from a jCard (RFC 7095), which is
+// ["vcard", [[name, params, type, value], ...]].
+func parseVCard(vcard []json.RawMessage) (name, email string) {
+	if len(vcard) != 2 {
+		return "", ""
+	}
+	var props [][]json.RawMessage
+	if err := json.Unmarshal(vcard[1], &props); err != nil {
+		return "", ""
+	}
+	for _, prop := range props {
+		if len(prop) < 4 {
+			continue
+		}
+		var key, value string
+		if json.Unmarshal(prop[0], &key) != nil || json.Unmarshal(prop[3], &value) != nil {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "fn":
+			name = value
+		case "email":
+			email = value
+		}
+	}
+	if strings.Contains(strings.ToUpper(name), "REDACTED") {
+		name = ""
+	}
+	if strings.Contains(strings.ToUpper(email), "REDACTED") {
+		email = ""
+	}
+	return name, email
+}
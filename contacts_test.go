@@ -0,0 +1,71 @@
+package letsdebug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSOAMailbox(t *testing.T) {
+	tests := map[string]string{
+		"hostmaster.example.org.":  "hostmaster@example.org",
+		`john\.smith.example.org.`: "john.smith@example.org",
+		"dns.cloudflare.com.":      "dns@cloudflare.com",
+		"localhost.":               "localhost",
+	}
+	for rname, want := range tests {
+		if got := soaMailbox(rname); got != want {
+			t.Errorf("%s: expected %q, got %q", rname, want, got)
+		}
+	}
+}
+
+func TestLookupRDAPContacts(t *testing.T) {
+	defer func(orig string) { rdapLookupURL = orig }(rdapLookupURL)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/domain/example.org" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		_, _ = w.Write([]byte(`{
+			"objectClassName": "domain",
+			"entities": [
+				{
+					"roles": ["registrar"],
+					"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Registrar, Inc."]]],
+					"entities": [
+						{
+							"roles": ["abuse"],
+							"vcardArray": ["vcard", [["fn", {}, "text", "Abuse Desk"], ["email", {}, "text", "abuse@registrar.example"]]]
+						}
+					]
+				},
+				{
+					"roles": ["technical"],
+					"vcardArray": ["vcard", [["fn", {}, "text", "REDACTED FOR PRIVACY"], ["email", {}, "text", "REDACTED FOR PRIVACY"]]]
+				}
+			]
+		}`))
+	}))
+	defer srv.Close()
+	rdapLookupURL = srv.URL + "/domain/"
+
+	contacts, err := lookupRDAPContacts("example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rdapContact{
+		{Roles: []string{"registrar"}, Name: "Example Registrar, Inc."},
+		{Roles: []string{"abuse"}, Name: "Abuse Desk", Email: "abuse@registrar.example"},
+	}
+	if !reflect.DeepEqual(contacts, want) {
+		t.Fatalf("expected %v, got %v", want, contacts)
+	}
+
+	if _, err := lookupRDAPContacts("example.net"); err == nil {
+		t.Fatal("expected an error for a domain without registration data")
+	}
+}
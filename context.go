@@ -1,31 +1,137 @@
 package letsdebug
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/miekg/dns"
 )
 
+// defaultDNSQueryBudget is the number of DNS queries a scan may send, unless overridden by
+// Options.DNSQueryBudget. A typical scan sends fewer than twenty.
+const defaultDNSQueryBudget = 200
+
+var errDNSQueryBudgetExhausted = errors.New("the DNS query budget for this test has been exhausted")
+
 type lookupResult struct {
 	RRs   []dns.RR
 	Error error
+
+	// Where the answer came from, for resolver-related bug reports
+	Name     string
+	Type     uint16
+	Backend  string
+	Duration time.Duration
+	Cached   bool
+}
+
+func (r lookupResult) String() string {
+	status := "ok"
+	if r.Error != nil {
+		status = r.Error.Error()
+	}
+	source := fmt.Sprintf("%s in %v", r.Backend, r.Duration.Round(time.Millisecond))
+	if r.Cached {
+		source = "scan cache, originally from " + source
+	}
+	return fmt.Sprintf("%s/%s: %d records from %s: %s", r.Name, dns.TypeToString[r.Type], len(r.RRs), source, status)
 }
 
 type scanContext struct {
 	rrs      map[string]map[uint16]lookupResult
 	rrsMutex sync.Mutex
+	// Every lookup performed during the scan, including those answered by rrs
+	lookups []lookupResult
+
+	// Origin servers to compare against the CDN serving the domain
+	originAddresses  []net.IP
+	probeOriginHints bool
+
+	// Whether to report the certificate presented at each HTTPS redirect of the validation request
+	redirectCertificates bool
+
+	// Protection against pathological zones: the number of queries a scan may send,
+	// what was skipped once that was exhausted, and which recursive steps were already taken
+	dnsQueryBudget int
+	dnsQueryCount  int
+	dnsTruncated   []string
+	visited        map[string]bool
 
 	httpRequestPath    string
+	userAgent          string
+	contactURL         string
 	httpExpectResponse string
+	safeBrowsingAPIKey string
+
+	// Whether third-party services are skipped, see Options.Offline
+	offline bool
+	// Names of the problems which are reported in SuppressedProblems instead, see Options.SuppressProblems
+	suppressProblems []string
+	// Severities of problems which are reclassified in the results, see Options.SeverityOverrides
+	severityOverrides map[string]SeverityLevel
+	// Whether the ACME client will bind port 80 itself, see Options.Standalone
+	standalone bool
+	// Requests made from other network locations by the caller, see Options.Vantages
+	vantages []VantageResult
+	// Probe agents which repeat the checks from other network locations, see Options.Perspectives
+	perspectives []Perspective
+	// The ports of the informational checks of the web server, see Options.HTTPPort
+	httpPort int
+	tlsPort  int
+
+	stagingResultsMaxAge time.Duration
+	stagingAccountFiles  []string
+	// The ACME server used by the staging checker, see Options.ACMEDirectory
+	acmeDirectory          string
+	acmeInsecureSkipVerify bool
+
+	// When set, the full response to every Lookup is retained in dnsResponses
+	recordDNSResponses bool
+	dnsResponses       []dnsResponse
+
+	// Shared between the scans of the identifiers of an order
+	certificates *certificateMemo
+
+	// Receives each problem as soon as it is found, if Options.Sink is set
+	sink *problemSink
+
+	// Correlate the test with the caller's logs and traces, see Options.RequestID
+	requestID string
+	logger    Logger
+	tracer    Tracer
+
+	// Evidence recorded by checkers for cross-referencing once the scan is complete
+	evidenceMu          sync.Mutex
+	httpResults         []httpCheckResult
+	skipped             []SkippedCheck
+	stagingChallenge    *stagingChallenge
+	perspectiveOutcomes []perspectiveOutcome
+	// How the connection to port 80 of each address went, see vaFirewallChecker
+	port80Connections map[string]string
+	// The URL whose response asked for HTTP probing to stop, see optedOut
+	httpOptOut string
+	// Whether safeBrowsingChecker got an answer from the Safe Browsing API
+	safeBrowsingChecked bool
 }
 
 func newScanContext() *scanContext {
 	return &scanContext{
-		rrs:             map[string]map[uint16]lookupResult{},
-		httpRequestPath: "letsdebug-test",
+		rrs:                map[string]map[uint16]lookupResult{},
+		dnsQueryBudget:     defaultDNSQueryBudget,
+		visited:            map[string]bool{},
+		certificates:       newCertificateMemo(),
+		httpPort:           80,
+		tlsPort:            443,
+		httpRequestPath:    "letsdebug-test",
+		userAgent:          "Let's Debug emulating Let's Encrypt validation server",
+		contactURL:         "https://letsdebug.net",
+		safeBrowsingAPIKey: os.Getenv("LETSDEBUG_SAFEBROWSING_APIKEY"),
 	}
 }
 
@@ -37,24 +143,107 @@ func (sc *scanContext) Lookup(name string, rrType uint16) ([]dns.RR, error) {
 		sc.rrs[name] = rrMap
 	}
 	result, ok := rrMap[rrType]
+	if ok {
+		result.Cached = true
+		sc.lookups = append(sc.lookups, result)
+	} else if sc.dnsQueryCount >= sc.dnsQueryBudget {
+		sc.dnsTruncated = append(sc.dnsTruncated, fmt.Sprintf("lookup of %s/%s", name, dns.TypeToString[rrType]))
+		sc.rrsMutex.Unlock()
+		return nil, errDNSQueryBudgetExhausted
+	} else {
+		sc.dnsQueryCount++
+	}
 	sc.rrsMutex.Unlock()
 
 	if ok {
 		return result.RRs, result.Error
 	}
 
-	resolved, err := lookup(name, rrType)
+	var resolved []dns.RR
+	start := time.Now()
+	raw, err := lookupRaw(name, rrType)
+	if err == nil {
+		resolved = raw.Rr
+	}
 
 	sc.rrsMutex.Lock()
-	rrMap[rrType] = lookupResult{
-		RRs:   resolved,
-		Error: err,
+	result = lookupResult{
+		RRs:      resolved,
+		Error:    err,
+		Name:     name,
+		Type:     rrType,
+		Backend:  unboundBackend,
+		Duration: time.Since(start),
+	}
+	rrMap[rrType] = result
+	sc.lookups = append(sc.lookups, result)
+	if sc.recordDNSResponses {
+		sc.dnsResponses = append(sc.dnsResponses, newDNSResponse(name, rrType, raw, err))
 	}
 	sc.rrsMutex.Unlock()
 
 	return resolved, err
 }
 
+// recentCertificates returns the certificates issued during the last week for registeredDomain.
+func (sc *scanContext) recentCertificates(registeredDomain string) (crtList, []Problem, error) {
+	return sc.certificates.get(registeredDomain)
+}
+
+// probeUserAgent is the User-Agent of the HTTP requests sent to the domain being tested.
+func (sc *scanContext) probeUserAgent() string {
+	return fmt.Sprintf("Mozilla/5.0 (compatible; %s; +%s)", sc.userAgent, sc.contactURL)
+}
+
+// offlineSkipped explains that a check was skipped because it depends on service, which is
+// not consulted in offline mode.
+func offlineSkipped(service string) error {
+	return skipReason(fmt.Sprintf("Skipped because %s is not consulted in offline mode", service))
+}
+
+// Visit records that a recursive step (identified by key) is being taken, and reports whether it
+// is the first time. If it is not, the step is recorded as truncated, since the scan is going in circles.
+func (sc *scanContext) Visit(key string) bool {
+	sc.rrsMutex.Lock()
+	defer sc.rrsMutex.Unlock()
+
+	if sc.visited[key] {
+		sc.dnsTruncated = append(sc.dnsTruncated, "repeated "+key)
+		return false
+	}
+	sc.visited[key] = true
+	return true
+}
+
+// dnsTruncationProblem describes the lookups which were skipped to protect the scan, if any.
+func (sc *scanContext) dnsTruncationProblem() (Problem, bool) {
+	sc.rrsMutex.Lock()
+	defer sc.rrsMutex.Unlock()
+
+	if len(sc.dnsTruncated) == 0 {
+		return Problem{}, false
+	}
+	return internalProblem(fmt.Sprintf("The test was cut short after %d DNS queries, so some results may be incomplete. "+
+		"The following were skipped:\n%s", sc.dnsQueryCount, strings.Join(sc.dnsTruncated, "\n")), SeverityWarning), true
+}
+
+// LookupAddresses returns every AAAA and A record for name, ignoring lookup errors.
+func (sc *scanContext) LookupAddresses(name string) []net.IP {
+	var ips []net.IP
+	for _, rrType := range []uint16{dns.TypeAAAA, dns.TypeA} {
+		rrs, _ := sc.Lookup(name, rrType)
+		for _, rr := range rrs {
+			switch rr := rr.(type) {
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA)
+			case *dns.A:
+				ips = append(ips, rr.A)
+			}
+		}
+	}
+	return ips
+}
+
 // Only slightly random - it will use AAAA over A if possible.
 func (sc *scanContext) LookupRandomHTTPRecord(name string) (net.IP, error) {
 	v6RRs, err := sc.Lookup(name, dns.TypeAAAA)
@@ -79,3 +268,47 @@ func (sc *scanContext) LookupRandomHTTPRecord(name string) (net.IP, error) {
 
 	return net.IP{}, fmt.Errorf("No AAAA or A records were found for %s", name)
 }
+
+func (sc *scanContext) recordHTTPResults(results []httpCheckResult) {
+	sc.evidenceMu.Lock()
+	defer sc.evidenceMu.Unlock()
+	sc.httpResults = append(sc.httpResults, results...)
+}
+
+func (sc *scanContext) recordStagingChallenge(chal stagingChallenge) {
+	sc.evidenceMu.Lock()
+	defer sc.evidenceMu.Unlock()
+	sc.stagingChallenge = &chal
+}
+
+// recordHTTPOptOut stops any further HTTP requests to the domain, since the response to url
+// asked not to be probed. Only the first refusal is kept.
+func (sc *scanContext) recordHTTPOptOut(url string) {
+	sc.evidenceMu.Lock()
+	defer sc.evidenceMu.Unlock()
+	if sc.httpOptOut == "" {
+		sc.httpOptOut = url
+	}
+}
+
+// httpOptOutURL returns the URL whose response asked not to be probed, if any.
+func (sc *scanContext) httpOptOutURL() string {
+	sc.evidenceMu.Lock()
+	defer sc.evidenceMu.Unlock()
+	return sc.httpOptOut
+}
+
+// recordSafeBrowsingChecked notes that the domain was looked up in Safe Browsing, so that an
+// UnsafeDomain problem would already have been reported.
+func (sc *scanContext) recordSafeBrowsingChecked() {
+	sc.evidenceMu.Lock()
+	defer sc.evidenceMu.Unlock()
+	sc.safeBrowsingChecked = true
+}
+
+// checkedSafeBrowsing returns whether safeBrowsingChecker got an answer from the Safe Browsing API.
+func (sc *scanContext) checkedSafeBrowsing() bool {
+	sc.evidenceMu.Lock()
+	defer sc.evidenceMu.Unlock()
+	return sc.safeBrowsingChecked
+}
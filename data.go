@@ -0,0 +1,209 @@
+package letsdebug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// bundledDataURL is where up to date copies of the datasets maintained in this repository are published.
+const bundledDataURL = "https://raw.githubusercontent.com/letsdebug/letsdebug/master/"
+
+// dataMaxBytes limits how much of a refreshed dataset is read.
+const dataMaxBytes = 16 << 20
+
+// DataVersion describes the copy of a bundled dataset which is in use.
+type DataVersion struct {
+	// Name identifies the dataset, e.g. "cdn_ranges"
+	Name string `json:"name"`
+	// Source is "embedded", the path of a local replacement, or the URL the dataset was refreshed from
+	Source string `json:"source"`
+	// Version is the date or module version of the embedded copy, or a digest of a replacement
+	Version string `json:"version"`
+	// LoadedAt is when a replacement was loaded, and is unset for the embedded copy
+	LoadedAt *time.Time `json:"loaded_at,omitempty"`
+}
+
+// bundledDataset is implemented by every dataset[T], regardless of the type of its contents.
+type bundledDataset interface {
+	Version() DataVersion
+	refresh(ctx context.Context, cl *http.Client) error
+}
+
+// dataset is data which checkers depend on, such as the address ranges of CDNs. A copy is embedded
+// in the binary, which may be replaced with a local file, or refreshed from url while running.
+type dataset[T any] struct {
+	name     string
+	embedded []byte
+	// embeddedVersion describes the embedded copy, e.g. the date it was last updated. A digest is used if nil.
+	embeddedVersion func() string
+	// url is where an up to date copy is published, if anywhere
+	url string
+	// fileEnv names the environment variable which may point at a local replacement for the embedded copy
+	fileEnv string
+	parse   func([]byte) (T, error)
+	// check rejects a replacement which is valid on its own, but suspicious compared to the copy in use
+	// (e.g. much shorter). It may be nil.
+	check func(current, next T) error
+	// replacementVersion describes a replacement, e.g. by the version it declares. A digest is used if it
+	// is nil or returns "".
+	replacementVersion func([]byte) string
+
+	once    sync.Once
+	mu      sync.RWMutex
+	value   T
+	version DataVersion
+	// local is set when the dataset was replaced with a local file, which is never refreshed
+	local bool
+}
+
+func (d *dataset[T]) init() {
+	d.once.Do(func() {
+		value, err := d.parse(d.embedded)
+		if err != nil {
+			panic(fmt.Sprintf("the embedded %s dataset is invalid: %v", d.name, err))
+		}
+		version := digest(d.embedded)
+		if d.embeddedVersion != nil {
+			version = d.embeddedVersion()
+		}
+		d.value, d.version = value, DataVersion{Name: d.name, Source: "embedded", Version: version}
+
+		if d.fileEnv == "" {
+			return
+		}
+		if path := os.Getenv(d.fileEnv); path != "" {
+			buf, err := os.ReadFile(path)
+			if err == nil {
+				err = d.replace(buf, path)
+			}
+			if err != nil {
+				log.Printf("Failed to load the %s dataset from %s, using the embedded copy: %v", d.name, path, err)
+				return
+			}
+			d.local = true
+		}
+	})
+}
+
+// Get returns the contents of the dataset which is currently in use.
+func (d *dataset[T]) Get() T {
+	d.init()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.value
+}
+
+func (d *dataset[T]) Version() DataVersion {
+	d.init()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.version
+}
+
+func (d *dataset[T]) replace(buf []byte, source string) error {
+	value, err := d.parse(buf)
+	if err != nil {
+		return err
+	}
+	version := digest(buf)
+	if d.replacementVersion != nil {
+		if v := d.replacementVersion(buf); v != "" {
+			version = v
+		}
+	}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.check != nil {
+		if err := d.check(d.value, value); err != nil {
+			return err
+		}
+	}
+	d.value = value
+	d.version = DataVersion{Name: d.name, Source: source, Version: version, LoadedAt: &now}
+	return nil
+}
+
+func (d *dataset[T]) refresh(ctx context.Context, cl *http.Client) error {
+	d.init()
+	if d.url == "" || d.local {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching the %s dataset: %w", d.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching the %s dataset: unexpected HTTP status %s", d.name, resp.Status)
+	}
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, dataMaxBytes))
+	if err != nil {
+		return fmt.Errorf("fetching the %s dataset: %w", d.name, err)
+	}
+	if err := d.replace(buf, d.url); err != nil {
+		return fmt.Errorf("the refreshed %s dataset is invalid: %w", d.name, err)
+	}
+	return nil
+}
+
+// bundledDatasets returns every dataset, in the order they are reported.
+func bundledDatasets() []bundledDataset {
+	return []bundledDataset{publicSuffixData, cdnRangesData, dnsProvidersData, dns01DelegationsData, acmeErrorsData, referencesData}
+}
+
+// DataPinned is whether LETSDEBUG_DATA_PINNED=1 is set, in which case RefreshData does nothing and
+// the embedded datasets (or their local replacements) are always used, e.g. for reproducible results
+// or for deployments without internet access beyond the checks themselves.
+func DataPinned() bool {
+	return os.Getenv("LETSDEBUG_DATA_PINNED") == "1"
+}
+
+// DataVersions describes the copy of each bundled dataset which is in use.
+func DataVersions() []DataVersion {
+	var versions []DataVersion
+	for _, d := range bundledDatasets() {
+		versions = append(versions, d.Version())
+	}
+	return versions
+}
+
+// RefreshData fetches up to date copies of the bundled datasets which are published online, and uses
+// them in place of the embedded copies for the checks which start afterwards. A dataset which can't be
+// fetched keeps its current copy, and the errors are returned together. Datasets which were replaced
+// with a local file are not refreshed.
+func RefreshData(ctx context.Context) error {
+	if DataPinned() {
+		return nil
+	}
+	cl := &http.Client{Timeout: time.Minute}
+	var errs []error
+	for _, d := range bundledDatasets() {
+		if err := d.refresh(ctx, cl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dataVersion returns the version of the named dataset, for ResultMetadata.
+func dataVersion(name string) string {
+	for _, v := range DataVersions() {
+		if v.Name == name {
+			return v.Version
+		}
+	}
+	return "unknown"
+}
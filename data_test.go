@@ -0,0 +1,103 @@
+package letsdebug
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func newTestDataset(url string) *dataset[int] {
+	return &dataset[int]{
+		name:            "test",
+		embedded:        []byte("1"),
+		embeddedVersion: func() string { return "2026-01-01" },
+		url:             url,
+		fileEnv:         "LETSDEBUG_TEST_DATA_FILE",
+		parse: func(buf []byte) (int, error) {
+			n, err := strconv.Atoi(string(buf))
+			if err == nil && n <= 0 {
+				err = errors.New("must be positive")
+			}
+			return n, err
+		},
+	}
+}
+
+func TestDatasetRefresh(t *testing.T) {
+	body := "2"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	d := newTestDataset(srv.URL)
+	if d.Get() != 1 || d.Version().Source != "embedded" || d.Version().Version != "2026-01-01" {
+		t.Fatalf("expected the embedded copy, got %d (%+v)", d.Get(), d.Version())
+	}
+
+	if err := d.refresh(context.Background(), srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	if v := d.Version(); d.Get() != 2 || v.Source != srv.URL || v.LoadedAt == nil || v.Version != digest([]byte("2")) {
+		t.Fatalf("expected the refreshed copy, got %d (%+v)", d.Get(), v)
+	}
+
+	body = "-1"
+	if err := d.refresh(context.Background(), srv.Client()); err == nil {
+		t.Fatal("expected an invalid copy to be rejected")
+	}
+	if d.Get() != 2 {
+		t.Fatalf("expected the previous copy to be kept, got %d", d.Get())
+	}
+}
+
+func TestDatasetLocalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("3"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LETSDEBUG_TEST_DATA_FILE", path)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a dataset replaced with a local file should not be refreshed")
+	}))
+	defer srv.Close()
+
+	d := newTestDataset(srv.URL)
+	if d.Get() != 3 || d.Version().Source != path {
+		t.Fatalf("expected the local copy, got %d (%+v)", d.Get(), d.Version())
+	}
+	if err := d.refresh(context.Background(), srv.Client()); err != nil || d.Get() != 3 {
+		t.Fatalf("expected the local copy to be kept, got %d (%v)", d.Get(), err)
+	}
+}
+
+func TestBundledDatasets(t *testing.T) {
+	t.Setenv("LETSDEBUG_DATA_PINNED", "1")
+	before := DataVersions()
+	if err := RefreshData(context.Background()); err != nil {
+		t.Fatalf("expected refreshing pinned data to do nothing, got %v", err)
+	}
+
+	names := map[string]bool{}
+	for i, v := range DataVersions() {
+		names[v.Name] = true
+		if v != before[i] || v.Source != "embedded" || v.Version == "" {
+			t.Errorf("unexpected version of %s: %+v", v.Name, v)
+		}
+	}
+	for _, name := range []string{"public_suffix_list", "cdn_ranges", "dns_providers", "dns01_delegations", "acme_errors", "references"} {
+		if !names[name] {
+			t.Errorf("expected a version for %s", name)
+		}
+	}
+
+	if acmeErrorsData.Get()["serverInternal"] != acmeErrorStagingBroken {
+		t.Error("expected the embedded ACME error mappings to be loaded")
+	}
+}
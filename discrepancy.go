@@ -0,0 +1,102 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Problems which indicate that our own HTTP probe could not connect to the server. Problems
+// which are reported regardless of connectivity (such as a bad redirect) are deliberately excluded.
+var localHTTPFailureProblems = map[string]bool{
+	"ANotWorking":             true,
+	"AAAANotWorking":          true,
+	"HTTPSRedirectNotWorking": true,
+}
+
+// Problems which indicate that our own resolver could not look up the dns-01 TXT record.
+var localDNSFailureProblems = map[string]bool{
+	"TXTRecordError":  true,
+	"DNSLookupFailed": true,
+}
+
+// analyzeDiscrepancies is run once every checker has completed. It cross-references the outcome
+// of our own HTTP and DNS probes with the outcome of the Let's Encrypt staging authorization, and
+// reports when they disagree. Such disagreements usually mean that the network path from Let's Encrypt
+// is treated differently to ours (geo-blocking, per-ASN firewalling, split-horizon DNS).
+func analyzeDiscrepancies(ctx *scanContext, domain string, method ValidationMethod, probs []Problem) []Problem {
+	ctx.evidenceMu.Lock()
+	chal := ctx.stagingChallenge
+	httpResults := ctx.httpResults
+	ctx.evidenceMu.Unlock()
+
+	if chal == nil || chal.Error == nil {
+		return nil
+	}
+
+	var localFailures []string
+	for _, p := range probs {
+		if (method == HTTP01 && localHTTPFailureProblems[p.Name]) ||
+			(method == DNS01 && localDNSFailureProblems[p.Name]) {
+			localFailures = append(localFailures, p.Name)
+		}
+	}
+
+	urn := strings.TrimPrefix(chal.Error.Type, "urn:ietf:params:acme:error:")
+	stagingUnreachable := urn == "connection" || urn == "dns" ||
+		strings.Contains(strings.ToLower(chal.Error.Detail), "timeout")
+	// Boulder reports NXDOMAIN on _acme-challenge as error:dns, which is the expected outcome
+	// of our test authorization, so it is not a sign of an unreachable nameserver.
+	if method == DNS01 && strings.Contains(chal.Error.Detail, "NXDOMAIN looking up TXT") {
+		stagingUnreachable = false
+	}
+
+	switch {
+	case stagingUnreachable && len(localFailures) == 0:
+		if method == HTTP01 && len(httpResults) == 0 {
+			return nil
+		}
+		return []Problem{stagingDiscrepancy(domain, true, localEvidence(method, httpResults, nil), *chal)}
+	case !stagingUnreachable && len(localFailures) > 0:
+		return []Problem{stagingDiscrepancy(domain, false, localEvidence(method, httpResults, localFailures), *chal)}
+	}
+
+	return nil
+}
+
+func localEvidence(method ValidationMethod, httpResults []httpCheckResult, localFailures []string) string {
+	var lines []string
+	if len(localFailures) > 0 {
+		lines = append(lines, "Let's Debug found: "+strings.Join(localFailures, ", "))
+	} else {
+		lines = append(lines, fmt.Sprintf("Let's Debug found no %s connectivity problems", method))
+	}
+	for _, res := range httpResults {
+		if res.IsZero() {
+			lines = append(lines, fmt.Sprintf("Request to %s: no response", res.IP))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Request to %s: %s", res.IP, res.String()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stagingDiscrepancy only quotes Boulder's error; the validation records are already reported
+// in full by the LetsEncryptStagingAuthz debug problem. It is only ever a warning, because a single
+// transient failure on the staging service is enough to produce one.
+func stagingDiscrepancy(domain string, onlyStagingFailed bool, local string, staging stagingChallenge) Problem {
+	explanation := fmt.Sprintf(`Let's Debug was able to reach %s, but the Let's Encrypt staging service was not. `+
+		`This usually means that the server or its network is treating requests from Let's Encrypt differently, `+
+		`such as through geo-blocking, per-ASN or per-country firewalling, or rate limiting by a hosting provider. `+
+		`Let's Encrypt validates from multiple network locations, none of which can be allowlisted by IP address.`, domain)
+	if !onlyStagingFailed {
+		explanation = fmt.Sprintf(`Let's Debug was unable to reach %s, but the Let's Encrypt staging service was. `+
+			`This may mean that the server or its network is blocking Let's Debug specifically, in which case some `+
+			`of the other problems reported in this test may not be accurate.`, domain)
+	}
+	return Problem{
+		Name:        "StagingDiscrepancy",
+		Explanation: explanation,
+		Detail:      fmt.Sprintf("%s\n\nLet's Encrypt reported: %s", local, staging.summary()),
+		Severity:    SeverityWarning,
+	}
+}
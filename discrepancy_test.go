@@ -0,0 +1,139 @@
+package letsdebug
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/eggsampler/acme/v3"
+)
+
+func TestAnalyzeDiscrepancies(t *testing.T) {
+	reachable := []httpCheckResult{{StatusCode: 404, InitialStatusCode: 404, IP: net.ParseIP("192.0.2.1")}}
+	failed := func(urn, detail string) *stagingChallenge {
+		return &stagingChallenge{
+			Type:   "test",
+			Status: "invalid",
+			Error:  &acme.Problem{Type: "urn:ietf:params:acme:error:" + urn, Detail: detail},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		method      ValidationMethod
+		chal        *stagingChallenge
+		probs       []Problem
+		httpResults []httpCheckResult
+		want        bool
+		wantLocal   string
+	}{
+		{
+			name:   "no staging challenge",
+			method: HTTP01,
+			want:   false,
+		},
+		{
+			name:   "staging challenge without error",
+			method: HTTP01,
+			chal:   &stagingChallenge{Type: "http-01", Status: "valid"},
+			probs:  []Problem{{Name: "ANotWorking"}},
+			want:   false,
+		},
+		{
+			name:        "http-01 only staging failed",
+			method:      HTTP01,
+			chal:        failed("connection", "Timeout during connect (likely firewall problem)"),
+			httpResults: reachable,
+			want:        true,
+			wantLocal:   "Let's Debug found no http-01 connectivity problems\nRequest to 192.0.2.1: ",
+		},
+		{
+			name:   "http-01 only staging failed without local requests",
+			method: HTTP01,
+			chal:   failed("connection", "Timeout during connect (likely firewall problem)"),
+			want:   false,
+		},
+		{
+			name:        "http-01 only local failed",
+			method:      HTTP01,
+			chal:        failed("unauthorized", "Invalid response from http://example.org/.well-known/acme-challenge/a: 404"),
+			probs:       []Problem{{Name: "ANotWorking"}},
+			httpResults: []httpCheckResult{{IP: net.ParseIP("192.0.2.1")}},
+			want:        true,
+			wantLocal:   "Let's Debug found: ANotWorking\nRequest to 192.0.2.1: no response",
+		},
+		{
+			name:        "http-01 both failed",
+			method:      HTTP01,
+			chal:        failed("connection", "Timeout during connect (likely firewall problem)"),
+			probs:       []Problem{{Name: "AAAANotWorking"}},
+			httpResults: reachable,
+			want:        false,
+		},
+		{
+			name:        "http-01 problems unrelated to connectivity are ignored",
+			method:      HTTP01,
+			chal:        failed("unauthorized", "Invalid response"),
+			probs:       []Problem{{Name: "BadRedirect"}, {Name: "WebserverMisconfiguration"}},
+			httpResults: reachable,
+			want:        false,
+		},
+		{
+			name:      "dns-01 only staging failed",
+			method:    DNS01,
+			chal:      failed("dns", "DNS problem: query timed out looking up TXT for _acme-challenge.example.org"),
+			want:      true,
+			wantLocal: "Let's Debug found no dns-01 connectivity problems",
+		},
+		{
+			name:   "dns-01 NXDOMAIN is the expected outcome",
+			method: DNS01,
+			chal:   failed("dns", "DNS problem: NXDOMAIN looking up TXT for _acme-challenge.example.org"),
+			want:   false,
+		},
+		{
+			name:      "dns-01 only local failed",
+			method:    DNS01,
+			chal:      failed("dns", "DNS problem: NXDOMAIN looking up TXT for _acme-challenge.example.org"),
+			probs:     []Problem{{Name: "TXTRecordError"}},
+			want:      true,
+			wantLocal: "Let's Debug found: TXTRecordError",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newScanContext()
+			ctx.stagingChallenge = tt.chal
+			ctx.httpResults = tt.httpResults
+
+			got := analyzeDiscrepancies(ctx, "example.org", tt.method, tt.probs)
+			if !tt.want {
+				if len(got) != 0 {
+					t.Fatalf("expected no discrepancy, got: %v", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Name != "StagingDiscrepancy" || got[0].Severity != SeverityWarning {
+				t.Fatalf("expected a single StagingDiscrepancy warning, got: %v", got)
+			}
+			if !strings.HasPrefix(got[0].Detail, tt.wantLocal) {
+				t.Fatalf("expected detail to start with %q, got: %q", tt.wantLocal, got[0].Detail)
+			}
+			if !strings.HasSuffix(got[0].Detail, "Let's Encrypt reported: "+tt.chal.summary()) {
+				t.Fatalf("expected detail to quote the staging error, got: %q", got[0].Detail)
+			}
+		})
+	}
+}
+
+func TestStagingDiscrepancyDirection(t *testing.T) {
+	chal := stagingChallenge{Error: &acme.Problem{Type: "urn:ietf:params:acme:error:connection", Detail: "Timeout"}}
+
+	if p := stagingDiscrepancy("example.org", true, "", chal); !strings.Contains(p.Explanation, "was able to reach") {
+		t.Fatalf("unexpected explanation when only staging failed: %s", p.Explanation)
+	}
+	if p := stagingDiscrepancy("example.org", false, "", chal); !strings.Contains(p.Explanation, "was unable to reach") {
+		t.Fatalf("unexpected explanation when only Let's Debug failed: %s", p.Explanation)
+	}
+}
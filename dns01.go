@@ -8,7 +8,6 @@ import (
 	"sync"
 
 	"github.com/miekg/dns"
-	"github.com/weppos/publicsuffix-go/publicsuffix"
 )
 
 // wildcardDNS01OnlyChecker ensures that a wildcard domain is only validated via dns-01.
@@ -45,7 +44,15 @@ func (c txtRecordChecker) Check(ctx *scanContext, domain string, method Validati
 
 	domain = strings.TrimPrefix(domain, "*.")
 
-	if _, err := ctx.Lookup("_acme-challenge."+domain, dns.TypeTXT); err != nil {
+	// The TXT record is looked up at the end of the chain, so that errors name the record at fault
+	chain, err := followCNAMEs(ctx, "_acme-challenge."+domain)
+	if err == nil {
+		_, err = ctx.Lookup(chain[len(chain)-1], dns.TypeTXT)
+		if err != nil && len(chain) > 1 {
+			err = fmt.Errorf("following %s: %w", strings.Join(chain, " -> "), err)
+		}
+	}
+	if err != nil {
 		// report this problem as a fatal problem as that is the purpose of this checker
 		return []Problem{txtRecordError(domain, err)}, nil
 	}
@@ -60,6 +67,7 @@ func txtRecordError(domain string, err error) Problem {
 			`Any resolver errors that the Let's Encrypt CA encounters on this record will cause certificate issuance to fail.`, domain),
 		Detail:   err.Error(),
 		Severity: SeverityFatal,
+		Err:      err,
 	}
 }
 
@@ -72,7 +80,7 @@ func (c txtDoubledLabelChecker) Check(ctx *scanContext, domain string, method Va
 		return nil, errNotApplicable
 	}
 
-	registeredDomain, _ := publicsuffix.Domain(domain)
+	registeredDomain, _ := effectiveTLDPlusOne(domain)
 
 	variants := []string{
 		fmt.Sprintf("_acme-challenge.%s.%s", domain, domain),           // _acme-challenge.www.example.org.www.example.org
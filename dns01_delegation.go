@@ -0,0 +1,238 @@
+package letsdebug
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dns01DelegationsJSON lists services which _acme-challenge records are commonly delegated to with a
+// CNAME, recognized by a suffix of the target.
+//
+//go:embed dns01_delegations.json
+var dns01DelegationsJSON []byte
+
+type dns01DelegationService struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	// Managed is whether the service answers the challenges of the certificates it issues itself,
+	// rather than publishing the TXT records of the user's own ACME client
+	Managed bool `json:"managed"`
+}
+
+var dns01DelegationsData = &dataset[[]dns01DelegationService]{
+	name:     "dns01_delegations",
+	embedded: dns01DelegationsJSON,
+	url:      bundledDataURL + "dns01_delegations.json",
+	parse: func(buf []byte) ([]dns01DelegationService, error) {
+		var services []dns01DelegationService
+		if err := json.Unmarshal(buf, &services); err != nil {
+			return nil, err
+		}
+		for _, s := range services {
+			if s.Name == "" || s.Pattern == "" {
+				return nil, fmt.Errorf("a service is missing its name or pattern: %+v", s)
+			}
+		}
+		return services, nil
+	},
+}
+
+// regexACMEDNSSubdomain matches the records of self-hosted acme-dns servers, which are named after
+// the UUID of the account.
+var regexACMEDNSSubdomain = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.`)
+
+// dns01DelegationServiceFor returns the service which target belongs to, if it is a known one.
+func dns01DelegationServiceFor(target string) (dns01DelegationService, bool) {
+	target = "." + strings.ToLower(normalizeFqdn(target))
+	for _, s := range dns01DelegationsData.Get() {
+		if strings.HasSuffix(target, s.Pattern) {
+			return s, true
+		}
+	}
+	if regexACMEDNSSubdomain.MatchString(target[1:]) {
+		return dns01DelegationService{Name: "acme-dns"}, true
+	}
+	return dns01DelegationService{}, false
+}
+
+// dns01DelegationChecker looks at the zone which _acme-challenge is delegated to with a CNAME, such
+// as an acme-dns server or a zone set aside for validation. Let's Encrypt follows the CNAME, so the
+// TXT record is looked up from the nameservers of the zone of the target, and any of them which
+// can't answer, or which don't agree with the others, cause validation to fail.
+type dns01DelegationChecker struct{}
+
+// delegatedAnswer is the answer of one of the nameservers of the delegated zone for the TXT record.
+type delegatedAnswer struct {
+	Server nameserverAddress
+	Rcode  int
+	TXT    []string
+	Error  error
+}
+
+func (a delegatedAnswer) String() string {
+	server := a.Server.String()
+	if a.Server.IP == nil {
+		server = a.Server.Host
+	}
+	switch {
+	case a.Error != nil:
+		return fmt.Sprintf("%s: %v", server, a.Error)
+	case a.Rcode != dns.RcodeSuccess:
+		return fmt.Sprintf("%s: %s", server, dns.RcodeToString[a.Rcode])
+	case len(a.TXT) == 0:
+		return fmt.Sprintf("%s: no TXT records", server)
+	}
+	return fmt.Sprintf("%s: %s", server, strings.Join(a.TXT, ", "))
+}
+
+// failed is whether the nameserver could not answer for the delegated zone at all. REFUSED and
+// SERVFAIL mean that it isn't serving the zone, or can't.
+func (a delegatedAnswer) failed() bool {
+	return a.Error != nil || (a.Rcode != dns.RcodeSuccess && a.Rcode != dns.RcodeNameError)
+}
+
+func (c dns01DelegationChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method != DNS01 {
+		return nil, errNotApplicable
+	}
+
+	chain, err := followCNAMEs(ctx, "_acme-challenge."+strings.TrimPrefix(domain, "*."))
+	if err != nil || len(chain) < 2 {
+		// Broken chains are reported by txtRecordChecker
+		return nil, errNotApplicable
+	}
+	target := chain[len(chain)-1]
+
+	cut, err := findZoneCut(ctx, target)
+	if err != nil {
+		return nil, errNotApplicable
+	}
+	registeredDomain, _ := effectiveTLDPlusOne(target)
+
+	var answers []delegatedAnswer
+	for _, host := range cut.Nameservers {
+		ips := ctx.LookupAddresses(host)
+		if len(ips) == 0 {
+			answers = append(answers, delegatedAnswer{Server: nameserverAddress{Host: host},
+				Error: errors.New("the nameserver has no addresses")})
+		}
+		for _, ip := range ips {
+			if isAddressReserved(ip) || len(answers) >= maxSizeCheckNameservers {
+				continue
+			}
+			server := nameserverAddress{host, ip}
+			answer := delegatedAnswer{Server: server}
+			r, err := queryNonRecursive(server, target, dns.TypeTXT)
+			if err != nil {
+				answer.Error = err
+			} else {
+				answer.Rcode = r.Rcode
+				for _, rr := range r.Answer {
+					if txt, ok := rr.(*dns.TXT); ok {
+						answer.TXT = append(answer.TXT, strings.Join(txt.Txt, ""))
+					}
+				}
+				sort.Strings(answer.TXT)
+			}
+			answers = append(answers, answer)
+		}
+	}
+
+	service, _ := dns01DelegationServiceFor(target)
+	return analyzeDNS01Delegation(chain, service, cut, registeredDomain, answers), nil
+}
+
+// analyzeDNS01Delegation reports the misconfigurations of the zone which the CNAME chain of the
+// _acme-challenge record leads to.
+func analyzeDNS01Delegation(chain []string, service dns01DelegationService, cut ZoneCut, registeredDomain string,
+	answers []delegatedAnswer) []Problem {
+	name, target := chain[0], chain[len(chain)-1]
+	operator := "the delegated zone"
+	if service.Name != "" {
+		operator = service.Name
+	}
+
+	var lines []string
+	for _, a := range answers {
+		lines = append(lines, a.String())
+	}
+	detail := fmt.Sprintf("CNAME chain: %s\nService: %s\nZone: %s\nNameservers: %s\n%s", strings.Join(chain, " -> "),
+		operator, cut.Zone, strings.Join(cut.Nameservers, ", "), strings.Join(lines, "\n"))
+	probs := []Problem{debugProblem("DNS01Delegation", fmt.Sprintf("%s is delegated to %s with a CNAME", name, operator), detail)}
+
+	// The zone cut was found above the registered domain of the target, which therefore doesn't exist
+	if registeredDomain != "" && cut.Zone != registeredDomain && !strings.HasSuffix(cut.Zone, "."+registeredDomain) {
+		return append(probs, Problem{
+			Name: "DNS01DelegationDangling",
+			Explanation: fmt.Sprintf(`%s is a CNAME to %s, but the domain %s does not exist, so the TXT record can't be `+
+				`created and Let's Encrypt will not find it. The domain may have expired, or the CNAME may have a typo. `+
+				`Anyone who registers %s could also answer dns-01 challenges for your domain: update or remove the CNAME.`,
+				name, target, registeredDomain, registeredDomain),
+			Detail:   detail,
+			Severity: SeverityError,
+		})
+	}
+
+	if len(cut.Nameservers) == 0 {
+		return append(probs, Problem{
+			Name: "DNS01DelegationNoNameservers",
+			Explanation: fmt.Sprintf(`%s is a CNAME to %s, in the zone %s, but that zone has no NS records. Let's Encrypt `+
+				`can't find out which servers to ask for the TXT record, and validation will fail. Add the NS records of %s, `+
+				`both in the zone and in its delegation from the parent zone.`, name, target, cut.Zone, cut.Zone),
+			Detail:   detail,
+			Severity: SeverityError,
+		})
+	}
+
+	var failed []string
+	distinct := map[string]bool{}
+	for _, a := range answers {
+		if a.failed() {
+			failed = append(failed, a.String())
+			continue
+		}
+		distinct[strings.Join(a.TXT, "\n")] = true
+	}
+	if len(failed) > 0 {
+		probs = append(probs, Problem{
+			Name: "DNS01DelegatedServerUnreachable",
+			Explanation: fmt.Sprintf(`%s is a CNAME to %s, so Let's Encrypt looks the TXT record up from the nameservers of `+
+				`the zone %s. Some of them could not answer for it, and validation will fail whenever Let's Encrypt asks one `+
+				`of those. Check that every nameserver of %s is running, reachable from the internet on port 53, and `+
+				`configured to serve the zone.`, name, target, cut.Zone, cut.Zone),
+			Detail:   strings.Join(failed, "\n"),
+			Severity: SeverityError,
+		})
+	}
+	if len(distinct) > 1 {
+		probs = append(probs, Problem{
+			Name: "DNS01DelegatedServersDisagree",
+			Explanation: fmt.Sprintf(`The nameservers of %s, which %s is delegated to, have different TXT records for %s. `+
+				`If a record was just created, it may not have reached every nameserver yet, and Let's Encrypt may ask one `+
+				`which doesn't have it. Wait until every nameserver returns the record before asking for validation.`,
+				cut.Zone, name, target),
+			Detail:   strings.Join(lines, "\n"),
+			Severity: SeverityWarning,
+		})
+	}
+
+	if service.Managed {
+		probs = append(probs, Problem{
+			Name: "DNS01DelegationManaged",
+			Explanation: fmt.Sprintf(`%s is a CNAME to %s, which belongs to %s. %s answers the dns-01 challenges of the `+
+				`certificates it issues itself, so unless you are obtaining the certificate through %s, your ACME client can't `+
+				`create the TXT record and validation will fail. Remove the CNAME, or validate with another method.`,
+				name, target, service.Name, service.Name, service.Name),
+			Detail:   detail,
+			Severity: SeverityWarning,
+		})
+	}
+	return probs
+}
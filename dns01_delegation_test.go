@@ -0,0 +1,80 @@
+package letsdebug
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNS01DelegationServiceFor(t *testing.T) {
+	if s, ok := dns01DelegationServiceFor("d420c923-bbd7-4056-ab64-c3ca54c9b3cf.auth.acme-dns.io."); !ok || s.Name != "acme-dns" || s.Managed {
+		t.Errorf("expected the public acme-dns instance, got: %+v", s)
+	}
+	if s, ok := dns01DelegationServiceFor("d420c923-bbd7-4056-ab64-c3ca54c9b3cf.acme.example.org"); !ok || s.Name != "acme-dns" {
+		t.Errorf("expected a self-hosted acme-dns server, got: %+v", s)
+	}
+	if s, ok := dns01DelegationServiceFor("example.org.0123abcd.dcv.cloudflare.com"); !ok || !s.Managed {
+		t.Errorf("expected Cloudflare's managed delegation, got: %+v", s)
+	}
+	if s, ok := dns01DelegationServiceFor("_acme-challenge.example.net"); ok {
+		t.Errorf("expected an unknown zone, got: %+v", s)
+	}
+}
+
+func TestAnalyzeDNS01Delegation(t *testing.T) {
+	chain := []string{"_acme-challenge.example.org", "example.org.acme.example.net"}
+	cut := ZoneCut{Zone: "acme.example.net", Nameservers: []string{"ns1.example.net", "ns2.example.net"}}
+	ns1 := nameserverAddress{"ns1.example.net", net.ParseIP("192.0.2.1")}
+	ns2 := nameserverAddress{"ns2.example.net", net.ParseIP("192.0.2.2")}
+	names := func(probs []Problem) map[string]bool {
+		found := map[string]bool{}
+		for _, p := range probs {
+			found[p.Name] = true
+		}
+		return found
+	}
+
+	found := names(analyzeDNS01Delegation(chain, dns01DelegationService{}, cut, "example.net", []delegatedAnswer{
+		{Server: ns1, TXT: []string{"abc"}},
+		{Server: ns2, TXT: []string{"abc"}},
+	}))
+	if len(found) != 1 || !found["DNS01Delegation"] {
+		t.Errorf("expected only the debug problem for a working delegation, got: %v", found)
+	}
+
+	found = names(analyzeDNS01Delegation(chain, dns01DelegationService{}, cut, "example.net", []delegatedAnswer{
+		{Server: ns1, TXT: []string{"abc"}},
+		{Server: ns2, Rcode: dns.RcodeRefused},
+		{Server: nameserverAddress{Host: "ns3.example.net"}, Error: errors.New("the nameserver has no addresses")},
+	}))
+	if !found["DNS01DelegatedServerUnreachable"] || found["DNS01DelegatedServersDisagree"] {
+		t.Errorf("expected the refusing nameserver to be reported, got: %v", found)
+	}
+
+	found = names(analyzeDNS01Delegation(chain, dns01DelegationService{}, cut, "example.net", []delegatedAnswer{
+		{Server: ns1, TXT: []string{"abc"}},
+		{Server: ns2, Rcode: dns.RcodeNameError},
+	}))
+	if !found["DNS01DelegatedServersDisagree"] || found["DNS01DelegatedServerUnreachable"] {
+		t.Errorf("expected the nameservers to disagree, got: %v", found)
+	}
+
+	found = names(analyzeDNS01Delegation(chain, dns01DelegationService{}, ZoneCut{Zone: "net", Nameservers: []string{"a.gtld-servers.net"}},
+		"example.net", nil))
+	if !found["DNS01DelegationDangling"] {
+		t.Errorf("expected the target's unregistered domain to be reported, got: %v", found)
+	}
+
+	found = names(analyzeDNS01Delegation(chain, dns01DelegationService{}, ZoneCut{Zone: "acme.example.net"}, "example.net", nil))
+	if !found["DNS01DelegationNoNameservers"] {
+		t.Errorf("expected the missing NS records to be reported, got: %v", found)
+	}
+
+	found = names(analyzeDNS01Delegation(chain, dns01DelegationService{Name: "Cloudflare", Managed: true}, cut, "example.net",
+		[]delegatedAnswer{{Server: ns1}}))
+	if !found["DNS01DelegationManaged"] {
+		t.Errorf("expected the managed delegation to be reported, got: %v", found)
+	}
+}
@@ -0,0 +1,78 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/miekg/unbound"
+)
+
+// dnsResponse is the full response to a single lookup performed during a scan, retained
+// so that the report contains everything an expert would otherwise re-run dig for.
+type dnsResponse struct {
+	Name     string
+	Type     uint16
+	Rcode    int
+	Secure   bool
+	Bogus    bool
+	WhyBogus string
+	Msg      *dns.Msg
+	Error    error
+}
+
+func newDNSResponse(name string, rrType uint16, result *unbound.Result, err error) dnsResponse {
+	resp := dnsResponse{Name: name, Type: rrType, Error: err}
+	if result != nil {
+		resp.Rcode = result.Rcode
+		resp.Secure = result.Secure
+		resp.Bogus = result.Bogus
+		resp.WhyBogus = result.WhyBogus
+		resp.Msg = result.AnswerPacket
+	}
+	return resp
+}
+
+// DNSSECStatus describes the validation state of the response, as determined by unbound.
+func (r dnsResponse) DNSSECStatus() string {
+	switch {
+	case r.Bogus:
+		return "bogus (" + r.WhyBogus + ")"
+	case r.Secure:
+		return "secure"
+	default:
+		return "insecure"
+	}
+}
+
+func (r dnsResponse) String() string {
+	lines := []string{fmt.Sprintf(";; %s/%s: rcode=%s, dnssec=%s",
+		r.Name, dns.TypeToString[r.Type], dns.RcodeToString[r.Rcode], r.DNSSECStatus())}
+	if r.Error != nil {
+		lines = append(lines, fmt.Sprintf(";; error: %v", r.Error))
+	}
+	if r.Msg != nil {
+		lines = append(lines, strings.TrimSpace(r.Msg.String()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func dnsResponsesProblem(responses []dnsResponse) Problem {
+	var out []string
+	for _, r := range responses {
+		out = append(out, r.String())
+	}
+	return debugProblem("DNSResponses",
+		fmt.Sprintf("The full responses to the %d DNS lookups performed during this test", len(responses)),
+		strings.Join(out, "\n\n"))
+}
+
+func dnsLookupsProblem(lookups []lookupResult) Problem {
+	var out []string
+	for _, l := range lookups {
+		out = append(out, l.String())
+	}
+	return debugProblem("DNSLookups",
+		"The resolver which answered each DNS lookup performed during this test, and how long it took",
+		strings.Join(out, "\n"))
+}
@@ -0,0 +1,70 @@
+package letsdebug
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/miekg/unbound"
+)
+
+func TestDNSResponseString(t *testing.T) {
+	msg := &dns.Msg{}
+	msg.SetQuestion("example.org.", dns.TypeA)
+	rr, _ := dns.NewRR("example.org. 300 IN A 192.0.2.1")
+	msg.Answer = append(msg.Answer, rr)
+
+	resp := newDNSResponse("example.org", dns.TypeA, &unbound.Result{Rcode: dns.RcodeSuccess, Secure: true, AnswerPacket: msg}, nil)
+	out := resp.String()
+	if !strings.HasPrefix(out, ";; example.org/A: rcode=NOERROR, dnssec=secure") {
+		t.Fatalf("unexpected summary line: %s", out)
+	}
+	if !strings.Contains(out, "192.0.2.1") {
+		t.Fatalf("expected the answer section to be included: %s", out)
+	}
+
+	bogus := newDNSResponse("example.org", dns.TypeA, &unbound.Result{Bogus: true, WhyBogus: "no signatures"}, nil)
+	if got := bogus.DNSSECStatus(); got != "bogus (no signatures)" {
+		t.Fatalf("unexpected DNSSEC status: %s", got)
+	}
+}
+
+func TestLookupResultString(t *testing.T) {
+	r := lookupResult{Name: "example.org", Type: dns.TypeA, Backend: unboundBackend, Duration: 12 * time.Millisecond}
+	if got := r.String(); got != "example.org/A: 0 records from libunbound in 12ms: ok" {
+		t.Fatalf("unexpected description: %s", got)
+	}
+
+	r.Cached = true
+	r.Error = errors.New("SERVFAIL")
+	if got := r.String(); got != "example.org/A: 0 records from scan cache, originally from libunbound in 12ms: SERVFAIL" {
+		t.Fatalf("unexpected description: %s", got)
+	}
+}
+
+func TestDNSQueryBudget(t *testing.T) {
+	ctx := newScanContext()
+	ctx.dnsQueryBudget = 0
+
+	if _, err := ctx.Lookup("example.org", dns.TypeA); !errors.Is(err, errDNSQueryBudgetExhausted) {
+		t.Fatalf("expected the budget to be exhausted, got: %v", err)
+	}
+	if p := dnsLookupFailed("example.org", "A", errDNSQueryBudgetExhausted); p.Severity != SeverityDebug {
+		t.Fatalf("expected a skipped lookup not to be fatal, got: %v", p)
+	}
+
+	if !ctx.Visit("CAA check of example.org") {
+		t.Fatal("expected the first visit to be allowed")
+	}
+	if ctx.Visit("CAA check of example.org") {
+		t.Fatal("expected a repeated visit to be refused")
+	}
+
+	p, truncated := ctx.dnsTruncationProblem()
+	if !truncated || p.Severity != SeverityWarning ||
+		!strings.Contains(p.Detail, "lookup of example.org/A") || !strings.Contains(p.Detail, "repeated CAA check of example.org") {
+		t.Fatalf("unexpected truncation problem: %v (%t)", p, truncated)
+	}
+}
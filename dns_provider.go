@@ -0,0 +1,119 @@
+package letsdebug
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dnsProvidersJSON lists well-known DNS hosts, recognized by a substring of their nameserver hostnames.
+//
+//go:embed dns_providers.json
+var dnsProvidersJSON []byte
+
+type dnsProvider struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	// Anycast is whether the provider serves from many points of presence behind anycast addresses
+	Anycast bool `json:"anycast"`
+}
+
+var dnsProvidersData = &dataset[[]dnsProvider]{
+	name:     "dns_providers",
+	embedded: dnsProvidersJSON,
+	url:      bundledDataURL + "dns_providers.json",
+	parse: func(buf []byte) ([]dnsProvider, error) {
+		var providers []dnsProvider
+		if err := json.Unmarshal(buf, &providers); err != nil {
+			return nil, err
+		}
+		for _, p := range providers {
+			if p.Name == "" || p.Pattern == "" {
+				return nil, fmt.Errorf("a provider is missing its name or pattern: %+v", p)
+			}
+		}
+		return providers, nil
+	},
+}
+
+// dnsProviderForHost returns the name of the DNS provider operating the nameserver, if it is a known one.
+func dnsProviderForHost(host string) string {
+	host = "." + strings.ToLower(normalizeFqdn(host))
+	for _, p := range dnsProvidersData.Get() {
+		if strings.Contains(host, p.Pattern) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// dnsProviderMismatchChecker compares the DNS provider which the domain is delegated to with the
+// one named as the primary nameserver in the SOA record of its zone. When they differ, the zone
+// was usually set up (or is still being edited) at one provider while the registrar points at
+// another, so changes made in the dashboard the user is looking at never take effect.
+type dnsProviderMismatchChecker struct{}
+
+func (c dnsProviderMismatchChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	cut, err := findZoneCut(ctx, strings.TrimPrefix(domain, "*."))
+	if err != nil || len(cut.Nameservers) == 0 {
+		return nil, errNotApplicable
+	}
+
+	// The parent's delegation is what the registrar has, and may differ from the NS records in the zone
+	nameservers := cut.Nameservers
+	if delegated, err := delegationNameservers(ctx, cut.Zone); err == nil && len(delegated) > 0 {
+		nameservers = delegated
+	}
+
+	var mname string
+	rrs, _ := ctx.Lookup(cut.Zone, dns.TypeSOA)
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			mname = normalizeFqdn(soa.Ns)
+		}
+	}
+	if mname == "" {
+		return nil, errNotApplicable
+	}
+
+	if p, ok := analyzeDNSProviders(cut.Zone, nameservers, mname); ok {
+		return []Problem{p}, nil
+	}
+	return nil, nil
+}
+
+// analyzeDNSProviders reports a mismatch only when both providers are recognized, since the
+// primary nameserver of a zone may legitimately be a private or self-hosted server.
+func analyzeDNSProviders(zone string, nameservers []string, mname string) (Problem, bool) {
+	soaProvider := dnsProviderForHost(mname)
+	if soaProvider == "" {
+		return Problem{}, false
+	}
+	var nsProvider string
+	for _, ns := range nameservers {
+		if p := dnsProviderForHost(ns); p != "" {
+			if p == soaProvider {
+				return Problem{}, false
+			}
+			nsProvider = p
+		}
+	}
+	if nsProvider == "" {
+		return Problem{}, false
+	}
+
+	return Problem{
+		Name: "DNSProviderMismatch",
+		Explanation: fmt.Sprintf(`The domain %s is delegated to nameservers at %s, but the SOA record of the zone names a `+
+			`nameserver at %s as its primary. This usually means the zone was created or copied at %s while the registrar points `+
+			`at %s, so changes made in the %s dashboard are never seen by Let's Encrypt. Make sure you are editing DNS records at `+
+			`%s, or change the nameservers at your registrar. This is expected if %s deliberately serves the zone as a secondary.`,
+			zone, nsProvider, soaProvider, soaProvider, nsProvider, soaProvider, nsProvider, nsProvider),
+		Detail: fmt.Sprintf("Nameservers (%s): %s\nSOA primary nameserver (%s): %s",
+			nsProvider, strings.Join(nameservers, ", "), soaProvider, mname),
+		Severity: SeverityWarning,
+	}, true
+}
@@ -0,0 +1,44 @@
+package letsdebug
+
+import "testing"
+
+func TestDNSProviderForHost(t *testing.T) {
+	tests := map[string]string{
+		"kate.ns.cloudflare.com.":    "Cloudflare",
+		"ns-1234.awsdns-12.org":      "Amazon Route 53",
+		"ns07.domaincontrol.com":     "GoDaddy",
+		"dns1.registrar-servers.com": "Namecheap",
+		"ns1.example.org":            "",
+	}
+	for host, want := range tests {
+		if got := dnsProviderForHost(host); got != want {
+			t.Errorf("%s: expected %q, got %q", host, want, got)
+		}
+	}
+}
+
+func TestAnalyzeDNSProviders(t *testing.T) {
+	tests := []struct {
+		name        string
+		nameservers []string
+		mname       string
+		want        bool
+	}{
+		{"same provider", []string{"kate.ns.cloudflare.com", "bob.ns.cloudflare.com"}, "kate.ns.cloudflare.com", false},
+		{"different providers", []string{"kate.ns.cloudflare.com", "bob.ns.cloudflare.com"}, "ns07.domaincontrol.com", true},
+		{"unrecognized primary", []string{"kate.ns.cloudflare.com"}, "hidden-master.example.org", false},
+		{"unrecognized nameservers", []string{"ns1.example.org"}, "ns07.domaincontrol.com", false},
+		{"secondary at another provider", []string{"ns07.domaincontrol.com", "kate.ns.cloudflare.com"}, "ns07.domaincontrol.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := analyzeDNSProviders("example.org", tt.nameservers, tt.mname)
+			if ok != tt.want {
+				t.Fatalf("expected mismatch=%v, got: %v", tt.want, p)
+			}
+			if ok && p.Name != "DNSProviderMismatch" {
+				t.Fatalf("unexpected problem: %v", p)
+			}
+		})
+	}
+}
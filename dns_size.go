@@ -0,0 +1,296 @@
+package letsdebug
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dnsSizeChecker queries the authoritative nameservers of the domain directly for the records the
+// CA will look up during validation, and measures the responses. Responses which are too large for
+// the resolver's EDNS buffer are truncated and must be retried over TCP, so a nameserver which
+// doesn't answer over TCP, or whose large UDP responses are fragmented and dropped along the way,
+// causes intermittent SERVFAILs at the CA which are very hard to reproduce with dig.
+type dnsSizeChecker struct{}
+
+const (
+	// caResolverBufferSize is the EDNS buffer size advertised by Let's Encrypt's resolvers,
+	// and by our own (see setUnboundConfig), as recommended by DNS Flag Day 2020.
+	caResolverBufferSize = 1232
+	// maxSizeCheckNameservers limits how many nameserver addresses are queried directly.
+	maxSizeCheckNameservers = 4
+)
+
+type dnsQuery struct {
+	name   string
+	rrType uint16
+}
+
+type nameserverAddress struct {
+	Host string
+	IP   net.IP
+}
+
+func (ns nameserverAddress) String() string {
+	return fmt.Sprintf("%s (%s)", ns.Host, ns.IP)
+}
+
+type dnsSizeMeasurement struct {
+	Server nameserverAddress
+	Name   string
+	Type   uint16
+	// UDPSize is the size of the response to the query over UDP, which may have been truncated
+	UDPSize   int
+	Truncated bool
+	// AdvertisedBuffer is the EDNS buffer size advertised by the nameserver, or 0 if it doesn't support EDNS
+	AdvertisedBuffer uint16
+	// TCPSize is the size of the response to the query over TCP, which is never truncated
+	TCPSize  int
+	TCPError error
+	Error    error
+	// The response code and answer section of each response, for comparison
+	UDPRcode, TCPRcode   int
+	UDPAnswer, TCPAnswer []string
+}
+
+// Size is the size of the complete response, as best as it could be determined.
+func (m dnsSizeMeasurement) Size() int {
+	if m.TCPSize > 0 {
+		return m.TCPSize
+	}
+	return m.UDPSize
+}
+
+func (m dnsSizeMeasurement) String() string {
+	prefix := fmt.Sprintf("%s/%s @ %s:", m.Name, dns.TypeToString[m.Type], m.Server)
+	if m.Error != nil {
+		return fmt.Sprintf("%s udp error: %v", prefix, m.Error)
+	}
+	edns := "no EDNS"
+	if m.AdvertisedBuffer > 0 {
+		edns = fmt.Sprintf("EDNS buffer %d", m.AdvertisedBuffer)
+	}
+	tcp := fmt.Sprintf("tcp %d bytes", m.TCPSize)
+	if m.TCPError != nil {
+		tcp = fmt.Sprintf("tcp error: %v", m.TCPError)
+	}
+	return fmt.Sprintf("%s udp %d bytes (truncated=%t, %s), %s", prefix, m.UDPSize, m.Truncated, edns, tcp)
+}
+
+// transportsDiffer is whether the nameserver gave a different answer over UDP than over TCP. Truncated
+// UDP responses are incomplete, so they can't be compared.
+func (m dnsSizeMeasurement) transportsDiffer() bool {
+	if m.Error != nil || m.TCPError != nil || m.Truncated {
+		return false
+	}
+	return m.UDPRcode != m.TCPRcode || strings.Join(m.UDPAnswer, "\n") != strings.Join(m.TCPAnswer, "\n")
+}
+
+func (m dnsSizeMeasurement) transportDifference() string {
+	describe := func(rcode int, answer []string) string {
+		if len(answer) == 0 {
+			return fmt.Sprintf("%s, no records", dns.RcodeToString[rcode])
+		}
+		return fmt.Sprintf("%s, %s", dns.RcodeToString[rcode], strings.Join(answer, " | "))
+	}
+	return fmt.Sprintf("%s/%s @ %s: udp: %s; tcp: %s", m.Name, dns.TypeToString[m.Type], m.Server,
+		describe(m.UDPRcode, m.UDPAnswer), describe(m.TCPRcode, m.TCPAnswer))
+}
+
+// canonicalAnswer is the answer section of r, with TTLs removed as they may legitimately differ.
+func canonicalAnswer(r *dns.Msg) []string {
+	var out []string
+	for _, rr := range r.Answer {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		out = append(out, rr.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+// needsTCP is whether the CA's resolver would have to retry the query over TCP.
+func (m dnsSizeMeasurement) needsTCP() bool {
+	return m.Truncated || m.Size() > caResolverBufferSize
+}
+
+func (c dnsSizeChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	domain = strings.TrimPrefix(domain, "*.")
+
+	queries := []dnsQuery{{domain, dns.TypeNS}, {domain, dns.TypeCAA}}
+	if method == DNS01 {
+		queries = append(queries, dnsQuery{"_acme-challenge." + domain, dns.TypeTXT})
+	}
+
+	servers := authoritativeServers(ctx, domain)
+	if len(servers) == 0 {
+		return nil, errNotApplicable
+	}
+
+	var measurements []dnsSizeMeasurement
+	for _, q := range queries {
+		for _, server := range servers {
+			measurements = append(measurements, measureDNSResponse(server, q.name, q.rrType))
+		}
+	}
+
+	return analyzeDNSSizes(measurements), nil
+}
+
+// authoritativeServers returns up to maxSizeCheckNameservers addresses of the nameservers of
+// the zone containing domain.
+func authoritativeServers(ctx *scanContext, domain string) []nameserverAddress {
+	var hosts []string
+	for name := domain; name != "" && len(hosts) == 0; {
+		rrs, _ := ctx.Lookup(name, dns.TypeNS)
+		for _, rr := range rrs {
+			if ns, ok := rr.(*dns.NS); ok {
+				hosts = append(hosts, normalizeFqdn(ns.Ns))
+			}
+		}
+		if name == publicSuffix(name) {
+			break
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+	sort.Strings(hosts)
+
+	var servers []nameserverAddress
+	for _, host := range hosts {
+		for _, ip := range ctx.LookupAddresses(host) {
+			if isAddressReserved(ip) {
+				continue
+			}
+			if len(servers) == maxSizeCheckNameservers {
+				return servers
+			}
+			servers = append(servers, nameserverAddress{host, ip})
+		}
+	}
+	return servers
+}
+
+func measureDNSResponse(server nameserverAddress, name string, rrType uint16) dnsSizeMeasurement {
+	m := dnsSizeMeasurement{Server: server, Name: name, Type: rrType}
+	addr := net.JoinHostPort(server.IP.String(), "53")
+
+	q := &dns.Msg{}
+	q.SetQuestion(dns.Fqdn(name), rrType)
+	q.SetEdns0(4096, true)
+
+	udp := &dns.Client{Net: "udp", UDPSize: 4096, Timeout: 5 * time.Second}
+	r, _, err := udp.Exchange(q, addr)
+	if err != nil {
+		m.Error = err
+		return m
+	}
+	m.UDPSize = r.Len()
+	m.Truncated = r.Truncated
+	m.UDPRcode, m.UDPAnswer = r.Rcode, canonicalAnswer(r)
+	if opt := r.IsEdns0(); opt != nil {
+		m.AdvertisedBuffer = opt.UDPSize()
+	}
+
+	tcp := &dns.Client{Net: "tcp", Timeout: 5 * time.Second}
+	if r, _, err = tcp.Exchange(q, addr); err != nil {
+		m.TCPError = err
+	} else {
+		m.TCPSize = r.Len()
+		m.TCPRcode, m.TCPAnswer = r.Rcode, canonicalAnswer(r)
+	}
+
+	return m
+}
+
+func analyzeDNSSizes(measurements []dnsSizeMeasurement) []Problem {
+	var probs []Problem
+	var lines []string
+
+	tcpFailed := map[dnsQuery][]string{}
+	tooLarge := map[dnsQuery][]string{}
+	transportMismatch := map[dnsQuery][]string{}
+	seen := map[dnsQuery]bool{}
+	var order []dnsQuery
+
+	for _, m := range measurements {
+		lines = append(lines, m.String())
+		if m.Error != nil {
+			continue
+		}
+		q := dnsQuery{m.Name, m.Type}
+		if !seen[q] {
+			seen[q] = true
+			order = append(order, q)
+		}
+		switch {
+		case m.needsTCP() && m.TCPError != nil:
+			tcpFailed[q] = append(tcpFailed[q], m.String())
+		case m.Size() > caResolverBufferSize || (m.AdvertisedBuffer > 0 && m.Size() > int(m.AdvertisedBuffer)):
+			tooLarge[q] = append(tooLarge[q], m.String())
+		}
+		if m.transportsDiffer() {
+			transportMismatch[q] = append(transportMismatch[q], m.transportDifference())
+		}
+	}
+
+	for _, q := range order {
+		if servers := tcpFailed[q]; len(servers) > 0 {
+			probs = append(probs, dnsTCPFallbackFailed(q.name, dns.TypeToString[q.rrType], servers))
+		}
+		if servers := tooLarge[q]; len(servers) > 0 {
+			probs = append(probs, dnsResponseTooLarge(q.name, dns.TypeToString[q.rrType], servers))
+		}
+		if servers := transportMismatch[q]; len(servers) > 0 {
+			probs = append(probs, dnsTransportMismatch(q.name, dns.TypeToString[q.rrType], servers))
+		}
+	}
+
+	if len(lines) > 0 {
+		probs = append(probs, debugProblem("DNSResponseSize",
+			"The size of the responses from the authoritative nameservers to the lookups the CA will perform", strings.Join(lines, "\n")))
+	}
+
+	return probs
+}
+
+func dnsTCPFallbackFailed(name, rrType string, servers []string) Problem {
+	return Problem{
+		Name: "DNSTCPFallbackFailed",
+		Explanation: fmt.Sprintf(`The response to the %s lookup for %s is too large to fit in the %d byte UDP responses that `+
+			`Let's Encrypt's resolvers accept, so it will be truncated and the lookup retried over TCP. However, some `+
+			`authoritative nameservers did not answer over TCP. This will cause the lookup to fail at the CA, `+
+			`which prevents issuance. Ensure that the nameservers accept DNS queries over TCP port 53.`, rrType, name, caResolverBufferSize),
+		Detail:   strings.Join(servers, "\n"),
+		Severity: SeverityError,
+	}
+}
+
+func dnsResponseTooLarge(name, rrType string, servers []string) Problem {
+	return Problem{
+		Name: "DNSResponseTooLarge",
+		Explanation: fmt.Sprintf(`The response to the %s lookup for %s is larger than the %d byte UDP responses that `+
+			`Let's Encrypt's resolvers accept, or than the EDNS buffer size advertised by the nameserver itself. `+
+			`The lookup currently succeeds when retried over TCP, but large UDP responses are prone to fragmentation `+
+			`and loss, which can cause intermittent SERVFAIL errors at the CA. Consider reducing the number or size `+
+			`of the records at this name.`, rrType, name, caResolverBufferSize),
+		Detail:   strings.Join(servers, "\n"),
+		Severity: SeverityWarning,
+	}
+}
+
+func dnsTransportMismatch(name, rrType string, servers []string) Problem {
+	return Problem{
+		Name: "DNSTransportMismatch",
+		Explanation: fmt.Sprintf(`Some authoritative nameservers gave a different answer to the %s lookup for %s over UDP than over TCP. `+
+			`This is usually caused by a firewall, load balancer or other middlebox which intercepts or rewrites DNS traffic `+
+			`on UDP port 53 only, or by a nameserver which is configured differently for each transport. Let's Encrypt's resolvers `+
+			`use UDP first and TCP when a response is truncated, so the CA may see different records from the ones you see with dig.`,
+			rrType, name),
+		Detail:   strings.Join(servers, "\n"),
+		Severity: SeverityError,
+	}
+}
@@ -0,0 +1,68 @@
+package letsdebug
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAnalyzeDNSSizes(t *testing.T) {
+	ns1 := nameserverAddress{"ns1.example.org", net.ParseIP("192.0.2.1")}
+	ns2 := nameserverAddress{"ns2.example.org", net.ParseIP("192.0.2.2")}
+	measure := func(server nameserverAddress, udp int, truncated bool, buffer uint16, tcp int, tcpErr error) dnsSizeMeasurement {
+		return dnsSizeMeasurement{Server: server, Name: "example.org", Type: dns.TypeCAA,
+			UDPSize: udp, Truncated: truncated, AdvertisedBuffer: buffer, TCPSize: tcp, TCPError: tcpErr}
+	}
+	refused := errors.New("connection refused")
+
+	tests := []struct {
+		name         string
+		measurements []dnsSizeMeasurement
+		expected     []string
+	}{
+		{"small", []dnsSizeMeasurement{
+			measure(ns1, 200, false, 1232, 200, nil),
+			measure(ns2, 200, false, 1232, 200, refused),
+		}, []string{"DNSResponseSize"}},
+		{"truncated without tcp", []dnsSizeMeasurement{
+			measure(ns1, 1200, true, 1232, 0, refused),
+			measure(ns2, 1500, false, 4096, 1500, nil),
+		}, []string{"DNSTCPFallbackFailed", "DNSResponseTooLarge", "DNSResponseSize"}},
+		{"larger than advertised buffer", []dnsSizeMeasurement{
+			measure(ns1, 700, false, 512, 700, nil),
+		}, []string{"DNSResponseTooLarge", "DNSResponseSize"}},
+		{"different answer over udp", []dnsSizeMeasurement{
+			func() dnsSizeMeasurement {
+				m := measure(ns1, 200, false, 1232, 200, nil)
+				m.UDPAnswer = []string{"example.org.\t0\tIN\tCAA\t0 issue \"example.net\""}
+				m.TCPAnswer = []string{"example.org.\t0\tIN\tCAA\t0 issue \"letsencrypt.org\""}
+				return m
+			}(),
+			func() dnsSizeMeasurement {
+				// Truncated responses are incomplete, so aren't compared
+				m := measure(ns2, 500, true, 1232, 900, nil)
+				m.TCPAnswer = []string{"example.org.\t0\tIN\tCAA\t0 issue \"letsencrypt.org\""}
+				return m
+			}(),
+		}, []string{"DNSTransportMismatch", "DNSResponseSize"}},
+		{"udp failure", []dnsSizeMeasurement{
+			{Server: ns1, Name: "example.org", Type: dns.TypeCAA, Error: errors.New("i/o timeout")},
+		}, []string{"DNSResponseSize"}},
+	}
+
+	for _, tc := range tests {
+		probs := analyzeDNSSizes(tc.measurements)
+		if len(probs) != len(tc.expected) {
+			t.Errorf("%s: expected %v, got %+v", tc.name, tc.expected, probs)
+			continue
+		}
+		for i, name := range tc.expected {
+			if probs[i].Name != name {
+				t.Errorf("%s: expected %v, got %+v", tc.name, tc.expected, probs)
+				break
+			}
+		}
+	}
+}
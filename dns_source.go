@@ -0,0 +1,142 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dnsResponseSourceChecker sends a query to each authoritative nameserver of the domain from an
+// unconnected UDP socket, so that every response can be seen no matter where it comes from.
+// A NAT or firewall in front of a nameserver which rewrites the source address or port of responses
+// (e.g. because the nameserver listens on a private address) causes the responses to be discarded
+// by any resolver which checks them, as Let's Encrypt's do, while many simple tools accept them.
+type dnsResponseSourceChecker struct{}
+
+const dnsSourceProbeTimeout = 5 * time.Second
+
+type dnsSourceProbe struct {
+	Server nameserverAddress
+	// From is where the response came from
+	From *net.UDPAddr
+	// IDMismatch is set when the transaction ID of the response didn't match the query
+	IDMismatch bool
+	Error      error
+}
+
+// Spoofed is whether a resolver which checks where responses come from would discard the response.
+func (p dnsSourceProbe) Spoofed() bool {
+	return p.From != nil && (!p.From.IP.Equal(p.Server.IP) || p.From.Port != 53)
+}
+
+func (p dnsSourceProbe) String() string {
+	if p.Error != nil {
+		return fmt.Sprintf("%s: %v", p.Server, p.Error)
+	}
+	s := fmt.Sprintf("%s: response from %s", p.Server, p.From)
+	if p.IDMismatch {
+		s += " with a different transaction ID"
+	}
+	return s
+}
+
+func (c dnsResponseSourceChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	domain = strings.TrimPrefix(domain, "*.")
+
+	servers := authoritativeServers(ctx, domain)
+	if len(servers) == 0 {
+		return nil, errNotApplicable
+	}
+
+	var probes []dnsSourceProbe
+	for _, server := range servers {
+		probes = append(probes, probeDNSResponseSource(server, domain, dns.TypeSOA))
+	}
+
+	return analyzeDNSSourceProbes(domain, probes), nil
+}
+
+func probeDNSResponseSource(server nameserverAddress, name string, rrType uint16) dnsSourceProbe {
+	probe := dnsSourceProbe{Server: server}
+
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	defer conn.Close()
+
+	q := &dns.Msg{}
+	q.SetQuestion(dns.Fqdn(name), rrType)
+	q.SetEdns0(caResolverBufferSize, false)
+	buf, err := q.Pack()
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	if _, err := conn.WriteTo(buf, &net.UDPAddr{IP: server.IP, Port: 53}); err != nil {
+		probe.Error = err
+		return probe
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(dnsSourceProbeTimeout))
+	rbuf := make([]byte, 65535)
+	for {
+		n, from, err := conn.ReadFrom(rbuf)
+		if err != nil {
+			probe.Error = err
+			return probe
+		}
+		r := &dns.Msg{}
+		if err := r.Unpack(rbuf[:n]); err != nil || !r.Response || len(r.Question) != 1 ||
+			!strings.EqualFold(r.Question[0].Name, q.Question[0].Name) || r.Question[0].Qtype != rrType {
+			// Not a response to our query
+			continue
+		}
+		udpFrom, ok := from.(*net.UDPAddr)
+		if !ok {
+			probe.Error = errors.New("response from a non-UDP address")
+			return probe
+		}
+		probe.From = udpFrom
+		probe.IDMismatch = r.Id != q.Id
+		return probe
+	}
+}
+
+func analyzeDNSSourceProbes(domain string, probes []dnsSourceProbe) []Problem {
+	var lines, offending []string
+	for _, p := range probes {
+		lines = append(lines, p.String())
+		if p.Error == nil && (p.Spoofed() || p.IDMismatch) {
+			offending = append(offending, p.String())
+		}
+	}
+
+	var probs []Problem
+	if len(offending) > 0 {
+		probs = append(probs, dnsResponseSourceMismatch(domain, offending))
+	}
+	if len(lines) > 0 {
+		probs = append(probs, debugProblem("DNSResponseSource", "Where the responses of the authoritative nameservers came from",
+			strings.Join(lines, "\n")))
+	}
+	return probs
+}
+
+func dnsResponseSourceMismatch(domain string, servers []string) Problem {
+	return Problem{
+		Name: "DNSResponseSourceMismatch",
+		Explanation: fmt.Sprintf(`Some authoritative nameservers of %s answered from a different address or port than the one `+
+			`that was queried, or with a different transaction ID. This is usually caused by a NAT or firewall in front of the `+
+			`nameserver which doesn't translate responses correctly, e.g. when the nameserver listens on a private address. `+
+			`Resolvers which check responses, including Let's Encrypt's, discard them, so lookups time out at the CA even though `+
+			`some tools show the right answer. Ensure that responses are sent from the public address and port 53 that was queried.`, domain),
+		Detail:   strings.Join(servers, "\n"),
+		Severity: SeverityError,
+	}
+}
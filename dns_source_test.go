@@ -0,0 +1,38 @@
+package letsdebug
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestAnalyzeDNSSourceProbes(t *testing.T) {
+	server := nameserverAddress{"ns1.example.org", net.ParseIP("203.0.113.1")}
+	names := func(probs []Problem) map[string]bool {
+		out := map[string]bool{}
+		for _, p := range probs {
+			out[p.Name] = true
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		probe dnsSourceProbe
+		want  bool
+	}{
+		{"from the queried address", dnsSourceProbe{Server: server, From: &net.UDPAddr{IP: net.ParseIP("203.0.113.1"), Port: 53}}, false},
+		{"from a private address", dnsSourceProbe{Server: server, From: &net.UDPAddr{IP: net.ParseIP("10.0.0.53"), Port: 53}}, true},
+		{"from a different port", dnsSourceProbe{Server: server, From: &net.UDPAddr{IP: net.ParseIP("203.0.113.1"), Port: 5353}}, true},
+		{"with a different transaction ID", dnsSourceProbe{Server: server, From: &net.UDPAddr{IP: net.ParseIP("203.0.113.1"), Port: 53}, IDMismatch: true}, true},
+		{"timeout", dnsSourceProbe{Server: server, Error: errors.New("i/o timeout")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(analyzeDNSSourceProbes("example.org", []dnsSourceProbe{tt.probe}))
+			if got["DNSResponseSourceMismatch"] != tt.want || !got["DNSResponseSource"] {
+				t.Fatalf("expected mismatch=%v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
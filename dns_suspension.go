@@ -0,0 +1,164 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dnsSuspensionChecker recognizes DNS hosting which has been suspended, usually because an account
+// is unpaid or a domain has expired. The domain is then delegated to placeholder nameservers, or its
+// nameservers refuse to answer for it, and every lookup fails. Let's Encrypt's error messages only
+// say that the lookup failed, which is often taken to be a problem with Let's Encrypt itself.
+type dnsSuspensionChecker struct{}
+
+// regexSuspendedNameserver matches the nameservers registrars and DNS hosts delegate suspended,
+// expired or parked domains to.
+// Generic words like "parking" are avoided, as some hosts use them for their regular nameservers.
+var regexSuspendedNameserver = regexp.MustCompile(`(?i)(suspend|expired|pendingrenewaldeletion|\.sedoparking\.com$|\.parkingcrew\.net$|\.bodis\.com$)`)
+
+type nameserverResponse struct {
+	Server nameserverAddress
+	Rcode  int
+	Error  error
+}
+
+func (r nameserverResponse) String() string {
+	if r.Error != nil {
+		return fmt.Sprintf("%s: %v", r.Server, r.Error)
+	}
+	return fmt.Sprintf("%s: %s", r.Server, dns.RcodeToString[r.Rcode])
+}
+
+func (c dnsSuspensionChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	registeredDomain, err := effectiveTLDPlusOne(strings.TrimPrefix(domain, "*."))
+	if err != nil {
+		return nil, errNotApplicable
+	}
+
+	nameservers, err := delegationNameservers(ctx, registeredDomain)
+	if err != nil || len(nameservers) == 0 {
+		// Missing delegations are reported by domainExistsChecker
+		return nil, errNotApplicable
+	}
+
+	var responses []nameserverResponse
+	for _, host := range nameservers {
+		for _, ip := range ctx.LookupAddresses(host) {
+			if isAddressReserved(ip) || len(responses) == maxSizeCheckNameservers {
+				continue
+			}
+			server := nameserverAddress{host, ip}
+			r, err := queryNonRecursive(server, registeredDomain, dns.TypeSOA)
+			resp := nameserverResponse{Server: server, Error: err}
+			if err == nil {
+				resp.Rcode = r.Rcode
+			}
+			responses = append(responses, resp)
+		}
+	}
+
+	return analyzeDNSSuspension(registeredDomain, nameservers, responses), nil
+}
+
+// delegationNameservers asks the nameservers of the parent zone which nameservers registeredDomain
+// is delegated to. Unlike a recursive NS lookup, this works even when those nameservers don't answer.
+func delegationNameservers(ctx *scanContext, registeredDomain string) ([]string, error) {
+	_, parent, _ := strings.Cut(registeredDomain, ".")
+	var parentServers []string
+	for ; parent != "" && len(parentServers) == 0; _, parent, _ = strings.Cut(parent, ".") {
+		rrs, _ := ctx.Lookup(parent, dns.TypeNS)
+		for _, rr := range rrs {
+			if ns, ok := rr.(*dns.NS); ok {
+				parentServers = append(parentServers, normalizeFqdn(ns.Ns))
+			}
+		}
+	}
+
+	for _, host := range parentServers {
+		for _, ip := range ctx.LookupAddresses(host) {
+			r, err := queryNonRecursive(nameserverAddress{host, ip}, registeredDomain, dns.TypeNS)
+			if err != nil {
+				continue
+			}
+			var nameservers []string
+			for _, rr := range append(r.Answer, r.Ns...) {
+				if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(normalizeFqdn(ns.Hdr.Name), registeredDomain) {
+					nameservers = append(nameservers, strings.ToLower(normalizeFqdn(ns.Ns)))
+				}
+			}
+			sort.Strings(nameservers)
+			return nameservers, nil
+		}
+	}
+	return nil, errors.New("none of the nameservers of the parent zone answered")
+}
+
+func queryNonRecursive(server nameserverAddress, name string, rrType uint16) (*dns.Msg, error) {
+	q := &dns.Msg{}
+	q.SetQuestion(dns.Fqdn(name), rrType)
+	q.RecursionDesired = false
+	q.SetEdns0(caResolverBufferSize, false)
+
+	cl := &dns.Client{Net: "udp", UDPSize: caResolverBufferSize, Timeout: 5 * time.Second}
+	r, _, err := cl.Exchange(q, net.JoinHostPort(server.IP.String(), "53"))
+	if err == nil && r.Truncated {
+		cl.Net = "tcp"
+		r, _, err = cl.Exchange(q, net.JoinHostPort(server.IP.String(), "53"))
+	}
+	return r, err
+}
+
+func analyzeDNSSuspension(registeredDomain string, nameservers []string, responses []nameserverResponse) []Problem {
+	var parked []string
+	for _, ns := range nameservers {
+		if regexSuspendedNameserver.MatchString(ns) {
+			parked = append(parked, ns)
+		}
+	}
+	if len(parked) > 0 {
+		return []Problem{dnsHostingSuspended(registeredDomain,
+			fmt.Sprintf(`%s is delegated to %s, which are the nameservers used for suspended, expired or parked domains`,
+				registeredDomain, strings.Join(parked, ", ")),
+			"Nameservers: "+strings.Join(nameservers, ", "))}
+	}
+
+	var answered int
+	var lines []string
+	for _, r := range responses {
+		lines = append(lines, r.String())
+		if r.Error != nil {
+			continue
+		}
+		if r.Rcode != dns.RcodeRefused {
+			return nil
+		}
+		answered++
+	}
+	if answered == 0 {
+		return nil
+	}
+	return []Problem{dnsHostingSuspended(registeredDomain,
+		fmt.Sprintf(`Every nameserver %s is delegated to refused to answer for it, which means that they no longer host the zone`,
+			registeredDomain),
+		strings.Join(lines, "\n"))}
+}
+
+func dnsHostingSuspended(registeredDomain, reason, detail string) Problem {
+	return Problem{
+		Name: "DNSHostingSuspended",
+		Explanation: fmt.Sprintf(`%s. This usually happens when a DNS hosting account is unpaid or closed, when the domain `+
+			`has expired, or when the zone was deleted at the DNS host without updating the nameservers at the registrar. `+
+			`No lookups for %s can succeed until this is fixed, so this is a problem with your DNS hosting rather than with `+
+			`Let's Encrypt. Contact your registrar or DNS host to restore the zone, or update the nameservers of the domain.`,
+			reason, registeredDomain),
+		Detail:   detail,
+		Severity: SeverityError,
+	}
+}
@@ -0,0 +1,40 @@
+package letsdebug
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAnalyzeDNSSuspension(t *testing.T) {
+	response := func(rcode int) nameserverResponse {
+		return nameserverResponse{Server: nameserverAddress{"ns1.example.net", net.ParseIP("192.0.2.1")}, Rcode: rcode}
+	}
+	timeout := nameserverResponse{Server: nameserverAddress{"ns2.example.net", net.ParseIP("192.0.2.2")}, Error: errors.New("i/o timeout")}
+
+	tests := []struct {
+		name        string
+		nameservers []string
+		responses   []nameserverResponse
+		want        bool
+	}{
+		{"working", []string{"ns1.example.net"}, []nameserverResponse{response(dns.RcodeSuccess)}, false},
+		{"parked nameservers", []string{"ns1.suspended-domain.com", "ns2.suspended-domain.com"}, nil, true},
+		{"bodis", []string{"ns1.bodis.com"}, nil, true},
+		{"parking service", []string{"ns1.sedoparking.com"}, nil, true},
+		{"regular nameservers named parking", []string{"ns1.dns-parking.com"}, []nameserverResponse{response(dns.RcodeSuccess)}, false},
+		{"all refused", []string{"ns1.example.net", "ns2.example.net"}, []nameserverResponse{response(dns.RcodeRefused), timeout}, true},
+		{"some refused", []string{"ns1.example.net"}, []nameserverResponse{response(dns.RcodeRefused), response(dns.RcodeSuccess)}, false},
+		{"no responses", []string{"ns2.example.net"}, []nameserverResponse{timeout}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probs := analyzeDNSSuspension("example.org", tt.nameservers, tt.responses)
+			if got := len(probs) == 1 && probs[0].Name == "DNSHostingSuspended"; got != tt.want {
+				t.Fatalf("expected suspended=%v, got: %v", tt.want, probs)
+			}
+		})
+	}
+}
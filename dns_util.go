@@ -13,6 +13,9 @@ import (
 	"golang.org/x/net/context"
 )
 
+// unboundBackend identifies answers which were resolved by lookupRaw.
+const unboundBackend = "libunbound"
+
 var (
 	reservedNets []*net.IPNet
 	cfClient     *dns.Client
@@ -130,6 +133,14 @@ func normalizeFqdn(name string) string {
 	return strings.ToLower(name)
 }
 
+// cgnatNet is the shared address space which ISPs number their customers from when they are
+// behind carrier-grade NAT (RFC 6598).
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+func isAddressCGNAT(ip net.IP) bool {
+	return cgnatNet.Contains(ip)
+}
+
 func isAddressReserved(ip net.IP) bool {
 	for _, reserved := range reservedNets {
 		if reserved.Contains(ip) {
@@ -0,0 +1,305 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// dnssecChainChecker walks the chain of trust from the root zone down to the zone of the domain,
+// checking that the DS records in each parent zone match a DNSKEY of the child zone, and that the
+// signatures along the way verify and are within their validity period. When the chain is broken,
+// unbound (and the CA) only report that the response was bogus, so this names the broken zone and why.
+type dnssecChainChecker struct{}
+
+// dnssecQuery fetches the records of name, along with their signatures, without validating them.
+type dnssecQuery func(name string, rrType uint16) (*dns.Msg, error)
+
+// dnssecResolver is the resolver which is asked for records without validation (with the CD bit),
+// so that records which fail validation can be examined.
+const dnssecResolver = "1.1.1.1:53"
+
+// dnssecSupportedAlgorithms are the DNSKEY algorithms which validating resolvers implement. A zone
+// whose DS records only use other algorithms is treated as unsigned.
+var dnssecSupportedAlgorithms = map[uint8]bool{
+	dns.RSASHA1: true, dns.RSASHA1NSEC3SHA1: true, dns.RSASHA256: true, dns.RSASHA512: true,
+	dns.ECDSAP256SHA256: true, dns.ECDSAP384SHA384: true, dns.ED25519: true, dns.ED448: true,
+}
+
+// dnssecSupportedDigests are the DS digest types which validating resolvers implement.
+var dnssecSupportedDigests = map[uint8]bool{dns.SHA1: true, dns.SHA256: true, dns.SHA384: true}
+
+func (c dnssecChainChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	domain = strings.TrimPrefix(domain, "*.")
+	return analyzeDNSSECChain(domain, queryWithoutValidation, time.Now()), nil
+}
+
+func queryWithoutValidation(name string, rrType uint16) (*dns.Msg, error) {
+	q := &dns.Msg{}
+	q.SetQuestion(dns.Fqdn(name), rrType)
+	q.SetEdns0(4096, true)
+	q.RecursionDesired = true
+	q.CheckingDisabled = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, _, err := (&dns.Client{Net: "udp", UDPSize: 4096}).ExchangeContext(ctx, q, dnssecResolver)
+	if err == nil && r.Truncated {
+		r, _, err = (&dns.Client{Net: "tcp"}).ExchangeContext(ctx, q, dnssecResolver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("DNS response for %s/%s had response code %s", name, dns.TypeToString[rrType], dns.RcodeToString[r.Rcode])
+	}
+	return r, nil
+}
+
+// dnssecRRset is the records of one type from the answer section of a response, and their signatures.
+type dnssecRRset struct {
+	RRs  []dns.RR
+	Sigs []*dns.RRSIG
+}
+
+func answerRRset(r *dns.Msg, name string, rrType uint16) dnssecRRset {
+	var set dnssecRRset
+	for _, rr := range r.Answer {
+		if normalizeFqdn(rr.Header().Name) != normalizeFqdn(name) {
+			continue
+		}
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == rrType {
+			set.Sigs = append(set.Sigs, sig)
+		} else if rr.Header().Rrtype == rrType {
+			set.RRs = append(set.RRs, rr)
+		}
+	}
+	return set
+}
+
+func dnssecTime(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format(time.RFC3339)
+}
+
+// verifyRRset returns the signature over the RRset which verifies with one of the keys and is valid
+// at now, or why there is none.
+func verifyRRset(set dnssecRRset, keys []*dns.DNSKEY, now time.Time) (*dns.RRSIG, error) {
+	if len(set.Sigs) == 0 {
+		return nil, errors.New("there are no RRSIG records over them")
+	}
+	var reasons []string
+	for _, sig := range set.Sigs {
+		var key *dns.DNSKEY
+		for _, k := range keys {
+			if k.KeyTag() == sig.KeyTag && k.Algorithm == sig.Algorithm && normalizeFqdn(k.Hdr.Name) == normalizeFqdn(sig.SignerName) {
+				key = k
+				break
+			}
+		}
+		if key == nil {
+			reasons = append(reasons, fmt.Sprintf("the signature by key tag %d (algorithm %s) was made with a key which is not "+
+				"in the DNSKEY records of %s", sig.KeyTag, dns.AlgorithmToString[sig.Algorithm], normalizeFqdn(sig.SignerName)))
+			continue
+		}
+		switch err := sig.Verify(key, set.RRs); {
+		case err != nil:
+			reasons = append(reasons, fmt.Sprintf("the signature by key tag %d does not verify: %v", sig.KeyTag, err))
+		case !sig.ValidityPeriod(now):
+			reasons = append(reasons, fmt.Sprintf("the signature by key tag %d is only valid from %s until %s, and it is now %s",
+				sig.KeyTag, dnssecTime(sig.Inception), dnssecTime(sig.Expiration), now.UTC().Format(time.RFC3339)))
+		default:
+			return sig, nil
+		}
+	}
+	return nil, errors.New(strings.Join(reasons, "; "))
+}
+
+func describeDS(records []dns.RR) string {
+	var out []string
+	for _, rr := range records {
+		if ds, ok := rr.(*dns.DS); ok {
+			out = append(out, fmt.Sprintf("key tag %d, algorithm %s, digest type %d", ds.KeyTag, dns.AlgorithmToString[ds.Algorithm], ds.DigestType))
+		}
+	}
+	return strings.Join(out, "; ")
+}
+
+func describeDNSKEYs(records []dns.RR) string {
+	var out []string
+	for _, rr := range records {
+		if key, ok := rr.(*dns.DNSKEY); ok {
+			role := "ZSK"
+			if key.Flags&dns.SEP != 0 {
+				role = "KSK"
+			}
+			out = append(out, fmt.Sprintf("key tag %d, algorithm %s (%s)", key.KeyTag(), dns.AlgorithmToString[key.Algorithm], role))
+		}
+	}
+	return strings.Join(out, "; ")
+}
+
+func dnskeys(records []dns.RR) []*dns.DNSKEY {
+	var keys []*dns.DNSKEY
+	for _, rr := range records {
+		if key, ok := rr.(*dns.DNSKEY); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// zonesOf returns the zones from the root down to the zone of domain, which are the names with an SOA
+// record of their own, along with the response to the SOA query of each.
+func zonesOf(domain string, query dnssecQuery) ([]string, map[string]*dns.Msg, error) {
+	labels := dns.SplitDomainName(domain)
+	zones := []string{"."}
+	soas := map[string]*dns.Msg{}
+	for i := len(labels) - 1; i >= 0; i-- {
+		name := strings.Join(labels[i:], ".")
+		r, err := query(name, dns.TypeSOA)
+		if err != nil {
+			return zones, soas, err
+		}
+		if r.Rcode == dns.RcodeNameError {
+			break
+		}
+		if len(answerRRset(r, name, dns.TypeSOA).RRs) > 0 {
+			zones = append(zones, name)
+			soas[name] = r
+		}
+	}
+	return zones, soas, nil
+}
+
+// analyzeDNSSECChain follows the chain of trust from the root to the zone of domain, and reports the
+// first link which is broken.
+func analyzeDNSSECChain(domain string, query dnssecQuery, now time.Time) []Problem {
+	var lines []string
+	debug := func() Problem {
+		return debugProblem("DNSSECChain", "The DNSSEC chain of trust from the root zone to the domain", strings.Join(lines, "\n"))
+	}
+
+	zones, soas, err := zonesOf(domain, query)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("Finding the zones of %s failed: %v", domain, err))
+		return []Problem{debug()}
+	}
+
+	r, err := query(".", dns.TypeDNSKEY)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("Looking up the DNSKEY records of the root zone failed: %v", err))
+		return []Problem{debug()}
+	}
+	// The keys of the root zone are taken on trust, they're the same for everyone
+	parentKeys := dnskeys(answerRRset(r, ".", dns.TypeDNSKEY).RRs)
+
+	for i, zone := range zones[1:] {
+		parent := zones[i]
+
+		r, err := query(zone, dns.TypeDS)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s: looking up the DS records failed: %v", zone, err))
+			return []Problem{debug()}
+		}
+		ds := answerRRset(r, zone, dns.TypeDS)
+		if len(ds.RRs) == 0 {
+			lines = append(lines, fmt.Sprintf("%s: no DS records in %s, so %s and the zones below it are unsigned", zone, parent, zone))
+			return []Problem{debug()}
+		}
+		if _, err := verifyRRset(ds, parentKeys, now); err != nil {
+			return []Problem{dnssecChainBroken(domain, parent, fmt.Sprintf(`the DS records for %s in its parent zone %s `+
+				`do not have a valid signature: %v`, zone, parent, err), describeDS(ds.RRs)), debug()}
+		}
+
+		var supported []*dns.DS
+		for _, rr := range ds.RRs {
+			if d, ok := rr.(*dns.DS); ok && dnssecSupportedAlgorithms[d.Algorithm] && dnssecSupportedDigests[d.DigestType] {
+				supported = append(supported, d)
+			}
+		}
+		if len(supported) == 0 {
+			lines = append(lines, fmt.Sprintf("%s: DS records only use unsupported algorithms (%s)", zone, describeDS(ds.RRs)))
+			return []Problem{dnssecAlgorithmUnsupported(domain, zone, describeDS(ds.RRs)), debug()}
+		}
+
+		if r, err = query(zone, dns.TypeDNSKEY); err != nil {
+			lines = append(lines, fmt.Sprintf("%s: looking up the DNSKEY records failed: %v", zone, err))
+			return []Problem{debug()}
+		}
+		keySet := answerRRset(r, zone, dns.TypeDNSKEY)
+		if len(keySet.RRs) == 0 {
+			return []Problem{dnssecChainBroken(domain, zone, fmt.Sprintf(`%s has DS records in its parent zone %s, but `+
+				`no DNSKEY records, which usually happens after moving the zone to a DNS provider which doesn't sign it `+
+				`without removing the DS records at the registrar`, zone, parent), "DS: "+describeDS(ds.RRs)), debug()}
+		}
+
+		var anchors []*dns.DNSKEY
+		for _, key := range dnskeys(keySet.RRs) {
+			for _, d := range supported {
+				if digest := key.ToDS(d.DigestType); digest != nil && key.KeyTag() == d.KeyTag && strings.EqualFold(digest.Digest, d.Digest) {
+					anchors = append(anchors, key)
+					break
+				}
+			}
+		}
+		if len(anchors) == 0 {
+			return []Problem{dnssecChainBroken(domain, zone, fmt.Sprintf(`none of the DS records for %s in its parent zone %s `+
+				`match a DNSKEY of %s, which usually happens after the zone's keys were changed (or the zone moved to another `+
+				`DNS provider) without updating the DS records at the registrar`, zone, parent, zone),
+				fmt.Sprintf("DS in %s: %s\nDNSKEY in %s: %s", parent, describeDS(ds.RRs), zone, describeDNSKEYs(keySet.RRs))), debug()}
+		}
+		if _, err := verifyRRset(keySet, anchors, now); err != nil {
+			return []Problem{dnssecChainBroken(domain, zone, fmt.Sprintf(`the DNSKEY records of %s are not validly signed by `+
+				`a key matching its DS records: %v`, zone, err), "DNSKEY: "+describeDNSKEYs(keySet.RRs)), debug()}
+		}
+
+		keys := dnskeys(keySet.RRs)
+		soa := answerRRset(soas[zone], zone, dns.TypeSOA)
+		sig, err := verifyRRset(soa, keys, now)
+		if err != nil {
+			return []Problem{dnssecChainBroken(domain, zone, fmt.Sprintf(`the records of %s are not validly signed, e.g. `+
+				`its SOA record: %v`, zone, err), "DNSKEY: "+describeDNSKEYs(keySet.RRs)), debug()}
+		}
+
+		var tags []string
+		for _, key := range anchors {
+			tags = append(tags, fmt.Sprint(key.KeyTag()))
+		}
+		sort.Strings(tags)
+		lines = append(lines, fmt.Sprintf("%s: secure, DS in %s matches key tag(s) %s, SOA signature valid until %s",
+			zone, parent, strings.Join(tags, ", "), dnssecTime(sig.Expiration)))
+		parentKeys = keys
+	}
+
+	return []Problem{debug()}
+}
+
+func dnssecChainBroken(domain, zone, why, detail string) Problem {
+	return Problem{
+		Name: "DNSSECChainBroken",
+		Explanation: fmt.Sprintf(`The DNSSEC chain of trust for %s is broken at the zone %s: %s. Validating resolvers, `+
+			`including those of Let's Encrypt, reject every response from %s and the zones below it as bogus, so all `+
+			`lookups for the domain will fail until this is fixed, or DNSSEC is turned off by removing the DS records at the `+
+			`registrar.`, domain, zone, why, zone),
+		Detail:   detail,
+		Severity: SeverityError,
+	}
+}
+
+func dnssecAlgorithmUnsupported(domain, zone, ds string) Problem {
+	return Problem{
+		Name: "DNSSECAlgorithmUnsupported",
+		Explanation: fmt.Sprintf(`The DS records of the zone %s only use algorithms or digest types which validating resolvers, `+
+			`including those of Let's Encrypt, don't support. They treat %s and the zones below it, including %s, as unsigned. `+
+			`This doesn't prevent issuance, but it is probably not what was intended: re-sign the zone with a supported algorithm, `+
+			`such as ECDSAP256SHA256, and publish its DS records with a SHA-256 digest.`, zone, zone, domain),
+		Detail:   ds,
+		Severity: SeverityWarning,
+	}
+}
@@ -0,0 +1,127 @@
+package letsdebug
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type testZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) testZone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+// sign returns the records along with their signature by the zone, valid from inception to expiration.
+func (z testZone) sign(t *testing.T, inception, expiration time.Time, rrs ...dns.RR) []dns.RR {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrs[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		Algorithm:  z.key.Algorithm,
+		SignerName: z.key.Hdr.Name,
+		KeyTag:     z.key.KeyTag(),
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(z.priv, rrs); err != nil {
+		t.Fatal(err)
+	}
+	return append(rrs, sig)
+}
+
+func (z testZone) soa() dns.RR {
+	return &dns.SOA{Hdr: dns.RR_Header{Name: dns.Fqdn(z.name), Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns: "ns.example.", Mbox: "hostmaster.example.", Serial: 1, Refresh: 1, Retry: 1, Expire: 1, Minttl: 1}
+}
+
+func TestAnalyzeDNSSECChain(t *testing.T) {
+	now := time.Now()
+	valid := func() (time.Time, time.Time) { return now.Add(-time.Hour), now.Add(24 * time.Hour) }
+	root, org, example := newTestZone(t, "."), newTestZone(t, "org"), newTestZone(t, "example.org")
+
+	// answers builds the responses of a correctly signed hierarchy, which the cases then break
+	answers := func() map[string][]dns.RR {
+		from, until := valid()
+		m := map[string][]dns.RR{}
+		for _, z := range []testZone{root, org, example} {
+			m[z.name+"/DNSKEY"] = z.sign(t, from, until, z.key)
+			m[z.name+"/SOA"] = z.sign(t, from, until, z.soa())
+		}
+		m["org/DS"] = root.sign(t, from, until, org.key.ToDS(dns.SHA256))
+		m["example.org/DS"] = org.sign(t, from, until, example.key.ToDS(dns.SHA256))
+		return m
+	}
+	query := func(m map[string][]dns.RR) dnssecQuery {
+		return func(name string, rrType uint16) (*dns.Msg, error) {
+			r := &dns.Msg{}
+			r.Answer = m[name+"/"+dns.TypeToString[rrType]]
+			return r, nil
+		}
+	}
+	find := func(probs []Problem, name string) (Problem, bool) {
+		for _, p := range probs {
+			if p.Name == name {
+				return p, true
+			}
+		}
+		return Problem{}, false
+	}
+
+	probs := analyzeDNSSECChain("www.example.org", query(answers()), now)
+	if len(probs) != 1 || !strings.Contains(probs[0].Detail, "example.org: secure") {
+		t.Fatalf("expected a secure chain, got: %v", probs)
+	}
+
+	m := answers()
+	delete(m, "example.org/DS")
+	delete(m, "example.org/DNSKEY")
+	if probs := analyzeDNSSECChain("example.org", query(m), now); len(probs) != 1 || !strings.Contains(probs[0].Detail, "unsigned") {
+		t.Errorf("expected an unsigned zone to be reported only in the debug problem, got: %v", probs)
+	}
+
+	m = answers()
+	from, until := valid()
+	m["example.org/DS"] = org.sign(t, from, until, newTestZone(t, "example.org").key.ToDS(dns.SHA256))
+	if p, ok := find(analyzeDNSSECChain("example.org", query(m), now), "DNSSECChainBroken"); !ok ||
+		!strings.Contains(p.Explanation, "zone example.org: none of the DS records") || !strings.Contains(p.Detail, "DNSKEY in example.org") {
+		t.Errorf("expected a DS mismatch at example.org, got: %v", p)
+	}
+
+	m = answers()
+	delete(m, "example.org/DNSKEY")
+	if p, ok := find(analyzeDNSSECChain("example.org", query(m), now), "DNSSECChainBroken"); !ok || !strings.Contains(p.Explanation, "no DNSKEY records") {
+		t.Errorf("expected missing DNSKEY records at example.org, got: %v", p)
+	}
+
+	m = answers()
+	expired := now.Add(-time.Hour)
+	m["example.org/SOA"] = example.sign(t, now.Add(-48*time.Hour), expired, example.soa())
+	if p, ok := find(analyzeDNSSECChain("example.org", query(m), now), "DNSSECChainBroken"); !ok ||
+		!strings.Contains(p.Explanation, "until "+expired.UTC().Format(time.RFC3339)) {
+		t.Errorf("expected the expiry of the SOA signature of example.org, got: %v", p)
+	}
+
+	m = answers()
+	ds := org.key.ToDS(dns.SHA256)
+	ds.Algorithm = 12 // ECC-GOST
+	m["org/DS"] = root.sign(t, from, until, ds)
+	if _, ok := find(analyzeDNSSECChain("example.org", query(m), now), "DNSSECAlgorithmUnsupported"); !ok {
+		t.Error("expected DS records with an unsupported algorithm to be reported")
+	}
+}
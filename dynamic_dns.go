@@ -0,0 +1,163 @@
+package letsdebug
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dynamicDNSSuffixes are the domains of popular dynamic DNS services, whose subdomains point at
+// home connections and are kept up to date by a client running on the user's network.
+var dynamicDNSSuffixes = []string{
+	"duckdns.org", "no-ip.com", "no-ip.org", "no-ip.biz", "ddns.net", "hopto.org", "zapto.org", "sytes.net",
+	"myftp.org", "dynu.net", "dynu.com", "dyndns.org", "dynv6.net", "freeddns.org", "mooo.com", "afraid.org",
+	"selfhost.de", "myfritz.net", "ddnss.de", "dedyn.io",
+}
+
+// regexResidentialPTR matches the reverse DNS names ISPs give to the addresses of home connections,
+// e.g. dynamic-203-0-113-7.isp.example or cpe-203-0-113-7.cable.example.
+var regexResidentialPTR = regexp.MustCompile(`(?i)(^|[.-])(dynamic|dyn|dsl|adsl|vdsl|xdsl|cable|pool|dhcp|ppp|pppoe|dialup|dial|broadband|residential|customer|cpe|ftth|fttx|home|client)([.-]|\d|$)`)
+
+// dynamicDNSEvidence is what is known about the address of a domain whose HTTP check timed out.
+type dynamicDNSEvidence struct {
+	Addresses []string
+	// The reverse DNS names of each address
+	PTRs map[string][]string
+	// The dynamic DNS service the domain belongs to, if any
+	Provider string
+	// The TTL of the A record, and when the zone was last changed according to its SOA serial, if known
+	TTL         uint32
+	ZoneChanged time.Time
+}
+
+// analyzeDynamicDNS is run once every checker has completed. When the HTTP check of an IPv4 address
+// timed out and the address belongs to a home connection, the usual cause is a dynamic DNS record
+// which is out of date, or a router which does not forward port 80, and this is explained explicitly.
+func analyzeDynamicDNS(ctx *scanContext, domain string, method ValidationMethod, probs []Problem) []Problem {
+	if method != HTTP01 {
+		return nil
+	}
+
+	ctx.evidenceMu.Lock()
+	httpResults := ctx.httpResults
+	ctx.evidenceMu.Unlock()
+
+	ev := dynamicDNSEvidence{PTRs: map[string][]string{}, Provider: dynamicDNSProvider(domain)}
+	for _, res := range httpResults {
+		if !res.IsZero() || res.IP.To4() == nil || !timedOut(probs, res.IP.String()) {
+			continue
+		}
+		addr := res.IP.String()
+		ev.Addresses = append(ev.Addresses, addr)
+		if rev, err := dns.ReverseAddr(addr); err == nil {
+			rrs, _ := ctx.Lookup(strings.TrimSuffix(rev, "."), dns.TypePTR)
+			for _, rr := range rrs {
+				if ptr, ok := rr.(*dns.PTR); ok {
+					ev.PTRs[addr] = append(ev.PTRs[addr], normalizeFqdn(ptr.Ptr))
+				}
+			}
+		}
+	}
+	if len(ev.Addresses) == 0 {
+		return nil
+	}
+
+	rrs, _ := ctx.Lookup(domain, dns.TypeA)
+	for _, rr := range rrs {
+		if a, ok := rr.(*dns.A); ok {
+			ev.TTL = a.Hdr.Ttl
+			break
+		}
+	}
+	if cut, err := findZoneCut(ctx, domain); err == nil {
+		rrs, _ := ctx.Lookup(cut.Zone, dns.TypeSOA)
+		for _, rr := range rrs {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ev.ZoneChanged, _ = soaSerialTime(soa.Serial, time.Now())
+			}
+		}
+	}
+
+	if p, ok := dynamicIPAddress(domain, ev, time.Now()); ok {
+		return []Problem{p}
+	}
+	return nil
+}
+
+// timedOut reports whether the HTTP check of the address failed with a timeout.
+func timedOut(probs []Problem, addr string) bool {
+	for _, p := range probs {
+		if p.Name == "ANotWorking" && strings.Contains(p.Explanation, "("+addr+")") &&
+			strings.Contains(strings.ToLower(p.Detail), "timeout") {
+			return true
+		}
+	}
+	return false
+}
+
+func dynamicDNSProvider(domain string) string {
+	for _, suffix := range dynamicDNSSuffixes {
+		if strings.HasSuffix(domain, "."+suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// soaSerialTime interprets an SOA serial number as the time the zone was last changed, for the
+// two conventions which encode it: a Unix timestamp, or the date as YYYYMMDDnn.
+func soaSerialTime(serial uint32, now time.Time) (time.Time, bool) {
+	if t := time.Unix(int64(serial), 0); t.After(now.AddDate(-10, 0, 0)) && !t.After(now.Add(24*time.Hour)) {
+		return t, true
+	}
+	if s := strconv.FormatUint(uint64(serial), 10); len(s) == 10 {
+		if t, err := time.Parse("20060102", s[:8]); err == nil && t.After(now.AddDate(-10, 0, 0)) && !t.After(now) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func dynamicIPAddress(domain string, ev dynamicDNSEvidence, now time.Time) (Problem, bool) {
+	var lines []string
+	if ev.Provider != "" {
+		lines = append(lines, fmt.Sprintf("%s is a subdomain of %s, a dynamic DNS service", domain, ev.Provider))
+	}
+	residential := ev.Provider != ""
+	for _, addr := range ev.Addresses {
+		for _, ptr := range ev.PTRs[addr] {
+			if regexResidentialPTR.MatchString(ptr) {
+				residential = true
+				lines = append(lines, fmt.Sprintf("The reverse DNS name of %s is %s, which looks like a home connection", addr, ptr))
+			}
+		}
+	}
+	if !residential {
+		return Problem{}, false
+	}
+
+	if ev.TTL > 0 {
+		lines = append(lines, fmt.Sprintf("The A record has a TTL of %d seconds, so an update made longer ago than that should already be visible",
+			ev.TTL))
+	}
+	if !ev.ZoneChanged.IsZero() {
+		lines = append(lines, fmt.Sprintf("According to the serial number of its SOA record, the zone was last changed at %s (%s ago)",
+			ev.ZoneChanged.UTC().Format(time.RFC3339), now.Sub(ev.ZoneChanged).Round(time.Minute)))
+	}
+
+	return Problem{
+		Name: "DynamicIPAddress",
+		Explanation: fmt.Sprintf(`The request to %s timed out, and its address (%s) appears to belong to a home internet connection. `+
+			`This usually means one of two things: the connection's IP address has changed and the dynamic DNS record has not `+
+			`been updated yet (check that your dynamic DNS client is running and compare the record with your current public IP `+
+			`address), or the record is up to date but your router does not forward port 80 to the server running your ACME `+
+			`client. Some ISPs also block inbound connections to port 80, in which case the dns-01 challenge is the way to go.`,
+			domain, strings.Join(ev.Addresses, ", ")),
+		Detail:   strings.Join(lines, "\n"),
+		Severity: SeverityWarning,
+	}, true
+}
@@ -0,0 +1,58 @@
+package letsdebug
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDynamicIPAddress(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	ev := dynamicDNSEvidence{
+		Addresses: []string{"203.0.113.7"},
+		PTRs:      map[string][]string{"203.0.113.7": {"static-203-0-113-7.isp.example"}},
+	}
+	if _, ok := dynamicIPAddress("example.org", ev, now); ok {
+		t.Fatal("expected a server with a static address not to be reported")
+	}
+
+	ev.PTRs["203.0.113.7"] = []string{"dynamic-203-0-113-7.isp.example"}
+	ev.TTL = 60
+	ev.ZoneChanged = now.Add(-3 * time.Hour)
+	p, ok := dynamicIPAddress("example.org", ev, now)
+	if !ok || p.Name != "DynamicIPAddress" || p.Severity != SeverityWarning {
+		t.Fatalf("expected a home connection to be reported, got: %+v", p)
+	}
+	for _, want := range []string{"dynamic-203-0-113-7.isp.example", "TTL of 60 seconds", "(3h0m0s ago)"} {
+		if !strings.Contains(p.Detail, want) {
+			t.Errorf("expected detail to contain %q, got: %s", want, p.Detail)
+		}
+	}
+
+	p, ok = dynamicIPAddress("home.duckdns.org", dynamicDNSEvidence{Addresses: []string{"203.0.113.7"},
+		Provider: dynamicDNSProvider("home.duckdns.org")}, now)
+	if !ok || !strings.Contains(p.Detail, "duckdns.org, a dynamic DNS service") {
+		t.Fatalf("expected a dynamic DNS subdomain to be reported, got: %+v", p)
+	}
+}
+
+func TestSOASerialTime(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		serial uint32
+		want   time.Time
+		ok     bool
+	}{
+		{uint32(now.Add(-time.Hour).Unix()), now.Add(-time.Hour), true},
+		{2026101502, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), true},
+		{1, time.Time{}, false},
+		{2099010100, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := soaSerialTime(tt.serial, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("soaSerialTime(%d) = %v, %v; want %v, %v", tt.serial, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package letsdebug
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// registrarForwarder is a "domain forwarding" service run by a registrar, which answers HTTP requests
+// for any host and path with a redirect to another site, or with that site in a frame ("masking").
+// Neither serves the challenge file, so http-01 validation fails for as long as it is enabled.
+type registrarForwarder struct {
+	Product string
+	// Addresses the service answers on, which the A records of the domain point at when it is enabled
+	Addresses []string
+	// A response header which identifies the service, and a substring of its value
+	Header, HeaderValue string
+	// How to disable the service
+	Disable string
+}
+
+var registrarForwarders = []registrarForwarder{
+	{
+		Product:   "GoDaddy Domain Forwarding",
+		Addresses: []string{"3.33.130.190", "15.197.148.33"},
+		Disable: "In GoDaddy's Domain Portfolio, open the DNS settings of the domain, delete the rule under Forwarding, " +
+			"then point the A record of the domain at your own server.",
+	},
+	{
+		Product:     "Namecheap URL Redirect",
+		Header:      "X-Served-By",
+		HeaderValue: "Namecheap URL Forward",
+		Disable: "In Namecheap's Advanced DNS page for the domain, delete the URL Redirect Record for this host " +
+			"and add an A record pointing at your own server instead.",
+	},
+	{
+		Product:   "Gandi web forwarding",
+		Addresses: []string{"217.70.184.38"},
+		Disable: "In Gandi's admin interface, delete the web forwarding of the domain under Web Forwarding, " +
+			"then point the A record of the domain at your own server.",
+	},
+}
+
+// maskingPayloads are found in the responses of forwarding services which show another site in a frame.
+var maskingPayloads = [][]byte{[]byte("<frameset"), []byte("<FRAMESET"), []byte("<iframe"), []byte("<IFRAME")}
+
+// matches reports whether the response came from the forwarding service.
+func (f registrarForwarder) matches(res httpCheckResult) bool {
+	for _, addr := range f.Addresses {
+		if res.IP != nil && res.IP.Equal(net.ParseIP(addr)) {
+			return true
+		}
+	}
+	if f.Header == "" || len(res.Exchanges) == 0 {
+		return false
+	}
+	for _, v := range res.Exchanges[0].ResponseHeader.Values(f.Header) {
+		if strings.Contains(v, f.HeaderValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeRegistrarForwarding looks for responses from a registrar's domain forwarding service.
+func analyzeRegistrarForwarding(domain string, results []httpCheckResult) (Problem, bool) {
+	for _, res := range results {
+		for _, f := range registrarForwarders {
+			if !f.matches(res) {
+				continue
+			}
+			mode := "forwarding"
+			for _, needle := range maskingPayloads {
+				if bytes.Contains(res.Content, needle) {
+					mode = "masking (showing another site in a frame)"
+					break
+				}
+			}
+			return registrarForwarding(domain, f, mode, res), true
+		}
+	}
+	return Problem{}, false
+}
+
+func registrarForwarding(domain string, f registrarForwarder, mode string, res httpCheckResult) Problem {
+	return Problem{
+		Name: "RegistrarForwarding",
+		Explanation: fmt.Sprintf(`Requests to %s are answered by %s, which responds to every request for the domain with a `+
+			`redirect or a framed copy of another site. Let's Encrypt's http-01 validation request is answered the same way, `+
+			`so the challenge file on your server is never reached. Disable the forwarding and point the domain at your `+
+			`server, or use the dns-01 challenge instead.`, domain, f.Product),
+		Detail: fmt.Sprintf("The server at %s is %s, configured for %s.\nTo disable it: %s",
+			res.IP, f.Product, mode, f.Disable),
+		Severity: SeverityError,
+	}
+}
@@ -0,0 +1,36 @@
+package letsdebug
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeRegistrarForwarding(t *testing.T) {
+	if _, ok := analyzeRegistrarForwarding("example.org", []httpCheckResult{
+		{IP: net.ParseIP("192.0.2.1"), StatusCode: 404, Content: []byte("<iframe src=x>")},
+	}); ok {
+		t.Fatal("expected an ordinary server not to be reported")
+	}
+
+	p, ok := analyzeRegistrarForwarding("example.org", []httpCheckResult{
+		{IP: net.ParseIP("192.0.2.1"), StatusCode: 404},
+		{IP: net.ParseIP("15.197.148.33"), StatusCode: 200, Content: []byte(`<html><frameset><frame src="https://example.net/"></frameset></html>`)},
+	})
+	if !ok || p.Name != "RegistrarForwarding" || p.Severity != SeverityError {
+		t.Fatalf("expected GoDaddy forwarding to be reported, got: %+v", p)
+	}
+	if !strings.Contains(p.Detail, "GoDaddy Domain Forwarding") || !strings.Contains(p.Detail, "masking") {
+		t.Errorf("expected the product and masking to be named, got: %s", p.Detail)
+	}
+
+	p, ok = analyzeRegistrarForwarding("example.org", []httpCheckResult{{
+		IP:         net.ParseIP("192.0.2.2"),
+		StatusCode: 302,
+		Exchanges:  []httpExchange{{ResponseHeader: http.Header{"X-Served-By": []string{"Namecheap URL Forward"}}}},
+	}})
+	if !ok || !strings.Contains(p.Detail, "Namecheap URL Redirect") || strings.Contains(p.Detail, "masking") {
+		t.Fatalf("expected Namecheap forwarding to be reported, got: %+v", p)
+	}
+}
@@ -1,14 +1,12 @@
 package letsdebug
 
 import (
+	"bytes"
 	"context"
 	"crypto/x509"
 	"database/sql"
-	"encoding/pem"
 	"encoding/xml"
 	"errors"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"golang.org/x/net/idna"
 	"golang.org/x/text/unicode/norm"
 	"net"
@@ -32,7 +30,6 @@ import (
 	// Driver for crtwatch/ratelimitChecker
 	_ "github.com/lib/pq"
 	"github.com/miekg/dns"
-	"github.com/weppos/publicsuffix-go/net/publicsuffix"
 	psl "github.com/weppos/publicsuffix-go/publicsuffix"
 )
 
@@ -148,7 +145,7 @@ func (c validDomainChecker) Check(ctx *scanContext, domain string, method Valida
 		}
 	}
 
-	rule := psl.DefaultList.Find(domain, &psl.FindOptions{IgnorePrivate: true, DefaultRule: nil})
+	rule := publicSuffixList().Find(domain, &psl.FindOptions{IgnorePrivate: true, DefaultRule: nil})
 	if rule == nil {
 		probs = append(probs, invalidDomain(domain, "Domain doesn't end in a public TLD"))
 		return probs, nil
@@ -171,7 +168,7 @@ type domainExistsChecker struct{}
 func (c domainExistsChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
 	var probs []Problem
 
-	domainName, err := psl.Parse(domain)
+	domainName, err := psl.ParseFromListWithOptions(publicSuffixList(), domain, psl.DefaultFindOptions)
 	if err != nil {
 		probs = append(probs, invalidDomain(domain, "Cannot find registered domain via publix suffix list"))
 		return probs, nil
@@ -222,6 +219,8 @@ func (c caaChecker) Check(ctx *scanContext, domain string, method ValidationMeth
 		var issue []*dns.CAA
 		var issuewild []*dns.CAA
 		var criticalUnknown []*dns.CAA
+		var unknown []*dns.CAA
+		var mistakes []string
 
 		for _, rr := range rrs {
 			caaRr, ok := rr.(*dns.CAA)
@@ -239,6 +238,11 @@ func (c caaChecker) Check(ctx *scanContext, domain string, method ValidationMeth
 			default:
 				if caaRr.Flag == 1 {
 					criticalUnknown = append(criticalUnknown, caaRr)
+					break
+				}
+				unknown = append(unknown, caaRr)
+				if mistake := caaTagMistake(caaRr); mistake != "" {
+					mistakes = append(mistakes, fmt.Sprintf("%s\n  %s", caaRr.String(), mistake))
 				}
 			}
 		}
@@ -247,24 +251,28 @@ func (c caaChecker) Check(ctx *scanContext, domain string, method ValidationMeth
 			"CAA records control authorization for certificate authorities to issue certificates for a domain",
 			collateRecords(append(issue, issuewild...))))
 
+		if len(unknown) > 0 {
+			probs = append(probs, debugProblem("CAAUnrecognizedTags",
+				"CAA records with tags that Let's Encrypt ignores, such as contact information for the domain",
+				collateRecords(unknown)))
+		}
+		if len(mistakes) > 0 {
+			probs = append(probs, caaUnrecognizedTag(domain, mistakes))
+		}
+
 		if len(criticalUnknown) > 0 {
 			probs = append(probs, caaCriticalUnknown(domain, wildcard, criticalUnknown))
 			return probs, nil
 		}
 
-		if len(issue) == 0 && !wildcard {
+		records, other := caaGoverningRecords(wildcard, issue, issuewild)
+		if len(records) == 0 || caaAllowsLetsEncrypt(records) {
 			return probs, nil
 		}
 
-		records := issue
-		if wildcard && len(issuewild) > 0 {
-			records = issuewild
-		}
-
-		for _, r := range records {
-			if extractIssuerDomain(r.Value) == "letsencrypt.org" {
-				return probs, nil
-			}
+		if len(other) > 0 && caaAllowsLetsEncrypt(other) {
+			probs = append(probs, caaIssueWildConflict(domain, wildcard, issue, issuewild))
+			return probs, nil
 		}
 
 		probs = append(probs, caaIssuanceNotAllowed(domain, wildcard, records))
@@ -273,8 +281,11 @@ func (c caaChecker) Check(ctx *scanContext, domain string, method ValidationMeth
 
 	// recurse up to the public suffix domain until a caa record is found
 	// a.b.c.com -> b.c.com -> c.com until
-	if ps, _ := publicsuffix.PublicSuffix(domain); domain != ps && ps != "" {
+	if ps := publicSuffix(domain); domain != ps && ps != "" {
 		splitDomain := strings.SplitN(domain, ".", 2)
+		if !ctx.Visit("CAA check of " + splitDomain[1]) {
+			return probs, nil
+		}
 
 		parentProbs, err := c.Check(ctx, splitDomain[1], method)
 		if err != nil {
@@ -422,12 +433,36 @@ var statusioSignificantStatuses = map[int]bool{
 	600: true, // Security Event
 }
 
+// statusioCacheTTL is how long a status.io response is reused across scans.
+const statusioCacheTTL = time.Minute
+
+var statusioCache = newProblemCache()
+
 func (c statusioChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if ctx.offline {
+		return nil, offlineSkipped(statusioBreaker.name)
+	}
+	if probs, ok := statusioCache.Get("status"); ok {
+		return probs, nil
+	}
+	if p, ok := statusioBreaker.Allow(); !ok {
+		return []Problem{p}, nil
+	}
+
+	probs, err := c.check()
+	if err == nil {
+		statusioCache.Set("status", probs, statusioCacheTTL)
+	}
+	return probs, err
+}
+
+func (c statusioChecker) check() ([]Problem, error) {
 	var probs []Problem
 
 	resp, err := http.Get("https://api.status.io/1.0/status/55957a99e800baa4470002da")
 	if err != nil {
 		// some connectivity errors with status.io is probably not worth reporting
+		statusioBreaker.Failure(err)
 		return probs, nil
 	}
 	defer resp.Body.Close()
@@ -443,8 +478,10 @@ func (c statusioChecker) Check(ctx *scanContext, domain string, method Validatio
 	}{}
 
 	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		statusioBreaker.Failure(err)
 		return probs, fmt.Errorf("error decoding status.io api response: %v", err)
 	}
+	statusioBreaker.Success()
 
 	if statusioSignificantStatuses[apiResp.Result.StatusOverall.StatusCode] {
 		probs = append(probs, statusioNotOperational(apiResp.Result.StatusOverall.Status, apiResp.Result.StatusOverall.Updated))
@@ -466,6 +503,116 @@ func statusioNotOperational(status string, updated time.Time) Problem {
 	}
 }
 
+// safeBrowsingChecker looks the domain up against the Google Safe Browsing API, which
+// Let's Encrypt has historically consulted before issuing a certificate.
+// It is only run when an API key is configured.
+type safeBrowsingChecker struct{}
+
+// safeBrowsingLookupURL is a variable so that it may be pointed at a test server.
+var safeBrowsingLookupURL = "https://safebrowsing.googleapis.com/v4/threatMatches:find?key="
+
+func (c safeBrowsingChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if ctx.safeBrowsingAPIKey == "" {
+		return nil, skipReason("No Google Safe Browsing API key was configured")
+	}
+	if ctx.offline {
+		return nil, offlineSkipped("Google Safe Browsing")
+	}
+
+	domain = strings.TrimPrefix(domain, "*.")
+
+	type threatEntry struct {
+		URL string `json:"url"`
+	}
+	lookupReq := struct {
+		Client struct {
+			ClientID      string `json:"clientId"`
+			ClientVersion string `json:"clientVersion"`
+		} `json:"client"`
+		ThreatInfo struct {
+			ThreatTypes      []string      `json:"threatTypes"`
+			PlatformTypes    []string      `json:"platformTypes"`
+			ThreatEntryTypes []string      `json:"threatEntryTypes"`
+			ThreatEntries    []threatEntry `json:"threatEntries"`
+		} `json:"threatInfo"`
+	}{}
+	lookupReq.Client.ClientID = "letsdebug"
+	lookupReq.Client.ClientVersion = "1.0"
+	lookupReq.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	lookupReq.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	lookupReq.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	lookupReq.ThreatInfo.ThreatEntries = []threatEntry{
+		{URL: "http://" + domain + "/"},
+		{URL: "https://" + domain + "/"},
+	}
+
+	body, err := json.Marshal(lookupReq)
+	if err != nil {
+		return nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodPost,
+		safeBrowsingLookupURL+url.QueryEscape(ctx.safeBrowsingAPIKey), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return []Problem{
+			internalProblem(fmt.Sprintf("Failed to query the Safe Browsing API: %v", err), SeverityDebug),
+		}, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return []Problem{
+			internalProblem(fmt.Sprintf("The Safe Browsing API responded with HTTP %s", resp.Status), SeverityDebug),
+		}, nil
+	}
+
+	lookupResp := struct {
+		Matches []struct {
+			ThreatType   string      `json:"threatType"`
+			PlatformType string      `json:"platformType"`
+			Threat       threatEntry `json:"threat"`
+		} `json:"matches"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&lookupResp); err != nil {
+		return []Problem{
+			internalProblem(fmt.Sprintf("Failed to decode the Safe Browsing API response: %v", err), SeverityDebug),
+		}, nil
+	}
+	ctx.recordSafeBrowsingChecked()
+
+	if len(lookupResp.Matches) == 0 {
+		return []Problem{debugProblem("SafeBrowsing", "The domain was looked up in Google Safe Browsing",
+			fmt.Sprintf("No threats were found for %s", domain))}, nil
+	}
+
+	var matches []string
+	for _, m := range lookupResp.Matches {
+		matches = append(matches, fmt.Sprintf("%s (threat=%s, platform=%s)", m.Threat.URL, m.ThreatType, m.PlatformType))
+	}
+
+	return []Problem{unsafeDomain(domain, matches)}, nil
+}
+
+func unsafeDomain(domain string, matches []string) Problem {
+	return Problem{
+		Name: "UnsafeDomain",
+		Explanation: fmt.Sprintf(`%s is flagged by Google Safe Browsing as hosting unsafe content. `+
+			`Let's Encrypt may refuse to issue certificates for domains on this list. You can review the status of your site `+
+			`and request a review via Google Search Console (https://search.google.com/search-console/security-issues).`, domain),
+		Detail:   strings.Join(matches, "\n"),
+		Severity: SeverityError,
+	}
+}
+
 type crtList map[string]*x509.Certificate
 
 // FindCommonPSLCertificates finds any certificates which contain any DNSName
@@ -475,7 +622,7 @@ func (l crtList) FindWithCommonRegisteredDomain(registeredDomain string) sortedC
 
 	for _, cert := range l {
 		for _, name := range cert.DNSNames {
-			if nameRegDomain, _ := publicsuffix.EffectiveTLDPlusOne(name); nameRegDomain == registeredDomain {
+			if nameRegDomain, _ := effectiveTLDPlusOne(name); nameRegDomain == registeredDomain {
 				out = append(out, cert)
 				break
 			}
@@ -563,57 +710,20 @@ ORDER BY le.ENTRY_TIMESTAMP DESC;`
 // Pointer receiver because we're keeping state across runs
 func (c *rateLimitChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
 	if os.Getenv("LETSDEBUG_DISABLE_CERTWATCH") != "" {
-		return nil, errNotApplicable
+		return nil, skipReason("Disabled by LETSDEBUG_DISABLE_CERTWATCH")
 	}
 
 	domain = strings.TrimPrefix(domain, "*.")
 
-	db, err := sql.Open("postgres", "user=guest dbname=certwatch host=crt.sh sslmode=disable connect_timeout=5")
-	if err != nil {
-		return []Problem{
-			internalProblem(fmt.Sprintf("Failed to connect to certwatch database to check rate limits: %v", err), SeverityDebug),
-		}, nil
-	}
-	defer db.Close()
-
 	// Since we are checking rate limits, we need to query the Registered Domain
 	// for the domain in question
-	registeredDomain, _ := publicsuffix.EffectiveTLDPlusOne(domain)
+	registeredDomain, _ := effectiveTLDPlusOne(domain)
 
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Avoiding using a prepared statement here because it's being weird with crt.sh
-	q := fmt.Sprintf(rateLimitCheckerQuery,
-		registeredDomain, registeredDomain, time.Now().Add(-168*time.Hour).Format(time.RFC3339))
-	rows, err := db.QueryContext(timeoutCtx, q)
-	if err != nil && err != sql.ErrNoRows {
-		return []Problem{
-			internalProblem(fmt.Sprintf("Failed to query certwatch database to check rate limits: %v", err), SeverityDebug),
-		}, nil
-	}
-
-	probs := []Problem{}
-
-	// Read in the DER-encoded certificates
-	certs := crtList{}
-	var certBytes []byte
-	for rows.Next() {
-		if err := rows.Scan(&certBytes); err != nil {
-			probs = append(probs, internalProblem(fmt.Sprintf("Failed to query certwatch database while checking rate limits: %v", err), SeverityDebug))
-			break
-		}
-		crt, err := x509.ParseCertificate(certBytes)
-		if err != nil {
-			probs = append(probs, internalProblem(fmt.Sprintf("Failed to parse certificate while checking rate limits: %v", err), SeverityDebug))
-			continue
-		}
-		certs[crt.SerialNumber.String()] = crt
-	}
-	if err := rows.Err(); err != nil {
-		return []Problem{
-			internalProblem(fmt.Sprintf("Failed to query certwatch database to check rate limits: %v", err), SeverityDebug),
-		}, nil
+	certs, probs, err := ctx.recentCertificates(registeredDomain)
+	if errors.Is(err, errNotApplicable) {
+		return nil, err
+	} else if err != nil {
+		return []Problem{internalProblem(err.Error(), SeverityDebug)}, nil
 	}
 
 	var debug string
@@ -655,8 +765,114 @@ func (c *rateLimitChecker) Check(ctx *scanContext, domain string, method Validat
 	return probs, nil
 }
 
+// certificateMemo shares the certificates found by crt.sh for each registered domain between the
+// scans of the identifiers of a single order, so that each registered domain is only queried once.
+type certificateMemo struct {
+	mu      sync.Mutex
+	entries map[string]*certificateMemoEntry
+	// offline is set when crt.sh must not be queried, see Options.Offline
+	offline bool
+}
+
+type certificateMemoEntry struct {
+	once  sync.Once
+	certs crtList
+	probs []Problem
+	err   error
+}
+
+func newCertificateMemo() *certificateMemo {
+	return &certificateMemo{entries: map[string]*certificateMemoEntry{}}
+}
+
+func (m *certificateMemo) get(registeredDomain string) (crtList, []Problem, error) {
+	if m.offline {
+		return nil, nil, offlineSkipped(crtshBreaker.name)
+	}
+
+	m.mu.Lock()
+	entry, ok := m.entries[registeredDomain]
+	if !ok {
+		entry = &certificateMemoEntry{}
+		m.entries[registeredDomain] = entry
+	}
+	m.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.certs, entry.probs, entry.err = queryRecentCertificates(registeredDomain)
+	})
+	return entry.certs, entry.probs, entry.err
+}
+
+// queryRecentCertificates fetches the certificates issued by Let's Encrypt during the last week
+// for registeredDomain from crt.sh. Certificates which could not be read are reported as debug problems.
+func queryRecentCertificates(registeredDomain string) (crtList, []Problem, error) {
+	if p, ok := crtshBreaker.Allow(); !ok {
+		return nil, nil, errors.New(p.Detail)
+	}
+
+	start := time.Now()
+	certs, probs, rows, err := queryCertwatch(registeredDomain)
+	observeQuery(crtshBreaker.name, start, rows, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certs, probs, nil
+}
+
+// queryCertwatch runs rateLimitCheckerQuery, and returns how many rows it returned alongside the certificates.
+func queryCertwatch(registeredDomain string) (crtList, []Problem, int, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db, err := CertwatchDB(timeoutCtx)
+	if err != nil {
+		crtshBreaker.Failure(err)
+		return nil, nil, 0, fmt.Errorf("Failed to connect to certwatch database to check rate limits: %v", err)
+	}
+
+	// Avoiding using a prepared statement here because it's being weird with crt.sh
+	q := fmt.Sprintf(rateLimitCheckerQuery,
+		registeredDomain, registeredDomain, time.Now().Add(-168*time.Hour).Format(time.RFC3339))
+	rows, err := db.QueryContext(timeoutCtx, q)
+	if err != nil && err != sql.ErrNoRows {
+		CertwatchQueryFailed(db, err)
+		crtshBreaker.Failure(err)
+		return nil, nil, 0, fmt.Errorf("Failed to query certwatch database to check rate limits: %v", err)
+	}
+	defer rows.Close()
+
+	probs := []Problem{}
+
+	// Read in the DER-encoded certificates
+	certs := crtList{}
+	var certBytes []byte
+	var n int
+	for rows.Next() {
+		n++
+		if err := rows.Scan(&certBytes); err != nil {
+			probs = append(probs, internalProblem(fmt.Sprintf("Failed to query certwatch database while checking rate limits: %v", err), SeverityDebug))
+			break
+		}
+		crt, err := x509.ParseCertificate(certBytes)
+		if err != nil {
+			probs = append(probs, internalProblem(fmt.Sprintf("Failed to parse certificate while checking rate limits: %v", err), SeverityDebug))
+			continue
+		}
+		certs[crt.SerialNumber.String()] = crt
+	}
+	if err := rows.Err(); err != nil {
+		CertwatchQueryFailed(db, err)
+		crtshBreaker.Failure(err)
+		return nil, nil, n, fmt.Errorf("Failed to query certwatch database to check rate limits: %v", err)
+	}
+	crtshBreaker.Success()
+
+	return certs, probs, n, nil
+}
+
 func rateLimited(domain, detail string) Problem {
-	registeredDomain, _ := publicsuffix.EffectiveTLDPlusOne(domain)
+	registeredDomain, _ := effectiveTLDPlusOne(domain)
 	return Problem{
 		Name: "RateLimit",
 		Explanation: fmt.Sprintf(`%s is currently affected by Let's Encrypt-based rate limits (https://letsencrypt.org/docs/rate-limits/). `+
@@ -667,22 +883,15 @@ func rateLimited(domain, detail string) Problem {
 	}
 }
 
-var (
-	stagingFailures = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: "letsdebug",
-			Name:      "staging_tests_failed_total",
-			Help:      "The total number of Let's Encrypt staging submissions encountering internal errors",
-		},
-		[]string{"method"})
-)
-
 // acmeStagingChecker tries to create an authorization on
 // Let's Encrypt's staging server and parse the error urn
 // to see if there's anything interesting reported.
 type acmeStagingChecker struct {
-	client   acme.Client
-	account  acme.Account
+	// clients holds one ACME client per directory, see Options.ACMEDirectory
+	clients map[string]acme.Client
+	// pools holds one account pool per directory and configured set of account files, so
+	// that the backoff state of each pool survives scans with different Options.
+	pools    map[string]*stagingAccountPool
 	clientMu sync.Mutex
 }
 
@@ -694,66 +903,136 @@ func ConfigureAcmeClient() acme.OptionFunc {
 	}
 }
 
-func (c *acmeStagingChecker) buildAcmeClient() error {
-	cl, err := acme.NewClient("https://acme-staging-v02.api.letsencrypt.org/directory", ConfigureAcmeClient())
-	if err != nil {
-		return err
+// setup builds the ACME client for the configured directory, if it has not already been, and
+// returns it with the account pool for the configured account files, loading it on first use.
+// Must be called with clientMu held.
+func (c *acmeStagingChecker) setup(ctx *scanContext) (acme.Client, *stagingAccountPool, error) {
+	directory := stagingDirectory(ctx.acmeDirectory)
+	clientKey := fmt.Sprintf("%s|%t", directory, ctx.acmeInsecureSkipVerify)
+	cl, ok := c.clients[clientKey]
+	if !ok {
+		opts := []acme.OptionFunc{ConfigureAcmeClient()}
+		if ctx.acmeInsecureSkipVerify {
+			opts = append(opts, acme.WithInsecureSkipVerify())
+		}
+		var err error
+		if cl, err = acme.NewClient(directory, opts...); err != nil {
+			return cl, nil, err
+		}
+		if c.clients == nil {
+			c.clients = map[string]acme.Client{}
+		}
+		c.clients[clientKey] = cl
 	}
 
-	regrPath := os.Getenv("LETSDEBUG_ACMESTAGING_ACCOUNTFILE")
-	if regrPath == "" {
-		regrPath = "acme-account.json"
-	}
-	buf, err := os.ReadFile(regrPath)
-	if err != nil {
-		return err
+	// Accounts only exist at the directory they were registered with
+	paths := stagingAccountFiles(ctx.stagingAccountFiles)
+	poolKey := directory + "|" + strings.Join(paths, string(os.PathListSeparator))
+	if pool, ok := c.pools[poolKey]; ok {
+		return cl, pool, nil
 	}
 
-	var out struct {
-		PEM string `json:"pem"`
-		URL string `json:"url"`
+	pool, err := newStagingAccountPool(paths)
+	if err != nil {
+		return cl, nil, err
 	}
-	if err := json.Unmarshal(buf, &out); err != nil {
-		return err
+	if c.pools == nil {
+		c.pools = map[string]*stagingAccountPool{}
 	}
+	c.pools[poolKey] = pool
 
-	block, _ := pem.Decode([]byte(out.PEM))
-	pk, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return err
-	}
+	return cl, pool, nil
+}
 
-	c.account = acme.Account{PrivateKey: pk, URL: out.URL}
-	c.client = cl
+// stagingCacheTTL is the longest that a staging result may be retained for reuse
+// via Options.StagingResultsMaxAge.
+const stagingCacheTTL = time.Hour
 
-	return nil
-}
+var stagingCache = newProblemCache()
 
 func (c *acmeStagingChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
 	if os.Getenv("LETSDEBUG_DISABLE_ACMESTAGING") != "" {
-		return nil, errNotApplicable
+		return nil, skipReason("Disabled by LETSDEBUG_DISABLE_ACMESTAGING")
+	}
+	if ctx.offline {
+		return nil, offlineSkipped(stagingBreaker.name)
 	}
 
-	c.clientMu.Lock()
-	if c.account.PrivateKey == nil {
-		if err := c.buildAcmeClient(); err != nil {
-			c.clientMu.Unlock()
-			stagingFailures.With(prometheus.Labels{"method": string(method)}).Inc()
-			return []Problem{
-				internalProblem(fmt.Sprintf("Couldn't setup Let's Encrypt staging checker, skipping: %v", err), SeverityWarning),
-			}, nil
+	cacheKey := stagingDirectory(ctx.acmeDirectory) + "|" + string(method) + "|" + domain
+	if ctx.stagingResultsMaxAge > 0 {
+		if entry, ok := stagingCache.GetFresh(cacheKey, ctx.stagingResultsMaxAge); ok {
+			if entry.Challenge != nil {
+				ctx.recordStagingChallenge(*entry.Challenge)
+			}
+			return append(entry.Problems, debugProblem("LetsEncryptStagingCached",
+				"The Let's Encrypt staging result was reused from a recent test of this domain",
+				fmt.Sprintf("Results up to %v old are reused", ctx.stagingResultsMaxAge))), nil
 		}
 	}
+
+	probs, err := c.check(ctx, domain, method)
+	if err == nil && !hasInternalProblem(probs) {
+		ctx.evidenceMu.Lock()
+		chal := ctx.stagingChallenge
+		ctx.evidenceMu.Unlock()
+		stagingCache.SetEntry(cacheKey, problemCacheEntry{Problems: probs, Challenge: chal}, stagingCacheTTL)
+	}
+	return probs, err
+}
+
+func (c *acmeStagingChecker) check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	c.clientMu.Lock()
+	client, pool, err := c.setup(ctx)
+	if err != nil {
+		c.clientMu.Unlock()
+		currentMetrics().Count(metricStagingFailures, map[string]string{"method": string(method)}, 1)
+		return []Problem{
+			internalProblem(fmt.Sprintf("Couldn't setup Let's Encrypt staging checker, skipping: %v", err), SeverityWarning),
+		}, nil
+	}
 	c.clientMu.Unlock()
 
+	if p, ok := stagingBreaker.Allow(); !ok {
+		return []Problem{p}, nil
+	}
+
 	probs := []Problem{}
 
-	order, err := c.client.NewOrder(c.account, []acme.Identifier{{Type: "dns", Value: domain}})
+	// Rotate through the account pool until we find an account which isn't rate limited
+	var order acme.Order
+	var acct *stagingAccount
+	for attempt := 0; attempt < pool.Len(); attempt++ {
+		var ok bool
+		if acct, ok = pool.Acquire(); !ok {
+			break
+		}
+		order, err = client.NewOrder(acct.Account, []acme.Identifier{{Type: "dns", Value: domain}})
+		if err != nil && isAccountRateLimited(err) {
+			pool.Backoff(acct)
+			acct = nil
+			continue
+		}
+		break
+	}
+	if acct == nil {
+		return []Problem{
+			internalProblem("All Let's Encrypt staging accounts are currently rate limited, skipping", SeverityDebug),
+		}, nil
+	}
+	if err == nil {
+		pool.Succeeded(acct)
+		stagingBreaker.Success()
+	}
+
 	if err != nil {
-		if p, stagingBroken := translateAcmeError(domain, err); p.Name != "" {
-			if stagingBroken {
-				stagingFailures.With(prometheus.Labels{"method": string(method)}).Inc()
-			}
+		p, stagingBroken := translateAcmeError(domain, err, ctx.checkedSafeBrowsing())
+		if stagingBroken {
+			stagingBreaker.Failure(err)
+			currentMetrics().Count(metricStagingFailures, map[string]string{"method": string(method)}, 1)
+		} else {
+			stagingBreaker.Success()
+		}
+		if p.Name != "" {
 			probs = append(probs, p)
 		}
 		probs = append(probs, debugProblem("LetsEncryptStaging", "Order creation error", err.Error()))
@@ -774,18 +1053,20 @@ func (c *acmeStagingChecker) Check(ctx *scanContext, domain string, method Valid
 		probsMu.Lock()
 		defer probsMu.Unlock()
 
-		stagingFailures.With(prometheus.Labels{"method": string(method)}).Inc()
+		stagingBreaker.Failure(err)
+		currentMetrics().Count(metricStagingFailures, map[string]string{"method": string(method)}, 1)
 		probs = append(probs, internalProblem("An unknown problem occurred while performing a test "+
 			"authorization against the Let's Encrypt staging service: "+err.Error(), SeverityWarning))
 	}
 
 	authzFailures := []string{}
+	authzIntrospections := []string{}
 
 	for _, authzURL := range order.Authorizations {
 		go func(authzURL string) {
 			defer wg.Done()
 
-			authz, err := c.client.FetchAuthorization(c.account, authzURL)
+			authz, err := client.FetchAuthorization(acct.Account, authzURL)
 			if err != nil {
 				unhandledError(err)
 				return
@@ -797,15 +1078,29 @@ func (c *acmeStagingChecker) Check(ctx *scanContext, domain string, method Valid
 				return
 			}
 
-			if _, err := c.client.UpdateChallenge(c.account, chal); err != nil {
+			if _, err := client.UpdateChallenge(acct.Account, chal); err != nil {
+				// What Boulder saw (for dns-01: which TXT values and resolvers, for http-01: which
+				// addresses it tried and which URLs it followed) is the most useful piece of information
+				// we can provide, so fetch the failed authorization again.
+				var introspection string
+				if stagingChal, err := fetchStagingChallenge(client, acct.Account, authzURL, method); err == nil {
+					introspection = stagingChal.describe()
+					ctx.recordStagingChallenge(stagingChal)
+				} else {
+					introspection = fmt.Sprintf("Couldn't fetch the failed authorization: %v", err)
+				}
+
 				probsMu.Lock()
-				if p, stagingBroken := translateAcmeError(domain, err); p.Name != "" {
+				if p, stagingBroken := translateAcmeError(domain, err, ctx.checkedSafeBrowsing()); p.Name != "" {
 					if stagingBroken {
-						stagingFailures.With(prometheus.Labels{"method": string(method)}).Inc()
+						currentMetrics().Count(metricStagingFailures, map[string]string{"method": string(method)}, 1)
 					}
 					probs = append(probs, p)
 				}
 				authzFailures = append(authzFailures, err.Error())
+				if introspection != "" {
+					authzIntrospections = append(authzIntrospections, introspection)
+				}
 				probsMu.Unlock()
 			}
 		}(authzURL)
@@ -813,6 +1108,12 @@ func (c *acmeStagingChecker) Check(ctx *scanContext, domain string, method Valid
 
 	wg.Wait()
 
+	if len(authzIntrospections) > 0 {
+		probs = append(probs, debugProblem("LetsEncryptStagingAuthz",
+			fmt.Sprintf("What Let's Encrypt saw while validating the failed %s challenge for %s", method, domain),
+			strings.Join(authzIntrospections, "\n\n")))
+	}
+
 	if len(authzFailures) > 0 {
 		probs = append(probs, debugProblem("LetsEncryptStaging",
 			fmt.Sprintf("Challenge update failures for %s in order %s", domain, order.URL),
@@ -824,12 +1125,25 @@ func (c *acmeStagingChecker) Check(ctx *scanContext, domain string, method Valid
 	return probs, nil
 }
 
-func translateAcmeError(domain string, err error) (problem Problem, stagingBroken bool) {
+// translateAcmeError converts an error from the staging service into a Problem, if it is worth reporting.
+// checkedSafeBrowsing should be set when safeBrowsingChecker has successfully looked the domain up, in which
+// case Safe Browsing rejections are left to its dedicated UnsafeDomain problem.
+func translateAcmeError(domain string, err error, checkedSafeBrowsing bool) (problem Problem, stagingBroken bool) {
+	defer func() {
+		if !problem.IsZero() {
+			problem.Err = err
+		}
+	}()
+
 	var acmeErr acme.Problem
 	if errors.As(err, &acmeErr) {
 		urn := strings.TrimPrefix(acmeErr.Type, "urn:ietf:params:acme:error:")
-		switch urn {
-		case "rejectedIdentifier", "unknownHost", "rateLimited", "caa", "dns", "connection":
+		// Unauthorized is what we expect, except when VA OR RA is checking Google Safe Browsing (groan)
+		if urn == "unauthorized" && !checkedSafeBrowsing && strings.Contains(acmeErr.Detail, "considered an unsafe domain") {
+			return letsencryptProblem(domain, acmeErr.Detail, SeverityError), false
+		}
+		switch acmeErrorsData.Get()[urn] {
+		case acmeErrorReport:
 			// Boulder can send error:dns when _acme-challenge is NXDOMAIN, which is
 			// equivalent to unauthorized
 			if strings.Contains(acmeErr.Detail, "NXDOMAIN looking up TXT") {
@@ -837,16 +1151,9 @@ func translateAcmeError(domain string, err error) (problem Problem, stagingBroke
 			}
 			return letsencryptProblem(domain, acmeErr.Detail, SeverityError), false
 		// When something bad is happening on staging
-		case "serverInternal":
+		case acmeErrorStagingBroken:
 			return letsencryptProblem(domain,
 				fmt.Sprintf(`There may be internal issues on the staging service: %v`, acmeErr.Detail), SeverityWarning), true
-		// Unauthorized is what we expect, except for these exceptions that we should handle:
-		// - When VA OR RA is checking Google Safe Browsing (groan)
-		case "unauthorized":
-			if strings.Contains(acmeErr.Detail, "considered an unsafe domain") {
-				return letsencryptProblem(domain, acmeErr.Detail, SeverityError), false
-			}
-			return Problem{}, false
 		default:
 			return Problem{}, false
 		}
@@ -880,7 +1187,7 @@ func (c *ofacSanctionChecker) Check(ctx *scanContext, domain string, method Vali
 	c.muRefresh.RLock()
 	defer c.muRefresh.RUnlock()
 
-	rd, _ := publicsuffix.EffectiveTLDPlusOne(domain)
+	rd, _ := effectiveTLDPlusOne(domain)
 	for sanctionedRD := range c.domains {
 		if rd != sanctionedRD {
 			continue
@@ -1000,6 +1307,6 @@ func (c *ofacSanctionChecker) extractRegisteredDomain(d string) string {
 		}
 		d = u.Host
 	}
-	d, _ = publicsuffix.EffectiveTLDPlusOne(d)
+	d, _ = effectiveTLDPlusOne(d)
 	return d
 }
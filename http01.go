@@ -6,6 +6,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/miekg/dns"
 )
@@ -25,11 +26,12 @@ var (
 )
 
 // dnsAChecker checks if there are any issues in Unbound looking up the A and
-// AAAA records for a domain (such as DNSSEC issues or dead nameservers)
+// AAAA records for a domain (such as DNSSEC issues or dead nameservers), which
+// the http-01 and tls-alpn-01 methods connect to
 type dnsAChecker struct{}
 
 func (c dnsAChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
-	if method != HTTP01 {
+	if method == DNS01 {
 		return nil, errNotApplicable
 	}
 
@@ -60,7 +62,9 @@ func (c dnsAChecker) Check(ctx *scanContext, domain string, method ValidationMet
 	}
 
 	for _, rr := range aRRs {
-		if aRR, ok := rr.(*dns.A); ok && isAddressReserved(aRR.A) {
+		if aRR, ok := rr.(*dns.A); ok && isAddressCGNAT(aRR.A) {
+			probs = append(probs, cgnatAddress(domain, aRR.A.String()))
+		} else if ok && isAddressReserved(aRR.A) {
 			probs = append(probs, reservedAddress(domain, aRR.A.String()))
 		}
 	}
@@ -126,39 +130,53 @@ func (c httpAccessibilityChecker) Check(ctx *scanContext, domain string, method
 	// for the domain
 	allCheckResults := []httpCheckResult{}
 
-	var debug []string
+	var debug, headers []string
+
+	// The protocol misbinding probes run alongside the validation requests
+	misbindings := make(chan []protocolMisbinding, 1)
+	go func() {
+		if ctx.httpOptOutURL() != "" {
+			misbindings <- nil
+			return
+		}
+		misbindings <- probeProtocolMisbinding(domain, ips, ctx.httpPort, ctx.tlsPort)
+	}()
 
 	for _, ip := range ips {
 		res, prob := checkHTTP(ctx, domain, ip)
+		prob = analyzeHungConnection(ctx, domain, ip, res, prob)
 		allCheckResults = append(allCheckResults, res)
 		if !prob.IsZero() {
 			probs = append(probs, prob)
 		}
 		debug = append(debug, fmt.Sprintf("Request to: %s/%s, Result: %s, Issue: %s\nTrace:\n%s\n",
 			domain, ip.String(), res.String(), prob.Name, strings.Join(res.DialStack, "\n")))
-	}
-
-	// Filter out the servers that didn't respond at all
-	var nonZeroResults []httpCheckResult
-	for _, v := range allCheckResults {
-		if v.IsZero() {
-			continue
-		}
-		nonZeroResults = append(nonZeroResults, v)
-	}
-	if len(nonZeroResults) > 1 {
-		firstResult := nonZeroResults[0]
-		for _, otherResult := range nonZeroResults[1:] {
-			if firstResult.StatusCode != otherResult.StatusCode ||
-				firstResult.ServerHeader != otherResult.ServerHeader ||
-				firstResult.NumRedirects != otherResult.NumRedirects ||
-				firstResult.InitialStatusCode != otherResult.InitialStatusCode {
-				probs = append(probs, multipleIPAddressDiscrepancy(domain, firstResult, otherResult))
-			}
+		for _, exchange := range res.Exchanges {
+			headers = append(headers, fmt.Sprintf("* Using initial address %s\n%s", ip, exchange.String()))
 		}
 	}
 
+	ctx.recordHTTPResults(allCheckResults)
+
+	probs = append(probs, analyzeAddressConsistency(domain, allCheckResults)...)
+	probs = append(probs, analyzeResponseLatency(domain, allCheckResults)...)
+	probs = append(probs, analyzeRedirectSimulation(domain, allCheckResults)...)
+	probs = append(probs, analyzeProtocolMisbinding(domain, <-misbindings, allCheckResults)...)
+
 	probs = append(probs, debugProblem("HTTPCheck", "Requests made to the domain", strings.Join(debug, "\n")))
+	if len(headers) > 0 {
+		probs = append(probs, debugProblem("HTTPHeaders", "The headers of each request made to the domain and its response, with cookies "+
+			"and credentials redacted", strings.Join(headers, "\n\n")))
+	}
+
+	if ctx.redirectCertificates {
+		probs = append(probs, analyzeRedirectHops(domain, allCheckResults, nil, time.Now())...)
+	}
+
+	if p, ok := analyzeRegistrarForwarding(domain, allCheckResults); ok {
+		probs = append(probs, p)
+	}
+	probs = append(probs, analyzeACMEClients(domain, allCheckResults)...)
 
 	if res := isLikelyModemRouter(allCheckResults); !res.IsZero() {
 		probs = append(probs, Problem{
@@ -234,14 +252,72 @@ func reservedAddress(name, address string) Problem {
 	}
 }
 
-func multipleIPAddressDiscrepancy(domain string, result1, result2 httpCheckResult) Problem {
+func cgnatAddress(name, address string) Problem {
+	return Problem{
+		Name: "CGNATAddress",
+		Explanation: fmt.Sprintf(`The A record of %s points at an address in the shared address space (100.64.0.0/10) which `+
+			`ISPs use for carrier-grade NAT (CGNAT). This is usually the WAN address your router was given, but it is shared with `+
+			`other customers behind your ISP's NAT, and connections from the internet can't reach it, so no amount of port `+
+			`forwarding will make HTTP validation work. Use the DNS validation method instead, or ask your ISP for a public `+
+			`(possibly static) IPv4 address. If your connection has IPv6, an AAAA record for the server may also work.`, name),
+		Detail:   address,
+		Severity: SeverityFatal,
+	}
+}
+
+// analyzeAddressConsistency compares the responses from every address of the domain, since Let's Encrypt
+// may use any one of them, and flags each address whose response differs from that of the majority.
+// When there is no majority, the response seen first is taken as the reference.
+// Addresses which didn't respond at all are reported elsewhere.
+func analyzeAddressConsistency(domain string, results []httpCheckResult) []Problem {
+	var signatures []string
+	groups := map[string][]httpCheckResult{}
+	for _, res := range results {
+		if res.IsZero() {
+			continue
+		}
+		sig := res.responseSignature()
+		if _, ok := groups[sig]; !ok {
+			signatures = append(signatures, sig)
+		}
+		groups[sig] = append(groups[sig], res)
+	}
+	if len(signatures) < 2 {
+		return nil
+	}
+
+	majority := signatures[0]
+	for _, sig := range signatures[1:] {
+		if len(groups[sig]) > len(groups[majority]) {
+			majority = sig
+		}
+	}
+
+	var probs []Problem
+	for _, sig := range signatures {
+		if sig == majority {
+			continue
+		}
+		for _, res := range groups[sig] {
+			probs = append(probs, multipleIPAddressDiscrepancy(domain, res, groups[majority]))
+		}
+	}
+	return probs
+}
+
+func multipleIPAddressDiscrepancy(domain string, outlier httpCheckResult, majority []httpCheckResult) Problem {
+	var addresses []string
+	for _, res := range majority {
+		addresses = append(addresses, res.IP.String())
+	}
 	return Problem{
 		Name: "MultipleIPAddressDiscrepancy",
 		Explanation: fmt.Sprintf(`%s has multiple IP addresses in its DNS records. While they appear to be accessible on the network, `+
-			`we have detected that they produce differing results when sent an ACME HTTP validation request. This may indicate that `+
-			`some of the IP addresses may unintentionally point to different servers, which would cause validation to fail.`,
-			domain),
-		Detail:   fmt.Sprintf("%s vs %s", result1.String(), result2.String()),
+			`we have detected that %s produces a different result from %s when sent an ACME HTTP validation request. Let's Encrypt `+
+			`may connect to any of the addresses, so this may indicate that %s unintentionally points to a different server, `+
+			`which would cause validation to fail some of the time.`,
+			domain, outlier.IP, strings.Join(addresses, ", "), outlier.IP),
+		Detail:   fmt.Sprintf("%s vs %s", outlier.String(), majority[0].String()),
 		Severity: SeverityWarning,
 	}
 }
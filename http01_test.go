@@ -0,0 +1,83 @@
+package letsdebug
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeAddressConsistency(t *testing.T) {
+	result := func(ip string, status int, server string) httpCheckResult {
+		return httpCheckResult{IP: net.ParseIP(ip), StatusCode: status, InitialStatusCode: status, ServerHeader: server}
+	}
+
+	probs := analyzeAddressConsistency("example.org", []httpCheckResult{
+		result("192.0.2.1", 404, "nginx"),
+		result("192.0.2.2", 404, "nginx"),
+		result("192.0.2.3", 404, "nginx"),
+	})
+	if len(probs) != 0 {
+		t.Fatalf("expected consistent addresses to be accepted, got: %v", probs)
+	}
+
+	// The outlier is last rather than first, which the majority should see past
+	probs = analyzeAddressConsistency("example.org", []httpCheckResult{
+		result("192.0.2.1", 200, "Apache"),
+		result("192.0.2.2", 404, "nginx"),
+		result("192.0.2.3", 404, "nginx"),
+		{IP: net.ParseIP("192.0.2.4")},
+	})
+	if len(probs) != 1 || probs[0].Name != "MultipleIPAddressDiscrepancy" {
+		t.Fatalf("expected a single discrepancy, got: %v", probs)
+	}
+	if want := "192.0.2.1 produces a different result from 192.0.2.2, 192.0.2.3"; !strings.Contains(probs[0].Explanation, want) {
+		t.Fatalf("expected the outlier to be named, got: %s", probs[0].Explanation)
+	}
+
+	// Without a majority, the first response is the reference
+	probs = analyzeAddressConsistency("example.org", []httpCheckResult{
+		result("2001:db8::1", 404, "nginx"),
+		result("192.0.2.1", 200, "nginx"),
+	})
+	if len(probs) != 1 || !strings.Contains(probs[0].Explanation, "192.0.2.1 produces") {
+		t.Fatalf("expected the second address to be flagged, got: %v", probs)
+	}
+}
+
+func TestHTTPExchangeRedactsHeaders(t *testing.T) {
+	exchange := httpExchange{
+		Method:         "GET",
+		URL:            "http://example.org/.well-known/acme-challenge/x",
+		RequestHeader:  redactHeaders(http.Header{"Cookie": {"session=secret"}, "Accept": {"*/*"}}),
+		Proto:          "HTTP/1.1",
+		Status:         "301 Moved Permanently",
+		ResponseHeader: redactHeaders(http.Header{"Set-Cookie": {"a=secret", "b=secret"}, "Location": {"https://example.org/"}, "Cf-Ray": {"1234-LHR"}}),
+	}
+	out := exchange.String()
+	if strings.Contains(out, "secret") {
+		t.Fatalf("expected cookies to be redacted, got:\n%s", out)
+	}
+	for _, want := range []string{"> Cookie: [1 redacted]", "< Set-Cookie: [2 redacted]", "< Location: https://example.org/", "< Cf-Ray: 1234-LHR", "< HTTP/1.1 301"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestIsAddressCGNAT(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"100.64.0.1":      true,
+		"100.127.255.254": true,
+		"100.128.0.1":     false,
+		"10.0.0.1":        false,
+		"2001:db8::1":     false,
+	} {
+		if got := isAddressCGNAT(net.ParseIP(addr)); got != want {
+			t.Errorf("isAddressCGNAT(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	if !isAddressReserved(net.ParseIP("100.64.0.1")) {
+		t.Error("expected CGNAT addresses to still be reserved")
+	}
+}
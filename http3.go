@@ -0,0 +1,161 @@
+package letsdebug
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// http3Checker looks for an Alt-Svc header advertising HTTP/3, and if there is one, whether
+// QUIC is actually reachable over UDP. Let's Encrypt never validates over HTTP/3, so this never
+// affects issuance, but a browser which follows the advertisement into a UDP firewall behaves
+// very differently to the validation server, which confuses users comparing the two.
+type http3Checker struct{}
+
+// quicGreaseVersion is a reserved QUIC version (RFC 9000 section 15), which a QUIC server must
+// answer with a Version Negotiation packet. This lets us detect QUIC without a QUIC stack.
+const quicGreaseVersion = 0x1a2a3a4a
+
+func (c http3Checker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method != HTTP01 {
+		return nil, errNotApplicable
+	}
+
+	domain = strings.TrimPrefix(domain, "*.")
+
+	cl := http.Client{
+		Timeout:   httpTimeout * time.Second,
+		Transport: makeSingleShotHTTPTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := cl.Get("https://" + domain)
+	if err != nil {
+		return nil, nil
+	}
+	resp.Body.Close()
+
+	altSvc := resp.Header.Get("Alt-Svc")
+	port, ok := advertisedH3Port(altSvc)
+	if !ok {
+		return nil, nil
+	}
+
+	ip, err := ctx.LookupRandomHTTPRecord(domain)
+	if err != nil {
+		return nil, nil
+	}
+
+	versions, err := probeQUIC(ip, port, 3*time.Second)
+	if err != nil {
+		return []Problem{http3Unreachable(domain, altSvc, ip, port, err)}, nil
+	}
+
+	return []Problem{debugProblem("HTTP3", "The domain advertises HTTP/3, and QUIC was reachable. "+
+		"Let's Encrypt does not use HTTP/3 for validation.",
+		fmt.Sprintf("Alt-Svc: %s\nQUIC versions offered by %s: %s", altSvc,
+			net.JoinHostPort(ip.String(), strconv.Itoa(port)), strings.Join(versions, ", ")))}, nil
+}
+
+// advertisedH3Port returns the port of the first HTTP/3 alternative in an Alt-Svc header value.
+func advertisedH3Port(altSvc string) (int, bool) {
+	for _, alt := range strings.Split(altSvc, ",") {
+		protocol, authority, ok := strings.Cut(strings.TrimSpace(alt), "=")
+		if !ok || (protocol != "h3" && !strings.HasPrefix(protocol, "h3-")) {
+			continue
+		}
+		authority, _, _ = strings.Cut(authority, ";")
+		authority = strings.Trim(strings.TrimSpace(authority), `"`)
+		// Only alternatives on the same host are considered, which is almost always the case
+		host, p, err := net.SplitHostPort(authority)
+		if err != nil || host != "" {
+			continue
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			continue
+		}
+		return port, true
+	}
+	return 0, false
+}
+
+// probeQUIC sends a QUIC Initial-sized packet with a reserved version, and returns the versions
+// listed in the Version Negotiation packet sent in response.
+func probeQUIC(ip net.IP, port int, timeout time.Duration) ([]string, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(ip.String(), strconv.Itoa(port)), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Long header packet: flags, version, DCID length + DCID, SCID length + SCID, padded to
+	// the minimum size of a datagram carrying an Initial packet.
+	pkt := make([]byte, 1200)
+	pkt[0] = 0xc0
+	binary.BigEndian.PutUint32(pkt[1:5], quicGreaseVersion)
+	pkt[5] = 8
+	pkt[14] = 8
+	if _, err := rand.Read(pkt[6:14]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(pkt[15:23]); err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(pkt); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return parseVersionNegotiation(buf[:n])
+}
+
+func parseVersionNegotiation(buf []byte) ([]string, error) {
+	if len(buf) < 7 || buf[0]&0x80 == 0 || binary.BigEndian.Uint32(buf[1:5]) != 0 {
+		return nil, errors.New("the response was not a QUIC Version Negotiation packet")
+	}
+	offset := 5
+	for i := 0; i < 2; i++ { // DCID, then SCID
+		if offset >= len(buf) {
+			return nil, errors.New("truncated QUIC Version Negotiation packet")
+		}
+		offset += 1 + int(buf[offset])
+	}
+	var versions []string
+	for ; offset+4 <= len(buf); offset += 4 {
+		versions = append(versions, fmt.Sprintf("0x%08x", binary.BigEndian.Uint32(buf[offset:offset+4])))
+	}
+	if len(versions) == 0 {
+		return nil, errors.New("the QUIC Version Negotiation packet listed no versions")
+	}
+	return versions, nil
+}
+
+func http3Unreachable(domain, altSvc string, ip net.IP, port int, err error) Problem {
+	return Problem{
+		Name: "HTTP3Unreachable",
+		Explanation: fmt.Sprintf(`%s advertises HTTP/3 (QUIC) via the Alt-Svc header, but QUIC on UDP port %d did not respond. `+
+			`This does not affect Let's Encrypt, which never validates over HTTP/3. However, browsers which try to follow `+
+			`the advertisement may stall or behave differently to the validation server, so browser behavior is not `+
+			`a reliable indicator of whether validation will succeed. Check that UDP port %d is allowed through any firewall, `+
+			`or stop advertising h3.`, domain, port, port),
+		Detail:   fmt.Sprintf("Alt-Svc: %s\nProbe of %s: %v", altSvc, net.JoinHostPort(ip.String(), strconv.Itoa(port)), err),
+		Severity: SeverityWarning,
+		Err:      err,
+	}
+}
@@ -0,0 +1,64 @@
+package letsdebug
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAdvertisedH3Port(t *testing.T) {
+	tests := []struct {
+		altSvc string
+		port   int
+		ok     bool
+	}{
+		{`h3=":443"; ma=86400`, 443, true},
+		{`h2=":443", h3-29=":8443"; ma=86400`, 8443, true},
+		{`h2=":443"; ma=86400`, 0, false},
+		{`h3="alt.example.org:443"`, 0, false},
+		{`clear`, 0, false},
+		{``, 0, false},
+	}
+	for _, tt := range tests {
+		port, ok := advertisedH3Port(tt.altSvc)
+		if port != tt.port || ok != tt.ok {
+			t.Errorf("%q: expected (%d, %t), got (%d, %t)", tt.altSvc, tt.port, tt.ok, port, ok)
+		}
+	}
+}
+
+func TestProbeQUIC(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("can't listen on UDP: %v", err)
+	}
+	defer conn.Close()
+
+	// Answer like a QUIC server which supports version 1
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil || n < 1200 || binary.BigEndian.Uint32(buf[1:5]) != quicGreaseVersion {
+			return
+		}
+		resp := []byte{0x80, 0, 0, 0, 0, 8}
+		resp = append(resp, buf[15:23]...) // their SCID is our DCID
+		resp = append(resp, 8)
+		resp = append(resp, buf[6:14]...)
+		resp = append(resp, 0, 0, 0, 1)
+		_, _ = conn.WriteToUDP(resp, addr)
+	}()
+
+	versions, err := probeQUIC(net.IPv4(127, 0, 0, 1), conn.LocalAddr().(*net.UDPAddr).Port, 2*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(versions) != 1 || versions[0] != "0x00000001" {
+		t.Fatalf("unexpected versions: %v", versions)
+	}
+
+	if _, err := parseVersionNegotiation([]byte{0x40, 0, 0, 0, 1}); err == nil {
+		t.Fatal("expected a short header packet to be rejected")
+	}
+}
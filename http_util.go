@@ -1,15 +1,20 @@
 package letsdebug
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -31,7 +36,70 @@ type httpCheckResult struct {
 	NumRedirects      int
 	FirstDial         time.Time
 	DialStack         []string
-	Content           []byte
+	// Whether a connection to IP was established
+	Connected bool
+	Content   []byte
+	// Requests made over HTTPS while following redirects
+	RedirectHops []redirectHop
+	// The headers of every request made and response received, including redirects
+	Exchanges []httpExchange
+	// How long after the request was made the first byte of the final response arrived, including
+	// any redirects which were followed
+	TimeToFirstByte time.Duration
+}
+
+// httpExchange is the headers of a single request and its response, with credentials redacted.
+type httpExchange struct {
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	Status         string
+	Proto          string
+	ResponseHeader http.Header
+	Error          error
+}
+
+// redactedHeaders are removed from captured headers, as they may contain session tokens.
+var redactedHeaders = []string{"Cookie", "Set-Cookie", "Authorization", "Proxy-Authorization"}
+
+func redactHeaders(h http.Header) http.Header {
+	out := http.Header{}
+	if h != nil {
+		out = h.Clone()
+	}
+	for _, name := range redactedHeaders {
+		if values := out.Values(name); len(values) > 0 {
+			out[name] = []string{fmt.Sprintf("[%d redacted]", len(values))}
+		}
+	}
+	return out
+}
+
+func formatHeaders(prefix string, h http.Header) []string {
+	var names []string
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		for _, v := range h[name] {
+			lines = append(lines, fmt.Sprintf("%s %s: %s", prefix, name, v))
+		}
+	}
+	return lines
+}
+
+func (e httpExchange) String() string {
+	lines := []string{fmt.Sprintf("> %s %s", e.Method, e.URL)}
+	lines = append(lines, formatHeaders(">", e.RequestHeader)...)
+	if e.Error != nil {
+		lines = append(lines, fmt.Sprintf("< error: %v", e.Error))
+		return strings.Join(lines, "\n")
+	}
+	lines = append(lines, fmt.Sprintf("< %s %s", e.Proto, e.Status))
+	lines = append(lines, formatHeaders("<", e.ResponseHeader)...)
+	return strings.Join(lines, "\n")
 }
 
 func (r *httpCheckResult) Trace(s string) {
@@ -46,6 +114,16 @@ func (r httpCheckResult) IsZero() bool {
 	return r.StatusCode == 0
 }
 
+// responseSignature is the part of the result which should be the same no matter which server
+// of the domain responded.
+func (r httpCheckResult) responseSignature() string {
+	sig := fmt.Sprintf("HTTP %d", r.InitialStatusCode)
+	if r.NumRedirects > 0 {
+		sig += fmt.Sprintf(" then %d redirects to HTTP %d", r.NumRedirects, r.StatusCode)
+	}
+	return sig + fmt.Sprintf(", Server=%q", r.ServerHeader)
+}
+
 func (r httpCheckResult) String() string {
 	addrType := "IPv6"
 	if r.IP.To4() != nil {
@@ -62,6 +140,9 @@ func (r httpCheckResult) String() string {
 		lines = append(lines, "Number of Redirects="+strconv.Itoa(r.NumRedirects))
 		lines = append(lines, "Final HTTP Status="+strconv.Itoa(r.StatusCode))
 	}
+	if r.TimeToFirstByte > 0 {
+		lines = append(lines, "Time to First Byte="+r.TimeToFirstByte.Round(time.Millisecond).String())
+	}
 
 	return fmt.Sprintf("[%s]", strings.Join(lines, ","))
 }
@@ -86,6 +167,24 @@ func (t checkHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error)
 		t.result.Trace(fmt.Sprintf("Server response: HTTP %s", resp.Status))
 	}
 
+	if t.result != nil {
+		reqHeader := redactHeaders(req.Header)
+		reqHeader.Set("Host", req.URL.Host)
+		exchange := httpExchange{Method: req.Method, URL: req.URL.String(), RequestHeader: reqHeader, Error: err}
+		if resp != nil {
+			exchange.Status, exchange.Proto, exchange.ResponseHeader = resp.Status, resp.Proto, redactHeaders(resp.Header)
+		}
+		t.result.Exchanges = append(t.result.Exchanges, exchange)
+	}
+
+	if t.result != nil && strings.EqualFold(req.URL.Scheme, "https") {
+		hop := redirectHop{URL: req.URL.String(), Host: req.URL.Hostname(), Error: err}
+		if resp != nil && resp.TLS != nil {
+			hop.Chain = resp.TLS.PeerCertificates
+		}
+		t.result.RedirectHops = append(t.result.RedirectHops, hop)
+	}
+
 	return resp, err
 }
 
@@ -113,6 +212,11 @@ func checkHTTP(scanCtx *scanContext, domain string, address net.IP) (httpCheckRe
 		DialStack: []string{},
 	}
 
+	if refusedBy := scanCtx.httpOptOutURL(); refusedBy != "" {
+		checkRes.Trace(fmt.Sprintf("Not making a request, since %s asked not to be probed", refusedBy))
+		return *checkRes, Problem{}
+	}
+
 	var redirErr redirectError
 
 	baseHTTPTransport := makeSingleShotHTTPTransport()
@@ -131,7 +235,11 @@ func checkHTTP(scanCtx *scanContext, domain string, address net.IP) (httpCheckRe
 		// Only override the address for this specific domain.
 		// We don't want to mangle redirects.
 		if host == domain {
-			return dialFunc(address, port)
+			conn, err := dialFunc(address, port)
+			if err == nil && port == "80" {
+				checkRes.Connected = true
+			}
+			return conn, err
 		}
 
 		// For other hosts, we need to use Unbound to resolve the name
@@ -150,6 +258,11 @@ func checkHTTP(scanCtx *scanContext, domain string, address net.IP) (httpCheckRe
 		},
 		// boulder: va.go fetchHTTP
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if optedOut(req.Response) {
+				scanCtx.recordHTTPOptOut(via[len(via)-1].URL.String())
+				return errHTTPOptOut
+			}
+
 			checkRes.NumRedirects++
 
 			if len(via) >= 10 {
@@ -159,25 +272,16 @@ func checkHTTP(scanCtx *scanContext, domain string, address net.IP) (httpCheckRe
 
 			checkRes.Trace(fmt.Sprintf("Received redirect to %s", req.URL.String()))
 
-			host := req.URL.Host
-			if _, p, err := net.SplitHostPort(host); err == nil {
-				if port, _ := strconv.Atoi(p); port != 80 && port != 443 {
-					redirErr = redirectError(fmt.Sprintf("Bad port number provided when fetching %s: %s", req.URL.String(), p))
+			// boulder: va.go processHTTPValidation
+			for _, v := range via {
+				if v.URL.String() == req.URL.String() {
+					redirErr = redirectError(fmt.Sprintf("Redirect loop detected, the redirect to %s was already followed", req.URL.String()))
 					return redirErr
 				}
 			}
 
-			scheme := strings.ToLower(req.URL.Scheme)
-			if scheme != "http" && scheme != "https" {
-				redirErr = redirectError(fmt.Sprintf("Bad scheme provided when fetching %s: %s", req.URL.String(), scheme))
-				return redirErr
-			}
-
-			// Also check for domain.tld.well-known/acme-challenge
-			if strings.HasSuffix(req.URL.Hostname(), ".well-known") {
-				redirErr = redirectError(fmt.Sprintf("It appears that a redirect was generated by your web server that is missing a trailing "+
-					"slash after your domain name: %v. Check your web server configuration and .htaccess for Redirect/RedirectMatch/RewriteRule.",
-					req.URL.String()))
+			if _, _, err := boulderRedirectTarget(req.URL); err != nil {
+				redirErr = redirectError(err.Error())
 				return redirErr
 			}
 
@@ -194,21 +298,35 @@ func checkHTTP(scanCtx *scanContext, domain string, address net.IP) (httpCheckRe
 	}
 
 	req.Header.Set("Accept", "*/*")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Let's Debug emulating Let's Encrypt validation server; +https://letsdebug.net)")
+	req.Header.Set("User-Agent", scanCtx.probeUserAgent())
 
 	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout*time.Second)
 	defer cancel()
 
-	req = req.WithContext(ctx)
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			checkRes.TimeToFirstByte = time.Since(start)
+		},
+	}))
 
 	resp, err := cl.Do(req)
 	if resp != nil {
 		checkRes.StatusCode = resp.StatusCode
 		checkRes.ServerHeader = resp.Header.Get("Server")
 	}
+	if errors.Is(err, errHTTPOptOut) {
+		checkRes.Trace("The server asked not to be probed, so the redirect was not followed")
+		return *checkRes, Problem{}
+	}
+	if err == nil && optedOut(resp) {
+		scanCtx.recordHTTPOptOut(resp.Request.URL.String())
+	}
 	if err != nil {
 		if redirErr != "" {
 			err = redirErr
+		} else if hop, ok := checkRes.failedHTTPSHop(); ok && !isWrongProtocolError(err) {
+			return *checkRes, httpsRedirectNotWorking(domain, address, hop, err, checkRes.DialStack)
 		}
 		return *checkRes, translateHTTPError(domain, address, err, checkRes.DialStack)
 	}
@@ -258,14 +376,14 @@ func translateHTTPError(domain string, address net.IP, e error, dialStack []stri
 		return badRedirect(domain, redirErr, dialStack)
 	}
 
-	if strings.HasSuffix(e.Error(), "http: server gave HTTP response to HTTPS client") {
+	if isWrongProtocolError(e) {
 		return httpServerMisconfiguration(domain, "Web server is serving the wrong protocol on the wrong port: "+e.Error()+
 			". This may be due to a previous HTTP redirect rather than a webserver misconfiguration.\n\nTrace:\n"+strings.Join(dialStack, "\n"))
 	}
 
 	// Make a nicer error message if it was a context timeout
 	if urlErr, ok := e.(*url.Error); ok && urlErr.Timeout() {
-		e = fmt.Errorf("A timeout was experienced while communicating with %s/%s: %v",
+		e = fmt.Errorf("A timeout was experienced while communicating with %s/%s: %w",
 			domain, address.String(), urlErr)
 	}
 
@@ -276,6 +394,15 @@ func translateHTTPError(domain string, address net.IP, e error, dialStack []stri
 	}
 }
 
+// isWrongProtocolError is whether either plain HTTP was spoken to an HTTPS port after a redirect, or
+// a TLS alert record was received in response to the plain HTTP request.
+func isWrongProtocolError(e error) bool {
+	var recordErr tls.RecordHeaderError
+	return strings.HasSuffix(e.Error(), "http: server gave HTTP response to HTTPS client") ||
+		(errors.As(e, &recordErr) && bytes.HasPrefix(recordErr.RecordHeader[:], []byte("HTTP/"))) ||
+		strings.Contains(e.Error(), `malformed HTTP response "\x15\x03`)
+}
+
 func httpServerMisconfiguration(domain, detail string) Problem {
 	return Problem{
 		Name:        "WebserverMisconfiguration",
@@ -294,6 +421,7 @@ func aaaaNotWorking(domain, ipv6Address string, err error, dialStack []string) P
 			domain, ipv6Address),
 		Detail:   fmt.Sprintf("%s\n\nTrace:\n%s", err.Error(), strings.Join(dialStack, "\n")),
 		Severity: SeverityError,
+		Err:      err,
 	}
 }
 
@@ -305,6 +433,7 @@ func aNotWorking(domain, addr string, err error, dialStack []string) Problem {
 			domain, addr),
 		Detail:   fmt.Sprintf("%s\n\nTrace:\n%s", err.Error(), strings.Join(dialStack, "\n")),
 		Severity: SeverityError,
+		Err:      err,
 	}
 }
 
@@ -316,6 +445,7 @@ func badRedirect(domain string, err error, dialStack []string) Problem {
 			domain),
 		Detail:   fmt.Sprintf("%s\n\nTrace:\n%s", err.Error(), strings.Join(dialStack, "\n")),
 		Severity: SeverityError,
+		Err:      err,
 	}
 }
 
@@ -327,3 +457,26 @@ func unexpectedHttpResponse(domain string, httpStatus string, httpBody string, d
 		Severity:    SeverityWarning,
 	}
 }
+
+// errReservedAddress is returned by the dialers of NewPublicDialer instead of connecting to a reserved address.
+var errReservedAddress = errors.New("connecting to a private or reserved address is not allowed")
+
+// NewPublicDialer returns a dialer which refuses to connect to private and IANA/IETF-reserved
+// addresses, for requests made on behalf of users to names which they control. Each address is
+// checked as it is connected to, after the name was resolved, so that it applies to redirects and
+// can't be bypassed by changing what the name resolves to.
+func NewPublicDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isAddressReserved(ip) {
+				return fmt.Errorf("%s: %w", host, errReservedAddress)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,29 @@
+package letsdebug
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewPublicDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	dialer := NewPublicDialer(time.Second)
+	for _, addr := range []string{l.Addr().String(), net.JoinHostPort("localhost", port)} {
+		conn, err := dialer.DialContext(context.Background(), "tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		if !errors.Is(err, errReservedAddress) {
+			t.Errorf("%s: expected the connection to be refused, got %v", addr, err)
+		}
+	}
+}
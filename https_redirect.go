@@ -0,0 +1,63 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// failedHTTPSHop returns the HTTPS request which the validation request failed at, if it failed after
+// being redirected to HTTPS.
+func (r httpCheckResult) failedHTTPSHop() (redirectHop, bool) {
+	if len(r.RedirectHops) == 0 || len(r.Exchanges) == 0 {
+		return redirectHop{}, false
+	}
+	hop := r.RedirectHops[len(r.RedirectHops)-1]
+	if hop.Error == nil || r.Exchanges[len(r.Exchanges)-1].URL != hop.URL {
+		return redirectHop{}, false
+	}
+	return hop, true
+}
+
+// describeHTTPSFailure explains why the connection to the target of an HTTPS redirect failed, in the
+// terms of the usual causes.
+func describeHTTPSFailure(host, port string, err error) string {
+	var netErr net.Error
+	msg := err.Error()
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(msg, "connection refused"):
+		return fmt.Sprintf("Nothing is listening on port %s of %s, or a firewall rejected the connection.", port, host)
+	case strings.Contains(msg, "TLS handshake timeout"):
+		return fmt.Sprintf("The connection to port %s of %s was made, but the TLS handshake did not complete in time.", port, host)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Sprintf("The connection to port %s of %s timed out, which usually means that a firewall is dropping it.", port, host)
+	case strings.Contains(msg, "unrecognized name") || strings.Contains(msg, "handshake failure") ||
+		strings.Contains(msg, "internal error"):
+		return fmt.Sprintf("The server on port %s refused the TLS handshake for %s, which usually means that it has no "+
+			"certificate (or virtual host) configured for that name.", port, host)
+	case strings.Contains(msg, "tls:") || strings.Contains(msg, "EOF") || strings.Contains(msg, "connection reset"):
+		return fmt.Sprintf("The TLS handshake with port %s of %s failed.", port, host)
+	}
+	return fmt.Sprintf("The HTTPS request to port %s of %s failed.", port, host)
+}
+
+func httpsRedirectNotWorking(domain string, address net.IP, hop redirectHop, err error, dialStack []string) Problem {
+	port := "443"
+	if u, parseErr := url.Parse(hop.URL); parseErr == nil && u.Port() != "" {
+		port = u.Port()
+	}
+	return Problem{
+		Name: "HTTPSRedirectNotWorking",
+		Explanation: fmt.Sprintf(`The validation request to %s (%s) on port 80 was redirected to %s, but the HTTPS request `+
+			`to it did not succeed. Let's Encrypt follows redirects to HTTPS, and while it doesn't check whether the certificate `+
+			`is trusted, it does need to connect and complete the TLS handshake, using %s as the server name (SNI). %s `+
+			`Either fix HTTPS on the target of the redirect, or stop redirecting requests under /.well-known/acme-challenge/.`,
+			domain, address, hop.URL, hop.Host, describeHTTPSFailure(hop.Host, port, err)),
+		Detail:   fmt.Sprintf("%s\n\nTrace:\n%s", err.Error(), strings.Join(dialStack, "\n")),
+		Severity: SeverityError,
+		Err:      err,
+	}
+}
@@ -0,0 +1,66 @@
+package letsdebug
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestFailedHTTPSHop(t *testing.T) {
+	failed := errors.New("connection refused")
+	res := httpCheckResult{
+		Exchanges: []httpExchange{
+			{URL: "http://example.org/.well-known/acme-challenge/x"},
+			{URL: "https://example.org/.well-known/acme-challenge/x", Error: failed},
+		},
+		RedirectHops: []redirectHop{{URL: "https://example.org/.well-known/acme-challenge/x", Host: "example.org", Error: failed}},
+	}
+	if hop, ok := res.failedHTTPSHop(); !ok || hop.Host != "example.org" {
+		t.Errorf("expected the HTTPS hop to be the failure, got %+v", hop)
+	}
+
+	// The HTTPS hop succeeded, and a later request failed
+	res.RedirectHops[0].Error = nil
+	res.Exchanges = append(res.Exchanges, httpExchange{URL: "http://www.example.org/", Error: failed})
+	if _, ok := res.failedHTTPSHop(); ok {
+		t.Error("expected no failed HTTPS hop")
+	}
+	if _, ok := (httpCheckResult{}).failedHTTPSHop(); ok {
+		t.Error("expected no failed HTTPS hop without any redirects")
+	}
+}
+
+func TestDescribeHTTPSFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	_, refused := net.Dial("tcp", addr)
+	if refused == nil {
+		t.Skip("the closed port accepted a connection")
+	}
+
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{refused, "Nothing is listening on port 443"},
+		{errors.New("net/http: TLS handshake timeout"), "did not complete in time"},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}, "timed out"},
+		{errors.New("remote error: tls: unrecognized name"), "no certificate"},
+		{errors.New("EOF"), "TLS handshake with port 443 of example.org failed"},
+	} {
+		if got := describeHTTPSFailure("example.org", "443", tt.err); !strings.Contains(got, tt.want) {
+			t.Errorf("%v: expected %q, got %q", tt.err, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package letsdebug
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// installedCertificateChecker connects to the TLS port (443, unless Options.TLSPort is set) of each address
+// once, and describes the certificate being served: who issued it, when it expires, and whether its chain
+// is complete. "My certificate expired" is one of the most common reasons to run a test, and the answer
+// is often that a renewed certificate was never installed, or that the server still sends an old chain.
+type installedCertificateChecker struct{}
+
+// dstRootCAX3 is the root which ISRG Root X1 was cross-signed by, so that old Android devices would trust
+// it. The cross-sign expired on 30 September 2024.
+const dstRootCAX3 = "DST Root CA X3"
+
+func (c installedCertificateChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	domain = strings.TrimPrefix(domain, "*.")
+
+	ips := ctx.LookupAddresses(domain)
+	if len(ips) == 0 {
+		return nil, errNotApplicable
+	}
+
+	var observations []tlsObservation
+	for _, ip := range ips {
+		observations = append(observations, observeTLS(domain, ip, ctx.tlsPort))
+	}
+	return analyzeInstalledCertificates(domain, ctx.tlsPort, observations, nil, time.Now()), nil
+}
+
+// analyzeInstalledCertificates describes each distinct certificate presented on the TLS port. If roots
+// is nil, the system roots are used.
+func analyzeInstalledCertificates(domain string, port int, observations []tlsObservation, roots *x509.CertPool, now time.Time) []Problem {
+	var order []string
+	chains := map[string]tlsObservation{}
+	addresses := map[string][]string{}
+	for _, obs := range observations {
+		if obs.Error != nil || len(obs.Chain) == 0 {
+			continue
+		}
+		fp := obs.Fingerprint()
+		if _, ok := chains[fp]; !ok {
+			order = append(order, fp)
+			chains[fp] = obs
+		}
+		addresses[fp] = append(addresses[fp], obs.Address.String())
+	}
+
+	var probs []Problem
+	for _, fp := range order {
+		obs := chains[fp]
+		chain := obs.Chain
+		addrs := strings.Join(addresses[fp], ", ")
+		leaf := chain[0]
+
+		probs = append(probs, installedCertificate(domain, port, addrs, obs, describeChain(chain, roots, now), now))
+		if now.After(leaf.NotAfter) {
+			probs = append(probs, installedCertificateExpired(domain, port, addrs, leaf, now))
+		}
+		for _, cert := range chain {
+			if cert.Issuer.CommonName == dstRootCAX3 {
+				probs = append(probs, expiredRootCrossSign(domain, port, addrs, cert))
+				break
+			}
+		}
+	}
+	return probs
+}
+
+// describeChain explains whether the presented chain leads to a trusted root on its own.
+func describeChain(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) string {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now})
+	if err == nil {
+		return "The chain is complete and trusted."
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		last := missingIssuer(chain)
+		if bytes.Equal(last.RawIssuer, last.RawSubject) && last.CheckSignatureFrom(last) == nil {
+			return fmt.Sprintf("The chain ends at %q, which is not a trusted root.", last.Subject.String())
+		}
+		return fmt.Sprintf("The chain is incomplete: the certificate of %q, which issued %q, was not sent.",
+			last.Issuer.String(), last.Subject.String())
+	}
+	return fmt.Sprintf("The chain is not trusted: %v.", err)
+}
+
+func installedCertificate(domain string, port int, addresses string, obs tlsObservation, chainStatus string, now time.Time) Problem {
+	leaf := obs.Chain[0]
+
+	validity := fmt.Sprintf("expires on %s (in %d days)", leaf.NotAfter.UTC().Format(time.RFC1123),
+		int(leaf.NotAfter.Sub(now).Hours()/24))
+	if now.After(leaf.NotAfter) {
+		validity = fmt.Sprintf("expired on %s", leaf.NotAfter.UTC().Format(time.RFC1123))
+	}
+	names := fmt.Sprintf("It covers %s.", strings.Join(leaf.DNSNames, ", "))
+	if err := leaf.VerifyHostname(domain); err != nil {
+		names = fmt.Sprintf("It does not cover %s, only %s.", domain, strings.Join(leaf.DNSNames, ", "))
+	}
+
+	var subjects []string
+	for _, cert := range obs.Chain {
+		subjects = append(subjects, fmt.Sprintf("%s (issued by %s, valid until %s)", cert.Subject, cert.Issuer,
+			cert.NotAfter.UTC().Format(time.RFC3339)))
+	}
+	return Problem{
+		Name: "InstalledCertificate",
+		Explanation: fmt.Sprintf(`The certificate currently served on port %d of %s (%s) was issued by %q and %s. %s %s`,
+			port, domain, addresses, leaf.Issuer.String(), validity, names, chainStatus),
+		Detail: fmt.Sprintf("Serial: %s\nSHA-256: %s\n\nPresented chain:\n%s", leaf.SerialNumber.Text(16),
+			obs.Fingerprint(), strings.Join(subjects, "\n")),
+		Severity: SeverityInfo,
+	}
+}
+
+func installedCertificateExpired(domain string, port int, addresses string, leaf *x509.Certificate, now time.Time) Problem {
+	return Problem{
+		Name: "InstalledCertificateExpired",
+		Explanation: fmt.Sprintf(`The certificate served on port %d of %s (%s) expired %d days ago. If your ACME client `+
+			`has renewed it since, the web server was not reloaded, or is configured with the path of a different `+
+			`certificate than the one being renewed (e.g. a copy, or the files of another ACME client). Otherwise, check `+
+			`why renewal is failing: the rest of this test shows whether a new certificate could be issued.`,
+			port, domain, addresses, int(now.Sub(leaf.NotAfter).Hours()/24)),
+		Detail:   fmt.Sprintf("%s expired at %s", leaf.Subject, leaf.NotAfter.UTC().Format(time.RFC3339)),
+		Severity: SeverityWarning,
+	}
+}
+
+func expiredRootCrossSign(domain string, port int, addresses string, cert *x509.Certificate) Problem {
+	return Problem{
+		Name: "ExpiredRootCrossSign",
+		Explanation: fmt.Sprintf(`The chain served on port %d of %s (%s) still includes %q, cross-signed by %s. `+
+			`The cross-sign expired on 30 September 2024, and clients which don't look for another path to a trusted `+
+			`root (such as those using OpenSSL 1.0.x) reject the whole chain. Let's Encrypt no longer provides this chain: `+
+			`remove any preferred chain setting from your ACME client (or the extra certificate from the chain file), and renew.`,
+			port, domain, addresses, cert.Subject.String(), dstRootCAX3),
+		Detail:   fmt.Sprintf("%s, issued by %s, valid until %s", cert.Subject, cert.Issuer, cert.NotAfter.UTC().Format(time.RFC3339)),
+		Severity: SeverityWarning,
+	}
+}
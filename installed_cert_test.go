@@ -0,0 +1,102 @@
+package letsdebug
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeInstalledCertificates(t *testing.T) {
+	now := time.Now()
+	issue := func(serial int64, name string, ca bool, notAfter time.Time, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             now.Add(-90 * 24 * time.Hour),
+			NotAfter:              notAfter,
+			IsCA:                  ca,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		if !ca {
+			tmpl.DNSNames = []string{name}
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	root, rootKey := issue(1, "Test Root", true, now.Add(365*24*time.Hour), nil, nil)
+	intermediate, intermediateKey := issue(2, "Test Intermediate", true, now.Add(365*24*time.Hour), root, rootKey)
+	leaf, _ := issue(3, "example.org", false, now.Add(30*24*time.Hour), intermediate, intermediateKey)
+	expired, _ := issue(4, "example.org", false, now.Add(-48*time.Hour), intermediate, intermediateKey)
+	dst, dstKey := issue(5, dstRootCAX3, true, now.Add(365*24*time.Hour), nil, nil)
+	crossSigned, _ := issue(6, "Test Root", true, now.Add(-24*time.Hour), dst, dstKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	names := func(probs []Problem) string {
+		var out []string
+		for _, p := range probs {
+			out = append(out, p.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	a, b := net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")
+
+	probs := analyzeInstalledCertificates("example.org", 443, []tlsObservation{
+		{Address: a, Chain: []*x509.Certificate{leaf, intermediate}},
+		{Address: b, Chain: []*x509.Certificate{leaf, intermediate}},
+	}, roots, now)
+	if names(probs) != "InstalledCertificate" {
+		t.Fatalf("expected the certificate to be described once, got: %v", probs)
+	}
+	for _, want := range []string{"192.0.2.1, 192.0.2.2", `"CN=Test Intermediate"`, "in 29 days", "complete and trusted"} {
+		if !strings.Contains(probs[0].Explanation, want) {
+			t.Errorf("expected %q in: %s", want, probs[0].Explanation)
+		}
+	}
+
+	probs = analyzeInstalledCertificates("example.org", 443, []tlsObservation{{Address: a, Chain: []*x509.Certificate{leaf}}}, roots, now)
+	if !strings.Contains(probs[0].Explanation, "incomplete") {
+		t.Errorf("expected the chain to be incomplete, got: %s", probs[0].Explanation)
+	}
+
+	probs = analyzeInstalledCertificates("www.example.org", 443, []tlsObservation{{Address: a, Chain: []*x509.Certificate{expired, intermediate}}}, roots, now)
+	if names(probs) != "InstalledCertificate,InstalledCertificateExpired" {
+		t.Fatalf("expected the expiry to be reported, got: %v", probs)
+	}
+	if !strings.Contains(probs[0].Explanation, "does not cover www.example.org") || !strings.Contains(probs[1].Explanation, "expired 2 days ago") {
+		t.Errorf("unexpected explanations: %v", probs)
+	}
+
+	probs = analyzeInstalledCertificates("example.org", 443, []tlsObservation{{Address: a, Chain: []*x509.Certificate{leaf, intermediate, crossSigned}}}, roots, now)
+	if names(probs) != "InstalledCertificate,ExpiredRootCrossSign" {
+		t.Fatalf("expected the cross-sign to be reported, got: %v", probs)
+	}
+
+	if probs := analyzeInstalledCertificates("example.org", 443, []tlsObservation{{Address: a, Error: errors.New("connection refused")}}, roots, now); len(probs) != 0 {
+		t.Errorf("expected nothing to be reported without a certificate, got: %v", probs)
+	}
+}
@@ -2,13 +2,16 @@
 
 package letsdebug
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestAcmeStaging(t *testing.T) {
 	checker := &acmeStagingChecker{}
 
 	// Fails at order creation
-	probs, err := checker.Check(nil, "paypal.com", HTTP01)
+	probs, err := checker.Check(newScanContext(), "paypal.com", HTTP01)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -17,7 +20,7 @@ func TestAcmeStaging(t *testing.T) {
 	}
 
 	// Fails at challenge update for an error we should report (domain is 127.0.0.1)
-	probs, err = checker.Check(nil, "localtest.me", HTTP01)
+	probs, err = checker.Check(newScanContext(), "localtest.me", HTTP01)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -26,7 +29,7 @@ func TestAcmeStaging(t *testing.T) {
 	}
 
 	// Fails at challenge update but with a simple unauthorized error
-	probs, err = checker.Check(nil, "fleetssl.com", HTTP01)
+	probs, err = checker.Check(newScanContext(), "fleetssl.com", HTTP01)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -52,7 +55,7 @@ func TestWildcards(t *testing.T) {
 
 	for _, checker := range checkers {
 		probs, err := checker.Check(ctx, "*.wildcard-test.letsdebug.net", DNS01)
-		if err != nil && err != errNotApplicable {
+		if err != nil && !errors.Is(err, errNotApplicable) {
 			t.Fatal(err)
 		}
 
@@ -0,0 +1,43 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// slowResponseThreshold is the time to first byte of the validation request beyond which the
+// response is considered at risk of exceeding the timeout of the Let's Encrypt validation server
+// (httpTimeout), which it makes its requests from several locations at once, and at a time the
+// server may be busier or colder than during this test.
+const slowResponseThreshold = httpTimeout * time.Second / 2
+
+// analyzeResponseLatency warns about each address whose response to the validation request took
+// long enough to approach the timeout of the validation server.
+func analyzeResponseLatency(domain string, results []httpCheckResult) []Problem {
+	var slow []string
+	for _, res := range results {
+		if res.IsZero() || res.TimeToFirstByte < slowResponseThreshold {
+			continue
+		}
+		slow = append(slow, fmt.Sprintf("%s: first byte of the response after %v", res.IP, res.TimeToFirstByte.Round(time.Millisecond)))
+	}
+	if len(slow) == 0 {
+		return nil
+	}
+	return []Problem{slowHTTPResponse(domain, slow)}
+}
+
+func slowHTTPResponse(domain string, slow []string) Problem {
+	return Problem{
+		Name: "SlowHTTPResponse",
+		Explanation: fmt.Sprintf(`The web server for %s took more than %v to begin responding to the validation request. Let's Encrypt `+
+			`gives up on validation requests after about %d seconds, and makes them from several locations at once, so a server which is `+
+			`this slow is likely to fail validation intermittently, and only during real validation. This is common with overloaded shared `+
+			`hosting, and with serverless functions or containers which are started on demand. Serve the challenge files before any slow `+
+			`application code, e.g. as static files from the web server, or keep the application warm.`,
+			domain, slowResponseThreshold, httpTimeout),
+		Detail:   strings.Join(slow, "\n"),
+		Severity: SeverityWarning,
+	}
+}
@@ -0,0 +1,22 @@
+package letsdebug
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeResponseLatency(t *testing.T) {
+	fast := httpCheckResult{IP: net.ParseIP("192.0.2.1"), StatusCode: 404, TimeToFirstByte: 200 * time.Millisecond}
+	slow := httpCheckResult{IP: net.ParseIP("192.0.2.2"), StatusCode: 200, TimeToFirstByte: 7 * time.Second}
+	failed := httpCheckResult{IP: net.ParseIP("192.0.2.3"), TimeToFirstByte: 9 * time.Second}
+
+	if probs := analyzeResponseLatency("example.org", []httpCheckResult{fast, failed}); len(probs) != 0 {
+		t.Fatalf("expected fast and failed requests not to be reported, got: %v", probs)
+	}
+
+	probs := analyzeResponseLatency("example.org", []httpCheckResult{fast, slow})
+	if len(probs) != 1 || probs[0].Name != "SlowHTTPResponse" || probs[0].Detail != "192.0.2.2: first byte of the response after 7s" {
+		t.Fatalf("expected the slow address to be reported, got: %v", probs)
+	}
+}
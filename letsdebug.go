@@ -9,7 +9,9 @@
 package letsdebug
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
 	"time"
@@ -24,6 +26,92 @@ type Options struct {
 	// respond with specific content. If the content does not match, then the test
 	// will fail with severity Error.
 	HTTPExpectResponse string
+	// SafeBrowsingAPIKey enables the Google Safe Browsing lookup for the domain.
+	// If empty, the LETSDEBUG_SAFEBROWSING_APIKEY environment variable is used instead,
+	// and if neither are set, the lookup is skipped.
+	SafeBrowsingAPIKey string
+	// StagingResultsMaxAge allows the result of a Let's Encrypt staging authorization for
+	// the same domain and validation method to be reused, if it was performed by this process
+	// no longer ago than this duration. Results are retained for at most one hour.
+	// Zero (default) disables reuse.
+	StagingResultsMaxAge time.Duration
+	// StagingAccountFiles is a pool of Let's Encrypt staging account files, which the staging
+	// checker rotates between. If empty, the LETSDEBUG_ACMESTAGING_ACCOUNTFILE environment
+	// variable is used, which may hold several paths separated by the OS path list separator.
+	StagingAccountFiles []string
+	// ACMEDirectory is the directory URL of the ACME server which the staging checker creates
+	// authorizations with. If empty, the LETSDEBUG_ACMESTAGING_DIRECTORY environment variable is
+	// used, and if neither are set, the Let's Encrypt staging service. It may point at a local
+	// Pebble server during development, with accounts registered there in StagingAccountFiles.
+	ACMEDirectory string
+	// ACMEInsecureSkipVerify disables verification of the certificate of the ACME server, such as
+	// the one Pebble generates for itself. It must not be used with a public ACME server.
+	ACMEInsecureSkipVerify bool
+	// RecordDNSResponses attaches the full response to every DNS lookup performed during
+	// the test (response code, flags, all sections and DNSSEC status) as a debug problem.
+	RecordDNSResponses bool
+	// DNSQueryBudget caps the number of DNS queries performed during the test, to protect
+	// against pathological zones. If zero, a default of 200 is used.
+	DNSQueryBudget int
+	// OriginAddresses are the addresses of the origin server behind the CDN or reverse proxy
+	// which serves the domain, if any. The validation request is also sent directly to them.
+	OriginAddresses []net.IP
+	// ProbeOriginHints looks for the origin server behind a CDN at common subdomains such as
+	// origin.example.org, and sends the validation request directly to any that are found.
+	ProbeOriginHints bool
+	// RedirectCertificates reports the certificate presented at each HTTPS hop of the redirects
+	// followed by the http-01 validation request, and warns about any which would not be trusted.
+	RedirectCertificates bool
+	// Offline skips every check which depends on a third-party service (status.io, crt.sh, the
+	// Let's Encrypt staging service, Google Safe Browsing and RDAP), so that the test can run on a
+	// network which only allows DNS and connections to the domain itself. Skipped checks are
+	// listed in the SkippedChecks debug problem, see SkippedChecks.
+	Offline bool
+	// SuppressProblems are the names of problems (e.g. "CloudflareCDN") which the caller considers
+	// noise, such as warnings about an intentional setup. They are left out of the results, and
+	// listed in a single SuppressedProblems debug problem instead.
+	SuppressProblems []string
+	// SeverityOverrides reclassifies the problems with the given names (e.g. treating "CloudflareCDN"
+	// as SeverityInfo), for operators whose users consider them more or less serious than Let's Debug
+	// does. The overridden severities are used for sorting and by everything which summarizes the
+	// results, and the original severities are listed in a single SeverityOverrides debug problem.
+	// A problem reclassified as Fatal does not stop the checks which follow it.
+	SeverityOverrides map[string]SeverityLevel
+	// Standalone declares that the ACME client will be run in standalone mode (e.g. certbot --standalone),
+	// binding port 80 itself. Anything which already answers on port 80 is then reported as a conflict,
+	// rather than checked as the server which will answer the challenge.
+	Standalone bool
+	// UserAgent and ContactURL identify whoever is running Let's Debug to the operators of the
+	// sites it probes. The User-Agent of the HTTP requests sent to the domain is
+	// "Mozilla/5.0 (compatible; {UserAgent}; +{ContactURL})". If empty, they identify letsdebug.net,
+	// which self-hosted deployments should not do.
+	UserAgent  string
+	ContactURL string
+	// Vantages are the results of the http-01 validation request made from other network locations
+	// by the caller's own probes. They are used to report domains which can't be reached from North
+	// America, where Let's Encrypt validates from.
+	Vantages []VantageResult
+	// Perspectives are probe agents in other network locations, which repeat the DNS lookups and the
+	// validation request of the test, to find geographic blocking and split-horizon DNS which only
+	// break some of the perspectives Let's Encrypt validates from. See NewProbeAgent.
+	Perspectives []Perspective
+	// HTTPPort and TLSPort are the ports which the informational checks of the web server connect to,
+	// instead of 80 and 443, so that they can be used with e.g. a staging environment on alternate ports.
+	// They apply to the TLS checks (interception, certificate chains and competing clients) and to the
+	// protocol misbinding probes, but never to the emulated validation requests, which Let's Encrypt
+	// always sends to port 80. Zero means the default.
+	HTTPPort int
+	TLSPort  int
+	// Sink receives each problem as soon as the check which found it completes, see ProblemSink.
+	Sink ProblemSink
+	// RequestID identifies the test in the caller's own logs, e.g. the ID of the request which asked
+	// for it, and is passed to Logger and Tracer so that they can be joined up.
+	RequestID string
+	// Logger receives the progress of the test, which is otherwise only written to stderr when the
+	// LETSDEBUG_DEBUG environment variable is set.
+	Logger Logger
+	// Tracer receives a span for each checker, see Tracer.
+	Tracer Tracer
 }
 
 // Check calls CheckWithOptions with default options
@@ -33,6 +121,8 @@ func Check(domain string, method ValidationMethod) (probs []Problem, retErr erro
 
 // CheckWithOptions will run each checker against the domain and validation method provided.
 // It is expected that this method may take a long time to execute, and may not be cancelled.
+// If an error is returned, the problems found before the error occurred are returned alongside it.
+// Problems are ordered by severity, then by name and detail, so the results of repeated runs are stable.
 func CheckWithOptions(domain string, method ValidationMethod, opts Options) (probs []Problem, retErr error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -40,6 +130,10 @@ func CheckWithOptions(domain string, method ValidationMethod, opts Options) (pro
 		}
 	}()
 
+	return check(newScanContextWithOptions(opts), domain, method)
+}
+
+func newScanContextWithOptions(opts Options) *scanContext {
 	ctx := newScanContext()
 	if opts.HTTPRequestPath != "" {
 		ctx.httpRequestPath = opts.HTTPRequestPath
@@ -47,27 +141,113 @@ func CheckWithOptions(domain string, method ValidationMethod, opts Options) (pro
 	if opts.HTTPExpectResponse != "" {
 		ctx.httpExpectResponse = opts.HTTPExpectResponse
 	}
+	if opts.SafeBrowsingAPIKey != "" {
+		ctx.safeBrowsingAPIKey = opts.SafeBrowsingAPIKey
+	}
+	ctx.stagingResultsMaxAge = opts.StagingResultsMaxAge
+	ctx.stagingAccountFiles = opts.StagingAccountFiles
+	ctx.acmeDirectory = opts.ACMEDirectory
+	ctx.acmeInsecureSkipVerify = opts.ACMEInsecureSkipVerify
+	ctx.recordDNSResponses = opts.RecordDNSResponses
+	ctx.originAddresses = opts.OriginAddresses
+	ctx.probeOriginHints = opts.ProbeOriginHints
+	ctx.redirectCertificates = opts.RedirectCertificates
+	ctx.offline = opts.Offline
+	ctx.certificates.offline = opts.Offline
+	ctx.suppressProblems = opts.SuppressProblems
+	ctx.severityOverrides = opts.SeverityOverrides
+	ctx.standalone = opts.Standalone
+	ctx.vantages = opts.Vantages
+	ctx.perspectives = opts.Perspectives
+	if opts.HTTPPort > 0 && opts.HTTPPort <= 65535 {
+		ctx.httpPort = opts.HTTPPort
+	}
+	if opts.TLSPort > 0 && opts.TLSPort <= 65535 {
+		ctx.tlsPort = opts.TLSPort
+	}
+	if opts.UserAgent != "" {
+		ctx.userAgent = opts.UserAgent
+	}
+	if opts.ContactURL != "" {
+		ctx.contactURL = opts.ContactURL
+	}
+	if opts.DNSQueryBudget > 0 {
+		ctx.dnsQueryBudget = opts.DNSQueryBudget
+	}
+	if opts.Sink != nil {
+		ctx.sink = newProblemSink(opts.Sink, opts.SuppressProblems, opts.SeverityOverrides)
+	}
+	ctx.requestID = opts.RequestID
+	ctx.logger = opts.Logger
+	ctx.tracer = opts.Tracer
+	return ctx
+}
 
+func check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	var probs []Problem
 	domain = normalizeFqdn(domain)
 
 	for _, checker := range checkers {
 		t := reflect.TypeOf(checker)
-		debug("[*] + %v\n", t)
+		ctx.logf("[*] + %v\n", t)
 		start := time.Now()
 		checkerProbs, err := checker.Check(ctx, domain, method)
-		debug("[*] - %v in %v\n", t, time.Since(start))
-		if err == nil {
-			if len(checkerProbs) > 0 {
-				probs = append(probs, checkerProbs...)
-			}
-			// dont continue checking when a fatal error occurs
-			if hasFatalProblem(probs) {
-				break
-			}
-		} else if err != errNotApplicable {
-			return nil, err
+		ctx.logf("[*] - %v in %v\n", t, time.Since(start))
+		if errors.Is(err, errNotApplicable) {
+			ctx.recordSkipped(t, err)
+		} else if err != nil {
+			// keep whatever was found before the failure, including by the other checkers in the block
+			probs = suppressProblems(append(probs, checkerProbs...), ctx.suppressProblems)
+			probs = overrideSeverities(probs, ctx.severityOverrides)
+			SortProblems(probs)
+			return probs, err
+		}
+		if len(checkerProbs) > 0 {
+			probs = append(probs, checkerProbs...)
 		}
+		// dont continue checking when a fatal error occurs
+		if hasFatalProblem(probs) {
+			break
+		}
+		if ctx.sink.Stopped() {
+			probs = suppressProblems(withCategories(withReferences(probs)), ctx.suppressProblems)
+			probs = overrideSeverities(probs, ctx.severityOverrides)
+			SortProblems(probs)
+			return probs, ErrStopped
+		}
+	}
+
+	// The problems of the checkers were already passed to the sink as each of them completed
+	found := len(probs)
+
+	probs = append(probs, analyzeDiscrepancies(ctx, domain, method, probs)...)
+	probs = append(probs, analyzeSourceBlocking(ctx, domain, method, probs)...)
+	probs = append(probs, analyzeChallengeTXT(ctx, domain, method)...)
+	probs = append(probs, analyzeDynamicDNS(ctx, domain, method, probs)...)
+	probs = append(probs, analyzeStandalone(ctx, domain, method)...)
+	probs = append(probs, analyzeHTTPOptOut(ctx, domain, method)...)
+	probs = append(probs, analyzeVantages(ctx, domain, method, probs)...)
+	probs = append(probs, analyzePerspectiveHTTP(ctx, domain, method, probs)...)
+	probs = append(probs, analyzeVAFirewall(ctx, domain, method)...)
+
+	if p, ok := ctx.skippedChecksProblem(); ok {
+		probs = append(probs, p)
 	}
+	if p, truncated := ctx.dnsTruncationProblem(); truncated {
+		probs = append(probs, p)
+	}
+	if len(ctx.lookups) > 0 {
+		probs = append(probs, dnsLookupsProblem(ctx.lookups))
+	}
+	if ctx.recordDNSResponses {
+		probs = append(probs, dnsResponsesProblem(ctx.dnsResponses))
+	}
+	ctx.sink.emit(domain, method, probs[found:])
+
+	probs = suppressProblems(withCategories(withReferences(probs)), ctx.suppressProblems)
+	probs = overrideSeverities(probs, ctx.severityOverrides)
+	SortProblems(probs)
+
 	return probs, nil
 }
 
@@ -1,6 +1,11 @@
 package letsdebug
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
 
 func TestCheck(t *testing.T) {
 	// check success condition
@@ -25,6 +30,19 @@ func TestCheck(t *testing.T) {
 		t.Fatal("expected error, got none")
 	}
 
+	// check that problems found before a failure are kept
+	checkers = []checker{
+		checkerSucceedWithProblem{},
+		asyncCheckerBlock{checkerSucceedWithProblem{}, checkerFail{}},
+	}
+	probs, err = Check("", "")
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+	if len(probs) != 2 {
+		t.Fatalf("expected 2 problems, got: %d", len(probs))
+	}
+
 	// check panic recovery
 	checkers = []checker{
 		checkerPanic{},
@@ -33,3 +51,89 @@ func TestCheck(t *testing.T) {
 		t.Fatal("expected error, got none")
 	}
 }
+
+func TestOffline(t *testing.T) {
+	ctx := newScanContextWithOptions(Options{Offline: true, SafeBrowsingAPIKey: "test-key"})
+
+	for _, c := range []checker{statusioChecker{}, safeBrowsingChecker{}, &acmeStagingChecker{}} {
+		probs, err := c.Check(ctx, "example.org", HTTP01)
+		if !errors.Is(err, errNotApplicable) || !strings.Contains(err.Error(), "offline mode") {
+			t.Fatalf("%T: expected to be skipped in offline mode, got: %v", c, err)
+		}
+		if len(probs) != 0 {
+			t.Errorf("%T: expected no problems, got: %+v", c, probs)
+		}
+	}
+
+	if _, _, err := ctx.recentCertificates("example.org"); err == nil || !strings.Contains(err.Error(), "offline mode") {
+		t.Errorf("expected crt.sh to be skipped, got: %v", err)
+	}
+}
+
+func TestProbeUserAgent(t *testing.T) {
+	want := "Mozilla/5.0 (compatible; Let's Debug emulating Let's Encrypt validation server; +https://letsdebug.net)"
+	if got := newScanContextWithOptions(Options{}).probeUserAgent(); got != want {
+		t.Errorf("expected the default User-Agent %q, got %q", want, got)
+	}
+
+	want = "Mozilla/5.0 (compatible; Example Corp certificate checker; +https://example.org/scans)"
+	ctx := newScanContextWithOptions(Options{UserAgent: "Example Corp certificate checker", ContactURL: "https://example.org/scans"})
+	if got := ctx.probeUserAgent(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOriginPorts(t *testing.T) {
+	if ctx := newScanContextWithOptions(Options{}); ctx.httpPort != 80 || ctx.tlsPort != 443 {
+		t.Errorf("expected the default ports, got %d and %d", ctx.httpPort, ctx.tlsPort)
+	}
+	if ctx := newScanContextWithOptions(Options{HTTPPort: 8080, TLSPort: 8443}); ctx.httpPort != 8080 || ctx.tlsPort != 8443 {
+		t.Errorf("expected the ports to be overridden, got %d and %d", ctx.httpPort, ctx.tlsPort)
+	}
+	if ctx := newScanContextWithOptions(Options{HTTPPort: 70000, TLSPort: -1}); ctx.httpPort != 80 || ctx.tlsPort != 443 {
+		t.Errorf("expected invalid ports to be ignored, got %d and %d", ctx.httpPort, ctx.tlsPort)
+	}
+}
+
+func TestProblemSink(t *testing.T) {
+	defer func(orig []checker) { checkers = orig }(checkers)
+	checkers = []checker{
+		asyncCheckerBlock{checkerSucceedWithProblem{}, checkerSucceedEmpty{}},
+		asyncCheckerBlock{checkerSucceedWithProblem{}},
+	}
+
+	var mu sync.Mutex
+	var received []string
+	sink := ProblemSinkFunc(func(domain string, method ValidationMethod, p Problem) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, p.Name)
+		return true
+	})
+	probs, err := CheckWithOptions("example.org", HTTP01, Options{Sink: sink})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(received) != len(probs) {
+		t.Fatalf("expected the sink to receive all %d problems, got: %v", len(probs), received)
+	}
+
+	received = nil
+	stop := ProblemSinkFunc(func(domain string, method ValidationMethod, p Problem) bool {
+		received = append(received, p.Name)
+		return false
+	})
+	probs, err = CheckWithOptions("example.org", HTTP01, Options{Sink: stop})
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected the test to be stopped, got: %v", err)
+	}
+	if len(probs) != 1 || len(received) != 1 {
+		t.Fatalf("expected no further checks to run once stopped, got %v and %v", probs, received)
+	}
+
+	received = nil
+	probs, _ = CheckWithOptions("example.org", HTTP01, Options{Sink: sink, SuppressProblems: []string{"Empty"}})
+	if len(received) != 0 || len(probs) != 1 || probs[0].Name != "SuppressedProblems" {
+		t.Fatalf("expected suppressed problems not to be passed to the sink, got %v and %v", received, probs)
+	}
+}
@@ -0,0 +1,103 @@
+package letsdebug
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// loadBalancerChecker sends the http-01 validation request to each address of the domain several
+// times. Behind a load balancer, each request may land on a different backend, and if only some of
+// them have the challenge file (e.g. the ACME client runs on one node of the pool), validation fails
+// some of the time, and the domain's other checks look fine.
+type loadBalancerChecker struct{}
+
+// loadBalancerAttempts is how many requests are made to each address. Every request uses a new
+// connection.
+const loadBalancerAttempts = 5
+
+// backendAttempt is the outcome of a single request to an address.
+type backendAttempt struct {
+	Result  httpCheckResult
+	Problem Problem
+}
+
+// Signature is the part of the outcome which is expected to be the same from every backend.
+func (a backendAttempt) Signature() string {
+	if a.Result.IsZero() {
+		return "no response (" + a.Problem.Name + ")"
+	}
+	sig := a.Result.responseSignature()
+	if a.Problem.Name != "" {
+		sig += ", " + a.Problem.Name
+	}
+	return sig
+}
+
+func (c loadBalancerChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method != HTTP01 {
+		return nil, errNotApplicable
+	}
+
+	addresses := ctx.LookupAddresses(domain)
+	if len(addresses) == 0 {
+		return nil, errNotApplicable
+	}
+
+	attempts := make([][]backendAttempt, len(addresses))
+	var wg sync.WaitGroup
+	for i, ip := range addresses {
+		wg.Add(1)
+		go func(i int, ip net.IP) {
+			defer wg.Done()
+			for n := 0; n < loadBalancerAttempts; n++ {
+				res, prob := checkHTTP(ctx, domain, ip)
+				attempts[i] = append(attempts[i], backendAttempt{Result: res, Problem: prob})
+			}
+		}(i, ip)
+	}
+	wg.Wait()
+
+	var probs []Problem
+	for i, ip := range addresses {
+		if p, ok := analyzeBackendAttempts(domain, ip, attempts[i]); ok {
+			probs = append(probs, p)
+		}
+	}
+	return probs, nil
+}
+
+// analyzeBackendAttempts reports when repeated requests to the same address produced materially
+// different results. It says nothing if no request got a response at all, which is reported elsewhere.
+func analyzeBackendAttempts(domain string, ip net.IP, attempts []backendAttempt) (Problem, bool) {
+	signatures := map[string]int{}
+	var responded bool
+	var lines []string
+	for n, a := range attempts {
+		sig := a.Signature()
+		signatures[sig]++
+		if !a.Result.IsZero() {
+			responded = true
+		}
+		lines = append(lines, fmt.Sprintf("Attempt %d: %s", n+1, sig))
+	}
+	if !responded || len(signatures) < 2 {
+		return Problem{}, false
+	}
+
+	return inconsistentBackends(domain, ip, len(signatures), lines), true
+}
+
+func inconsistentBackends(domain string, ip net.IP, variants int, attempts []string) Problem {
+	return Problem{
+		Name: "InconsistentBackends",
+		Explanation: fmt.Sprintf(`Repeated validation requests to %s at %s produced %d different results. This usually means that `+
+			`the address belongs to a load balancer, and that its backends are not configured identically. If the ACME challenge `+
+			`response is only present on some of the backends, validation will fail whenever Let's Encrypt reaches one of the others. `+
+			`Serve /.well-known/acme-challenge/ from shared storage, route it to a single backend, or use the dns-01 method instead.`,
+			domain, ip, variants),
+		Detail:   strings.Join(attempts, "\n"),
+		Severity: SeverityWarning,
+	}
+}
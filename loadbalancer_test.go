@@ -0,0 +1,41 @@
+package letsdebug
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAnalyzeBackendAttempts(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+	ok := backendAttempt{Result: httpCheckResult{StatusCode: 200, InitialStatusCode: 200, ServerHeader: "nginx"}}
+	missing := backendAttempt{
+		Result:  httpCheckResult{StatusCode: 404, InitialStatusCode: 404, ServerHeader: "nginx"},
+		Problem: Problem{Name: "UnexpectedHttpResponse"},
+	}
+	otherServer := backendAttempt{Result: httpCheckResult{StatusCode: 200, InitialStatusCode: 200, ServerHeader: "Apache"}}
+	timeout := backendAttempt{Problem: Problem{Name: "ANotWorking"}}
+
+	tests := []struct {
+		name     string
+		attempts []backendAttempt
+		want     bool
+	}{
+		{"consistent", []backendAttempt{ok, ok, ok, ok, ok}, false},
+		{"alternating status", []backendAttempt{ok, missing, ok, missing, ok}, true},
+		{"different server", []backendAttempt{ok, ok, otherServer, ok, ok}, true},
+		{"some backends down", []backendAttempt{ok, timeout, ok, ok, ok}, true},
+		{"all down", []backendAttempt{timeout, timeout, timeout}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, got := analyzeBackendAttempts("example.org", ip, tt.attempts)
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v: %+v", tt.want, got, p)
+			}
+			if got && p.Name != "InconsistentBackends" {
+				t.Fatalf("unexpected problem: %+v", p)
+			}
+		})
+	}
+}
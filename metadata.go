@@ -0,0 +1,90 @@
+package letsdebug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	runtimedebug "runtime/debug"
+	"sync"
+)
+
+// Version is the version of Let's Debug. Release builds set it with
+// -ldflags "-X github.com/letsdebug/letsdebug.Version=v1.2.3"; otherwise it is taken from the
+// module version or VCS revision recorded in the binary.
+var Version string
+
+const (
+	modulePath       = "github.com/letsdebug/letsdebug"
+	publicSuffixPath = "github.com/weppos/publicsuffix-go"
+)
+
+// ResultMetadata identifies the version of Let's Debug and of the data sources it used, so that
+// results from a stale self-hosted deployment can be told apart from those of letsdebug.net.
+type ResultMetadata struct {
+	// Version is the version of Let's Debug which produced the result
+	Version string `json:"version"`
+	// PublicSuffixList is the version of the publicsuffix-go module, which embeds a snapshot of the list,
+	// or the version declared by the list (or a digest, if it declares none) if it was refreshed or replaced
+	PublicSuffixList string `json:"public_suffix_list"`
+	// CDNRanges is the date the embedded CDN address ranges were last updated, or a digest if they were refreshed
+	CDNRanges string `json:"cdn_ranges"`
+	// References is a digest of the table of documentation links in use, which may have been
+	// replaced with LETSDEBUG_REFERENCES_FILE or refreshed
+	References string `json:"references"`
+}
+
+var (
+	buildVersions     [2]string
+	buildVersionsOnce sync.Once
+)
+
+// moduleVersions returns the versions of Let's Debug and of publicsuffix-go from the build info of the binary.
+func moduleVersions() (letsdebugVersion, pslVersion string) {
+	buildVersionsOnce.Do(func() {
+		buildVersions = [2]string{"unknown", "unknown"}
+		info, ok := runtimedebug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		modules := append([]*runtimedebug.Module{&info.Main}, info.Deps...)
+		for _, m := range modules {
+			version := m.Version
+			if m.Replace != nil && m.Replace.Version != "" {
+				version = m.Replace.Version
+			}
+			switch m.Path {
+			case modulePath:
+				buildVersions[0] = version
+			case publicSuffixPath:
+				buildVersions[1] = version
+			}
+		}
+		// A binary built from a checkout of this repository has no module version, but has the revision
+		if info.Main.Path == modulePath && (buildVersions[0] == "" || buildVersions[0] == "(devel)") {
+			for _, s := range info.Settings {
+				if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+					buildVersions[0] = s.Value[:12]
+				}
+			}
+		}
+	})
+	return buildVersions[0], buildVersions[1]
+}
+
+// NewResultMetadata describes the running version of Let's Debug and its data sources.
+func NewResultMetadata() *ResultMetadata {
+	version, _ := moduleVersions()
+	if Version != "" {
+		version = Version
+	}
+	return &ResultMetadata{
+		Version:          version,
+		PublicSuffixList: dataVersion(publicSuffixData.name),
+		CDNRanges:        dataVersion(cdnRangesData.name),
+		References:       dataVersion(referencesData.name),
+	}
+}
+
+func digest(buf []byte) string {
+	sum := sha256.Sum256(buf)
+	return "sha256:" + hex.EncodeToString(sum[:])[:16]
+}
@@ -0,0 +1,89 @@
+package letsdebug
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics receives the measurements which the checkers make of themselves, such as how long each of
+// them ran for, so that a service built on Let's Debug can monitor them without the library depending
+// on any particular metrics system. It is shared by every scan in the process, and may be called from
+// several goroutines at once. Each name is always given the same set of label names.
+type Metrics interface {
+	// Count adds delta to the counter called name
+	Count(name string, labels map[string]string, delta float64)
+	// Time records a duration measured by the timer called name
+	Time(name string, labels map[string]string, d time.Duration)
+}
+
+// The measurements reported to Metrics
+const (
+	// metricCheckerDuration times each checker, by checker and method
+	metricCheckerDuration = "checker_duration"
+	// metricCheckerProblems counts the problems found by each checker, by checker and method
+	metricCheckerProblems = "checker_problems"
+	// metricCheckerErrors counts the checkers which failed, by checker and method
+	metricCheckerErrors = "checker_errors"
+	// metricStagingFailures counts the Let's Encrypt staging submissions which encountered internal errors, by method
+	metricStagingFailures = "staging_tests_failed"
+	// metricDataSourceReconnects counts the connections (re)established to third-party data sources, by source and outcome
+	metricDataSourceReconnects = "datasource_reconnects"
+)
+
+type noopMetrics struct{}
+
+func (noopMetrics) Count(string, map[string]string, float64)      {}
+func (noopMetrics) Time(string, map[string]string, time.Duration) {}
+
+var (
+	metricsMu sync.RWMutex
+	metrics   Metrics = noopMetrics{}
+)
+
+// SetMetrics sets where the measurements of the checkers are reported. They aren't reported
+// anywhere by default, or if m is nil.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noopMetrics{}
+	}
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = m
+}
+
+func currentMetrics() Metrics {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
+
+// DataSourceMetrics receives a measurement of each query made to a third-party data source (currently
+// the certwatch database of crt.sh), e.g. so that operators notice when a degraded source is inflating
+// scan times. It is shared by every scan in the process, and may be called from several goroutines at once.
+type DataSourceMetrics interface {
+	// ObserveQuery is called once a query of source completes, with how long it took and how many
+	// rows it returned. err is set if the query failed.
+	ObserveQuery(source string, duration time.Duration, rows int, err error)
+}
+
+var (
+	dataSourceMetricsMu sync.RWMutex
+	dataSourceMetrics   DataSourceMetrics
+)
+
+// SetDataSourceMetrics sets where the measurements of the queries made to third-party data sources are
+// reported. They aren't reported anywhere by default, or if m is nil.
+func SetDataSourceMetrics(m DataSourceMetrics) {
+	dataSourceMetricsMu.Lock()
+	defer dataSourceMetricsMu.Unlock()
+	dataSourceMetrics = m
+}
+
+func observeQuery(source string, start time.Time, rows int, err error) {
+	dataSourceMetricsMu.RLock()
+	m := dataSourceMetrics
+	dataSourceMetricsMu.RUnlock()
+	if m != nil {
+		m.ObserveQuery(source, time.Since(start), rows, err)
+	}
+}
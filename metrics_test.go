@@ -0,0 +1,78 @@
+package letsdebug
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingMetrics struct {
+	mu     sync.Mutex
+	counts map[string]float64
+	timers map[string]int
+}
+
+func (m *countingMetrics) Count(name string, labels map[string]string, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[name+"/"+labels["checker"]] += delta
+}
+
+func (m *countingMetrics) Time(name string, labels map[string]string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timers[name+"/"+labels["checker"]]++
+}
+
+type failingChecker struct{}
+
+func (failingChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	return []Problem{{Name: "Failing"}, {Name: "Failing"}}, errors.New("failed")
+}
+
+func TestMetrics(t *testing.T) {
+	m := &countingMetrics{counts: map[string]float64{}, timers: map[string]int{}}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	block := asyncCheckerBlock{failingChecker{}}
+	if _, err := block.Check(newScanContext(), "example.org", HTTP01); err == nil {
+		t.Fatal("expected the checker to fail")
+	}
+
+	if m.timers["checker_duration/letsdebug.failingChecker"] != 1 ||
+		m.counts["checker_problems/letsdebug.failingChecker"] != 2 ||
+		m.counts["checker_errors/letsdebug.failingChecker"] != 1 {
+		t.Fatalf("unexpected measurements: %v %v", m.counts, m.timers)
+	}
+}
+
+type recordedQuery struct {
+	source string
+	rows   int
+	err    error
+}
+
+type recordingMetrics []recordedQuery
+
+func (m *recordingMetrics) ObserveQuery(source string, duration time.Duration, rows int, err error) {
+	*m = append(*m, recordedQuery{source, rows, err})
+}
+
+func TestDataSourceMetrics(t *testing.T) {
+	// Nothing is reported, and nothing fails, until metrics are set
+	observeQuery("crt.sh", time.Now(), 1, nil)
+
+	var m recordingMetrics
+	SetDataSourceMetrics(&m)
+	defer SetDataSourceMetrics(nil)
+
+	failed := errors.New("connection refused")
+	observeQuery("crt.sh", time.Now(), 3, nil)
+	observeQuery("crt.sh", time.Now(), 0, failed)
+
+	if len(m) != 2 || m[0].rows != 3 || m[0].err != nil || m[1].err != failed || m[1].source != "crt.sh" {
+		t.Fatalf("unexpected measurements: %+v", m)
+	}
+}
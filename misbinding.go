@@ -0,0 +1,160 @@
+package letsdebug
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// misbindingProbeTimeout bounds each connection made by the protocol misbinding probes. A server
+// speaking the expected protocol may wait for more input than it was given, so the probes only ever
+// detect the wrong protocol and never report anything when they time out.
+const misbindingProbeTimeout = 5 * time.Second
+
+// protocolMisbinding is a port of an address of the domain which speaks the wrong protocol:
+// TLS on the HTTP port (80), or plain HTTP on the HTTPS port (443).
+type protocolMisbinding struct {
+	IP   net.IP
+	Port string
+	// Whether the port is the HTTPS port, i.e. was expected to speak TLS
+	HTTPS bool
+}
+
+// probeProtocolMisbinding checks the HTTP and HTTPS ports of each address for the wrong protocol, with
+// connections of its own rather than through net/http, whose errors are ambiguous about which side
+// spoke what.
+func probeProtocolMisbinding(domain string, ips []net.IP, httpPort, tlsPort int) []protocolMisbinding {
+	httpPortStr, tlsPortStr := strconv.Itoa(httpPort), strconv.Itoa(tlsPort)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var found []protocolMisbinding
+	for _, ip := range ips {
+		wg.Add(2)
+		go func(ip net.IP) {
+			defer wg.Done()
+			if speaksTLS(net.JoinHostPort(ip.String(), httpPortStr), domain) {
+				mu.Lock()
+				found = append(found, protocolMisbinding{IP: ip, Port: httpPortStr})
+				mu.Unlock()
+			}
+		}(ip)
+		go func(ip net.IP) {
+			defer wg.Done()
+			if speaksPlaintextHTTP(net.JoinHostPort(ip.String(), tlsPortStr), domain) {
+				mu.Lock()
+				found = append(found, protocolMisbinding{IP: ip, Port: tlsPortStr, HTTPS: true})
+				mu.Unlock()
+			}
+		}(ip)
+	}
+	wg.Wait()
+	return found
+}
+
+// speaksTLS is whether the server at addr completes a TLS handshake.
+func speaksTLS(addr, serverName string) bool {
+	conn, err := net.DialTimeout("tcp", addr, misbindingProbeTimeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(misbindingProbeTimeout))
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	return tlsConn.Handshake() == nil
+}
+
+// speaksPlaintextHTTP is whether the server at addr speaks plain HTTP rather than TLS. Many TLS
+// servers (including nginx, Apache and Go) answer a plain HTTP request with an HTTP 400 response,
+// so a TLS handshake must also fail.
+func speaksPlaintextHTTP(addr, host string) bool {
+	return answersHTTP(addr, host) && !speaksTLS(addr, host)
+}
+
+// answersHTTP is whether the server at addr answers a plain HTTP request with an HTTP response.
+func answersHTTP(addr, host string) bool {
+	conn, err := net.DialTimeout("tcp", addr, misbindingProbeTimeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(misbindingProbeTimeout))
+
+	if _, err := fmt.Fprintf(conn, "HEAD / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", host); err != nil {
+		return false
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return false
+	}
+	return bytes.Equal(buf, []byte("HTTP/"))
+}
+
+// analyzeProtocolMisbinding reports each port which speaks the wrong protocol. Plain HTTP on the HTTPS
+// port only breaks validation when a request is redirected to HTTPS on the domain itself, and is
+// otherwise a warning.
+func analyzeProtocolMisbinding(domain string, found []protocolMisbinding, results []httpCheckResult) []Problem {
+	redirectedToHTTPS := false
+	for _, res := range results {
+		for _, hop := range res.RedirectHops {
+			if strings.EqualFold(normalizeFqdn(hop.Host), domain) {
+				redirectedToHTTPS = true
+			}
+		}
+	}
+
+	var tlsOnHTTP, plaintextOnHTTPS []string
+	httpPort, httpsPort := "80", "443"
+	for _, m := range found {
+		if m.HTTPS {
+			plaintextOnHTTPS = append(plaintextOnHTTPS, m.IP.String())
+			httpsPort = m.Port
+		} else {
+			tlsOnHTTP = append(tlsOnHTTP, m.IP.String())
+			httpPort = m.Port
+		}
+	}
+
+	var probs []Problem
+	if len(tlsOnHTTP) > 0 {
+		probs = append(probs, tlsOnHTTPPort(domain, httpPort, tlsOnHTTP))
+	}
+	if len(plaintextOnHTTPS) > 0 {
+		probs = append(probs, plaintextOnHTTPSPort(domain, httpsPort, plaintextOnHTTPS, redirectedToHTTPS))
+	}
+	return probs
+}
+
+func tlsOnHTTPPort(domain, port string, addresses []string) Problem {
+	return Problem{
+		Name: "TLSOnHTTPPort",
+		Explanation: fmt.Sprintf(`The web server for %s answers with TLS (HTTPS) on port %s. Let's Encrypt always makes the `+
+			`validation request over plain HTTP to port 80, so it will fail. This is usually caused by enabling SSL on the port 80 `+
+			`virtual host (such as "listen 80 ssl" in nginx, or "SSLEngine on" in an Apache <VirtualHost *:80>), or by forwarding `+
+			`port 80 to the HTTPS port of the server.`, domain, port),
+		Detail:   fmt.Sprintf("A TLS handshake succeeded on port %s of: %s", port, strings.Join(addresses, ", ")),
+		Severity: SeverityError,
+	}
+}
+
+func plaintextOnHTTPSPort(domain, port string, addresses []string, redirectedToHTTPS bool) Problem {
+	severity := SeverityWarning
+	if redirectedToHTTPS {
+		severity = SeverityError
+	}
+	return Problem{
+		Name: "PlaintextOnHTTPSPort",
+		Explanation: fmt.Sprintf(`The web server for %s answers with plain HTTP on port %s, instead of TLS (HTTPS). Let's `+
+			`Encrypt follows redirects to HTTPS, and validation will fail if the request is redirected there. This is usually `+
+			`caused by a port 443 virtual host without SSL enabled (such as "listen 443" without "ssl" in nginx), or by forwarding `+
+			`port 443 to the HTTP port of the server.`, domain, port),
+		Detail:   fmt.Sprintf("A plain HTTP request was answered on port %s of: %s", port, strings.Join(addresses, ", ")),
+		Severity: severity,
+	}
+}
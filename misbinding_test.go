@@ -0,0 +1,68 @@
+package letsdebug
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProtocolProbes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	plainAddr, secureAddr := plain.Listener.Addr().String(), secure.Listener.Addr().String()
+	if !speaksTLS(secureAddr, "example.org") {
+		t.Error("expected the TLS server to complete a handshake")
+	}
+	if !answersHTTP(secureAddr, "example.org") {
+		t.Error("expected the TLS server to answer a plain HTTP request with an error response")
+	}
+	if speaksPlaintextHTTP(secureAddr, "example.org") {
+		t.Error("expected the TLS server not to answer a plain HTTP request")
+	}
+	if !speaksPlaintextHTTP(plainAddr, "example.org") {
+		t.Error("expected the plain HTTP server to answer a plain HTTP request")
+	}
+}
+
+func TestAnalyzeProtocolMisbinding(t *testing.T) {
+	if probs := analyzeProtocolMisbinding("example.org", nil, nil); len(probs) != 0 {
+		t.Fatalf("expected nothing to be reported, got: %v", probs)
+	}
+
+	found := []protocolMisbinding{
+		{IP: net.ParseIP("192.0.2.1"), Port: "80"},
+		{IP: net.ParseIP("192.0.2.2"), Port: "443", HTTPS: true},
+	}
+	probs := analyzeProtocolMisbinding("example.org", found, nil)
+	if len(probs) != 2 || probs[0].Name != "TLSOnHTTPPort" || probs[1].Name != "PlaintextOnHTTPSPort" {
+		t.Fatalf("expected both directions to be reported, got: %v", probs)
+	}
+	if probs[1].Severity != SeverityWarning {
+		t.Errorf("expected plain HTTP on port 443 to be a warning without a redirect to HTTPS, got: %s", probs[1].Severity)
+	}
+
+	results := []httpCheckResult{{RedirectHops: []redirectHop{{URL: "https://example.org/", Host: "example.org"}}}}
+	probs = analyzeProtocolMisbinding("example.org", found[1:], results)
+	if len(probs) != 1 || probs[0].Severity != SeverityError {
+		t.Fatalf("expected plain HTTP on port 443 to be an error after a redirect to HTTPS, got: %v", probs)
+	}
+}
+
+func TestTranslateHTTPErrorMisbinding(t *testing.T) {
+	for _, err := range []error{
+		errors.New(`Get "https://example.org/": http: server gave HTTP response to HTTPS client`),
+		errors.New(`Get "http://example.org/": net/http: HTTP/1.x transport connection broken: malformed HTTP response "\x15\x03\x01\x00\x02\x02P"`),
+	} {
+		p := translateHTTPError("example.org", net.ParseIP("192.0.2.1"), err, nil)
+		if p.Name != "WebserverMisconfiguration" || !strings.Contains(p.Detail, "wrong protocol") {
+			t.Errorf("expected %q to be reported as a misconfiguration, got: %v", err, p)
+		}
+	}
+}
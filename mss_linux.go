@@ -0,0 +1,17 @@
+//go:build linux
+
+package letsdebug
+
+import "syscall"
+
+// setMSS limits the maximum segment size of a TCP connection before it is established, which is
+// advertised to the server in the SYN and so also limits the size of the segments it sends.
+func setMSS(c syscall.RawConn, mss int) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG, mss)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
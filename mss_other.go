@@ -0,0 +1,13 @@
+//go:build !linux
+
+package letsdebug
+
+import (
+	"errors"
+	"syscall"
+)
+
+// setMSS is only supported on Linux, elsewhere the path MTU probe is skipped.
+func setMSS(c syscall.RawConn, mss int) error {
+	return errors.New("setting the TCP maximum segment size is not supported on this platform")
+}
@@ -0,0 +1,105 @@
+package letsdebug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// When a connection is established but the response never arrives, the server's full-sized packets
+// may be getting dropped somewhere along the path, with the ICMP "fragmentation needed" messages which
+// would tell it to send smaller ones filtered out (a path MTU discovery blackhole). This is common on
+// PPPoE links and IPv6 tunnels, whose MTU is smaller than usual. Advertising a small maximum segment
+// size in a second request makes the server send small packets, which get through if that is the case.
+const (
+	reducedMSSv4 = 536
+	reducedMSSv6 = 1220
+)
+
+// hungAfterConnect is whether the validation request to the initial address connected, then timed out
+// without any response, rather than somewhere along its redirects.
+func hungAfterConnect(res httpCheckResult, prob Problem) bool {
+	if !res.Connected || res.InitialStatusCode != 0 || prob.Err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(prob.Err, &netErr) && netErr.Timeout()
+}
+
+// probeReducedMSS repeats the validation request to address over a connection with a reduced maximum
+// segment size, and returns the start of the response.
+func probeReducedMSS(ctx *scanContext, domain string, address net.IP) (string, error) {
+	mss := reducedMSSv4
+	if address.To4() == nil {
+		mss = reducedMSSv6
+	}
+	dialer := net.Dialer{
+		Timeout: httpTimeout * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			return setMSS(c, mss)
+		},
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), httpTimeout*time.Second)
+	defer cancel()
+	conn, err := dialer.DialContext(timeoutCtx, "tcp", net.JoinHostPort(address.String(), "80"))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	deadline, _ := timeoutCtx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := fmt.Fprintf(conn, "GET /.well-known/acme-challenge/%s HTTP/1.1\r\nHost: %s\r\nAccept: */*\r\n"+
+		"User-Agent: %s\r\nConnection: close\r\n\r\n", ctx.httpRequestPath, domain, ctx.probeUserAgent()); err != nil {
+		return "", err
+	}
+	buf, err := io.ReadAll(io.LimitReader(conn, 8192))
+	if len(buf) == 0 && err != nil {
+		return "", err
+	}
+	if !bytes.HasPrefix(buf, []byte("HTTP/")) {
+		return "", fmt.Errorf("the response was not HTTP: %q", buf[:min(len(buf), 64)])
+	}
+	statusLine, _, _ := strings.Cut(string(buf), "\r\n")
+	return statusLine, nil
+}
+
+// analyzeHungConnection replaces the timeout of a validation request which connected and then hung,
+// when repeating it with a reduced maximum segment size succeeds.
+func analyzeHungConnection(ctx *scanContext, domain string, address net.IP, res httpCheckResult, prob Problem) Problem {
+	if !hungAfterConnect(res, prob) {
+		return prob
+	}
+	statusLine, err := probeReducedMSS(ctx, domain, address)
+	if err != nil {
+		return prob
+	}
+	return pathMTUBlackhole(domain, address, statusLine, prob)
+}
+
+func pathMTUBlackhole(domain string, address net.IP, statusLine string, timeout Problem) Problem {
+	mss := reducedMSSv4
+	if address.To4() == nil {
+		mss = reducedMSSv6
+	}
+	return Problem{
+		Name: "PathMTUBlackhole",
+		Explanation: fmt.Sprintf(`A request to %s (%s) connected but never received a response, yet the same request succeeded `+
+			`when the server was asked to send smaller packets. This is most likely a path MTU problem: the server's full-sized `+
+			`packets are dropped somewhere along the way, and the ICMP messages which would tell it to send smaller ones are `+
+			`blocked. This is common behind PPPoE links and IPv6 tunnels. Allow ICMP "fragmentation needed" (IPv4) and "packet `+
+			`too big" (IPv6) messages through your firewall, lower the MTU of the server's network interface, or enable MSS `+
+			`clamping on your router.`, domain, address),
+		Detail: fmt.Sprintf("With a maximum segment size of %d, the server responded: %s\n\nThe original request failed with: %s",
+			mss, statusLine, timeout.Detail),
+		Severity: SeverityError,
+		Err:      timeout.Err,
+	}
+}
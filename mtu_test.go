@@ -0,0 +1,67 @@
+package letsdebug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+func TestHungAfterConnect(t *testing.T) {
+	timeout := aNotWorking("example.org", "192.0.2.1", fmt.Errorf("A timeout was experienced: %w",
+		&url.Error{Op: "Get", URL: "http://example.org/", Err: context.DeadlineExceeded}), nil)
+	refused := aNotWorking("example.org", "192.0.2.1", errors.New("connection refused"), nil)
+
+	tests := []struct {
+		name string
+		res  httpCheckResult
+		prob Problem
+		want bool
+	}{
+		{"hung", httpCheckResult{Connected: true}, timeout, true},
+		{"never connected", httpCheckResult{}, timeout, false},
+		{"refused", httpCheckResult{Connected: true}, refused, false},
+		{"hung after a redirect", httpCheckResult{Connected: true, InitialStatusCode: 301}, timeout, false},
+		{"no problem", httpCheckResult{Connected: true, StatusCode: 200, InitialStatusCode: 200}, Problem{}, false},
+	}
+	for _, tt := range tests {
+		if got := hungAfterConnect(tt.res, tt.prob); got != tt.want {
+			t.Errorf("%s: hungAfterConnect = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetMSS(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("setting the maximum segment size is only supported on Linux")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	var mssErr error
+	dialer := net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+		mssErr = setMSS(c, reducedMSSv4)
+		return mssErr
+	}}
+	conn, err := dialer.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect with a reduced maximum segment size: %v (%v)", err, mssErr)
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: example.org\r\nConnection: close\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 12)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "HTTP/1.1 404" {
+		t.Fatalf("expected a response over the connection, got %q (%v)", buf, err)
+	}
+}
@@ -0,0 +1,59 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Site operators who don't want Let's Debug (or anyone using it) to probe their web server can
+// respond with "X-LetsDebug: deny". No further HTTP requests are then made to the domain for the
+// rest of the test, and the refusal is reported instead.
+const (
+	optOutHeader = "X-LetsDebug"
+	optOutValue  = "deny"
+)
+
+// errHTTPOptOut stops a redirect from being followed when the redirecting response opted out.
+var errHTTPOptOut = errors.New("the server asked not to be probed")
+
+// optedOut is whether resp asks not to be probed any further.
+func optedOut(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	for _, v := range resp.Header.Values(optOutHeader) {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), optOutValue) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// analyzeHTTPOptOut is run once every checker has completed, and reports when the web server
+// asked not to be probed, since the results of the HTTP checks are then incomplete.
+func analyzeHTTPOptOut(ctx *scanContext, domain string, method ValidationMethod) []Problem {
+	if method != HTTP01 {
+		return nil
+	}
+	url := ctx.httpOptOutURL()
+	if url == "" {
+		return nil
+	}
+	return []Problem{httpProbingRefused(domain, url)}
+}
+
+func httpProbingRefused(domain, url string) Problem {
+	return Problem{
+		Name: "HTTPProbingRefused",
+		Explanation: fmt.Sprintf(`The web server for %s responded with "%s: %s", asking not to be probed by Let's Debug, so no `+
+			`further HTTP requests were made to it. The results of the HTTP checks are incomplete, and problems with the web server `+
+			`may not have been found. This header has no effect on Let's Encrypt, which will still make its validation requests.`,
+			domain, optOutHeader, optOutValue),
+		Detail:   fmt.Sprintf("Refused by the response to %s", url),
+		Severity: SeverityInfo,
+	}
+}
@@ -0,0 +1,53 @@
+package letsdebug
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOptedOut(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{"deny"}, true},
+		{[]string{"DENY"}, true},
+		{[]string{"noindex, deny"}, true},
+		{[]string{"allow"}, false},
+		{[]string{"allow", "deny"}, true},
+		{[]string{"denying"}, false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		for _, v := range tt.values {
+			resp.Header.Add(optOutHeader, v)
+		}
+		if got := optedOut(resp); got != tt.want {
+			t.Errorf("optedOut(%q) = %t, want %t", tt.values, got, tt.want)
+		}
+	}
+	if optedOut(nil) {
+		t.Error("expected no response not to opt out")
+	}
+}
+
+func TestAnalyzeHTTPOptOut(t *testing.T) {
+	ctx := newScanContext()
+	if probs := analyzeHTTPOptOut(ctx, "example.org", HTTP01); len(probs) != 0 {
+		t.Fatalf("expected nothing to be reported without a refusal, got: %v", probs)
+	}
+
+	ctx.recordHTTPOptOut("http://example.org/.well-known/acme-challenge/letsdebug-test")
+	ctx.recordHTTPOptOut("https://example.org/.well-known/acme-challenge/letsdebug-test")
+	probs := analyzeHTTPOptOut(ctx, "example.org", HTTP01)
+	if len(probs) != 1 || probs[0].Name != "HTTPProbingRefused" || probs[0].Severity != SeverityInfo {
+		t.Fatalf("expected the refusal to be reported, got: %v", probs)
+	}
+	if probs[0].Detail != "Refused by the response to http://example.org/.well-known/acme-challenge/letsdebug-test" {
+		t.Fatalf("expected the first refusal to be kept, got: %s", probs[0].Detail)
+	}
+	if probs := analyzeHTTPOptOut(ctx, "example.org", DNS01); len(probs) != 0 {
+		t.Fatalf("expected dns-01 to be unaffected, got: %v", probs)
+	}
+}
@@ -0,0 +1,236 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxOrderIdentifiers is the most names Let's Encrypt will include in a single certificate.
+	maxOrderIdentifiers = 100
+	// orderConcurrency is how many identifiers of an order are checked at once.
+	orderConcurrency = 4
+)
+
+// Identifier is a single name in a certificate order. Only "dns" identifiers are supported.
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// Method overrides the validation method of the order for this identifier, as ACME clients
+	// allow, e.g. to validate wildcards via dns-01 and everything else via http-01.
+	Method ValidationMethod `json:"method,omitempty"`
+}
+
+// methodOr returns the validation method of the identifier, falling back to orderMethod.
+func (id Identifier) methodOr(orderMethod ValidationMethod) ValidationMethod {
+	if id.Method != "" {
+		return id.Method
+	}
+	return orderMethod
+}
+
+// DNSIdentifier returns a "dns" Identifier for domain.
+func DNSIdentifier(domain string) Identifier {
+	return Identifier{Type: "dns", Value: domain}
+}
+
+// IdentifierResult holds the problems found for a single identifier of an order.
+type IdentifierResult struct {
+	Identifier Identifier `json:"identifier"`
+	Problems   []Problem  `json:"problems"`
+}
+
+// OrderResult holds the problems found by CheckOrder.
+type OrderResult struct {
+	// Identifiers holds the result of checking each identifier, in the order they were given.
+	Identifiers []IdentifierResult `json:"identifiers"`
+	// Problems affect the order as a whole, rather than a single identifier.
+	Problems []Problem `json:"problems"`
+}
+
+// CheckOrder mirrors an ACME order containing several identifiers: each identifier is checked
+// as by CheckWithOptions, using its own Method if it has one, and method otherwise, and then the order as a whole is checked for problems which only arise
+// from the combination of identifiers, such as the Duplicate Certificate rate limit. Because one
+// bad identifier causes the entire order to fail, that is also reported at the order level.
+// If an identifier could not be checked, the error is reported as a problem of that identifier.
+func CheckOrder(identifiers []Identifier, method ValidationMethod, opts Options) (OrderResult, error) {
+	var result OrderResult
+	if len(identifiers) == 0 {
+		return result, errors.New("an order must contain at least one identifier")
+	}
+	for _, id := range identifiers {
+		if id.Type != "dns" {
+			return result, fmt.Errorf("unsupported identifier type %q for %q: only dns identifiers are supported", id.Type, id.Value)
+		}
+	}
+
+	if len(identifiers) > maxOrderIdentifiers {
+		result.Problems = append(result.Problems, tooManyIdentifiers(len(identifiers)))
+		return result, nil
+	}
+
+	// Every identifier shares the crt.sh results of the others
+	certificates := newCertificateMemo()
+	certificates.offline = opts.Offline
+
+	result.Identifiers = make([]IdentifierResult, len(identifiers))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, orderConcurrency)
+	for i, id := range identifiers {
+		wg.Add(1)
+		go func(i int, id Identifier) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result.Identifiers[i] = IdentifierResult{Identifier: id, Problems: checkIdentifier(certificates, id, id.methodOr(method), opts)}
+		}(i, id)
+	}
+	wg.Wait()
+
+	result.Problems = append(result.Problems, checkOrder(certificates, result.Identifiers, method)...)
+	result.Problems = suppressProblems(result.Problems, opts.SuppressProblems)
+	result.Problems = overrideSeverities(result.Problems, opts.SeverityOverrides)
+	SortProblems(result.Problems)
+	return result, nil
+}
+
+func checkIdentifier(certificates *certificateMemo, id Identifier, method ValidationMethod, opts Options) (probs []Problem) {
+	defer func() {
+		if r := recover(); r != nil {
+			probs = append(probs, internalProblem(fmt.Sprintf("panic: %v", r), SeverityError))
+		}
+	}()
+
+	ctx := newScanContextWithOptions(opts)
+	ctx.certificates = certificates
+	probs, err := check(ctx, id.Value, method)
+	if err != nil {
+		probs = append(probs, internalProblem(fmt.Sprintf("Checking %s failed: %v", id.Value, err), SeverityError))
+		SortProblems(probs)
+	}
+	return probs
+}
+
+// checkOrder looks for problems with the combination of identifiers in the order.
+func checkOrder(certificates *certificateMemo, results []IdentifierResult, method ValidationMethod) []Problem {
+	var probs []Problem
+
+	names := map[string]bool{}
+	var wildcards []string
+	for _, r := range results {
+		name := normalizeFqdn(r.Identifier.Value)
+		names[name] = true
+		if strings.HasPrefix(name, "*.") && r.Identifier.methodOr(method) != DNS01 {
+			wildcards = append(wildcards, fmt.Sprintf("%s (%s)", name, r.Identifier.methodOr(method)))
+		}
+	}
+
+	if len(wildcards) > 0 {
+		probs = append(probs, orderWildcardMethod(wildcards, len(names)))
+	}
+
+	if p, ok := orderDuplicateCertificate(certificates, names); ok {
+		probs = append(probs, p)
+	}
+
+	if p, ok := orderBlockedByIdentifier(results); ok {
+		probs = append(probs, p)
+	}
+
+	return probs
+}
+
+// orderDuplicateCertificate checks the Duplicate Certificate limit against the exact set of names in
+// the order, which the checks of the individual identifiers cannot know about.
+func orderDuplicateCertificate(certificates *certificateMemo, names map[string]bool) (Problem, bool) {
+	if os.Getenv("LETSDEBUG_DISABLE_CERTWATCH") != "" {
+		return Problem{}, false
+	}
+
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	key := strings.Join(sorted, ",")
+
+	domain := strings.TrimPrefix(sorted[0], "*.")
+	registeredDomain, err := effectiveTLDPlusOne(domain)
+	if err != nil {
+		return Problem{}, false
+	}
+	certs, _, err := certificates.get(registeredDomain)
+	if err != nil {
+		return Problem{}, false
+	}
+
+	if dupes := certs.CountDuplicates(sorted[0])[key]; dupes >= 5 {
+		return rateLimited(domain, fmt.Sprintf(`The Duplicate Certificate limit (5 certificates with the exact same set of domains per week) `+
+			`has been exceeded by %d certificates for exactly the names in this order: "%s". Adding or removing a name from the order `+
+			`would avoid this rate limit.`, dupes, key)), true
+	}
+	return Problem{}, false
+}
+
+// orderBlockedByIdentifier summarizes which identifiers have problems serious enough to cause the
+// whole order to fail.
+func orderBlockedByIdentifier(results []IdentifierResult) (Problem, bool) {
+	var lines []string
+	worst := SeverityDebug
+	for _, r := range results {
+		var blocking []string
+		for _, p := range r.Problems {
+			if p.Severity != SeverityFatal && p.Severity != SeverityError {
+				continue
+			}
+			blocking = append(blocking, p.Name)
+			if p.Severity == SeverityFatal {
+				worst = SeverityFatal
+			} else if worst != SeverityFatal {
+				worst = SeverityError
+			}
+		}
+		if len(blocking) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s", r.Identifier.Value, strings.Join(blocking, ", ")))
+		}
+	}
+	if len(lines) == 0 {
+		return Problem{}, false
+	}
+
+	return Problem{
+		Name: "OrderBlockedByIdentifier",
+		Explanation: fmt.Sprintf(`%d of the %d identifiers in this order have problems which are likely to prevent validation. `+
+			`Let's Encrypt only issues a certificate once every identifier in the order has been validated, so a single failing `+
+			`identifier causes the whole order to fail. Fix the problems listed for these identifiers, or remove them from the order.`,
+			len(lines), len(results)),
+		Detail:   strings.Join(lines, "\n"),
+		Severity: worst,
+	}, true
+}
+
+func orderWildcardMethod(wildcards []string, count int) Problem {
+	return Problem{
+		Name: "OrderMethodNotSuitable",
+		Explanation: fmt.Sprintf(`This order of %d identifiers includes wildcard identifiers, which can only be validated using dns-01, `+
+			`but a different method was assigned to them. The order cannot succeed unless the wildcard identifiers are validated `+
+			`with dns-01, or removed from the order. Most ACME clients allow a different method to be configured for each name.`, count),
+		Detail:   strings.Join(wildcards, "\n"),
+		Severity: SeverityFatal,
+	}
+}
+
+func tooManyIdentifiers(count int) Problem {
+	return Problem{
+		Name: "TooManyIdentifiers",
+		Explanation: fmt.Sprintf(`This order has %d identifiers, but Let's Encrypt allows at most %d names per certificate. `+
+			`Split the names across several certificates.`, count, maxOrderIdentifiers),
+		Detail:   fmt.Sprintf("%d identifiers", count),
+		Severity: SeverityFatal,
+	}
+}
@@ -0,0 +1,85 @@
+package letsdebug
+
+import (
+	"crypto/x509"
+	"math/big"
+	"strconv"
+	"testing"
+)
+
+func TestCheckOrderInvalid(t *testing.T) {
+	if _, err := CheckOrder(nil, HTTP01, Options{}); err == nil {
+		t.Error("expected an empty order to be rejected")
+	}
+	if _, err := CheckOrder([]Identifier{{Type: "ip", Value: "192.0.2.1"}}, HTTP01, Options{}); err == nil {
+		t.Error("expected an ip identifier to be rejected")
+	}
+
+	var ids []Identifier
+	for i := 0; i <= maxOrderIdentifiers; i++ {
+		ids = append(ids, DNSIdentifier(strconv.Itoa(i)+".example.org"))
+	}
+	result, err := CheckOrder(ids, HTTP01, Options{})
+	if err != nil || len(result.Problems) != 1 || result.Problems[0].Name != "TooManyIdentifiers" || len(result.Identifiers) != 0 {
+		t.Errorf("expected the order to be rejected without checking identifiers, got: %+v, %v", result, err)
+	}
+}
+
+func TestCheckOrderCombination(t *testing.T) {
+	t.Setenv("LETSDEBUG_DISABLE_CERTWATCH", "")
+
+	// Five certificates for exactly this order, and one with an extra name
+	memo := newCertificateMemo()
+	entry := &certificateMemoEntry{}
+	entry.once.Do(func() {
+		entry.certs = crtList{}
+		for i := 0; i < 6; i++ {
+			names := []string{"*.example.org", "example.org"}
+			if i == 5 {
+				names = append(names, "www.example.org")
+			}
+			entry.certs[strconv.Itoa(i)] = &x509.Certificate{SerialNumber: big.NewInt(int64(i)), DNSNames: names}
+		}
+	})
+	memo.entries["example.org"] = entry
+
+	results := []IdentifierResult{
+		{Identifier: DNSIdentifier("example.org")},
+		{Identifier: DNSIdentifier("*.example.org"), Problems: []Problem{wildcardHTTP01("*.example.org", HTTP01)}},
+	}
+
+	probs := checkOrder(memo, results, HTTP01)
+	names := map[string]Problem{}
+	for _, p := range probs {
+		names[p.Name] = p
+	}
+	if len(probs) != 3 || names["OrderMethodNotSuitable"].Name == "" || names["RateLimit"].Name == "" ||
+		names["OrderBlockedByIdentifier"].Severity != SeverityFatal {
+		t.Fatalf("unexpected order problems: %+v", probs)
+	}
+	if names["OrderBlockedByIdentifier"].Detail != "*.example.org: MethodNotSuitable" {
+		t.Errorf("unexpected blocking identifiers: %q", names["OrderBlockedByIdentifier"].Detail)
+	}
+
+	results[1].Problems = nil
+	if probs := checkOrder(memo, results[:1], DNS01); len(probs) != 0 {
+		t.Errorf("expected no order problems for a single name, got: %+v", probs)
+	}
+}
+
+func TestCheckOrderMixedMethods(t *testing.T) {
+	t.Setenv("LETSDEBUG_DISABLE_CERTWATCH", "1")
+
+	wildcard := Identifier{Type: "dns", Value: "*.example.org", Method: DNS01}
+	results := []IdentifierResult{{Identifier: DNSIdentifier("example.org")}, {Identifier: wildcard}}
+	if probs := checkOrder(newCertificateMemo(), results, HTTP01); len(probs) != 0 {
+		t.Errorf("expected wildcards validated via dns-01 to be accepted in an http-01 order, got: %+v", probs)
+	}
+
+	wildcard.Method = HTTP01
+	results[1].Identifier = wildcard
+	probs := checkOrder(newCertificateMemo(), results, DNS01)
+	if len(probs) != 1 || probs[0].Name != "OrderMethodNotSuitable" || probs[0].Detail != "*.example.org (http-01)" {
+		t.Errorf("expected the per-identifier method to override the order, got: %+v", probs)
+	}
+}
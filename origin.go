@@ -0,0 +1,102 @@
+package letsdebug
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// originHintLabels are subdomains which are commonly pointed directly at the origin server of a
+// proxied website, bypassing the CDN.
+var originHintLabels = []string{"origin", "direct", "direct-connect", "origin-www"}
+
+// originChecker applies when the domain is served by a known CDN or reverse proxy. It sends the
+// http-01 validation request straight to the origin server, to tell users whether their origin
+// would pass validation on its own, e.g. if they were to pause the proxy. It only runs when the
+// origin has been supplied via Options.OriginAddresses, or Options.ProbeOriginHints is set.
+type originChecker struct{}
+
+func (c originChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method != HTTP01 || (len(ctx.originAddresses) == 0 && !ctx.probeOriginHints) {
+		return nil, errNotApplicable
+	}
+
+	var proxyIP net.IP
+	var provider string
+	for _, ip := range ctx.LookupAddresses(domain) {
+		if name, ok := cdnForAddress(ip); ok {
+			proxyIP, provider = ip, name
+			break
+		}
+	}
+	if proxyIP == nil {
+		return nil, errNotApplicable
+	}
+
+	origins := append([]net.IP(nil), ctx.originAddresses...)
+	var hints []string
+	if ctx.probeOriginHints {
+		for _, label := range originHintLabels {
+			hint := label + "." + domain
+			for _, ip := range ctx.LookupAddresses(hint) {
+				if _, isCDN := cdnForAddress(ip); isCDN {
+					continue
+				}
+				origins = append(origins, ip)
+				hints = append(hints, fmt.Sprintf("%s -> %s", hint, ip))
+			}
+		}
+	}
+	if len(origins) == 0 {
+		return []Problem{debugProblem("OriginCheck", fmt.Sprintf("%s is served by %s, but no origin server was found", domain, provider),
+			fmt.Sprintf("Looked for: %s", strings.Join(originHintLabels, ", ")))}, nil
+	}
+
+	proxyRes, proxyProb := checkHTTP(ctx, domain, proxyIP)
+	lines := []string{fmt.Sprintf("Via %s (%s): %s %s", provider, proxyIP, proxyRes.String(), proxyProb.Name)}
+	lines = append(lines, hints...)
+
+	var probs []Problem
+	for _, origin := range origins {
+		if isAddressReserved(origin) {
+			lines = append(lines, fmt.Sprintf("Origin %s: skipped, reserved address", origin))
+			continue
+		}
+		originRes, originProb := checkHTTP(ctx, domain, origin)
+		lines = append(lines, fmt.Sprintf("Origin %s: %s %s", origin, originRes.String(), originProb.Name))
+
+		switch {
+		case proxyProb.IsZero() && !originProb.IsZero():
+			probs = append(probs, originWouldFail(domain, provider, origin, originProb))
+		case !proxyProb.IsZero() && originProb.IsZero():
+			probs = append(probs, proxyBlocksValidation(domain, provider, origin, proxyProb))
+		}
+	}
+
+	probs = append(probs, debugProblem("OriginCheck",
+		fmt.Sprintf("%s is served by %s, so the validation request was also sent directly to the origin", domain, provider),
+		strings.Join(lines, "\n")))
+	return probs, nil
+}
+
+func originWouldFail(domain, provider string, origin net.IP, prob Problem) Problem {
+	return Problem{
+		Name: "OriginWouldFail",
+		Explanation: fmt.Sprintf(`%s is served by %s, which answered the validation request, but the origin server at %s did not. `+
+			`Validation currently works through the proxy, but would fail if the proxy were disabled or bypassed.`,
+			domain, provider, origin),
+		Detail:   fmt.Sprintf("%s\n\n%s", prob.Explanation, prob.Detail),
+		Severity: SeverityWarning,
+	}
+}
+
+func proxyBlocksValidation(domain, provider string, origin net.IP, prob Problem) Problem {
+	return Problem{
+		Name: "ProxyBlocksValidation",
+		Explanation: fmt.Sprintf(`The origin server of %s at %s answered the validation request, but %s, which serves the domain, did not. `+
+			`The proxy configuration (such as firewall rules, bot protection or forced HTTPS redirects) is likely preventing validation.`,
+			domain, origin, provider),
+		Detail:   fmt.Sprintf("%s\n\n%s", prob.Explanation, prob.Detail),
+		Severity: SeverityError,
+	}
+}
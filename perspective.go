@@ -0,0 +1,381 @@
+package letsdebug
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// perspectiveTimeout bounds how long a probe agent may take to repeat the checks, which involves
+// resolving the domain and making the validation request to each of its addresses.
+const perspectiveTimeout = 45 * time.Second
+
+// Perspective is a probe agent in another network location, which repeats the DNS lookups and the
+// validation request of a test from there, like the remote perspectives Let's Encrypt validates from.
+// Agents are operated by the caller, see NewProbeAgent.
+type Perspective struct {
+	// Name identifies the perspective, e.g. "aws-eu-central-1"
+	Name string `json:"name"`
+	// Country is the ISO 3166-1 alpha-2 code of the country the agent runs in, e.g. "DE"
+	Country string `json:"country"`
+	// URL is where the agent accepts PerspectiveRequests
+	URL string `json:"url"`
+	// Token is sent to the agent as a bearer token, if set
+	Token string `json:"token,omitempty"`
+}
+
+// PerspectiveRequest is POSTed as JSON to a probe agent, which answers with a PerspectiveReport.
+type PerspectiveRequest struct {
+	Domain          string           `json:"domain"`
+	Method          ValidationMethod `json:"method"`
+	HTTPRequestPath string           `json:"http_request_path,omitempty"`
+}
+
+// PerspectiveReport is what a probe agent saw of the domain.
+type PerspectiveReport struct {
+	// Addresses are the A and AAAA records of the domain
+	Addresses []string `json:"addresses"`
+	// TXT are the TXT records of _acme-challenge, only looked up for dns-01
+	TXT []string `json:"txt,omitempty"`
+	// DNSError is set if the lookups failed
+	DNSError string `json:"dns_error,omitempty"`
+	// HTTP is the outcome of the validation request, only made for http-01
+	HTTP *PerspectiveHTTP `json:"http,omitempty"`
+}
+
+// PerspectiveHTTP is the outcome of the validation request made by a probe agent.
+type PerspectiveHTTP struct {
+	// Reachable is whether any address of the domain responded, with any HTTP status
+	Reachable bool `json:"reachable"`
+	// Detail describes the outcome for each address
+	Detail string `json:"detail,omitempty"`
+	// Connections is how the connection to port 80 of each address went: connected, refused, reset,
+	// timeout or unreachable
+	Connections map[string]string `json:"connections,omitempty"`
+}
+
+// NewProbeAgent returns a handler which repeats the checks of a PerspectiveRequest from wherever it runs.
+// If token is not empty, requests must present it as a bearer token.
+func NewProbeAgent(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req PerspectiveRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Malformed request", http.StatusBadRequest)
+			return
+		}
+		if !validMethods[req.Method] || req.Domain == "" {
+			http.Error(w, "Missing or invalid domain or method", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(probePerspective(req))
+	})
+}
+
+// probePerspective performs the checks of a probe agent.
+func probePerspective(req PerspectiveRequest) PerspectiveReport {
+	sc := newScanContext()
+	if req.HTTPRequestPath != "" {
+		sc.httpRequestPath = req.HTTPRequestPath
+	}
+	domain := normalizeFqdn(strings.TrimPrefix(req.Domain, "*."))
+
+	var report PerspectiveReport
+	if req.Method == DNS01 {
+		txt, err := lookupChallengeTXT(sc, domain)
+		if err != nil {
+			report.DNSError = err.Error()
+		}
+		report.TXT = txt
+		return report
+	}
+
+	var lookupErr error
+	for _, rrType := range []uint16{dns.TypeAAAA, dns.TypeA} {
+		rrs, err := sc.Lookup(domain, rrType)
+		if err != nil && lookupErr == nil {
+			lookupErr = err
+		}
+		for _, rr := range rrs {
+			switch rr := rr.(type) {
+			case *dns.AAAA:
+				report.Addresses = append(report.Addresses, rr.AAAA.String())
+			case *dns.A:
+				report.Addresses = append(report.Addresses, rr.A.String())
+			}
+		}
+	}
+	if lookupErr != nil && len(report.Addresses) == 0 {
+		report.DNSError = lookupErr.Error()
+	}
+
+	if req.Method != HTTP01 || len(report.Addresses) == 0 {
+		return report
+	}
+	var ips []net.IP
+	for _, addr := range report.Addresses {
+		ips = append(ips, net.ParseIP(addr))
+	}
+	report.HTTP = &PerspectiveHTTP{Connections: dialPort80(ips)}
+	var lines []string
+	for _, addr := range report.Addresses {
+		res, prob := checkHTTP(sc, domain, net.ParseIP(addr))
+		switch {
+		case !res.IsZero():
+			report.HTTP.Reachable = true
+			lines = append(lines, fmt.Sprintf("%s: HTTP %d", addr, res.StatusCode))
+		case prob.Err != nil:
+			lines = append(lines, fmt.Sprintf("%s: %v", addr, prob.Err))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s", addr, prob.Name))
+		}
+	}
+	report.HTTP.Detail = strings.Join(lines, "; ")
+	return report
+}
+
+// lookupChallengeTXT returns the TXT records which a dns-01 validation of domain would find.
+func lookupChallengeTXT(sc *scanContext, domain string) ([]string, error) {
+	rrs, err := sc.Lookup("_acme-challenge."+domain, dns.TypeTXT)
+	var txt []string
+	for _, rr := range rrs {
+		if rr, ok := rr.(*dns.TXT); ok {
+			txt = append(txt, strings.Join(rr.Txt, ""))
+		}
+	}
+	sort.Strings(txt)
+	return txt, err
+}
+
+// queryPerspective asks the probe agent of p to repeat the checks.
+func queryPerspective(client *http.Client, p Perspective, req PerspectiveRequest) (PerspectiveReport, error) {
+	var report PerspectiveReport
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return report, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, p.URL, bytes.NewReader(buf))
+	if err != nil {
+		return report, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return report, fmt.Errorf("the probe agent responded with HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&report); err != nil {
+		return report, fmt.Errorf("the probe agent sent a malformed report: %w", err)
+	}
+	return report, nil
+}
+
+type perspectiveOutcome struct {
+	Perspective Perspective
+	Report      PerspectiveReport
+	Error       error
+}
+
+func (o perspectiveOutcome) String() string {
+	name := fmt.Sprintf("%s (%s)", o.Perspective.Name, strings.ToUpper(o.Perspective.Country))
+	if o.Error != nil {
+		return fmt.Sprintf("%s: not available: %v", name, o.Error)
+	}
+	var parts []string
+	if o.Report.DNSError != "" {
+		parts = append(parts, "DNS error: "+o.Report.DNSError)
+	}
+	if len(o.Report.Addresses) > 0 {
+		parts = append(parts, "addresses: "+strings.Join(o.Report.Addresses, ", "))
+	}
+	if o.Report.TXT != nil {
+		parts = append(parts, fmt.Sprintf("TXT: %q", o.Report.TXT))
+	}
+	if o.Report.HTTP != nil {
+		parts = append(parts, "HTTP: "+o.Report.HTTP.Detail)
+	}
+	return name + ": " + strings.Join(parts, "; ")
+}
+
+// perspectiveChecker repeats the DNS lookups and the validation request of the test from the probe agents
+// of Options.Perspectives. Let's Encrypt validates from several network perspectives, and a domain which
+// only fails from some of them, because of geographic blocking or split-horizon DNS, can't be validated
+// even though it appears to work from wherever its owner looks.
+type perspectiveChecker struct{}
+
+func (c perspectiveChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if len(ctx.perspectives) == 0 {
+		return nil, errNotApplicable
+	}
+	domain = strings.TrimPrefix(domain, "*.")
+
+	req := PerspectiveRequest{Domain: domain, Method: method, HTTPRequestPath: ctx.httpRequestPath}
+	client := &http.Client{Timeout: perspectiveTimeout}
+	outcomes := make([]perspectiveOutcome, len(ctx.perspectives))
+	var wg sync.WaitGroup
+	for i, p := range ctx.perspectives {
+		wg.Add(1)
+		go func(i int, p Perspective) {
+			defer wg.Done()
+			report, err := queryPerspective(client, p, req)
+			outcomes[i] = perspectiveOutcome{Perspective: p, Report: report, Error: err}
+		}(i, p)
+	}
+
+	var local PerspectiveReport
+	if method == DNS01 {
+		local.TXT, _ = lookupChallengeTXT(ctx, domain)
+	} else {
+		for _, ip := range ctx.LookupAddresses(domain) {
+			local.Addresses = append(local.Addresses, ip.String())
+		}
+	}
+	wg.Wait()
+
+	// The validation requests made from here may not have completed yet, see analyzePerspectiveHTTP
+	ctx.evidenceMu.Lock()
+	ctx.perspectiveOutcomes = outcomes
+	ctx.evidenceMu.Unlock()
+
+	return analyzePerspectiveDNS(domain, method, local, outcomes), nil
+}
+
+// analyzePerspectiveDNS compares the records each perspective found with those found locally.
+func analyzePerspectiveDNS(domain string, method ValidationMethod, local PerspectiveReport, outcomes []perspectiveOutcome) []Problem {
+	var lines, failed []string
+	for _, o := range outcomes {
+		lines = append(lines, o.String())
+		if o.Error != nil {
+			continue
+		}
+		if method == DNS01 && strings.Join(o.Report.TXT, "\n") != strings.Join(local.TXT, "\n") ||
+			method != DNS01 && len(o.Report.Addresses) == 0 && len(local.Addresses) > 0 {
+			failed = append(failed, o.String())
+		}
+	}
+
+	var probs []Problem
+	if len(failed) > 0 {
+		probs = append(probs, perspectiveDNSMismatch(domain, method, local, failed))
+	}
+	if len(lines) > 0 {
+		probs = append(probs, debugProblem("Perspectives", "What the probe agents in other network locations saw of the domain",
+			strings.Join(lines, "\n")))
+	}
+	return probs
+}
+
+// analyzePerspectiveHTTP is run once every checker has completed, when perspectives were queried. It reports
+// perspectives whose validation request failed, while the request succeeded from here or from another perspective.
+func analyzePerspectiveHTTP(ctx *scanContext, domain string, method ValidationMethod, probs []Problem) []Problem {
+	if method != HTTP01 {
+		return nil
+	}
+
+	ctx.evidenceMu.Lock()
+	outcomes := ctx.perspectiveOutcomes
+	httpResults := ctx.httpResults
+	ctx.evidenceMu.Unlock()
+
+	reachable := false
+	for _, res := range httpResults {
+		reachable = reachable || !res.IsZero()
+	}
+	for _, p := range probs {
+		if localHTTPFailureProblems[p.Name] {
+			reachable = false
+		}
+	}
+
+	if failed := unreachablePerspectives(reachable, outcomes); len(failed) > 0 {
+		return []Problem{perspectiveUnreachable(domain, failed)}
+	}
+	return nil
+}
+
+// unreachablePerspectives returns the perspectives whose validation request failed, if it succeeded
+// from any other location.
+func unreachablePerspectives(reachedLocally bool, outcomes []perspectiveOutcome) []string {
+	reachable := reachedLocally
+	for _, o := range outcomes {
+		if o.Error == nil && o.Report.HTTP != nil && o.Report.HTTP.Reachable {
+			reachable = true
+		}
+	}
+	if !reachable {
+		return nil
+	}
+
+	var failed []string
+	for _, o := range outcomes {
+		if o.Error == nil && o.Report.HTTP != nil && !o.Report.HTTP.Reachable {
+			failed = append(failed, o.String())
+		}
+	}
+	return failed
+}
+
+// perspectiveSeverity is an Error once more than one perspective fails, since Let's Encrypt tolerates
+// a single failed remote perspective.
+func perspectiveSeverity(failed int) SeverityLevel {
+	if failed > 1 {
+		return SeverityError
+	}
+	return SeverityWarning
+}
+
+func perspectiveDNSMismatch(domain string, method ValidationMethod, local PerspectiveReport, failed []string) Problem {
+	seen := fmt.Sprintf("Let's Debug found the addresses %s", strings.Join(local.Addresses, ", "))
+	if method == DNS01 {
+		seen = fmt.Sprintf("Let's Debug found the TXT records %q at _acme-challenge.%s", local.TXT, domain)
+	}
+	return Problem{
+		Name: "PerspectiveDNSMismatch",
+		Explanation: fmt.Sprintf(`%s, but %d of the other network perspectives saw something else. Let's Encrypt validates `+
+			`from several perspectives in different regions, and validation fails unless all but one of them agree. This `+
+			`is usually caused by split-horizon or geographic DNS which answers some resolvers differently, or by `+
+			`nameservers which are out of sync with each other. Make sure that every nameserver of %s gives every `+
+			`resolver the same answer.`, seen, len(failed), domain),
+		Detail:   strings.Join(failed, "\n"),
+		Severity: perspectiveSeverity(len(failed)),
+	}
+}
+
+func perspectiveUnreachable(domain string, failed []string) Problem {
+	return Problem{
+		Name: "PerspectiveUnreachable",
+		Explanation: fmt.Sprintf(`%s responded to the validation request from some network locations, but not from %d of `+
+			`the other perspectives. Let's Encrypt validates from several perspectives in different regions, and `+
+			`validation fails unless all but one of them succeed. This is usually caused by a firewall, CDN or hosting `+
+			`provider which blocks some countries or networks. Allow requests to /.well-known/acme-challenge/ from `+
+			`everywhere, or use the dns-01 validation method instead.`, domain, len(failed)),
+		Detail:   strings.Join(failed, "\n"),
+		Severity: perspectiveSeverity(len(failed)),
+	}
+}
@@ -0,0 +1,110 @@
+package letsdebug
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestQueryPerspective(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req PerspectiveRequest
+		if r.Header.Get("Authorization") != "Bearer secret" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(PerspectiveReport{Addresses: []string{"192.0.2.1"},
+			HTTP: &PerspectiveHTTP{Reachable: true, Detail: "192.0.2.1: HTTP 404 (" + req.Domain + ")"}})
+	}))
+	defer agent.Close()
+
+	req := PerspectiveRequest{Domain: "example.org", Method: HTTP01}
+	report, err := queryPerspective(agent.Client(), Perspective{Name: "eu", URL: agent.URL, Token: "secret"}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.HTTP.Reachable || !strings.Contains(report.HTTP.Detail, "example.org") {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if _, err := queryPerspective(agent.Client(), Perspective{Name: "eu", URL: agent.URL, Token: "wrong"}, req); err == nil {
+		t.Error("expected the agent to refuse the wrong token")
+	}
+}
+
+func TestProbeAgentRefusesRequests(t *testing.T) {
+	agent := httptest.NewServer(NewProbeAgent("secret"))
+	defer agent.Close()
+
+	for _, tt := range []struct {
+		token, body string
+		want        int
+	}{
+		{"", `{"domain":"example.org","method":"http-01"}`, http.StatusUnauthorized},
+		{"secret", `{"domain":"example.org","method":"http-02"}`, http.StatusBadRequest},
+		{"secret", `{"method":"dns-01"}`, http.StatusBadRequest},
+		{"secret", `not json`, http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, agent.URL, strings.NewReader(tt.body))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		resp, err := agent.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: expected HTTP %d, got %d", tt.body, tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestAnalyzePerspectiveDNS(t *testing.T) {
+	outcomes := []perspectiveOutcome{
+		{Perspective: Perspective{Name: "us", Country: "us"}, Report: PerspectiveReport{Addresses: []string{"192.0.2.1"}}},
+		{Perspective: Perspective{Name: "eu", Country: "de"}, Report: PerspectiveReport{DNSError: "SERVFAIL"}},
+		{Perspective: Perspective{Name: "ap", Country: "sg"}, Error: errors.New("connection refused")},
+	}
+	local := PerspectiveReport{Addresses: []string{"192.0.2.1"}}
+
+	probs := analyzePerspectiveDNS("example.org", HTTP01, local, outcomes)
+	if len(probs) != 2 || probs[0].Name != "PerspectiveDNSMismatch" || probs[0].Severity != SeverityWarning {
+		t.Fatalf("expected a warning about the perspective which couldn't resolve the domain, got: %v", probs)
+	}
+	if !strings.Contains(probs[0].Detail, "eu (DE): DNS error: SERVFAIL") || strings.Contains(probs[0].Detail, "us (US)") {
+		t.Errorf("unexpected detail: %s", probs[0].Detail)
+	}
+	if !strings.Contains(probs[1].Detail, "ap (SG): not available") {
+		t.Errorf("expected the unavailable agent to be listed, got: %s", probs[1].Detail)
+	}
+
+	// Stale TXT records on some of the nameservers
+	outcomes = []perspectiveOutcome{
+		{Perspective: Perspective{Name: "us"}, Report: PerspectiveReport{TXT: []string{"new"}}},
+		{Perspective: Perspective{Name: "eu"}, Report: PerspectiveReport{TXT: []string{"old"}}},
+		{Perspective: Perspective{Name: "ap"}, Report: PerspectiveReport{}},
+	}
+	probs = analyzePerspectiveDNS("example.org", DNS01, PerspectiveReport{TXT: []string{"new"}}, outcomes)
+	if len(probs) != 2 || probs[0].Name != "PerspectiveDNSMismatch" || probs[0].Severity != SeverityError {
+		t.Fatalf("expected an error about the two perspectives which disagree, got: %v", probs)
+	}
+}
+
+func TestUnreachablePerspectives(t *testing.T) {
+	reachable := perspectiveOutcome{Perspective: Perspective{Name: "us"}, Report: PerspectiveReport{HTTP: &PerspectiveHTTP{Reachable: true}}}
+	blocked := perspectiveOutcome{Perspective: Perspective{Name: "eu"}, Report: PerspectiveReport{HTTP: &PerspectiveHTTP{Detail: "i/o timeout"}}}
+
+	if failed := unreachablePerspectives(true, []perspectiveOutcome{blocked}); len(failed) != 1 {
+		t.Errorf("expected the blocked perspective to be reported, got: %v", failed)
+	}
+	if failed := unreachablePerspectives(false, []perspectiveOutcome{reachable, blocked}); len(failed) != 1 {
+		t.Errorf("expected the blocked perspective to be reported, got: %v", failed)
+	}
+	if failed := unreachablePerspectives(false, []perspectiveOutcome{blocked}); len(failed) != 0 {
+		t.Errorf("expected nothing to be reported when no location could reach the domain, got: %v", failed)
+	}
+}
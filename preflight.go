@@ -0,0 +1,73 @@
+package letsdebug
+
+import (
+	_ "embed"
+)
+
+// PreflightSchemaVersion is the version of the JSON Schema (PreflightSchema) which serialized
+// Preflights conform to. The major version is incremented whenever a change is not backwards compatible.
+const PreflightSchemaVersion = "1.0.0"
+
+// PreflightSchema is the JSON Schema describing the serialized form of Preflight.
+//
+//go:embed schema/preflight.schema.json
+var PreflightSchema []byte
+
+// Preflight is a verdict for each validation method, derived from the results of testing a domain
+// with each of them. It is intended to be consumed by ACME clients (e.g. from a certbot hook), to
+// choose a validation method which is expected to work before requesting a certificate.
+type Preflight struct {
+	SchemaVersion string `json:"schema_version"`
+	Domain        string `json:"domain"`
+	// Recommended is the first method in the order they were tested which passed, if any
+	Recommended ValidationMethod `json:"recommended,omitempty"`
+	Verdicts    []Verdict        `json:"verdicts"`
+}
+
+// Verdict is whether validation with Method is expected to succeed.
+type Verdict struct {
+	Method ValidationMethod `json:"method"`
+	// Pass is whether no Fatal or Error problems were found, and the test itself did not fail
+	Pass bool `json:"pass"`
+	// Error is set when the test could not be completed
+	Error string `json:"error,omitempty"`
+	// Reasons are the Fatal, Error and Warning problems found, most severe first
+	Reasons []VerdictReason `json:"reasons,omitempty"`
+}
+
+// VerdictReason is a problem which contributed to a Verdict.
+type VerdictReason struct {
+	Name     string        `json:"name"`
+	Severity SeverityLevel `json:"severity"`
+	Summary  string        `json:"summary"`
+}
+
+// NewVerdict summarizes the result of testing a domain with method.
+func NewVerdict(method ValidationMethod, result Result) Verdict {
+	v := Verdict{Method: method, Pass: result.Error == "", Error: result.Error}
+	probs := append([]Problem(nil), result.Problems...)
+	SortProblems(probs)
+	for _, p := range probs {
+		switch p.Severity {
+		case SeverityFatal, SeverityError:
+			v.Pass = false
+		case SeverityWarning:
+		default:
+			continue
+		}
+		v.Reasons = append(v.Reasons, VerdictReason{Name: p.Name, Severity: p.Severity, Summary: p.Explanation})
+	}
+	return v
+}
+
+// NewPreflight builds a Preflight from the verdicts for domain, in order of preference.
+func NewPreflight(domain string, verdicts []Verdict) Preflight {
+	pf := Preflight{SchemaVersion: PreflightSchemaVersion, Domain: domain, Verdicts: verdicts}
+	for _, v := range verdicts {
+		if v.Pass {
+			pf.Recommended = v.Method
+			break
+		}
+	}
+	return pf
+}
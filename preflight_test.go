@@ -0,0 +1,67 @@
+package letsdebug
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPreflightSchema(t *testing.T) {
+	var schema struct {
+		schemaObject
+		Defs struct {
+			Verdict schemaObject `json:"verdict"`
+			Reason  schemaObject `json:"reason"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(PreflightSchema, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	var version struct {
+		Const string `json:"const"`
+	}
+	if err := json.Unmarshal(schema.Properties["schema_version"], &version); err != nil || version.Const != PreflightSchemaVersion {
+		t.Fatalf("schema version %q does not match PreflightSchemaVersion %q", version.Const, PreflightSchemaVersion)
+	}
+
+	checkSchemaObject(t, "Preflight", reflect.TypeOf(Preflight{}), schema.schemaObject)
+	checkSchemaObject(t, "Verdict", reflect.TypeOf(Verdict{}), schema.Defs.Verdict)
+	checkSchemaObject(t, "VerdictReason", reflect.TypeOf(VerdictReason{}), schema.Defs.Reason)
+}
+
+func TestNewPreflight(t *testing.T) {
+	httpVerdict := NewVerdict(HTTP01, NewResult([]Problem{
+		{Name: "CloudflareCDN", Severity: SeverityWarning},
+		{Name: "HTTPCheck", Severity: SeverityDebug},
+		{Name: "ANotWorking", Severity: SeverityError, Explanation: "no response"},
+	}, nil))
+	if httpVerdict.Pass {
+		t.Fatal("expected http-01 to fail because of the error")
+	}
+	want := []VerdictReason{
+		{Name: "ANotWorking", Severity: SeverityError, Summary: "no response"},
+		{Name: "CloudflareCDN", Severity: SeverityWarning},
+	}
+	if !reflect.DeepEqual(httpVerdict.Reasons, want) {
+		t.Fatalf("expected the error and warning as reasons, most severe first, got: %+v", httpVerdict.Reasons)
+	}
+
+	dnsVerdict := NewVerdict(DNS01, NewResult([]Problem{{Name: "DNSProviderMismatch", Severity: SeverityWarning}}, nil))
+	if !dnsVerdict.Pass {
+		t.Fatal("expected dns-01 to pass with only a warning")
+	}
+	alpnVerdict := NewVerdict(TLSALPN01, NewResult(nil, errors.New("timed out")))
+	if alpnVerdict.Pass || alpnVerdict.Error != "timed out" {
+		t.Fatalf("expected tls-alpn-01 to fail because the test failed, got: %+v", alpnVerdict)
+	}
+
+	pf := NewPreflight("example.org", []Verdict{httpVerdict, dnsVerdict, alpnVerdict})
+	if pf.Recommended != DNS01 || pf.SchemaVersion != PreflightSchemaVersion {
+		t.Fatalf("expected dns-01 to be recommended, got: %+v", pf)
+	}
+	if pf := NewPreflight("example.org", []Verdict{httpVerdict, alpnVerdict}); pf.Recommended != "" {
+		t.Fatalf("expected nothing to be recommended, got %q", pf.Recommended)
+	}
+}
@@ -1,7 +1,9 @@
 package letsdebug
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -11,17 +13,25 @@ type SeverityLevel string
 // Problem represents an issue found by one of the checkers in this package.
 // Explanation is a human-readable explanation of the issue.
 // Detail is usually the underlying machine error.
+// References are links to documentation or community threads describing how to fix the issue.
+// Err is the underlying error, when the problem was generated from one. It is not serialized,
+// but a Problem is itself an error which unwraps to Err, so errors.Is and errors.As can be used
+// to inspect it (e.g. for a *net.DNSError or *tls.CertificateVerificationError).
 type Problem struct {
 	Name        string        `json:"name"`
 	Explanation string        `json:"explanation"`
 	Detail      string        `json:"detail"`
 	Severity    SeverityLevel `json:"severity"`
+	References  []string      `json:"references,omitempty"`
+	Category    Category      `json:"category,omitempty"`
+	Err         error         `json:"-"`
 }
 
 const (
 	SeverityFatal   SeverityLevel = "Fatal" // Represents a fatal error which will stop any further checks
 	SeverityError   SeverityLevel = "Error"
 	SeverityWarning SeverityLevel = "Warning"
+	SeverityInfo    SeverityLevel = "Info"  // Worth knowing, but not a problem
 	SeverityDebug   SeverityLevel = "Debug" // Not to be shown by default
 )
 
@@ -29,6 +39,14 @@ func (p Problem) String() string {
 	return fmt.Sprintf("[%s] %s: %s", p.Name, p.Explanation, p.Detail)
 }
 
+func (p Problem) Error() string {
+	return p.String()
+}
+
+func (p Problem) Unwrap() error {
+	return p.Err
+}
+
 func (p Problem) IsZero() bool {
 	return p.Name == ""
 }
@@ -37,6 +55,125 @@ func (p Problem) DetailLines() []string {
 	return strings.Split(p.Detail, "\n")
 }
 
+// severityRanks orders the severity levels from the most to the least severe.
+var severityRanks = map[SeverityLevel]int{
+	SeverityFatal:   0,
+	SeverityError:   1,
+	SeverityWarning: 2,
+	SeverityInfo:    3,
+	SeverityDebug:   4,
+}
+
+// SortProblems orders problems by severity (most severe first, unknown levels last), then by name
+// and detail, so that the results of repeated runs can be compared regardless of the order the
+// checkers finished in. Check, CheckWithOptions and CheckOrder already return problems in this order.
+func SortProblems(probs []Problem) {
+	rank := func(s SeverityLevel) int {
+		if r, ok := severityRanks[s]; ok {
+			return r
+		}
+		return len(severityRanks)
+	}
+	sort.SliceStable(probs, func(i, j int) bool {
+		if ri, rj := rank(probs[i].Severity), rank(probs[j].Severity); ri != rj {
+			return ri < rj
+		}
+		if probs[i].Name != probs[j].Name {
+			return probs[i].Name < probs[j].Name
+		}
+		return probs[i].Detail < probs[j].Detail
+	})
+}
+
+// suppressProblems removes the problems with any of the names, which the caller considers noise, and
+// records them in a single debug problem instead so that they aren't lost altogether.
+func suppressProblems(probs []Problem, names []string) []Problem {
+	if len(names) == 0 {
+		return probs
+	}
+	suppress := map[string]bool{}
+	for _, name := range names {
+		suppress[name] = true
+	}
+
+	var kept []Problem
+	var suppressed []string
+	for _, p := range probs {
+		if !suppress[p.Name] {
+			kept = append(kept, p)
+			continue
+		}
+		suppressed = append(suppressed, fmt.Sprintf("%s %s", p.Severity, p))
+	}
+	if len(suppressed) == 0 {
+		return probs
+	}
+	sort.Strings(suppressed)
+	return append(kept, debugProblem("SuppressedProblems",
+		"Problems which were found, but which were suppressed at the request of the caller",
+		strings.Join(suppressed, "\n")))
+}
+
+// ParseSeverityOverrides parses a list of overrides like "CloudflareCDN=Info,AAAANotWorking=Warning",
+// in the form taken by Options.SeverityOverrides. Severity levels are case-insensitive.
+func ParseSeverityOverrides(s string) (map[string]SeverityLevel, error) {
+	overrides := map[string]SeverityLevel{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid severity override %q, expected Name=Level", entry)
+		}
+		severity, err := parseSeverityLevel(strings.TrimSpace(level))
+		if err != nil {
+			return nil, err
+		}
+		overrides[name] = severity
+	}
+	return overrides, nil
+}
+
+func parseSeverityLevel(s string) (SeverityLevel, error) {
+	for level := range severityRanks {
+		if strings.EqualFold(string(level), s) {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown severity level %q", s)
+}
+
+// overrideSeverities reclassifies the problems with any of the names, at the request of the caller,
+// and records the original severities in a single debug problem so that the change is visible.
+// It is applied to the final results, so that Fatal problems still stop the checks where they occur.
+func overrideSeverities(probs []Problem, overrides map[string]SeverityLevel) []Problem {
+	if len(overrides) == 0 {
+		return probs
+	}
+	var changed []string
+	seen := map[string]bool{}
+	out := make([]Problem, 0, len(probs)+1)
+	for _, p := range probs {
+		if level, ok := overrides[p.Name]; ok && level != p.Severity {
+			if line := fmt.Sprintf("%s: %s -> %s", p.Name, p.Severity, level); !seen[line] {
+				seen[line] = true
+				changed = append(changed, line)
+			}
+			p.Severity = level
+		}
+		out = append(out, p)
+	}
+	if len(changed) == 0 {
+		return probs
+	}
+	sort.Strings(changed)
+	return append(out, debugProblem("SeverityOverrides",
+		"Problems whose severity was changed at the request of the caller",
+		strings.Join(changed, "\n")))
+}
+
 func hasFatalProblem(probs []Problem) bool {
 	for _, p := range probs {
 		if p.Severity == SeverityFatal {
@@ -47,6 +184,16 @@ func hasFatalProblem(probs []Problem) bool {
 	return false
 }
 
+func hasInternalProblem(probs []Problem) bool {
+	for _, p := range probs {
+		if p.Name == "InternalProblem" {
+			return true
+		}
+	}
+
+	return false
+}
+
 func internalProblem(message string, level SeverityLevel) Problem {
 	return Problem{
 		Name:        "InternalProblem",
@@ -57,11 +204,19 @@ func internalProblem(message string, level SeverityLevel) Problem {
 }
 
 func dnsLookupFailed(name, rrType string, err error) Problem {
+	// Running out of query budget is not the domain's fault, and is reported once per scan
+	// by scanContext.dnsTruncationProblem
+	if errors.Is(err, errDNSQueryBudgetExhausted) {
+		p := debugProblem("DNSLookupSkipped", fmt.Sprintf("The DNS lookup for %s/%s was skipped.", name, rrType), err.Error())
+		p.Err = err
+		return p
+	}
 	return Problem{
 		Name:        "DNSLookupFailed",
 		Explanation: fmt.Sprintf(`A fatal issue occurred during the DNS lookup process for %s/%s.`, name, rrType),
 		Detail:      err.Error(),
 		Severity:    SeverityFatal,
+		Err:         err,
 	}
 }
 
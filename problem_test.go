@@ -0,0 +1,117 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestProblemUnwrap(t *testing.T) {
+	dnsErr := &net.DNSError{Err: "no such host", Name: "example.org", IsNotFound: true}
+	var err error = dnsLookupFailed("example.org", "A", dnsErr)
+
+	var target *net.DNSError
+	if !errors.As(err, &target) || target != dnsErr {
+		t.Fatalf("expected errors.As to find the *net.DNSError behind %v", err)
+	}
+	if err.Error() != dnsLookupFailed("example.org", "A", dnsErr).String() {
+		t.Errorf("expected Error to match String, got %q", err.Error())
+	}
+
+	skipped := dnsLookupFailed("example.org", "A", errDNSQueryBudgetExhausted)
+	if skipped.Name != "DNSLookupSkipped" || !errors.Is(skipped, errDNSQueryBudgetExhausted) {
+		t.Errorf("expected a skipped lookup wrapping errDNSQueryBudgetExhausted, got %v", skipped)
+	}
+
+	if debugProblem("Debug", "explanation", "detail").Unwrap() != nil {
+		t.Error("expected a problem without an underlying error to unwrap to nil")
+	}
+}
+
+func TestSortProblems(t *testing.T) {
+	probs := []Problem{
+		debugProblem("HTTPCheck", "", "b"),
+		{Name: "ANotWorking", Severity: SeverityError},
+		debugProblem("HTTPCheck", "", "a"),
+		{Name: "Custom", Severity: "Unknown"},
+		{Name: "AAAANotWorking", Severity: SeverityError},
+		{Name: "DNSLookupFailed", Severity: SeverityFatal},
+		{Name: "CloudflareCDN", Severity: SeverityWarning},
+		{Name: "DNSContacts", Severity: SeverityInfo},
+	}
+	SortProblems(probs)
+
+	var got []string
+	for _, p := range probs {
+		got = append(got, p.Name+"/"+p.Detail)
+	}
+	want := []string{"DNSLookupFailed/", "AAAANotWorking/", "ANotWorking/", "CloudflareCDN/", "DNSContacts/", "HTTPCheck/a", "HTTPCheck/b", "Custom/"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSuppressProblems(t *testing.T) {
+	probs := []Problem{
+		{Name: "CloudflareCDN", Detail: "a", Severity: SeverityWarning},
+		{Name: "IssueFromLetsEncrypt", Detail: "b", Severity: SeverityError},
+	}
+
+	if got := suppressProblems(probs, nil); len(got) != 2 {
+		t.Fatalf("expected nothing to be suppressed, got: %+v", got)
+	}
+	if got := suppressProblems(probs, []string{"UnknownProblem"}); len(got) != 2 {
+		t.Fatalf("expected nothing to be suppressed, got: %+v", got)
+	}
+
+	got := suppressProblems(probs, []string{"CloudflareCDN"})
+	if len(got) != 2 || got[0].Name != "IssueFromLetsEncrypt" {
+		t.Fatalf("expected CloudflareCDN to be suppressed, got: %+v", got)
+	}
+	if got[1].Name != "SuppressedProblems" || got[1].Severity != SeverityDebug || !strings.Contains(got[1].Detail, "[CloudflareCDN]") {
+		t.Errorf("expected the suppressed problem to be recorded in debug output, got: %+v", got[1])
+	}
+}
+
+func TestOverrideSeverities(t *testing.T) {
+	overrides, err := ParseSeverityOverrides(" CloudflareCDN=info, AAAANotWorking=Warning,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overrides) != 2 || overrides["CloudflareCDN"] != SeverityInfo || overrides["AAAANotWorking"] != SeverityWarning {
+		t.Fatalf("unexpected overrides: %v", overrides)
+	}
+	for _, invalid := range []string{"CloudflareCDN", "=Info", "CloudflareCDN=Severe"} {
+		if _, err := ParseSeverityOverrides(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+
+	probs := []Problem{
+		{Name: "CloudflareCDN", Severity: SeverityWarning},
+		{Name: "AAAANotWorking", Severity: SeverityError},
+		{Name: "DNSContacts", Severity: SeverityInfo},
+	}
+	if got := overrideSeverities(probs, map[string]SeverityLevel{"DNSContacts": SeverityInfo}); len(got) != 3 {
+		t.Fatalf("expected nothing to be changed, got: %+v", got)
+	}
+
+	got := overrideSeverities(probs, overrides)
+	SortProblems(got)
+	var names []string
+	for _, p := range got {
+		names = append(names, fmt.Sprintf("%s/%s", p.Name, p.Severity))
+	}
+	want := "AAAANotWorking/Warning CloudflareCDN/Info DNSContacts/Info SeverityOverrides/Debug"
+	if strings.Join(names, " ") != want {
+		t.Fatalf("expected %s, got %v", want, names)
+	}
+	if !strings.Contains(got[3].Detail, "CloudflareCDN: Warning -> Info") {
+		t.Errorf("expected the original severity to be recorded, got: %q", got[3].Detail)
+	}
+	if probs[0].Severity != SeverityWarning {
+		t.Error("expected the original problems to be left unchanged")
+	}
+}
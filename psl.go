@@ -0,0 +1,192 @@
+package letsdebug
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weppos/publicsuffix-go/net/publicsuffix"
+	psl "github.com/weppos/publicsuffix-go/publicsuffix"
+)
+
+const (
+	// pslMinRules is fewer rules than any copy of the list has had for years (it has almost 10,000), so
+	// that a truncated download is not mistaken for the list.
+	pslMinRules = 5000
+	// pslMaxShrink is the fraction of its rules which a refreshed list may lose compared to the one in use.
+	pslMaxShrink = 0.1
+)
+
+// pslRequiredRules are rules which every copy of the list has, as a check that it was parsed correctly.
+var pslRequiredRules = []string{"com", "net", "org", "uk", "co.uk", "github.io"}
+
+// pslSnapshot is a copy of the Public Suffix List which replaced the one compiled into publicsuffix-go.
+type pslSnapshot struct {
+	list *psl.List
+	// rules is how many rules the list has
+	rules int
+	// version is what the list declares in its "// VERSION:" header, which sorts by date
+	version string
+}
+
+// publicSuffixData is the Public Suffix List. The embedded copy is the one compiled into the
+// publicsuffix-go module, so it has no contents of its own and is versioned by the module version.
+// It is nil unless the list has been refreshed, or replaced with LETSDEBUG_PUBLIC_SUFFIX_LIST_FILE,
+// so that the compiled-in list remains the fallback whenever a copy fails the checks of parsePublicSuffixList.
+var publicSuffixData = &dataset[*pslSnapshot]{
+	name: "public_suffix_list",
+	embeddedVersion: func() string {
+		_, version := moduleVersions()
+		return version
+	},
+	url:                "https://publicsuffix.org/list/public_suffix_list.dat",
+	fileEnv:            "LETSDEBUG_PUBLIC_SUFFIX_LIST_FILE",
+	parse:              parsePublicSuffixList,
+	check:              checkPublicSuffixList,
+	replacementVersion: publicSuffixListVersion,
+}
+
+// parsePublicSuffixList parses a copy of the list, after checking that it is complete: both of its
+// sections are present, it has as many rules as the list could be expected to, and some which it
+// always has.
+func parsePublicSuffixList(buf []byte) (*pslSnapshot, error) {
+	if buf == nil {
+		return nil, nil
+	}
+	src := string(buf)
+	for _, marker := range []string{"===BEGIN ICANN DOMAINS===", "===END ICANN DOMAINS===",
+		"===BEGIN PRIVATE DOMAINS===", "===END PRIVATE DOMAINS==="} {
+		if !strings.Contains(src, marker) {
+			return nil, fmt.Errorf("not a complete copy of the Public Suffix List: %s is missing", marker)
+		}
+	}
+
+	rules := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		rules[strings.Fields(line)[0]] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rules) < pslMinRules {
+		return nil, fmt.Errorf("the Public Suffix List has only %d rules, fewer than the %d expected", len(rules), pslMinRules)
+	}
+	for _, rule := range pslRequiredRules {
+		if !rules[rule] {
+			return nil, fmt.Errorf("the Public Suffix List is missing the rule %q", rule)
+		}
+	}
+
+	list, err := psl.NewListFromString(src, &psl.ParserOption{PrivateDomains: true})
+	if err != nil {
+		return nil, err
+	}
+	return &pslSnapshot{list: list, rules: len(rules), version: publicSuffixListVersion(buf)}, nil
+}
+
+// checkPublicSuffixList rejects a list which is older than the one in use, or which lost many of its rules,
+// as happens when a mirror serves a stale or damaged copy.
+func checkPublicSuffixList(current, next *pslSnapshot) error {
+	if current == nil || next == nil {
+		return nil
+	}
+	if current.version != "" && next.version != "" && next.version < current.version {
+		return fmt.Errorf("the Public Suffix List %s is older than the one in use (%s)", next.version, current.version)
+	}
+	if float64(next.rules) < float64(current.rules)*(1-pslMaxShrink) {
+		return fmt.Errorf("the Public Suffix List has only %d rules, down from %d", next.rules, current.rules)
+	}
+	return nil
+}
+
+// publicSuffixListVersion returns the version declared in the header of a copy of the list, e.g.
+// "2026-10-14_08-20-35_UTC", or "" if it has none.
+func publicSuffixListVersion(buf []byte) string {
+	scanner := bufio.NewScanner(strings.NewReader(string(buf)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if version, ok := strings.CutPrefix(line, "// VERSION:"); ok {
+			return strings.TrimSpace(version)
+		}
+		// The header precedes the first rule
+		if line != "" && !strings.HasPrefix(line, "//") {
+			break
+		}
+	}
+	return ""
+}
+
+// RefreshPublicSuffixList fetches an up to date copy of the Public Suffix List, and uses it for the
+// checks which start afterwards. A copy which can't be fetched, or which fails the integrity checks,
+// is ignored, and the list in use is kept. It does nothing if LETSDEBUG_DATA_PINNED=1 is set, or the
+// list was replaced with LETSDEBUG_PUBLIC_SUFFIX_LIST_FILE.
+func RefreshPublicSuffixList(ctx context.Context) error {
+	if DataPinned() {
+		return nil
+	}
+	return publicSuffixData.refresh(ctx, &http.Client{Timeout: time.Minute})
+}
+
+// StartPublicSuffixListRefresher refreshes the Public Suffix List in the background, at once and then
+// every interval, until ctx is done. The version of the list in use is logged whenever it changes.
+func StartPublicSuffixListRefresher(ctx context.Context, interval time.Duration) {
+	log.Printf("Using the Public Suffix List %s", publicSuffixData.Version().Version)
+	go func() {
+		for {
+			before := publicSuffixData.Version()
+			refreshCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			err := RefreshPublicSuffixList(refreshCtx)
+			cancel()
+			if after := publicSuffixData.Version(); err != nil {
+				log.Printf("Failed to refresh the Public Suffix List, still using %s: %v", after.Version, err)
+			} else if after.Version != before.Version {
+				log.Printf("Refreshed the Public Suffix List from %s to %s", before.Version, after.Version)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// publicSuffixList returns the refreshed Public Suffix List, or the one compiled into publicsuffix-go.
+func publicSuffixList() *psl.List {
+	if l := publicSuffixData.Get(); l != nil {
+		return l.list
+	}
+	return psl.DefaultList
+}
+
+// publicSuffix returns the public suffix of name, which is name itself if it is a public suffix.
+func publicSuffix(name string) string {
+	l := publicSuffixData.Get()
+	if l == nil {
+		ps, _ := publicsuffix.PublicSuffix(name)
+		return ps
+	}
+	if ps := l.list.Find(name, psl.DefaultFindOptions).Decompose(name)[1]; ps != "" {
+		return ps
+	}
+	return name
+}
+
+// effectiveTLDPlusOne returns the registered domain of name, i.e. its public suffix and one more label.
+func effectiveTLDPlusOne(name string) (string, error) {
+	l := publicSuffixData.Get()
+	if l == nil {
+		return publicsuffix.EffectiveTLDPlusOne(name)
+	}
+	return psl.DomainFromListWithOptions(l.list, name, psl.DefaultFindOptions)
+}
@@ -0,0 +1,117 @@
+package letsdebug
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testPublicSuffixList builds a copy of the list with the given version and number of filler rules.
+func testPublicSuffixList(version string, rules int, sections ...string) string {
+	if sections == nil {
+		sections = []string{"ICANN", "PRIVATE"}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "// This Source Code Form is subject to the terms of the Mozilla Public License\n\n")
+	if version != "" {
+		fmt.Fprintf(&b, "// VERSION: %s\n// COMMIT: 0123456789abcdef\n\n", version)
+	}
+	for i, section := range sections {
+		fmt.Fprintf(&b, "// ===BEGIN %s DOMAINS===\n\n", section)
+		if i == 0 {
+			b.WriteString("com\nnet\norg\nuk\nco.uk\n*.ck\n!www.ck\n")
+			for j := 0; j < rules; j++ {
+				fmt.Fprintf(&b, "// filler %d\nexample%d.test\n", j, j)
+			}
+		} else {
+			b.WriteString("github.io\n")
+		}
+		fmt.Fprintf(&b, "\n// ===END %s DOMAINS===\n", section)
+	}
+	return b.String()
+}
+
+func TestParsePublicSuffixList(t *testing.T) {
+	l, err := parsePublicSuffixList([]byte(testPublicSuffixList("2026-10-14_08-20-35_UTC", pslMinRules)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.version != "2026-10-14_08-20-35_UTC" || l.rules != pslMinRules+len(pslRequiredRules)+2 {
+		t.Errorf("unexpected list: version %q, %d rules", l.version, l.rules)
+	}
+
+	for name, src := range map[string]string{
+		"truncated":      testPublicSuffixList("", pslMinRules, "ICANN"),
+		"too few rules":  testPublicSuffixList("", 100),
+		"missing a rule": strings.Replace(testPublicSuffixList("", pslMinRules), "\nco.uk\n", "\n", 1),
+		"not the list":   "<html>Not Found</html>",
+	} {
+		if _, err := parsePublicSuffixList([]byte(src)); err == nil {
+			t.Errorf("%s: expected the copy to be rejected", name)
+		}
+	}
+}
+
+func TestCheckPublicSuffixList(t *testing.T) {
+	current := &pslSnapshot{rules: 10000, version: "2026-10-14_08-20-35_UTC"}
+	for _, tt := range []struct {
+		next *pslSnapshot
+		ok   bool
+	}{
+		{&pslSnapshot{rules: 10010, version: "2026-10-15_08-20-35_UTC"}, true},
+		{&pslSnapshot{rules: 9500, version: "2026-10-14_08-20-35_UTC"}, true},
+		{&pslSnapshot{rules: 10000}, true},
+		{&pslSnapshot{rules: 10000, version: "2026-09-01_08-20-35_UTC"}, false},
+		{&pslSnapshot{rules: 8000, version: "2026-10-15_08-20-35_UTC"}, false},
+	} {
+		if err := checkPublicSuffixList(current, tt.next); (err == nil) != tt.ok {
+			t.Errorf("%+v: expected ok=%t, got %v", tt.next, tt.ok, err)
+		}
+	}
+	if err := checkPublicSuffixList(nil, &pslSnapshot{rules: pslMinRules}); err != nil {
+		t.Errorf("expected any valid list to replace the compiled-in one, got %v", err)
+	}
+}
+
+func TestPublicSuffixListRefresh(t *testing.T) {
+	body := testPublicSuffixList("2026-10-14_08-20-35_UTC", pslMinRules)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	d := &dataset[*pslSnapshot]{
+		name:               publicSuffixData.name,
+		embeddedVersion:    func() string { return "v0.40.2" },
+		url:                srv.URL,
+		parse:              parsePublicSuffixList,
+		check:              checkPublicSuffixList,
+		replacementVersion: publicSuffixListVersion,
+	}
+	if d.Get() != nil || d.Version().Version != "v0.40.2" {
+		t.Fatalf("expected the compiled-in list, got %+v", d.Version())
+	}
+
+	if err := d.refresh(context.Background(), srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	if v := d.Version(); d.Get() == nil || v.Version != "2026-10-14_08-20-35_UTC" || v.Source != srv.URL {
+		t.Fatalf("expected the refreshed list, got %+v", v)
+	}
+
+	// A stale mirror, then a truncated download
+	for _, body = range []string{
+		testPublicSuffixList("2026-09-01_08-20-35_UTC", pslMinRules),
+		testPublicSuffixList("2026-10-15_08-20-35_UTC", pslMinRules, "ICANN"),
+	} {
+		if err := d.refresh(context.Background(), srv.Client()); err == nil {
+			t.Error("expected the copy to be rejected")
+		}
+		if v := d.Version(); v.Version != "2026-10-14_08-20-35_UTC" {
+			t.Errorf("expected the previous list to be kept, got %+v", v)
+		}
+	}
+}
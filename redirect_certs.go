@@ -0,0 +1,97 @@
+package letsdebug
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// redirectHop is a request made over HTTPS while following the redirects of an http-01 validation
+// request, along with the certificate chain presented by the server, or the reason there was none.
+type redirectHop struct {
+	URL   string
+	Host  string
+	Chain []*x509.Certificate
+	Error error
+}
+
+func (h redirectHop) String() string {
+	if h.Error != nil {
+		return fmt.Sprintf("%s: %v", h.URL, h.Error)
+	}
+	if len(h.Chain) == 0 {
+		return fmt.Sprintf("%s: no certificate was presented", h.URL)
+	}
+	leaf := h.Chain[0]
+	return fmt.Sprintf("%s: subject=%s, names=%s, issuer=%s, not before=%s, not after=%s, serial=%s", h.URL,
+		leaf.Subject.String(), strings.Join(leaf.DNSNames, ","), leaf.Issuer.String(),
+		leaf.NotBefore.UTC().Format(time.RFC3339), leaf.NotAfter.UTC().Format(time.RFC3339), leaf.SerialNumber.Text(16))
+}
+
+// verifyRedirectCertificate checks the chain presented at a hop as a browser would. If roots is nil,
+// the system roots are used.
+func verifyRedirectCertificate(hop redirectHop, roots *x509.CertPool, now time.Time) error {
+	if len(hop.Chain) == 0 {
+		return nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range hop.Chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := hop.Chain[0].Verify(x509.VerifyOptions{
+		DNSName:       hop.Host,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	return err
+}
+
+// analyzeRedirectHops summarizes the certificate presented at each HTTPS hop of the redirect chains
+// followed by the validation requests, and warns about any which would not be trusted.
+func analyzeRedirectHops(domain string, results []httpCheckResult, roots *x509.CertPool, now time.Time) []Problem {
+	var summary, invalid []string
+	var errs []error
+	// Every address of the domain usually redirects to the same places
+	seen := map[string]bool{}
+	for _, res := range results {
+		for _, hop := range res.RedirectHops {
+			line := hop.String()
+			if seen[line] {
+				continue
+			}
+			seen[line] = true
+			summary = append(summary, line)
+			if err := verifyRedirectCertificate(hop, roots, now); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s: %v", hop.URL, err))
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(summary) == 0 {
+		return nil
+	}
+
+	probs := []Problem{debugProblem("RedirectCertificates", "Certificates presented at each HTTPS redirect of the validation request",
+		strings.Join(summary, "\n"))}
+	if len(invalid) > 0 {
+		probs = append(probs, redirectCertificateInvalid(domain, invalid, errors.Join(errs...)))
+	}
+	return probs
+}
+
+func redirectCertificateInvalid(domain string, hops []string, err error) Problem {
+	return Problem{
+		Name: "RedirectCertificateInvalid",
+		Explanation: fmt.Sprintf(`The validation request to %s is redirected to HTTPS, and the certificate presented at one or more of `+
+			`those redirects would not be trusted by a browser. Let's Encrypt does not currently verify the certificate when following `+
+			`a redirect, but it does require the TLS handshake to succeed, and the same broken certificate is likely to affect visitors `+
+			`and other tooling. If the certificate is for a different name, or has expired, the redirect may be going somewhere unintended.`, domain),
+		Detail:   strings.Join(hops, "\n"),
+		Severity: SeverityWarning,
+		Err:      err,
+	}
+}
@@ -0,0 +1,77 @@
+package letsdebug
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeRedirectHops(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "www.example.org"},
+		DNSNames:              []string{"www.example.org"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	names := func(probs []Problem) map[string]bool {
+		out := map[string]bool{}
+		for _, p := range probs {
+			out[p.Name] = true
+		}
+		return out
+	}
+
+	good := redirectHop{URL: "https://www.example.org/.well-known/acme-challenge/x", Host: "www.example.org", Chain: []*x509.Certificate{cert}}
+	results := []httpCheckResult{{RedirectHops: []redirectHop{good}}, {RedirectHops: []redirectHop{good}}}
+	probs := analyzeRedirectHops("example.org", results, roots, now)
+	if got := names(probs); !got["RedirectCertificates"] || got["RedirectCertificateInvalid"] {
+		t.Fatalf("expected only a summary for a trusted certificate, got: %v", got)
+	}
+	if len(probs) != 1 || probs[0].Detail != good.String() {
+		t.Fatalf("expected the hop seen from both addresses to be summarized once, got: %q", probs[0].Detail)
+	}
+
+	wrongName := good
+	wrongName.URL, wrongName.Host = "https://other.example.org/", "other.example.org"
+	if got := names(analyzeRedirectHops("example.org", []httpCheckResult{{RedirectHops: []redirectHop{good, wrongName}}}, roots, now)); !got["RedirectCertificateInvalid"] {
+		t.Fatalf("expected a certificate for a different name to be reported, got: %v", got)
+	}
+
+	if got := names(analyzeRedirectHops("example.org", results, roots, now.Add(48*time.Hour))); !got["RedirectCertificateInvalid"] {
+		t.Fatalf("expected an expired certificate to be reported, got: %v", got)
+	}
+
+	if got := names(analyzeRedirectHops("example.org", results, x509.NewCertPool(), now)); !got["RedirectCertificateInvalid"] {
+		t.Fatalf("expected an untrusted certificate to be reported, got: %v", got)
+	}
+
+	if probs := analyzeRedirectHops("example.org", []httpCheckResult{{}}, roots, now); len(probs) != 0 {
+		t.Fatalf("expected nothing without any HTTPS redirects, got: %v", probs)
+	}
+}
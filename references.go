@@ -0,0 +1,40 @@
+package letsdebug
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+// referencesJSON maps Problem.Name to curated documentation and community forum URLs which
+// describe how to fix the problem. To update the table without rebuilding, point the
+// LETSDEBUG_REFERENCES_FILE environment variable at a file in the same format.
+//
+//go:embed references.json
+var referencesJSON []byte
+
+var referencesData = &dataset[map[string][]string]{
+	name:     "references",
+	embedded: referencesJSON,
+	url:      bundledDataURL + "references.json",
+	fileEnv:  "LETSDEBUG_REFERENCES_FILE",
+	parse: func(buf []byte) (map[string][]string, error) {
+		var table map[string][]string
+		err := json.Unmarshal(buf, &table)
+		return table, err
+	},
+}
+
+func loadReferences() map[string][]string {
+	return referencesData.Get()
+}
+
+// withReferences populates Problem.References for every problem with an entry in the table.
+func withReferences(probs []Problem) []Problem {
+	table := loadReferences()
+	for i := range probs {
+		if refs, ok := table[probs[i].Name]; ok && len(probs[i].References) == 0 {
+			probs[i].References = refs
+		}
+	}
+	return probs
+}
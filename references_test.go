@@ -0,0 +1,28 @@
+package letsdebug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	table := loadReferences()
+	if len(table) == 0 {
+		t.Fatal("expected the embedded references table to be loaded")
+	}
+	for name, refs := range table {
+		for _, ref := range refs {
+			if !strings.HasPrefix(ref, "https://") {
+				t.Errorf("reference for %s is not an https URL: %s", name, ref)
+			}
+		}
+	}
+
+	probs := withReferences([]Problem{{Name: "RateLimit"}, {Name: "PublicSuffix"}})
+	if len(probs[0].References) == 0 {
+		t.Fatal("expected RateLimit to have references")
+	}
+	if len(probs[1].References) != 0 {
+		t.Fatalf("expected no references for PublicSuffix, got: %v", probs[1].References)
+	}
+}
@@ -0,0 +1,32 @@
+package letsdebug
+
+import (
+	_ "embed"
+)
+
+// ResultSchemaVersion is the version of the JSON Schema (ResultSchema) which serialized Results
+// conform to. The major version is incremented whenever a change is not backwards compatible.
+const ResultSchemaVersion = "1.4.0"
+
+// ResultSchema is the JSON Schema describing the serialized form of Result and Problem.
+//
+//go:embed schema/result.schema.json
+var ResultSchema []byte
+
+// Result is the serialized outcome of a test, as published by the web API and the CLI.
+type Result struct {
+	SchemaVersion string          `json:"schema_version"`
+	Metadata      *ResultMetadata `json:"metadata,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Problems      []Problem       `json:"problems,omitempty"`
+	Skipped       []SkippedCheck  `json:"skipped,omitempty"`
+}
+
+// NewResult builds a Result from the return values of Check or CheckWithOptions.
+func NewResult(probs []Problem, err error) Result {
+	r := Result{SchemaVersion: ResultSchemaVersion, Metadata: NewResultMetadata(), Problems: probs, Skipped: SkippedChecks(probs)}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
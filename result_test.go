@@ -0,0 +1,96 @@
+package letsdebug
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type schemaObject struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+// jsonFields returns the serialized field names of t, and those which are always present.
+func jsonFields(t reflect.Type) (fields, required []string) {
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")
+		if tag[0] == "" || tag[0] == "-" {
+			continue
+		}
+		fields = append(fields, tag[0])
+		if len(tag) == 1 || tag[1] != "omitempty" {
+			required = append(required, tag[0])
+		}
+	}
+	return fields, required
+}
+
+func checkSchemaObject(t *testing.T, what string, typ reflect.Type, obj schemaObject) {
+	fields, required := jsonFields(typ)
+	var properties []string
+	for k := range obj.Properties {
+		properties = append(properties, k)
+	}
+	sort.Strings(fields)
+	sort.Strings(properties)
+	sort.Strings(required)
+	sort.Strings(obj.Required)
+	if !reflect.DeepEqual(fields, properties) {
+		t.Errorf("%s fields %v do not match the schema properties %v; update schema/result.schema.json "+
+			"and ResultSchemaVersion", what, fields, properties)
+	}
+	if !reflect.DeepEqual(required, obj.Required) {
+		t.Errorf("%s required fields %v do not match the schema %v; update schema/result.schema.json "+
+			"and ResultSchemaVersion", what, required, obj.Required)
+	}
+}
+
+func TestResultSchema(t *testing.T) {
+	var schema struct {
+		schemaObject
+		Defs struct {
+			Problem  schemaObject `json:"problem"`
+			Metadata schemaObject `json:"metadata"`
+			Skipped  schemaObject `json:"skipped_check"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(ResultSchema, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	var version struct {
+		Const string `json:"const"`
+	}
+	if err := json.Unmarshal(schema.Properties["schema_version"], &version); err != nil || version.Const != ResultSchemaVersion {
+		t.Fatalf("schema version %q does not match ResultSchemaVersion %q", version.Const, ResultSchemaVersion)
+	}
+
+	checkSchemaObject(t, "Result", reflect.TypeOf(Result{}), schema.schemaObject)
+	checkSchemaObject(t, "Problem", reflect.TypeOf(Problem{}), schema.Defs.Problem)
+	checkSchemaObject(t, "ResultMetadata", reflect.TypeOf(ResultMetadata{}), schema.Defs.Metadata)
+	checkSchemaObject(t, "SkippedCheck", reflect.TypeOf(SkippedCheck{}), schema.Defs.Skipped)
+}
+
+func TestNewResult(t *testing.T) {
+	r := NewResult([]Problem{{Name: "A"}}, errors.New("failure"))
+	if r.SchemaVersion != ResultSchemaVersion || r.Error != "failure" || len(r.Problems) != 1 || r.Metadata == nil {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+}
+
+func TestNewResultMetadata(t *testing.T) {
+	m := NewResultMetadata()
+	if m.Version == "" || m.PublicSuffixList == "" || m.CDNRanges != cdnRangesUpdated || !strings.HasPrefix(m.References, "sha256:") {
+		t.Fatalf("unexpected metadata: %+v", m)
+	}
+
+	defer func(orig string) { Version = orig }(Version)
+	Version = "v9.9.9"
+	if m := NewResultMetadata(); m.Version != "v9.9.9" {
+		t.Fatalf("expected the version set at build time, got %q", m.Version)
+	}
+}
@@ -0,0 +1,89 @@
+package letsdebug
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSafeBrowsingChecker(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantName string
+		wantSev  SeverityLevel
+		checked  bool
+	}{
+		{
+			name:     "match",
+			status:   http.StatusOK,
+			body:     `{"matches":[{"threatType":"MALWARE","platformType":"ANY_PLATFORM","threat":{"url":"http://example.org/"}}]}`,
+			wantName: "UnsafeDomain",
+			wantSev:  SeverityError,
+			checked:  true,
+		},
+		{
+			name:     "no match",
+			status:   http.StatusOK,
+			body:     `{}`,
+			wantName: "SafeBrowsing",
+			wantSev:  SeverityDebug,
+			checked:  true,
+		},
+		{
+			name:     "non-200",
+			status:   http.StatusForbidden,
+			body:     `{"error":{"code":403}}`,
+			wantName: "InternalProblem",
+			wantSev:  SeverityDebug,
+		},
+		{
+			name:     "bad json",
+			status:   http.StatusOK,
+			body:     `{"matches":`,
+			wantName: "InternalProblem",
+			wantSev:  SeverityDebug,
+		},
+	}
+
+	defer func(orig string) { safeBrowsingLookupURL = orig }(safeBrowsingLookupURL)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("key") != "test-key" {
+					t.Errorf("expected API key to be sent, got query: %s", r.URL.RawQuery)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			safeBrowsingLookupURL = srv.URL + "/?key="
+
+			ctx := newScanContext()
+			ctx.safeBrowsingAPIKey = "test-key"
+
+			probs, err := safeBrowsingChecker{}.Check(ctx, "example.org", HTTP01)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(probs) != 1 || probs[0].Name != tt.wantName || probs[0].Severity != tt.wantSev {
+				t.Fatalf("expected a single %s/%s problem, got: %v", tt.wantName, tt.wantSev, probs)
+			}
+			// A failed lookup must leave the staging checker to report Safe Browsing rejections
+			if ctx.checkedSafeBrowsing() != tt.checked {
+				t.Errorf("expected checkedSafeBrowsing to be %t", tt.checked)
+			}
+		})
+	}
+}
+
+func TestSafeBrowsingCheckerWithoutKey(t *testing.T) {
+	ctx := newScanContext()
+	ctx.safeBrowsingAPIKey = ""
+	if _, err := (safeBrowsingChecker{}).Check(ctx, "example.org", HTTP01); !errors.Is(err, errNotApplicable) {
+		t.Fatalf("expected errNotApplicable, got: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package letsdebug
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// siblingCertificatesChecker lists the certificates recently issued by Let's Encrypt for the other
+// subdomains of the registered domain, as found by rateLimitChecker on crt.sh. A sibling which
+// already has certificates is often managed by an ACME client which has been forgotten about, and
+// which may fight the new one over the same DNS records, web server configuration or rate limits.
+type siblingCertificatesChecker struct{}
+
+func (c siblingCertificatesChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if os.Getenv("LETSDEBUG_DISABLE_CERTWATCH") != "" {
+		return nil, errNotApplicable
+	}
+
+	domain = strings.TrimPrefix(domain, "*.")
+	registeredDomain, _ := effectiveTLDPlusOne(domain)
+	certs, _, err := ctx.recentCertificates(registeredDomain)
+	if err != nil {
+		// Already reported by rateLimitChecker
+		return nil, nil
+	}
+	return describeSiblingCertificates(domain, registeredDomain, certs), nil
+}
+
+// describeSiblingCertificates lists the certificates which don't cover domain, newest first.
+func describeSiblingCertificates(domain, registeredDomain string, certs crtList) []Problem {
+	var siblings sortedCertificates
+	for _, cert := range certs {
+		if cert.VerifyHostname(domain) != nil {
+			siblings = append(siblings, cert)
+		}
+	}
+	if len(siblings) == 0 {
+		return nil
+	}
+	sort.Sort(siblings)
+
+	var lines []string
+	for _, cert := range siblings {
+		lines = append(lines, fmt.Sprintf("%s\n  Issued %s by %s, %s key, serial %s",
+			strings.Join(cert.DNSNames, ", "), cert.NotBefore.UTC().Format(time.RFC3339), cert.Issuer.CommonName,
+			describePublicKey(cert), cert.SerialNumber.String()))
+	}
+	return []Problem{debugProblem("SiblingCertificates",
+		fmt.Sprintf("%d certificates were issued by Let's Encrypt during the last week for other subdomains of %s. "+
+			"Any of them may be managed by an existing ACME client, whose key type may tell which.",
+			len(siblings), registeredDomain),
+		strings.Join(lines, "\n"))}
+}
+
+// describePublicKey names the type and size of the key of cert, e.g. "ECDSA P-256".
+func describePublicKey(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
@@ -0,0 +1,52 @@
+package letsdebug
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeSiblingCertificates(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := pkix.Name{Organization: []string{"Let's Encrypt"}, CommonName: "R3"}
+	now := time.Now()
+	certs := crtList{
+		"1": {DNSNames: []string{"example.org", "www.example.org"}, NotBefore: now.Add(-time.Hour), Issuer: issuer,
+			SerialNumber: big.NewInt(1), PublicKey: &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), 2047)}},
+		"2": {DNSNames: []string{"mail.example.org"}, NotBefore: now.Add(-48 * time.Hour), Issuer: issuer,
+			SerialNumber: big.NewInt(2), PublicKey: &ecKey.PublicKey},
+		"3": {DNSNames: []string{"*.example.org"}, NotBefore: now.Add(-24 * time.Hour), Issuer: issuer,
+			SerialNumber: big.NewInt(3), PublicKey: &ecKey.PublicKey},
+	}
+
+	probs := describeSiblingCertificates("www.example.org", "example.org", certs)
+	if len(probs) != 1 || probs[0].Name != "SiblingCertificates" || probs[0].Severity != SeverityDebug {
+		t.Fatalf("expected the sibling certificates to be listed, got: %v", probs)
+	}
+	if !strings.HasPrefix(probs[0].Explanation, "1 certificates were issued") {
+		t.Errorf("expected only the certificate for mail.example.org, got: %s", probs[0].Explanation)
+	}
+	if want := "mail.example.org\n  Issued "; !strings.HasPrefix(probs[0].Detail, want) ||
+		!strings.Contains(probs[0].Detail, "by R3, ECDSA P-256 key, serial 2") {
+		t.Errorf("unexpected detail: %s", probs[0].Detail)
+	}
+
+	probs = describeSiblingCertificates("blog.example.org", "example.org", certs)
+	if len(probs) != 1 || !strings.HasPrefix(probs[0].Explanation, "2 certificates were issued") ||
+		!strings.HasPrefix(probs[0].Detail, "example.org, www.example.org\n") || !strings.Contains(probs[0].Detail, "RSA 2048 key") {
+		t.Errorf("expected both other certificates, newest first, got: %v", probs)
+	}
+
+	if probs := describeSiblingCertificates("example.org", "example.org", crtList{"1": certs["1"]}); len(probs) != 0 {
+		t.Errorf("expected nothing to be listed, got: %v", probs)
+	}
+}
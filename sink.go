@@ -0,0 +1,85 @@
+package letsdebug
+
+import (
+	"errors"
+	"sync"
+)
+
+// ProblemSink receives each problem as soon as the check which found it completes, rather than
+// once the whole test has finished, e.g. for logging, metrics, or to stop a test early. Problems
+// arrive in the order they were found rather than by severity, and the problems of the analyses
+// which cross-reference the checks arrive last. Suppressed problems (see Options.SuppressProblems)
+// are not passed to the sink, and problems arrive with their severities already overridden (see
+// Options.SeverityOverrides).
+type ProblemSink interface {
+	// Problem is called with each problem found while testing domain. It may be called from several
+	// goroutines at once. Returning false stops the test from starting any further checks, and the
+	// test then returns ErrStopped alongside the problems found so far.
+	Problem(domain string, method ValidationMethod, p Problem) bool
+}
+
+// ProblemSinkFunc adapts a function to a ProblemSink.
+type ProblemSinkFunc func(domain string, method ValidationMethod, p Problem) bool
+
+func (f ProblemSinkFunc) Problem(domain string, method ValidationMethod, p Problem) bool {
+	return f(domain, method, p)
+}
+
+// ErrStopped is returned when the ProblemSink of the test asked for it to stop.
+var ErrStopped = errors.New("the test was stopped by its problem sink")
+
+// problemSink delivers the problems of a scan to the ProblemSink of its options, if any.
+type problemSink struct {
+	sink       ProblemSink
+	suppressed map[string]bool
+	overrides  map[string]SeverityLevel
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+func newProblemSink(sink ProblemSink, suppressProblems []string, overrides map[string]SeverityLevel) *problemSink {
+	s := &problemSink{sink: sink, suppressed: map[string]bool{}, overrides: overrides}
+	for _, name := range suppressProblems {
+		s.suppressed[name] = true
+	}
+	return s
+}
+
+// emit passes probs to the sink, with their references and categories attached and their severities overridden.
+func (s *problemSink) emit(domain string, method ValidationMethod, probs []Problem) {
+	if s == nil {
+		return
+	}
+	for _, p := range withCategories(withReferences(probs)) {
+		if s.suppressed[p.Name] {
+			continue
+		}
+		if level, ok := s.overrides[p.Name]; ok {
+			p.Severity = level
+		}
+		if !s.sink.Problem(domain, method, p) {
+			s.mu.Lock()
+			s.stopped = true
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Stopped is whether the sink asked for the test to stop.
+func (s *problemSink) Stopped() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// emitProblems passes the problems found by a checker to the sink of the scan, if any.
+func (sc *scanContext) emitProblems(domain string, method ValidationMethod, probs []Problem) {
+	if sc == nil {
+		return
+	}
+	sc.sink.emit(domain, method, probs)
+}
@@ -0,0 +1,87 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// skipReason is returned by a checker in place of errNotApplicable, to say why it was skipped.
+type skipReason string
+
+func (r skipReason) Error() string {
+	return string(r)
+}
+
+func (r skipReason) Is(target error) bool {
+	return target == errNotApplicable
+}
+
+// SkippedCheck is a check which was not performed during a test, and why, so that a clean result
+// isn't mistaken for one in which every class of problem was examined.
+type SkippedCheck struct {
+	Check  string `json:"check"`
+	Reason string `json:"reason"`
+}
+
+// skippedChecks is the Err of the SkippedChecks debug problem, from which SkippedChecks recovers them.
+type skippedChecks []SkippedCheck
+
+func (s skippedChecks) Error() string {
+	var lines []string
+	for _, c := range s {
+		lines = append(lines, fmt.Sprintf("%s: %s", c.Check, c.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkName is the name of a checker in a SkippedCheck, e.g. rateLimit for rateLimitChecker.
+func checkName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.TrimSuffix(t.Name(), "Checker")
+}
+
+// recordSkipped records that the checker of type t returned err, which is errNotApplicable or a skipReason.
+func (sc *scanContext) recordSkipped(t reflect.Type, err error) {
+	reason := "Not applicable to this domain and validation method"
+	var r skipReason
+	if errors.As(err, &r) {
+		reason = string(r)
+	}
+	sc.evidenceMu.Lock()
+	defer sc.evidenceMu.Unlock()
+	sc.skipped = append(sc.skipped, SkippedCheck{Check: checkName(t), Reason: reason})
+}
+
+// skippedChecksProblem lists the checks which were skipped during the scan, if any.
+func (sc *scanContext) skippedChecksProblem() (Problem, bool) {
+	sc.evidenceMu.Lock()
+	skipped := append(skippedChecks(nil), sc.skipped...)
+	sc.evidenceMu.Unlock()
+	if len(skipped) == 0 {
+		return Problem{}, false
+	}
+
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Check < skipped[j].Check })
+	p := debugProblem("SkippedChecks", "Checks which were not performed, so the problems they look for were not examined",
+		skipped.Error())
+	p.Err = skipped
+	return p, true
+}
+
+// SkippedChecks returns the checks which were skipped during the test which found probs, as reported
+// by Check and CheckWithOptions in a SkippedChecks debug problem. It returns nil if that problem was
+// suppressed, or the problems were deserialized rather than returned by this package.
+func SkippedChecks(probs []Problem) []SkippedCheck {
+	for _, p := range probs {
+		var skipped skippedChecks
+		if errors.As(p, &skipped) {
+			return skipped
+		}
+	}
+	return nil
+}
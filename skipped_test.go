@@ -0,0 +1,46 @@
+package letsdebug
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type checkerSkipped struct{}
+
+func (c checkerSkipped) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	return nil, skipReason("Disabled for the test")
+}
+
+type checkerNotApplicable struct{}
+
+func (c checkerNotApplicable) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	return nil, errNotApplicable
+}
+
+func TestSkippedChecks(t *testing.T) {
+	if !errors.Is(skipReason("x"), errNotApplicable) {
+		t.Fatal("expected a skip reason to be errNotApplicable")
+	}
+
+	defer func(orig []checker) { checkers = orig }(checkers)
+	checkers = []checker{
+		asyncCheckerBlock{checkerSucceedEmpty{}, checkerSkipped{}},
+		&checkerNotApplicable{},
+	}
+
+	probs, err := CheckWithOptions("example.org", HTTP01, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SkippedCheck{
+		{Check: "checkerNotApplicable", Reason: "Not applicable to this domain and validation method"},
+		{Check: "checkerSkipped", Reason: "Disabled for the test"},
+	}
+	if got := SkippedChecks(probs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if r := NewResult(probs, nil); !reflect.DeepEqual(r.Skipped, want) {
+		t.Fatalf("expected the result to list the skipped checks, got %v", r.Skipped)
+	}
+}
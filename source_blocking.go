@@ -0,0 +1,118 @@
+package letsdebug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sourceBlocker is a security product or hosting provider which is known to block requests based on
+// where they come from, by country, network (ASN) or IP reputation. Let's Encrypt validates from cloud
+// networks in several countries, which such features frequently block, while Let's Debug gets through.
+type sourceBlocker struct {
+	Product string
+	// A response header which identifies the product, and a substring of its value (case-insensitive).
+	// An empty HeaderValue matches any value.
+	Header, HeaderValue string
+	// The features of the product which should be checked
+	Features string
+}
+
+var sourceBlockers = []sourceBlocker{
+	{
+		Product:     "Imunify360",
+		Header:      "Server",
+		HeaderValue: "imunify360",
+		Features: "the Imunify360 firewall's country blocking and IP reputation (the Black List, and the Gray List of the " +
+			"CAPTCHA), which your hosting provider may manage for you",
+	},
+	{
+		Product:     "Plesk",
+		Header:      "X-Powered-By",
+		HeaderValue: "Plesk",
+		Features:    "the rules of the Plesk Firewall extension, including any which block countries, and IP Address Banning (Fail2Ban)",
+	},
+	{
+		Product:  "Sucuri",
+		Header:   "X-Sucuri-Id",
+		Features: "the blocked countries and IP addresses under Access Control in the Sucuri firewall dashboard",
+	},
+	{
+		Product:  "Imperva",
+		Header:   "X-Iinfo",
+		Features: "the security rules of the Imperva website protection which block countries, networks or client classifications",
+	},
+	{
+		Product:     "Akamai",
+		Header:      "Server",
+		HeaderValue: "AkamaiGHost",
+		Features:    "the IP/Geo Firewall and client reputation settings of the Akamai security configuration",
+	},
+}
+
+// matches returns the header which identified the product in the response, if any.
+func (b sourceBlocker) matches(res httpCheckResult) (string, bool) {
+	for _, exchange := range res.Exchanges {
+		for _, v := range exchange.ResponseHeader.Values(b.Header) {
+			if strings.Contains(strings.ToLower(v), strings.ToLower(b.HeaderValue)) {
+				return fmt.Sprintf("%s: %s (%s)", b.Header, v, exchange.URL), true
+			}
+		}
+	}
+	return "", false
+}
+
+// analyzeSourceBlocking is run once every checker has completed. When the http-01 validation request
+// of the Let's Encrypt staging service timed out while our own requests were answered, it names the
+// features of any known security product or hosting provider the server is behind which are likely
+// to have blocked Let's Encrypt. It complements StagingDiscrepancy, which reports the disagreement.
+func analyzeSourceBlocking(ctx *scanContext, domain string, method ValidationMethod, probs []Problem) []Problem {
+	if method != HTTP01 {
+		return nil
+	}
+
+	ctx.evidenceMu.Lock()
+	chal := ctx.stagingChallenge
+	httpResults := ctx.httpResults
+	ctx.evidenceMu.Unlock()
+
+	// Blocking by source is usually done by dropping packets, which Boulder reports as a timeout
+	if chal == nil || chal.Error == nil || !strings.Contains(strings.ToLower(chal.Error.Detail), "timeout") {
+		return nil
+	}
+	for _, p := range probs {
+		if localHTTPFailureProblems[p.Name] {
+			return nil
+		}
+	}
+
+	var found []Problem
+	for _, b := range sourceBlockers {
+		seen := map[string]bool{}
+		var evidence []string
+		for _, res := range httpResults {
+			if line, ok := b.matches(res); ok && !seen[line] {
+				seen[line] = true
+				evidence = append(evidence, line)
+			}
+		}
+		if len(evidence) > 0 {
+			sort.Strings(evidence)
+			found = append(found, sourceBlockingSuspected(domain, b, evidence, *chal))
+		}
+	}
+	return found
+}
+
+func sourceBlockingSuspected(domain string, b sourceBlocker, evidence []string, staging stagingChallenge) Problem {
+	return Problem{
+		Name: "SourceBlockingSuspected",
+		Explanation: fmt.Sprintf(`Let's Debug was able to reach %s, but the validation request of the Let's Encrypt staging `+
+			`service timed out. The server appears to be protected by %s, which can block requests based on the country, `+
+			`network or IP reputation they come from. Let's Encrypt validates from cloud networks in several countries, which `+
+			`such features frequently block. Check %s, and allow requests to /.well-known/acme-challenge/ from anywhere.`,
+			domain, b.Product, b.Features),
+		Detail:   fmt.Sprintf("%s\n\nLet's Encrypt reported: %s", strings.Join(evidence, "\n"), staging.summary()),
+		Severity: SeverityWarning,
+	}
+}
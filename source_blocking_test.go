@@ -0,0 +1,57 @@
+package letsdebug
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/eggsampler/acme/v3"
+)
+
+func TestAnalyzeSourceBlocking(t *testing.T) {
+	imunify := []httpCheckResult{{
+		IP: net.ParseIP("192.0.2.1"), StatusCode: 404, InitialStatusCode: 404,
+		Exchanges: []httpExchange{{
+			URL:            "http://example.org/.well-known/acme-challenge/letsdebug-test",
+			ResponseHeader: http.Header{"Server": []string{"imunify360-webshield/1.21"}},
+		}},
+	}}
+	timedOut := &stagingChallenge{Type: "http-01", Status: "invalid",
+		Error: &acme.Problem{Type: "urn:ietf:params:acme:error:connection", Detail: "Timeout during connect (likely firewall problem)"}}
+	unauthorized := &stagingChallenge{Type: "http-01", Status: "invalid",
+		Error: &acme.Problem{Type: "urn:ietf:params:acme:error:unauthorized", Detail: "Invalid response: 404"}}
+
+	tests := []struct {
+		name        string
+		method      ValidationMethod
+		chal        *stagingChallenge
+		probs       []Problem
+		httpResults []httpCheckResult
+		want        string
+	}{
+		{name: "staging timed out behind Imunify360", method: HTTP01, chal: timedOut, httpResults: imunify, want: "Imunify360"},
+		{name: "no staging challenge", method: HTTP01, httpResults: imunify},
+		{name: "staging failed for another reason", method: HTTP01, chal: unauthorized, httpResults: imunify},
+		{name: "local requests failed too", method: HTTP01, chal: timedOut, httpResults: imunify, probs: []Problem{{Name: "ANotWorking"}}},
+		{name: "no known product", method: HTTP01, chal: timedOut, httpResults: []httpCheckResult{{StatusCode: 404}}},
+		{name: "dns-01", method: DNS01, chal: timedOut, httpResults: imunify},
+	}
+	for _, tt := range tests {
+		ctx := newScanContext()
+		ctx.stagingChallenge = tt.chal
+		ctx.httpResults = tt.httpResults
+		probs := analyzeSourceBlocking(ctx, "example.org", tt.method, tt.probs)
+		if tt.want == "" {
+			if len(probs) != 0 {
+				t.Errorf("%s: expected nothing to be reported, got: %v", tt.name, probs)
+			}
+			continue
+		}
+		if len(probs) != 1 || probs[0].Name != "SourceBlockingSuspected" || !strings.Contains(probs[0].Explanation, tt.want) {
+			t.Errorf("%s: expected %s to be named, got: %v", tt.name, tt.want, probs)
+		} else if !strings.Contains(probs[0].Detail, "Server: imunify360-webshield/1.21") {
+			t.Errorf("%s: expected the matching header in the detail, got: %s", tt.name, probs[0].Detail)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package letsdebug
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eggsampler/acme/v3"
+)
+
+const (
+	stagingAccountMinBackoff = time.Minute
+	stagingAccountMaxBackoff = time.Hour
+)
+
+// stagingAccount is a Let's Encrypt staging account which is a member of a stagingAccountPool.
+type stagingAccount struct {
+	acme.Account
+	Path string
+
+	failures     int
+	backoffUntil time.Time
+}
+
+// stagingAccountPool rotates staging authorizations between a number of ACME accounts, so that
+// account-scoped rate limits (new orders, failed authorizations) are spread between them during
+// traffic spikes. Accounts which hit such a rate limit are backed off exponentially.
+type stagingAccountPool struct {
+	mu       sync.Mutex
+	accounts []*stagingAccount
+	next     int
+}
+
+// defaultStagingDirectory is the directory of the Let's Encrypt staging service.
+const defaultStagingDirectory = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+// stagingDirectory returns the directory URL of the ACME server used by the staging checker,
+// preferring the one provided via Options, then the LETSDEBUG_ACMESTAGING_DIRECTORY environment
+// variable, then the Let's Encrypt staging service.
+func stagingDirectory(fromOpts string) string {
+	if fromOpts != "" {
+		return fromOpts
+	}
+	if dir := os.Getenv("LETSDEBUG_ACMESTAGING_DIRECTORY"); dir != "" {
+		return dir
+	}
+	return defaultStagingDirectory
+}
+
+// stagingAccountFiles returns the configured account files, preferring those provided via
+// Options, then the LETSDEBUG_ACMESTAGING_ACCOUNTFILE environment variable (which may contain
+// a list of paths separated by the OS path list separator), then acme-account.json.
+func stagingAccountFiles(fromOpts []string) []string {
+	if len(fromOpts) > 0 {
+		return fromOpts
+	}
+	if paths := filepath.SplitList(os.Getenv("LETSDEBUG_ACMESTAGING_ACCOUNTFILE")); len(paths) > 0 {
+		return paths
+	}
+	return []string{"acme-account.json"}
+}
+
+func newStagingAccountPool(paths []string) (*stagingAccountPool, error) {
+	pool := &stagingAccountPool{}
+	for _, path := range paths {
+		acct, err := loadStagingAccount(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading staging account %s: %w", path, err)
+		}
+		pool.accounts = append(pool.accounts, &stagingAccount{Account: acct, Path: path})
+	}
+	if len(pool.accounts) == 0 {
+		return nil, errors.New("no staging accounts were configured")
+	}
+	return pool, nil
+}
+
+func loadStagingAccount(path string) (acme.Account, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return acme.Account{}, err
+	}
+
+	var out struct {
+		PEM string `json:"pem"`
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(buf, &out); err != nil {
+		return acme.Account{}, err
+	}
+
+	block, _ := pem.Decode([]byte(out.PEM))
+	if block == nil {
+		return acme.Account{}, errors.New("account file does not contain a PEM private key")
+	}
+	pk, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return acme.Account{}, err
+	}
+
+	return acme.Account{PrivateKey: pk, URL: out.URL}, nil
+}
+
+// Len returns the number of accounts in the pool.
+func (p *stagingAccountPool) Len() int {
+	return len(p.accounts)
+}
+
+// Acquire returns the next account in the rotation which is not currently backing off.
+func (p *stagingAccountPool) Acquire() (*stagingAccount, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(p.accounts); i++ {
+		acct := p.accounts[(p.next+i)%len(p.accounts)]
+		if now.Before(acct.backoffUntil) {
+			continue
+		}
+		p.next = (p.next + i + 1) % len(p.accounts)
+		return acct, true
+	}
+
+	return nil, false
+}
+
+// Backoff removes the account from the rotation for an exponentially increasing duration.
+func (p *stagingAccountPool) Backoff(acct *stagingAccount) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	backoff := stagingAccountMinBackoff << acct.failures
+	if backoff > stagingAccountMaxBackoff || backoff <= 0 {
+		backoff = stagingAccountMaxBackoff
+	} else {
+		acct.failures++
+	}
+	acct.backoffUntil = time.Now().Add(backoff)
+	debug("[*] staging account %s backing off for %v\n", acct.Path, backoff)
+}
+
+// Succeeded resets the backoff state of the account.
+func (p *stagingAccountPool) Succeeded(acct *stagingAccount) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	acct.failures = 0
+	acct.backoffUntil = time.Time{}
+}
+
+// isAccountRateLimited determines whether an ACME error is a rate limit which is scoped to
+// the account performing the request, rather than to the domain being checked.
+func isAccountRateLimited(err error) bool {
+	var acmeErr acme.Problem
+	if !errors.As(err, &acmeErr) || acmeErr.Type != "urn:ietf:params:acme:error:rateLimited" {
+		return false
+	}
+	detail := strings.ToLower(acmeErr.Detail)
+	return strings.Contains(detail, "new orders") || strings.Contains(detail, "failed authorizations")
+}
@@ -0,0 +1,48 @@
+package letsdebug
+
+import "testing"
+
+func TestStagingAccountPool(t *testing.T) {
+	a, b := &stagingAccount{Path: "a"}, &stagingAccount{Path: "b"}
+	pool := &stagingAccountPool{accounts: []*stagingAccount{a, b}}
+
+	// Accounts are handed out in rotation
+	if acct, _ := pool.Acquire(); acct != a {
+		t.Fatalf("expected account a, got %s", acct.Path)
+	}
+	if acct, _ := pool.Acquire(); acct != b {
+		t.Fatalf("expected account b, got %s", acct.Path)
+	}
+
+	// Backed off accounts are skipped
+	pool.Backoff(a)
+	for i := 0; i < 3; i++ {
+		if acct, _ := pool.Acquire(); acct != b {
+			t.Fatalf("expected account b, got %s", acct.Path)
+		}
+	}
+
+	pool.Backoff(b)
+	if _, ok := pool.Acquire(); ok {
+		t.Fatal("expected no account to be available")
+	}
+
+	pool.Succeeded(a)
+	if acct, _ := pool.Acquire(); acct != a {
+		t.Fatalf("expected account a after success, got %v", acct)
+	}
+}
+
+func TestStagingDirectory(t *testing.T) {
+	t.Setenv("LETSDEBUG_ACMESTAGING_DIRECTORY", "")
+	if got := stagingDirectory(""); got != defaultStagingDirectory {
+		t.Errorf("expected the Let's Encrypt staging directory by default, got %q", got)
+	}
+	t.Setenv("LETSDEBUG_ACMESTAGING_DIRECTORY", "https://localhost:14000/dir")
+	if got := stagingDirectory(""); got != "https://localhost:14000/dir" {
+		t.Errorf("expected the directory from the environment, got %q", got)
+	}
+	if got := stagingDirectory("https://pebble:14000/dir"); got != "https://pebble:14000/dir" {
+		t.Errorf("expected the directory from the options to take precedence, got %q", got)
+	}
+}
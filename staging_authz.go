@@ -0,0 +1,114 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eggsampler/acme/v3"
+)
+
+// stagingAuthorization is the subset of an ACME authorization object, as returned by Boulder,
+// including the challenge fields which the ACME client library does not expose.
+type stagingAuthorization struct {
+	Status     string             `json:"status"`
+	Challenges []stagingChallenge `json:"challenges"`
+}
+
+type stagingChallenge struct {
+	Type             string                    `json:"type"`
+	Status           string                    `json:"status"`
+	Error            *acme.Problem             `json:"error,omitempty"`
+	ValidationRecord []stagingValidationRecord `json:"validationRecord,omitempty"`
+}
+
+// stagingValidationRecord is Boulder's record of what it did while validating a challenge.
+// https://github.com/letsencrypt/boulder/blob/main/core/objects.go
+type stagingValidationRecord struct {
+	URL               string   `json:"url,omitempty"`
+	Hostname          string   `json:"hostname,omitempty"`
+	Port              string   `json:"port,omitempty"`
+	AddressesResolved []string `json:"addressesResolved,omitempty"`
+	AddressUsed       string   `json:"addressUsed,omitempty"`
+	AddressesTried    []string `json:"addressesTried,omitempty"`
+	ResolverAddrs     []string `json:"resolverAddrs,omitempty"`
+}
+
+func (r stagingValidationRecord) String() string {
+	var fields []string
+	add := func(k, v string) {
+		if v != "" {
+			fields = append(fields, k+"="+v)
+		}
+	}
+	add("URL", r.URL)
+	add("Hostname", r.Hostname)
+	add("Port", r.Port)
+	add("Addresses Resolved", strings.Join(r.AddressesResolved, ","))
+	add("Address Used", r.AddressUsed)
+	add("Addresses Tried", strings.Join(r.AddressesTried, ","))
+	add("Resolvers", strings.Join(r.ResolverAddrs, ","))
+	return fmt.Sprintf("[%s]", strings.Join(fields, ", "))
+}
+
+// stagingFetcher is the subset of acme.Client used by fetchStagingChallenge.
+type stagingFetcher interface {
+	Fetch(account acme.Account, requestURL string, result interface{}, expectedStatus ...int) error
+}
+
+// fetchStagingChallenge re-fetches an authorization after its challenge has failed, and returns the
+// challenge for the validation method, including Boulder's error and validation records.
+func fetchStagingChallenge(client stagingFetcher, acct acme.Account, authzURL string,
+	method ValidationMethod) (stagingChallenge, error) {
+	var authz stagingAuthorization
+	if err := client.Fetch(acct, authzURL, &authz); err != nil {
+		return stagingChallenge{}, err
+	}
+
+	for _, chal := range authz.Challenges {
+		if chal.Type == string(method) {
+			return chal, nil
+		}
+	}
+
+	return stagingChallenge{}, fmt.Errorf("authorization %s has no %s challenge", authzURL, method)
+}
+
+// summary returns Boulder's error for the challenge on a single line.
+func (chal stagingChallenge) summary() string {
+	if chal.Error == nil {
+		return fmt.Sprintf("Challenge %s was %s", chal.Type, chal.Status)
+	}
+	return fmt.Sprintf("%s: %s", chal.Error.Type, chal.Error.Detail)
+}
+
+// describe summarizes what Boulder reported about the challenge validation attempt.
+func (chal stagingChallenge) describe() string {
+	lines := []string{fmt.Sprintf("Challenge %s was %s", chal.Type, chal.Status)}
+	if chal.Error != nil {
+		lines = append(lines, fmt.Sprintf("Error: %s: %s", chal.Error.Type, chal.Error.Detail))
+	}
+	if chal.Type == string(HTTP01) && len(chal.ValidationRecord) > 0 {
+		return strings.Join(append(lines, chal.describeHTTP()), "\n")
+	}
+	for i, record := range chal.ValidationRecord {
+		lines = append(lines, fmt.Sprintf("Validation record #%d: %s", i+1, record.String()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describeHTTP narrates the http-01 validation records, so that what Let's Encrypt connected to can be
+// compared with the requests that Let's Debug made itself (shown in the HTTPCheck debug information).
+func (chal stagingChallenge) describeHTTP() string {
+	var hops []string
+	for i, record := range chal.ValidationRecord {
+		hop := fmt.Sprintf("%d. %s", i+1, record.URL)
+		if record.AddressUsed != "" {
+			hop += fmt.Sprintf(" via %s", record.AddressUsed)
+		}
+		if len(record.AddressesTried) > 0 {
+			hop += fmt.Sprintf(" (after failing to connect to %s)", strings.Join(record.AddressesTried, ", "))
+		}
+		hops = append(hops, hop)
+	}
+	return "Let's Encrypt followed:\n" + strings.Join(hops, "\n")
+}
@@ -0,0 +1,119 @@
+package letsdebug
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eggsampler/acme/v3"
+)
+
+// A failed dns-01 authorization, as returned by Boulder.
+const stagingDNS01AuthzFixture = `{
+  "identifier": {"type": "dns", "value": "example.org"},
+  "status": "invalid",
+  "expires": "2024-01-08T00:00:00Z",
+  "challenges": [
+    {
+      "type": "http-01",
+      "status": "pending",
+      "url": "https://acme-staging-v02.api.letsencrypt.org/acme/chall-v3/1/a",
+      "token": "a"
+    },
+    {
+      "type": "dns-01",
+      "status": "invalid",
+      "error": {
+        "type": "urn:ietf:params:acme:error:unauthorized",
+        "detail": "Incorrect TXT record \"abc\" found at _acme-challenge.example.org",
+        "status": 403
+      },
+      "url": "https://acme-staging-v02.api.letsencrypt.org/acme/chall-v3/1/b",
+      "token": "b",
+      "validationRecord": [
+        {
+          "hostname": "example.org",
+          "resolverAddrs": ["10.0.0.1:53", "10.0.0.2:53"]
+        }
+      ]
+    }
+  ]
+}`
+
+type fixtureFetcher struct {
+	body string
+	err  error
+}
+
+func (f fixtureFetcher) Fetch(account acme.Account, requestURL string, result interface{}, expectedStatus ...int) error {
+	if f.err != nil {
+		return f.err
+	}
+	return json.Unmarshal([]byte(f.body), result)
+}
+
+func TestFetchStagingChallenge(t *testing.T) {
+	chal, err := fetchStagingChallenge(fixtureFetcher{body: stagingDNS01AuthzFixture}, acme.Account{}, "authz", DNS01)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chal.Type != "dns-01" || chal.Status != "invalid" || chal.Error == nil {
+		t.Fatalf("unexpected challenge: %+v", chal)
+	}
+
+	expected := strings.Join([]string{
+		"Challenge dns-01 was invalid",
+		`Error: urn:ietf:params:acme:error:unauthorized: Incorrect TXT record "abc" found at _acme-challenge.example.org`,
+		"Validation record #1: [Hostname=example.org, Resolvers=10.0.0.1:53,10.0.0.2:53]",
+	}, "\n")
+	if got := chal.describe(); got != expected {
+		t.Fatalf("unexpected description.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if _, err := fetchStagingChallenge(fixtureFetcher{body: stagingDNS01AuthzFixture}, acme.Account{}, "authz", "tls-alpn-01"); err == nil {
+		t.Fatal("expected an error for a missing challenge type")
+	}
+
+	if _, err := fetchStagingChallenge(fixtureFetcher{err: errors.New("offline")}, acme.Account{}, "authz", DNS01); err == nil {
+		t.Fatal("expected the fetch error to be returned")
+	}
+}
+
+func TestStagingChallengeDescribeHTTP(t *testing.T) {
+	chal := stagingChallenge{
+		Type:   "http-01",
+		Status: "invalid",
+		Error: &acme.Problem{
+			Type:   "urn:ietf:params:acme:error:connection",
+			Detail: "Timeout during connect (likely firewall problem)",
+		},
+		ValidationRecord: []stagingValidationRecord{
+			{
+				URL:               "http://example.org/.well-known/acme-challenge/a",
+				Hostname:          "example.org",
+				Port:              "80",
+				AddressesResolved: []string{"2001:db8::1", "192.0.2.1"},
+				AddressUsed:       "192.0.2.1",
+				AddressesTried:    []string{"2001:db8::1"},
+			},
+			{
+				URL:         "https://www.example.org/.well-known/acme-challenge/a",
+				Hostname:    "www.example.org",
+				Port:        "443",
+				AddressUsed: "192.0.2.2",
+			},
+		},
+	}
+
+	expected := strings.Join([]string{
+		"Challenge http-01 was invalid",
+		"Error: urn:ietf:params:acme:error:connection: Timeout during connect (likely firewall problem)",
+		"Let's Encrypt followed:",
+		"1. http://example.org/.well-known/acme-challenge/a via 192.0.2.1 (after failing to connect to 2001:db8::1)",
+		"2. https://www.example.org/.well-known/acme-challenge/a via 192.0.2.2",
+	}, "\n")
+	if got := chal.describe(); got != expected {
+		t.Fatalf("unexpected description.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,45 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+)
+
+// analyzeStandalone is run once every checker has completed, when the user has declared that their
+// ACME client will run in standalone mode. Such a client must bind port 80 itself, which fails if
+// a web server is already listening there, so every server which answered our request is a conflict.
+func analyzeStandalone(ctx *scanContext, domain string, method ValidationMethod) []Problem {
+	if !ctx.standalone || method != HTTP01 {
+		return nil
+	}
+
+	ctx.evidenceMu.Lock()
+	httpResults := ctx.httpResults
+	ctx.evidenceMu.Unlock()
+
+	var answering []string
+	for _, res := range httpResults {
+		if !res.IsZero() {
+			answering = append(answering, fmt.Sprintf("%s: %s", res.IP, res.String()))
+		}
+	}
+	if len(answering) == 0 {
+		return nil
+	}
+	return []Problem{standalonePortConflict(domain, answering)}
+}
+
+func standalonePortConflict(domain string, answering []string) Problem {
+	return Problem{
+		Name: "StandalonePortConflict",
+		Explanation: fmt.Sprintf(`You are planning to use an ACME client in standalone mode (such as certbot --standalone), which `+
+			`answers the challenge by starting its own web server on port 80. However, a web server is already answering on port 80 `+
+			`for %s. If it runs on the same machine as your ACME client, the client will fail to bind the port (certbot reports `+
+			`"Could not bind TCP port 80 because it is already in use"). Stop the web server while the client runs (e.g. with `+
+			`certbot's --pre-hook and --post-hook), or use a mode which works with it instead, such as certbot --webroot or the `+
+			`--nginx and --apache plugins. If the web server is on another machine, make sure port 80 is forwarded to the machine `+
+			`running the ACME client instead.`, domain),
+		Detail:   strings.Join(answering, "\n"),
+		Severity: SeverityError,
+	}
+}
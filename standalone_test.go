@@ -0,0 +1,29 @@
+package letsdebug
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAnalyzeStandalone(t *testing.T) {
+	ctx := newScanContextWithOptions(Options{Standalone: true})
+	ctx.recordHTTPResults([]httpCheckResult{{IP: net.ParseIP("192.0.2.1")}})
+	if probs := analyzeStandalone(ctx, "example.org", HTTP01); len(probs) != 0 {
+		t.Fatalf("expected no conflict when nothing answers on port 80, got: %v", probs)
+	}
+
+	ctx.recordHTTPResults([]httpCheckResult{{IP: net.ParseIP("192.0.2.2"), StatusCode: 404, ServerHeader: "nginx"}})
+	probs := analyzeStandalone(ctx, "example.org", HTTP01)
+	if len(probs) != 1 || probs[0].Name != "StandalonePortConflict" {
+		t.Fatalf("expected a conflict with the server answering on port 80, got: %v", probs)
+	}
+	if probs := analyzeStandalone(ctx, "example.org", DNS01); len(probs) != 0 {
+		t.Fatalf("expected dns-01 to be unaffected, got: %v", probs)
+	}
+
+	ctx = newScanContextWithOptions(Options{})
+	ctx.recordHTTPResults([]httpCheckResult{{IP: net.ParseIP("192.0.2.2"), StatusCode: 404}})
+	if probs := analyzeStandalone(ctx, "example.org", HTTP01); len(probs) != 0 {
+		t.Fatalf("expected nothing to be reported unless standalone mode was declared, got: %v", probs)
+	}
+}
@@ -0,0 +1,187 @@
+package letsdebug
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tlsInterceptionChecker connects to the TLS port (443, unless Options.TLSPort is set) several times and compares what it sees each time.
+// Interception appliances (corporate MITM CAs, ISP and hosting provider proxies) on the path to the
+// origin present certificates from their own CA, and sometimes only for some connections, which
+// often explains validation results that seem to change from one attempt to the next. It also checks
+// that the chains presented are complete.
+type tlsInterceptionChecker struct{}
+
+const tlsObservationsPerAddress = 3
+
+// interceptionIssuers are fragments of the issuer names used by the CAs of common TLS
+// interception products. They are matched case-insensitively against the whole chain.
+var interceptionIssuers = []string{
+	"fortinet", "fortigate", "palo alto", "zscaler", "sophos", "netskope", "blue coat", "bluecoat",
+	"cisco umbrella", "barracuda", "watchguard", "untangle", "kaspersky", "avast", "eset ssl filter",
+	"mcafee web gateway", "forcepoint", "checkpoint", "check point", "smoothwall", "lightspeed",
+}
+
+type tlsObservation struct {
+	Address     net.IP
+	Version     uint16
+	CipherSuite uint16
+	Chain       []*x509.Certificate
+	Error       error
+}
+
+// Fingerprint identifies the leaf certificate of the observation.
+func (o tlsObservation) Fingerprint() string {
+	if len(o.Chain) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(o.Chain[0].Raw))
+}
+
+func (o tlsObservation) String() string {
+	if o.Error != nil {
+		return fmt.Sprintf("%s: %v", o.Address, o.Error)
+	}
+	var issuers []string
+	for _, cert := range o.Chain {
+		issuers = append(issuers, cert.Issuer.String())
+	}
+	return fmt.Sprintf("%s: %s, %s, leaf sha256=%s, issued by: %s", o.Address, tls.VersionName(o.Version),
+		tls.CipherSuiteName(o.CipherSuite), o.Fingerprint(), strings.Join(issuers, " <- "))
+}
+
+func (c tlsInterceptionChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method == DNS01 {
+		return nil, errNotApplicable
+	}
+
+	domain = strings.TrimPrefix(domain, "*.")
+
+	ips := ctx.LookupAddresses(domain)
+	if len(ips) == 0 {
+		return nil, errNotApplicable
+	}
+
+	var observations []tlsObservation
+	for _, ip := range ips {
+		for i := 0; i < tlsObservationsPerAddress; i++ {
+			obs := observeTLS(domain, ip, ctx.tlsPort)
+			observations = append(observations, obs)
+			// Don't keep knocking on a port that isn't open
+			if obs.Error != nil {
+				break
+			}
+		}
+	}
+
+	probs := analyzeTLSObservations(domain, ctx.tlsPort, observations)
+	probs = append(probs, analyzeCertificateChains(domain, ctx.tlsPort, observations, nil, time.Now(), fetchAIACertificate)...)
+	return probs, nil
+}
+
+func observeTLS(domain string, ip net.IP, port int) tlsObservation {
+	obs := tlsObservation{Address: ip}
+
+	dialer := &net.Dialer{Timeout: httpTimeout * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)), &tls.Config{
+		ServerName:         domain,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		obs.Error = err
+		return obs
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	obs.Version = state.Version
+	obs.CipherSuite = state.CipherSuite
+	obs.Chain = state.PeerCertificates
+	return obs
+}
+
+func analyzeTLSObservations(domain string, port int, observations []tlsObservation) []Problem {
+	var probs []Problem
+	var lines []string
+
+	fingerprints := map[string]map[string]bool{}
+	interceptedBy := map[string]bool{}
+	for _, obs := range observations {
+		lines = append(lines, obs.String())
+		if obs.Error != nil {
+			continue
+		}
+		addr := obs.Address.String()
+		if fingerprints[addr] == nil {
+			fingerprints[addr] = map[string]bool{}
+		}
+		fingerprints[addr][obs.Fingerprint()] = true
+		if issuer := interceptionIssuer(obs.Chain); issuer != "" {
+			interceptedBy[issuer] = true
+		}
+	}
+
+	if len(interceptedBy) > 0 {
+		var issuers []string
+		for issuer := range interceptedBy {
+			issuers = append(issuers, issuer)
+		}
+		probs = append(probs, tlsInterception(domain, port, issuers, lines))
+	}
+
+	for addr, seen := range fingerprints {
+		if len(seen) > 1 {
+			probs = append(probs, inconsistentTLS(domain, port, addr, len(seen), lines))
+		}
+	}
+
+	if len(lines) > 0 {
+		probs = append(probs, debugProblem("TLS", fmt.Sprintf("TLS connections made to port %d of the domain", port), strings.Join(lines, "\n")))
+	}
+
+	return probs
+}
+
+// interceptionIssuer returns the issuer of the first certificate in the chain which appears to belong
+// to a TLS interception product, if any.
+func interceptionIssuer(chain []*x509.Certificate) string {
+	for _, cert := range chain {
+		issuer := strings.ToLower(cert.Issuer.String())
+		for _, needle := range interceptionIssuers {
+			if strings.Contains(issuer, needle) {
+				return cert.Issuer.String()
+			}
+		}
+	}
+	return ""
+}
+
+func tlsInterception(domain string, port int, issuers, observations []string) Problem {
+	return Problem{
+		Name: "TLSInterception",
+		Explanation: fmt.Sprintf(`The certificate presented on port %d of %s was issued by what appears to be a TLS `+
+			`interception product, rather than by a public certificate authority. This usually means that a firewall, `+
+			`proxy or security appliance sits in front of the web server and is decrypting traffic. Such devices frequently `+
+			`interfere with validation requests, and any certificate installed on the web server will not be seen by visitors.`, port, domain),
+		Detail:   fmt.Sprintf("Issued by: %s\n\n%s", strings.Join(issuers, ", "), strings.Join(observations, "\n")),
+		Severity: SeverityWarning,
+	}
+}
+
+func inconsistentTLS(domain string, port int, address string, count int, observations []string) Problem {
+	return Problem{
+		Name: "InconsistentTLS",
+		Explanation: fmt.Sprintf(`Repeated connections to port %d of %s (%s) were answered with %d different certificates. `+
+			`This can be caused by a load balancer with inconsistently configured backends, or by a middlebox which only `+
+			`intercepts some connections, and may explain validation results which differ from one attempt to the next.`,
+			port, domain, address, count),
+		Detail:   strings.Join(observations, "\n"),
+		Severity: SeverityWarning,
+	}
+}
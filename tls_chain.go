@@ -0,0 +1,159 @@
+package letsdebug
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// aiaFetcher fetches the certificate published at a "CA Issuers" URL of the Authority Information
+// Access extension of a certificate.
+type aiaFetcher func(url string) (*x509.Certificate, error)
+
+// fetchAIACertificate is the aiaFetcher used in scans. CA Issuers URLs usually serve a DER
+// certificate, but some serve PEM.
+func fetchAIACertificate(url string) (*x509.Certificate, error) {
+	client := &http.Client{Timeout: httpTimeout * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(bytes.TrimSpace(buf)); block != nil {
+		buf = block.Bytes
+	}
+	return x509.ParseCertificate(buf)
+}
+
+// missingIssuer follows the presented chain up from the leaf, and returns the last certificate
+// reached, whose issuer was not presented.
+func missingIssuer(chain []*x509.Certificate) *x509.Certificate {
+	cert := chain[0]
+	// Each presented certificate can extend the path at most once
+	for range chain[1:] {
+		var next *x509.Certificate
+		for _, candidate := range chain[1:] {
+			if candidate != cert && cert.CheckSignatureFrom(candidate) == nil {
+				next = candidate
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		cert = next
+	}
+	return cert
+}
+
+// analyzeCertificateChains checks whether the chain presented on the TLS port is missing an intermediate
+// certificate, and if so, whether a client which fetches intermediates from the AIA extension (as most
+// browsers do) could complete it. If roots is nil, the system roots are used.
+func analyzeCertificateChains(domain string, port int, observations []tlsObservation, roots *x509.CertPool, now time.Time, fetch aiaFetcher) []Problem {
+	var probs []Problem
+	seen := map[string]bool{}
+	fetched := map[string]*x509.Certificate{}
+	fetchErrs := map[string]error{}
+
+	for _, obs := range observations {
+		if obs.Error != nil || len(obs.Chain) == 0 || seen[obs.Fingerprint()] {
+			continue
+		}
+		seen[obs.Fingerprint()] = true
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range obs.Chain[1:] {
+			intermediates.AddCert(cert)
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now}
+		var unknownAuthority x509.UnknownAuthorityError
+		if _, err := obs.Chain[0].Verify(opts); !errors.As(err, &unknownAuthority) {
+			continue
+		}
+
+		last := missingIssuer(obs.Chain)
+		// A self-signed certificate at the end of the chain isn't missing anything, it's just not trusted
+		if bytes.Equal(last.RawIssuer, last.RawSubject) && last.CheckSignatureFrom(last) == nil {
+			continue
+		}
+
+		var lines []string
+		var issuer *x509.Certificate
+		for _, url := range last.IssuingCertificateURL {
+			if _, ok := fetched[url]; !ok {
+				fetched[url], fetchErrs[url] = fetch(url)
+			}
+			cert, err := fetched[url], fetchErrs[url]
+			switch {
+			case err != nil:
+				lines = append(lines, fmt.Sprintf("%s: %v", url, err))
+			case last.CheckSignatureFrom(cert) != nil:
+				lines = append(lines, fmt.Sprintf("%s: %s did not issue %s", url, cert.Subject, last.Subject))
+			default:
+				lines = append(lines, fmt.Sprintf("%s: %s", url, cert.Subject))
+				if issuer == nil {
+					issuer = cert
+				}
+			}
+		}
+		if len(last.IssuingCertificateURL) == 0 {
+			lines = append(lines, fmt.Sprintf("%s has no CA Issuers URL to fetch its issuer from", last.Subject))
+		}
+
+		completed := false
+		if issuer != nil {
+			intermediates.AddCert(issuer)
+			_, err := obs.Chain[0].Verify(opts)
+			completed = err == nil
+		}
+		probs = append(probs, incompleteCertificateChain(domain, port, obs, last, issuer, completed, lines))
+	}
+
+	return probs
+}
+
+func incompleteCertificateChain(domain string, port int, obs tlsObservation, last, issuer *x509.Certificate, completed bool, fetches []string) Problem {
+	missing := fmt.Sprintf("the certificate of %s", last.Issuer)
+	if issuer != nil {
+		missing = fmt.Sprintf("the intermediate certificate %q", issuer.Subject.String())
+	}
+	explanation := fmt.Sprintf(`The certificate chain presented on port %d of %s (%s) is incomplete: it is missing %s, `+
+		`which issued %q. `, port, domain, obs.Address, missing, last.Subject.String())
+	if completed {
+		explanation += `Most browsers fetch the missing certificate from the URL in the Authority Information Access extension, which masks ` +
+			`the problem, but stricter clients do not, and will fail to connect: command-line tools like curl and wget, ` +
+			`programming languages and API clients, some ACME clients and monitoring tools. `
+	} else if issuer != nil {
+		explanation += `Even with it fetched from the URL in the Authority Information Access extension, the chain does not lead to ` +
+			`a trusted root, so browsers are also likely to reject the certificate. `
+	} else {
+		explanation += `It also could not be fetched from the Authority Information Access extension, so even browsers are likely ` +
+			`to reject the certificate. `
+	}
+	explanation += `Configure the web server to send the full chain, which your ACME client normally saves as fullchain.pem, ` +
+		`rather than only the certificate (cert.pem).`
+
+	var chain []string
+	for _, cert := range obs.Chain {
+		chain = append(chain, cert.Subject.String())
+	}
+	return Problem{
+		Name:        "IncompleteCertificateChain",
+		Explanation: explanation,
+		Detail:      fmt.Sprintf("Presented chain: %s\n\nAIA fetching:\n%s", strings.Join(chain, " <- "), strings.Join(fetches, "\n")),
+		Severity:    SeverityWarning,
+	}
+}
@@ -0,0 +1,77 @@
+package letsdebug
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeCertificateChains(t *testing.T) {
+	now := time.Now()
+	issue := func(serial int64, name string, ca bool, aia []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(24 * time.Hour),
+			IsCA:                  ca,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			IssuingCertificateURL: aia,
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	root, rootKey := issue(1, "Test Root", true, nil, nil, nil)
+	intermediate, intermediateKey := issue(2, "Test Intermediate", true, nil, root, rootKey)
+	leaf, _ := issue(3, "example.org", false, []string{"http://aia.example/intermediate.der"}, intermediate, intermediateKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	ip := net.ParseIP("192.0.2.1")
+	serving := func(url string) (*x509.Certificate, error) { return intermediate, nil }
+	offline := func(url string) (*x509.Certificate, error) { return nil, errors.New("connection refused") }
+
+	if probs := analyzeCertificateChains("example.org", 443, []tlsObservation{{Address: ip, Chain: []*x509.Certificate{leaf, intermediate}}}, roots, now, offline); len(probs) != 0 {
+		t.Fatalf("expected no problems with a complete chain, got: %v", probs)
+	}
+
+	incomplete := []tlsObservation{{Address: ip, Chain: []*x509.Certificate{leaf}}, {Address: ip, Chain: []*x509.Certificate{leaf}}}
+	probs := analyzeCertificateChains("example.org", 443, incomplete, roots, now, serving)
+	if len(probs) != 1 || probs[0].Name != "IncompleteCertificateChain" ||
+		!strings.Contains(probs[0].Explanation, `"CN=Test Intermediate"`) || !strings.Contains(probs[0].Explanation, "masks") {
+		t.Fatalf("expected the missing intermediate to be named and fetchable, got: %v", probs)
+	}
+
+	probs = analyzeCertificateChains("example.org", 443, incomplete, roots, now, offline)
+	if len(probs) != 1 || !strings.Contains(probs[0].Explanation, "could not be fetched") || !strings.Contains(probs[0].Detail, "connection refused") {
+		t.Fatalf("expected the intermediate to be reported as unfetchable, got: %v", probs)
+	}
+
+	if probs := analyzeCertificateChains("example.org", 443, []tlsObservation{{Address: ip, Chain: []*x509.Certificate{root}}}, x509.NewCertPool(), now, offline); len(probs) != 0 {
+		t.Fatalf("expected an untrusted self-signed certificate not to be reported as incomplete, got: %v", probs)
+	}
+}
@@ -0,0 +1,49 @@
+package letsdebug
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestAnalyzeTLSObservations(t *testing.T) {
+	public := &x509.Certificate{Raw: []byte("public"), Issuer: pkix.Name{Organization: []string{"Let's Encrypt"}, CommonName: "R3"}}
+	other := &x509.Certificate{Raw: []byte("other"), Issuer: pkix.Name{Organization: []string{"Let's Encrypt"}, CommonName: "R3"}}
+	intercepted := &x509.Certificate{Raw: []byte("mitm"), Issuer: pkix.Name{Organization: []string{"Fortinet"}, CommonName: "FortiGate CA"}}
+	ip := net.ParseIP("192.0.2.1")
+
+	names := func(probs []Problem) map[string]bool {
+		out := map[string]bool{}
+		for _, p := range probs {
+			out[p.Name] = true
+		}
+		return out
+	}
+
+	probs := names(analyzeTLSObservations("example.org", 443, []tlsObservation{
+		{Address: ip, Chain: []*x509.Certificate{public}},
+		{Address: ip, Chain: []*x509.Certificate{public}},
+	}))
+	if probs["TLSInterception"] || probs["InconsistentTLS"] || !probs["TLS"] {
+		t.Fatalf("expected only debug output for consistent public certificates, got: %v", probs)
+	}
+
+	probs = names(analyzeTLSObservations("example.org", 443, []tlsObservation{
+		{Address: ip, Chain: []*x509.Certificate{public}},
+		{Address: ip, Chain: []*x509.Certificate{intercepted}},
+	}))
+	if !probs["TLSInterception"] || !probs["InconsistentTLS"] {
+		t.Fatalf("expected interception and inconsistency to be reported, got: %v", probs)
+	}
+
+	probs = names(analyzeTLSObservations("example.org", 443, []tlsObservation{
+		{Address: ip, Chain: []*x509.Certificate{public}},
+		{Address: net.ParseIP("192.0.2.2"), Chain: []*x509.Certificate{other}},
+		{Address: net.ParseIP("192.0.2.3"), Error: errors.New("connection refused")},
+	}))
+	if probs["InconsistentTLS"] || probs["TLSInterception"] {
+		t.Fatalf("expected different certificates on different addresses to be accepted, got: %v", probs)
+	}
+}
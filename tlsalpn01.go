@@ -0,0 +1,284 @@
+package letsdebug
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acmeTLSALPNProtocol is the ALPN protocol which the validation server negotiates for tls-alpn-01.
+const acmeTLSALPNProtocol = "acme-tls/1"
+
+// idPeAcmeIdentifier is the OID of the extension of the tls-alpn-01 challenge certificate which holds
+// the digest of the key authorization (RFC 8737).
+var idPeAcmeIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// tlsALPNChecker connects to port 443 of each address of the domain in the way the Let's Encrypt
+// validation server does for tls-alpn-01: always port 443 (regardless of Options.TLSPort), without
+// following redirects, and asking for the acme-tls/1 protocol. Unless an ACME client is waiting for
+// validation, the server isn't expected to agree to it.
+type tlsALPNChecker struct{}
+
+type tlsALPNObservation struct {
+	Address net.IP
+	// Protocol is the ALPN protocol negotiated, if any
+	Protocol string
+	// Rejected is whether the server refused the handshake with a no_application_protocol alert,
+	// meaning that it understands ALPN but doesn't serve acme-tls/1
+	Rejected bool
+	Chain    []*x509.Certificate
+	Error    error
+}
+
+func (o tlsALPNObservation) String() string {
+	switch {
+	case o.Error != nil:
+		return fmt.Sprintf("%s: %v", o.Address, o.Error)
+	case o.Rejected:
+		return fmt.Sprintf("%s: the server refused to negotiate %s", o.Address, acmeTLSALPNProtocol)
+	case o.Protocol == "":
+		return fmt.Sprintf("%s: no ALPN protocol was negotiated", o.Address)
+	}
+	var subject string
+	if len(o.Chain) > 0 {
+		subject = fmt.Sprintf(", certificate for %s", strings.Join(o.Chain[0].DNSNames, ", "))
+	}
+	return fmt.Sprintf("%s: negotiated %s%s", o.Address, o.Protocol, subject)
+}
+
+func (c tlsALPNChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method != TLSALPN01 {
+		return nil, errNotApplicable
+	}
+
+	ips := ctx.LookupAddresses(domain)
+	if len(ips) == 0 {
+		return nil, errNotApplicable
+	}
+
+	var observations []tlsALPNObservation
+	for _, ip := range ips {
+		observations = append(observations, observeTLSALPN(domain, ip))
+	}
+	probs := analyzeTLSALPN(domain, observations)
+
+	// Only the first address is asked, like a browser would
+	if location := probeRootRedirect(ctx, domain, ips[0]); location != "" {
+		if p, ok := analyzeIgnoredRedirect(domain, location, ips, ctx.LookupAddresses); ok {
+			probs = append(probs, p)
+		}
+	}
+	return probs, nil
+}
+
+func observeTLSALPN(domain string, ip net.IP) tlsALPNObservation {
+	obs := tlsALPNObservation{Address: ip}
+
+	dialer := &net.Dialer{Timeout: httpTimeout * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip.String(), "443"), &tls.Config{
+		ServerName:         domain,
+		NextProtos:         []string{acmeTLSALPNProtocol},
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no application protocol") {
+			obs.Rejected = true
+			return obs
+		}
+		obs.Error = err
+		return obs
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	obs.Protocol = state.NegotiatedProtocol
+	obs.Chain = state.PeerCertificates
+	return obs
+}
+
+// acmeTLSCertificateError checks the certificate presented with acme-tls/1 against the requirements
+// of RFC 8737. The digest of the key authorization can't be checked, as it isn't known.
+func acmeTLSCertificateError(domain string, cert *x509.Certificate) error {
+	if len(cert.DNSNames) != 1 || !strings.EqualFold(cert.DNSNames[0], domain) || len(cert.IPAddresses) > 0 ||
+		len(cert.EmailAddresses) > 0 || len(cert.URIs) > 0 {
+		return fmt.Errorf("it must have %s as its only subjectAltName, but has: %s", domain, strings.Join(cert.DNSNames, ", "))
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(idPeAcmeIdentifier) {
+			continue
+		}
+		if !ext.Critical {
+			return errors.New("its acmeIdentifier extension must be marked critical")
+		}
+		var digest []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &digest); err != nil || len(rest) > 0 || len(digest) != 32 {
+			return errors.New("its acmeIdentifier extension must hold the SHA-256 digest of the key authorization")
+		}
+		return nil
+	}
+	return errors.New("it does not have the acmeIdentifier extension")
+}
+
+// analyzeTLSALPN reports the addresses which Let's Encrypt could not connect to, and whether an ACME
+// client appears to be answering tls-alpn-01 challenges on each of them.
+func analyzeTLSALPN(domain string, observations []tlsALPNObservation) []Problem {
+	var probs []Problem
+	var lines, unreachable, negotiated, notNegotiated []string
+
+	for _, obs := range observations {
+		lines = append(lines, obs.String())
+		switch {
+		case obs.Error != nil:
+			unreachable = append(unreachable, obs.String())
+		case obs.Protocol == acmeTLSALPNProtocol:
+			negotiated = append(negotiated, obs.Address.String())
+			if len(obs.Chain) == 0 {
+				continue
+			}
+			if err := acmeTLSCertificateError(domain, obs.Chain[0]); err != nil {
+				probs = append(probs, tlsALPNBadCertificate(domain, obs.Address, err))
+			}
+		default:
+			notNegotiated = append(notNegotiated, obs.Address.String())
+		}
+	}
+
+	if len(unreachable) > 0 {
+		probs = append(probs, tlsALPNNotWorking(domain, unreachable))
+	}
+	if len(notNegotiated) > 0 {
+		probs = append(probs, tlsALPNNotNegotiated(domain, notNegotiated, negotiated))
+	}
+	probs = append(probs, debugProblem("TLSALPNCheck", "Connections made to port 443 of each address of the domain, "+
+		"asking for the acme-tls/1 protocol", strings.Join(lines, "\n")))
+	return probs
+}
+
+// probeRootRedirect returns where the server at ip redirects a request for the root of domain on
+// port 80, if it does.
+func probeRootRedirect(ctx *scanContext, domain string, ip net.IP) string {
+	if ctx.httpOptOutURL() != "" {
+		return ""
+	}
+
+	dialer := &net.Dialer{Timeout: httpTimeout * time.Second}
+	transport := makeSingleShotHTTPTransport()
+	transport.DialContext = func(c context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(c, "tcp", net.JoinHostPort(ip.String(), "80"))
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   httpTimeout * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+domain+"/", nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-Agent", ctx.probeUserAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if !isFollowedRedirect(resp.StatusCode) {
+		return ""
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return ""
+	}
+	return location.String()
+}
+
+// analyzeIgnoredRedirect warns when the website of the domain redirects to a host on other servers,
+// which suggests that its addresses belong to a forwarding service or a different server than the one
+// the ACME client runs on. tls-alpn-01 validation doesn't follow redirects.
+func analyzeIgnoredRedirect(domain, location string, ips []net.IP, lookup func(string) []net.IP) (Problem, bool) {
+	target, err := url.Parse(location)
+	if err != nil || target.Hostname() == "" || normalizeFqdn(target.Hostname()) == domain {
+		return Problem{}, false
+	}
+	host := target.Hostname()
+
+	own := map[string]bool{}
+	for _, ip := range ips {
+		own[ip.String()] = true
+	}
+	for _, ip := range lookup(host) {
+		if own[ip.String()] {
+			return Problem{}, false
+		}
+	}
+	return tlsALPNRedirectIgnored(domain, host, location), true
+}
+
+func tlsALPNNotWorking(domain string, unreachable []string) Problem {
+	return Problem{
+		Name: "TLSALPNNotWorking",
+		Explanation: fmt.Sprintf(`A TLS connection to port 443 of one or more of the addresses of %s could not be made. `+
+			`Let's Encrypt makes tls-alpn-01 validation connections to port 443 of any of the addresses in the A and AAAA records `+
+			`of the domain (it can't use another port, and doesn't follow redirects), so each of them must be reachable from the `+
+			`internet on port 443. Check the firewall and the port forwarding of the server, and that the records are correct.`, domain),
+		Detail:   strings.Join(unreachable, "\n"),
+		Severity: SeverityError,
+	}
+}
+
+func tlsALPNNotNegotiated(domain string, notNegotiated, negotiated []string) Problem {
+	if len(negotiated) > 0 {
+		return Problem{
+			Name: "TLSALPNNotNegotiated",
+			Explanation: fmt.Sprintf(`An ACME client appears to be answering tls-alpn-01 challenges for %s on some of its `+
+				`addresses (%s), but not on others. Let's Encrypt may connect to any of them, and validation will fail when it picks `+
+				`one where the acme-tls/1 protocol isn't served. Make sure every address leads to the server the ACME client runs on.`,
+				domain, strings.Join(negotiated, ", ")),
+			Detail:   strings.Join(notNegotiated, "\n"),
+			Severity: SeverityError,
+		}
+	}
+	return Problem{
+		Name: "TLSALPNNotNegotiated",
+		Explanation: fmt.Sprintf(`The server for %s did not negotiate the acme-tls/1 protocol, so no ACME client is currently `+
+			`answering tls-alpn-01 challenges. This is expected unless your ACME client is waiting for validation at the time of `+
+			`the test. If it is, the server, or a proxy, load balancer or CDN which terminates TLS in front of it, is not passing `+
+			`acme-tls/1 connections through to the ACME client, and validation will fail.`, domain),
+		Detail:   strings.Join(notNegotiated, "\n"),
+		Severity: SeverityInfo,
+	}
+}
+
+func tlsALPNBadCertificate(domain string, address net.IP, err error) Problem {
+	return Problem{
+		Name: "TLSALPNBadCertificate",
+		Explanation: fmt.Sprintf(`The server at %s negotiated the acme-tls/1 protocol for %s, so an ACME client appears to be `+
+			`answering tls-alpn-01 challenges, but the certificate it presented would be rejected by Let's Encrypt. `+
+			`It is likely that another piece of software is answering, or that the ACME client is misconfigured.`, address, domain),
+		Detail:   fmt.Sprintf("The certificate is not a valid tls-alpn-01 challenge certificate: %v", err),
+		Severity: SeverityError,
+	}
+}
+
+func tlsALPNRedirectIgnored(domain, host, location string) Problem {
+	return Problem{
+		Name: "TLSALPNRedirectIgnored",
+		Explanation: fmt.Sprintf(`The website of %s redirects to %s, which is hosted elsewhere. Unlike http-01, tls-alpn-01 `+
+			`validation doesn't follow redirects: Let's Encrypt only ever connects to port 443 of the addresses of %s itself. `+
+			`If those addresses belong to a forwarding service, or to another server than the one your ACME client runs on, `+
+			`tls-alpn-01 validation will fail. Point the A and AAAA records of %s at the server of the ACME client, `+
+			`or use a different validation method.`, domain, host, domain, domain),
+		Detail:   fmt.Sprintf("http://%s/ redirects to %s", domain, location),
+		Severity: SeverityWarning,
+	}
+}
@@ -0,0 +1,114 @@
+package letsdebug
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeTLSALPN(t *testing.T) {
+	challengeCert := func(names []string, extensions []pkix.Extension) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:    big.NewInt(1),
+			NotBefore:       time.Now().Add(-time.Hour),
+			NotAfter:        time.Now().Add(time.Hour),
+			DNSNames:        names,
+			ExtraExtensions: extensions,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	digest := sha256.Sum256([]byte("token.thumbprint"))
+	value, err := asn1.Marshal(digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := challengeCert([]string{"example.org"}, []pkix.Extension{{Id: idPeAcmeIdentifier, Critical: true, Value: value}})
+	noExtension := challengeCert([]string{"example.org"}, nil)
+	wrongName := challengeCert([]string{"example.org", "www.example.org"}, []pkix.Extension{{Id: idPeAcmeIdentifier, Critical: true, Value: value}})
+
+	a, b := net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")
+	names := func(probs []Problem) map[string]SeverityLevel {
+		found := map[string]SeverityLevel{}
+		for _, p := range probs {
+			found[p.Name] = p.Severity
+		}
+		return found
+	}
+
+	found := names(analyzeTLSALPN("example.org", []tlsALPNObservation{
+		{Address: a, Protocol: acmeTLSALPNProtocol, Chain: []*x509.Certificate{valid}},
+		{Address: b, Protocol: acmeTLSALPNProtocol, Chain: []*x509.Certificate{valid}},
+	}))
+	if len(found) != 1 || found["TLSALPNCheck"] != SeverityDebug {
+		t.Errorf("expected only the debug problem when a valid challenge certificate is served, got: %v", found)
+	}
+
+	found = names(analyzeTLSALPN("example.org", []tlsALPNObservation{{Address: a, Rejected: true}, {Address: b}}))
+	if found["TLSALPNNotNegotiated"] != SeverityInfo || len(found) != 2 {
+		t.Errorf("expected an informational problem when no ACME client is answering, got: %v", found)
+	}
+
+	found = names(analyzeTLSALPN("example.org", []tlsALPNObservation{
+		{Address: a, Protocol: acmeTLSALPNProtocol, Chain: []*x509.Certificate{valid}},
+		{Address: b, Error: errors.New("connection refused")},
+	}))
+	if found["TLSALPNNotWorking"] != SeverityError || len(found) != 2 {
+		t.Errorf("expected an error for the unreachable address, got: %v", found)
+	}
+
+	found = names(analyzeTLSALPN("example.org", []tlsALPNObservation{
+		{Address: a, Protocol: acmeTLSALPNProtocol, Chain: []*x509.Certificate{valid}},
+		{Address: b, Protocol: "h2"},
+	}))
+	if found["TLSALPNNotNegotiated"] != SeverityError {
+		t.Errorf("expected an error when only some addresses answer the challenge, got: %v", found)
+	}
+
+	for _, cert := range []*x509.Certificate{noExtension, wrongName} {
+		found = names(analyzeTLSALPN("example.org", []tlsALPNObservation{{Address: a, Protocol: acmeTLSALPNProtocol, Chain: []*x509.Certificate{cert}}}))
+		if found["TLSALPNBadCertificate"] != SeverityError {
+			t.Errorf("expected the certificate for %v to be rejected, got: %v", cert.DNSNames, found)
+		}
+	}
+}
+
+func TestAnalyzeIgnoredRedirect(t *testing.T) {
+	ips := []net.IP{net.ParseIP("192.0.2.1")}
+	lookup := func(name string) []net.IP {
+		if name == "www.example.org" {
+			return ips
+		}
+		return []net.IP{net.ParseIP("198.51.100.1")}
+	}
+
+	if _, ok := analyzeIgnoredRedirect("example.org", "https://example.org/", ips, lookup); ok {
+		t.Error("a redirect to the same domain should not be reported")
+	}
+	if _, ok := analyzeIgnoredRedirect("example.org", "https://www.example.org/", ips, lookup); ok {
+		t.Error("a redirect to a host on the same server should not be reported")
+	}
+	if p, ok := analyzeIgnoredRedirect("example.org", "https://shop.example.net/", ips, lookup); !ok || p.Name != "TLSALPNRedirectIgnored" {
+		t.Errorf("expected a redirect to another server to be reported, got: %v", p)
+	}
+}
@@ -0,0 +1,52 @@
+package letsdebug
+
+import (
+	"time"
+)
+
+// Logger receives the progress of a single test: each checker as it starts and completes, and
+// anything unusual which happened along the way. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Tracer receives a span for each checker of a single test, e.g. to record them alongside the
+// caller's own traces. It may be called from several goroutines at once. requestID is the
+// Options.RequestID of the test.
+type Tracer interface {
+	// CheckerStarted is called as each checker starts
+	CheckerStarted(requestID, checker string)
+	// CheckerFinished is called as each checker completes, with the number of problems it found,
+	// and the error it failed with, if any. Checkers which don't apply to the test finish without
+	// an error.
+	CheckerFinished(requestID, checker string, d time.Duration, problems int, err error)
+}
+
+// logf writes a line about the progress of the test to Options.Logger, or to stderr if
+// LETSDEBUG_DEBUG is set. Lines are prefixed with Options.RequestID, if set.
+func (sc *scanContext) logf(format string, args ...interface{}) {
+	if sc == nil {
+		debug(format, args...)
+		return
+	}
+	if sc.requestID != "" {
+		format = "[" + sc.requestID + "] " + format
+	}
+	if sc.logger != nil {
+		sc.logger.Printf(format, args...)
+		return
+	}
+	debug(format, args...)
+}
+
+func (sc *scanContext) checkerStarted(checker string) {
+	if sc != nil && sc.tracer != nil {
+		sc.tracer.CheckerStarted(sc.requestID, checker)
+	}
+}
+
+func (sc *scanContext) checkerFinished(checker string, d time.Duration, problems int, err error) {
+	if sc != nil && sc.tracer != nil {
+		sc.tracer.CheckerFinished(sc.requestID, checker, d, problems, err)
+	}
+}
@@ -0,0 +1,68 @@
+package letsdebug
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	mu       sync.Mutex
+	started  []string
+	finished map[string]error
+}
+
+func (r *recordingTracer) CheckerStarted(requestID, checker string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = append(r.started, requestID+"/"+checker)
+}
+
+func (r *recordingTracer) CheckerFinished(requestID, checker string, d time.Duration, problems int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished[requestID+"/"+checker] = err
+}
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{finished: map[string]error{}}
+	logger := &recordingLogger{}
+	ctx := newScanContextWithOptions(Options{RequestID: "req-1", Tracer: tracer, Logger: logger})
+
+	block := asyncCheckerBlock{failingChecker{}, checkerSkipped{}}
+	if _, err := block.Check(ctx, "example.org", HTTP01); err == nil {
+		t.Fatal("expected the checker to fail")
+	}
+
+	if len(tracer.started) != 2 {
+		t.Errorf("expected both checkers to start, got: %v", tracer.started)
+	}
+	if err, ok := tracer.finished["req-1/letsdebug.failingChecker"]; !ok || err == nil {
+		t.Errorf("expected the failing checker to finish with its error, got: %v", tracer.finished)
+	}
+	if err, ok := tracer.finished["req-1/letsdebug.checkerSkipped"]; !ok || err != nil {
+		t.Errorf("expected the skipped checker to finish without an error, got: %v", tracer.finished)
+	}
+
+	if len(logger.lines) == 0 {
+		t.Fatal("expected the progress of the test to be logged")
+	}
+	for _, line := range logger.lines {
+		if !strings.HasPrefix(line, "[req-1] ") {
+			t.Errorf("expected every line to carry the request ID, got: %q", line)
+		}
+	}
+}
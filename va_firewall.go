@@ -0,0 +1,220 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// port80Timeout bounds each connection attempt of dialPort80. Let's Encrypt gives up on connecting after
+// a similar time.
+const port80Timeout = 10 * time.Second
+
+// How a connection to port 80 went, as classified by classifyConnection and classifyBoulderConnection.
+const (
+	connConnected   = "connected"
+	connRefused     = "refused"
+	connReset       = "reset"
+	connTimeout     = "timeout"
+	connUnreachable = "unreachable"
+)
+
+// vaFirewallChecker connects to port 80 of each address of the domain, and records how each connection
+// went, so that analyzeVAFirewall can compare it with how the connections of the Let's Encrypt staging
+// service and of the probe agents went. A server which accepts connections from here, but refuses or
+// drops them from elsewhere, is behind a firewall which filters by source address.
+type vaFirewallChecker struct{}
+
+func (c vaFirewallChecker) Check(ctx *scanContext, domain string, method ValidationMethod) ([]Problem, error) {
+	if method != HTTP01 || ctx.httpOptOutURL() != "" {
+		return nil, errNotApplicable
+	}
+
+	ips := ctx.LookupAddresses(domain)
+	if len(ips) == 0 {
+		return nil, errNotApplicable
+	}
+
+	connections := dialPort80(ips)
+	ctx.evidenceMu.Lock()
+	ctx.port80Connections = connections
+	ctx.evidenceMu.Unlock()
+	return nil, nil
+}
+
+// dialPort80 connects to port 80 of each address, and returns how each connection went, by address.
+func dialPort80(ips []net.IP) map[string]string {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	connections := map[string]string{}
+	for _, ip := range ips {
+		wg.Add(1)
+		go func(ip net.IP) {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), "80"), port80Timeout)
+			if err == nil {
+				conn.Close()
+			}
+			mu.Lock()
+			connections[ip.String()] = classifyConnection(err)
+			mu.Unlock()
+		}(ip)
+	}
+	wg.Wait()
+	return connections
+}
+
+// classifyConnection describes the outcome of a connection attempt, or returns "" if it failed for
+// a reason which says nothing about the network path.
+func classifyConnection(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return connConnected
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return connRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return connReset
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return connUnreachable
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return connTimeout
+	}
+	return ""
+}
+
+// classifyBoulderConnection classifies the connection problem reported by Boulder for an http-01
+// challenge, or returns "" if it was not a failure to connect.
+func classifyBoulderConnection(chal *stagingChallenge) string {
+	if chal == nil || chal.Error == nil || strings.TrimPrefix(chal.Error.Type, "urn:ietf:params:acme:error:") != "connection" {
+		return ""
+	}
+	detail := strings.ToLower(chal.Error.Detail)
+	switch {
+	// As opposed to "Timeout after connect", which means the server was reached but was too slow
+	case strings.Contains(detail, "timeout during connect"):
+		return connTimeout
+	case strings.Contains(detail, "connection refused"):
+		return connRefused
+	case strings.Contains(detail, "connection reset"):
+		return connReset
+	case strings.Contains(detail, "no route to host"), strings.Contains(detail, "network is unreachable"):
+		return connUnreachable
+	}
+	return ""
+}
+
+// analyzeVAFirewall is run once every checker has completed. It reports when port 80 accepted the
+// connections made from here, but the connections of the Let's Encrypt staging service, or of probe
+// agents to the same addresses, were refused or dropped.
+func analyzeVAFirewall(ctx *scanContext, domain string, method ValidationMethod) []Problem {
+	if method != HTTP01 {
+		return nil
+	}
+
+	ctx.evidenceMu.Lock()
+	local := ctx.port80Connections
+	chal := ctx.stagingChallenge
+	outcomes := ctx.perspectiveOutcomes
+	ctx.evidenceMu.Unlock()
+
+	return analyzeSourceFiltering(domain, local, chal, outcomes)
+}
+
+// analyzeSourceFiltering compares how the connections to port 80 made from here went with those of the
+// staging service and of the probe agents.
+func analyzeSourceFiltering(domain string, local map[string]string, chal *stagingChallenge, outcomes []perspectiveOutcome) []Problem {
+	var localLines []string
+	for addr, conn := range local {
+		localLines = append(localLines, fmt.Sprintf("Let's Debug: %s: %s", addr, conn))
+	}
+	sort.Strings(localLines)
+	connected := false
+	for _, conn := range local {
+		connected = connected || conn == connConnected
+	}
+	if !connected {
+		return nil
+	}
+
+	var blocked []string
+	kinds := map[string]bool{}
+	stagingBlocked := false
+	if conn := classifyBoulderConnection(chal); conn != "" {
+		stagingBlocked = true
+		kinds[conn] = true
+		blocked = append(blocked, fmt.Sprintf("Let's Encrypt staging: %s", chal.summary()))
+	}
+	agents := 0
+	for _, o := range outcomes {
+		if o.Error != nil || o.Report.HTTP == nil {
+			continue
+		}
+		var lines []string
+		for addr, conn := range o.Report.HTTP.Connections {
+			// Only addresses which accepted our connection, so that an address which is down isn't blamed on a firewall
+			if local[addr] != connConnected || conn == connConnected || conn == "" {
+				continue
+			}
+			kinds[conn] = true
+			lines = append(lines, fmt.Sprintf("%s (%s): %s: %s", o.Perspective.Name, strings.ToUpper(o.Perspective.Country), addr, conn))
+		}
+		if len(lines) > 0 {
+			agents++
+			sort.Strings(lines)
+			blocked = append(blocked, lines...)
+		}
+	}
+	if len(blocked) == 0 {
+		return nil
+	}
+
+	var sources []string
+	if stagingBlocked {
+		sources = append(sources, "the Let's Encrypt staging service")
+	}
+	switch {
+	case agents == 1:
+		sources = append(sources, "1 of the probe agents")
+	case agents > 1:
+		sources = append(sources, fmt.Sprintf("%d of the probe agents", agents))
+	}
+
+	severity := perspectiveSeverity(agents)
+	if stagingBlocked {
+		severity = SeverityError
+	}
+	return []Problem{sourceAddressFiltered(domain, strings.Join(sources, " and "), kinds,
+		append(localLines, blocked...), severity)}
+}
+
+func sourceAddressFiltered(domain, sources string, kinds map[string]bool, evidence []string, severity SeverityLevel) Problem {
+	var how []string
+	if kinds[connRefused] || kinds[connReset] {
+		how = append(how, "actively refused (with a TCP reset)")
+	}
+	if kinds[connTimeout] {
+		how = append(how, "silently dropped")
+	}
+	if kinds[connUnreachable] {
+		how = append(how, "rejected as unreachable")
+	}
+	return Problem{
+		Name: "SourceAddressFiltered",
+		Explanation: fmt.Sprintf(`Port 80 of %s accepted connections from Let's Debug, but the connections of %s were %s. `+
+			`A server which is up, but refuses or drops connections only from some networks, is behind a firewall which `+
+			`filters them by their source address: for example by blocking countries (GeoIP), the networks of cloud `+
+			`providers, or addresses on a reputation list. This is why the site can work in your browser while validation `+
+			`requests fail. Let's Encrypt validates from cloud networks in several countries, and doesn't publish its `+
+			`addresses, which change. Allow connections to port 80 from any address in the firewall of the server, of its `+
+			`hosting provider, and of any CDN or security service in front of it, or use the dns-01 validation method instead.`,
+			domain, sources, strings.Join(how, " or ")),
+		Detail:   strings.Join(evidence, "\n"),
+		Severity: severity,
+	}
+}
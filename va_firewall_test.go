@@ -0,0 +1,80 @@
+package letsdebug
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/eggsampler/acme/v3"
+)
+
+func TestClassifyConnection(t *testing.T) {
+	// A port which nothing listens on any more
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	conn, err := net.Dial("tcp", addr)
+	if classifyConnection(err) != connConnected {
+		t.Fatalf("expected the connection to succeed, got %v", err)
+	}
+	conn.Close()
+	l.Close()
+	if _, err := net.Dial("tcp", addr); classifyConnection(err) != connRefused {
+		t.Errorf("expected the connection to be refused, got %v", err)
+	}
+
+	for detail, want := range map[string]string{
+		"192.0.2.1: Fetching http://example.org/.well-known/acme-challenge/x: Timeout during connect (likely firewall problem)": connTimeout,
+		"192.0.2.1: Fetching http://example.org/.well-known/acme-challenge/x: Connection refused":                               connRefused,
+		"192.0.2.1: Fetching http://example.org/.well-known/acme-challenge/x: Connection reset by peer":                         connReset,
+		"192.0.2.1: Fetching http://example.org/.well-known/acme-challenge/x: Timeout after connect (your server may be slow)":  "",
+	} {
+		chal := &stagingChallenge{Error: &acme.Problem{Type: "urn:ietf:params:acme:error:connection", Detail: detail}}
+		if got := classifyBoulderConnection(chal); got != want {
+			t.Errorf("%s: expected %q, got %q", detail, want, got)
+		}
+	}
+}
+
+func TestAnalyzeSourceFiltering(t *testing.T) {
+	local := map[string]string{"192.0.2.1": connConnected, "192.0.2.2": connTimeout}
+	agent := func(name string, connections map[string]string) perspectiveOutcome {
+		return perspectiveOutcome{Perspective: Perspective{Name: name, Country: "de"},
+			Report: PerspectiveReport{HTTP: &PerspectiveHTTP{Connections: connections}}}
+	}
+	blocked := &stagingChallenge{Type: "http-01", Status: "invalid", Error: &acme.Problem{Type: "urn:ietf:params:acme:error:connection",
+		Detail: "192.0.2.1: Fetching http://example.org/.well-known/acme-challenge/x: Connection reset by peer"}}
+
+	probs := analyzeSourceFiltering("example.org", local, blocked, []perspectiveOutcome{
+		agent("eu", map[string]string{"192.0.2.1": connConnected}),
+		agent("ap", map[string]string{"192.0.2.1": connTimeout}),
+	})
+	if len(probs) != 1 || probs[0].Name != "SourceAddressFiltered" || probs[0].Severity != SeverityError {
+		t.Fatalf("expected the filtering to be reported as an error, got: %v", probs)
+	}
+	for _, want := range []string{"the Let's Encrypt staging service and 1 of the probe agents", "refused (with a TCP reset) or silently dropped"} {
+		if !strings.Contains(probs[0].Explanation, want) {
+			t.Errorf("expected %q in: %s", want, probs[0].Explanation)
+		}
+	}
+	if !strings.Contains(probs[0].Detail, "ap (DE): 192.0.2.1: timeout") || strings.Contains(probs[0].Detail, "eu (DE)") {
+		t.Errorf("unexpected detail: %s", probs[0].Detail)
+	}
+
+	// An address which doesn't accept our connections either is down, not filtering
+	if probs := analyzeSourceFiltering("example.org", local, nil, []perspectiveOutcome{
+		agent("ap", map[string]string{"192.0.2.2": connTimeout}),
+	}); len(probs) != 0 {
+		t.Errorf("expected nothing to be reported, got: %v", probs)
+	}
+	if probs := analyzeSourceFiltering("example.org", map[string]string{"192.0.2.1": connRefused}, blocked, nil); len(probs) != 0 {
+		t.Errorf("expected nothing to be reported when the server refuses everyone, got: %v", probs)
+	}
+
+	probs = analyzeSourceFiltering("example.org", local, nil, []perspectiveOutcome{agent("ap", map[string]string{"192.0.2.1": connRefused})})
+	if len(probs) != 1 || probs[0].Severity != SeverityWarning {
+		t.Errorf("expected a single probe agent to be a warning, got: %v", probs)
+	}
+}
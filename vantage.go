@@ -0,0 +1,92 @@
+package letsdebug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VantageResult is the outcome of the http-01 validation request for the domain, made from another
+// network location than the one Let's Debug runs in. Let's Debug doesn't make such requests itself:
+// they are supplied by embedders which operate their own probes, through Options.Vantages.
+type VantageResult struct {
+	// Name identifies the vantage point, e.g. "aws-us-east-1"
+	Name string `json:"name"`
+	// Country is the ISO 3166-1 alpha-2 code of the country the request was made from, e.g. "US"
+	Country string `json:"country"`
+	// Reachable is whether the server responded to the request, with any HTTP status
+	Reachable bool `json:"reachable"`
+	// Detail describes the outcome, e.g. the response status or the error
+	Detail string `json:"detail,omitempty"`
+}
+
+func (v VantageResult) String() string {
+	outcome := "reachable"
+	if !v.Reachable {
+		outcome = "unreachable"
+	}
+	if v.Detail != "" {
+		outcome += ": " + v.Detail
+	}
+	return fmt.Sprintf("%s (%s): %s", v.Name, strings.ToUpper(v.Country), outcome)
+}
+
+// northAmerica are the countries of the primary validation location of Let's Encrypt, and of several
+// of its remote perspectives. A domain which can't be reached from any of them can't be validated.
+var northAmerica = map[string]bool{"US": true, "CA": true}
+
+// analyzeVantages is run once every checker has completed, when the results of requests made from other
+// network locations were supplied. It reports a domain which is reachable from elsewhere, but not from
+// North America, which is usually the result of a deliberate legal or geographic block.
+func analyzeVantages(ctx *scanContext, domain string, method ValidationMethod, probs []Problem) []Problem {
+	if method != HTTP01 || len(ctx.vantages) == 0 {
+		return nil
+	}
+
+	ctx.evidenceMu.Lock()
+	httpResults := ctx.httpResults
+	ctx.evidenceMu.Unlock()
+
+	reachableElsewhere := false
+	for _, res := range httpResults {
+		if !res.IsZero() {
+			reachableElsewhere = true
+		}
+	}
+	for _, p := range probs {
+		if localHTTPFailureProblems[p.Name] {
+			reachableElsewhere = false
+		}
+	}
+
+	var northAmerican, lines []string
+	for _, v := range ctx.vantages {
+		lines = append(lines, v.String())
+		if !northAmerica[strings.ToUpper(v.Country)] {
+			reachableElsewhere = reachableElsewhere || v.Reachable
+			continue
+		}
+		if v.Reachable {
+			return nil
+		}
+		northAmerican = append(northAmerican, v.Name)
+	}
+	if len(northAmerican) == 0 || !reachableElsewhere {
+		return nil
+	}
+	sort.Strings(lines)
+	return []Problem{unreachableFromNorthAmerica(domain, lines)}
+}
+
+func unreachableFromNorthAmerica(domain string, lines []string) Problem {
+	return Problem{
+		Name: "UnreachableFromNorthAmerica",
+		Explanation: fmt.Sprintf(`%s could be reached from some network locations, but not from any in North America. Let's `+
+			`Encrypt makes its primary validation request from the United States, and validation will fail for as long as `+
+			`the domain is inaccessible from there. This is usually a deliberate block, such as a website which refuses `+
+			`visitors from the United States for legal reasons, or a firewall or CDN rule which blocks countries. Allow `+
+			`requests to /.well-known/acme-challenge/ from every country, or use the dns-01 validation method instead.`, domain),
+		Detail:   strings.Join(lines, "\n"),
+		Severity: SeverityError,
+	}
+}
@@ -0,0 +1,49 @@
+package letsdebug
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAnalyzeVantages(t *testing.T) {
+	reachable := []httpCheckResult{{IP: net.ParseIP("192.0.2.1"), StatusCode: 404, InitialStatusCode: 404}}
+	usBlocked := []VantageResult{
+		{Name: "us-east", Country: "US", Detail: "Timeout during connect"},
+		{Name: "ca-central", Country: "ca", Detail: "Connection refused"},
+		{Name: "eu-central", Country: "DE", Reachable: true, Detail: "HTTP 404"},
+	}
+
+	tests := []struct {
+		name        string
+		method      ValidationMethod
+		vantages    []VantageResult
+		httpResults []httpCheckResult
+		probs       []Problem
+		want        bool
+	}{
+		{name: "no vantages", method: HTTP01, httpResults: reachable},
+		{name: "blocked from North America", method: HTTP01, vantages: usBlocked, httpResults: reachable, want: true},
+		{name: "blocked from North America, reachable elsewhere", method: HTTP01, vantages: usBlocked, want: true},
+		{name: "reachable from one North American vantage", method: HTTP01, httpResults: reachable,
+			vantages: append([]VantageResult{{Name: "us-west", Country: "US", Reachable: true}}, usBlocked...)},
+		{name: "unreachable from everywhere", method: HTTP01, vantages: usBlocked[:2], probs: []Problem{{Name: "ANotWorking"}}},
+		{name: "no North American vantages", method: HTTP01, vantages: usBlocked[2:], httpResults: reachable},
+		{name: "dns-01", method: DNS01, vantages: usBlocked, httpResults: reachable},
+	}
+	for _, tt := range tests {
+		ctx := newScanContextWithOptions(Options{Vantages: tt.vantages})
+		ctx.recordHTTPResults(tt.httpResults)
+		probs := analyzeVantages(ctx, "example.org", tt.method, tt.probs)
+		if got := len(probs) == 1 && probs[0].Name == "UnreachableFromNorthAmerica"; got != tt.want || (!tt.want && len(probs) > 0) {
+			t.Errorf("%s: expected a problem: %t, got: %v", tt.name, tt.want, probs)
+		}
+	}
+
+	ctx := newScanContextWithOptions(Options{Vantages: usBlocked})
+	ctx.recordHTTPResults(reachable)
+	probs := analyzeVantages(ctx, "example.org", HTTP01, nil)
+	want := "ca-central (CA): unreachable: Connection refused\neu-central (DE): reachable: HTTP 404\nus-east (US): unreachable: Timeout during connect"
+	if len(probs) != 1 || probs[0].Detail != want {
+		t.Fatalf("expected the outcome of every vantage in the detail, got: %v", probs)
+	}
+}
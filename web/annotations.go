@@ -0,0 +1,160 @@
+package web
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+)
+
+// maxAnnotationLength limits the length of an annotation, in characters.
+const maxAnnotationLength = 2000
+
+// annotation is a comment attached to a test by a helper, such as a community forum moderator,
+// e.g. to point out which of the problems matters when the test is shared on the forum.
+type annotation struct {
+	ID        int64     `db:"id" json:"id"`
+	TestID    uint64    `db:"test_id" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	Helper    string    `db:"helper" json:"helper"`
+	Body      string    `db:"body" json:"body"`
+}
+
+func (a annotation) CreatedTimestamp() string {
+	return a.CreatedAt.Format(time.RFC3339Nano)
+}
+
+func (a annotation) BodyLines() []string {
+	return strings.Split(a.Body, "\n")
+}
+
+// helperFromToken returns the name of the helper with the token, from LETSDEBUG_WEB_HELPER_TOKENS,
+// which holds entries like "name:token" separated by commas. If there are none, annotations are disabled.
+func helperFromToken(token string) (string, bool) {
+	var helper string
+	for _, entry := range strings.Split(envOrDefault("HELPER_TOKENS", ""), ",") {
+		name, t, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || name == "" || t == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			helper = name
+		}
+	}
+	return helper, helper != ""
+}
+
+func (s *server) findAnnotations(testID uint64) ([]annotation, error) {
+	var annotations []annotation
+	if err := s.db.Select(&annotations, `SELECT * FROM annotations WHERE test_id = $1 ORDER BY created_at, id;`, testID); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// httpAnnotateTest attaches an annotation to a test, on behalf of the helper whose token is presented
+// as a bearer token, or in the token field of the form on the result page.
+func (s *server) httpAnnotateTest(w http.ResponseWriter, r *http.Request) {
+	domain := chi.URLParam(r, "domain")
+	testID, err := strconv.Atoi(chi.URLParam(r, "testID"))
+
+	isBrowser := r.Header.Get("content-type") != "application/json"
+	var token, body string
+	if isBrowser {
+		if !s.checkCSRF(r) {
+			http.Error(w, "Your session has expired, please submit the form again.", http.StatusForbidden)
+			return
+		}
+		token, body = r.PostFormValue("token"), r.PostFormValue("body")
+	} else {
+		token, _ = strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+		var req struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			http.Error(w, "Request body was not valid JSON", http.StatusBadRequest)
+			return
+		}
+		body = req.Body
+	}
+
+	body = strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+	if domain == "" || err != nil || body == "" || utf8.RuneCountInString(body) > maxAnnotationLength {
+		http.Error(w, fmt.Sprintf("Annotations must be between 1 and %d characters long.", maxAnnotationLength), http.StatusBadRequest)
+		return
+	}
+	helper, ok := helperFromToken(token)
+	if !ok {
+		http.Error(w, "The helper token is not valid.", http.StatusUnauthorized)
+		return
+	}
+
+	test, err := s.findViewableTest(r, domain, testID)
+	if err != nil {
+		log.Printf("fetching %s/%d: %v", domain, testID, err)
+		http.Error(w, "An internal error occurred fetching that test.", http.StatusInternalServerError)
+		return
+	}
+	if test == nil {
+		http.Error(w, "No such test exists. Old tests are deleted after 7 days.", http.StatusNotFound)
+		return
+	}
+
+	var a annotation
+	if err := s.db.Get(&a, `INSERT INTO annotations (test_id, helper, body) VALUES ($1, $2, $3) RETURNING *;`,
+		test.ID, helper, body); err != nil {
+		log.Printf("Failed to annotate test %d: %v", test.ID, err)
+		http.Error(w, "An internal error occurred annotating the test.", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, auditAnnotate, test.Domain, test.ID, auditDetails{"annotation_id": a.ID, "helper": helper})
+
+	if isBrowser {
+		http.Redirect(w, r, fmt.Sprintf("/%s/%d#annotations", test.Domain, test.ID), http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		log.Printf("Error encoding annotation response: %v", err)
+	}
+}
+
+// httpDeleteAnnotation removes an annotation, which only the helper who wrote it may do.
+func (s *server) httpDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	domain := chi.URLParam(r, "domain")
+	testID, err1 := strconv.ParseUint(chi.URLParam(r, "testID"), 10, 64)
+	id, err2 := strconv.ParseInt(chi.URLParam(r, "annotationID"), 10, 64)
+	if domain == "" || err1 != nil || err2 != nil {
+		http.Error(w, "Invalid request parameters.", http.StatusBadRequest)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+	helper, ok := helperFromToken(token)
+	if !ok {
+		http.Error(w, "The helper token is not valid.", http.StatusUnauthorized)
+		return
+	}
+
+	var deleted int64
+	err := s.db.QueryRow(`DELETE FROM annotations WHERE id = $1 AND test_id = $2 AND helper = $3 `+
+		`AND test_id IN (SELECT id FROM tests WHERE domain = $4) RETURNING id;`, id, testID, helper, domain).Scan(&deleted)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "No such annotation was written by you.", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Failed to delete annotation %d: %v", id, err)
+		http.Error(w, "An internal error occurred deleting the annotation.", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, auditDeleteAnnotation, domain, testID, auditDetails{"annotation_id": id, "helper": helper})
+	w.WriteHeader(http.StatusNoContent)
+}
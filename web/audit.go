@@ -0,0 +1,186 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// Actions recorded in the audit log
+const (
+	auditSubmit            = "submit"
+	auditCancel            = "cancel"
+	auditInvalidateVerdict = "invalidate_verdict"
+	auditSubmitBatch       = "submit_batch"
+	auditClaim             = "claim"
+	auditVerifyClaim       = "verify_claim"
+	auditUpdateClaim       = "update_claim"
+	auditReleaseClaim      = "release_claim"
+	auditDeleteTests       = "delete_tests"
+	auditAnnotate          = "annotate"
+	auditDeleteAnnotation  = "delete_annotation"
+	auditBan               = "ban"
+	auditLiftBan           = "lift_ban"
+)
+
+type auditDetails map[string]interface{}
+
+func (d auditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+func (d *auditDetails) Scan(src interface{}) error {
+	buf, ok := jsonBytes(src)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(buf, d)
+}
+
+type auditEntry struct {
+	ID        int64        `db:"id" json:"id"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+	Action    string       `db:"action" json:"action"`
+	ActorIP   string       `db:"actor_ip" json:"actor_ip"`
+	APIKey    *string      `db:"api_key" json:"api_key,omitempty"`
+	Domain    *string      `db:"domain" json:"domain,omitempty"`
+	TestID    *int64       `db:"test_id" json:"test_id,omitempty"`
+	Details   auditDetails `db:"details" json:"details,omitempty"`
+}
+
+// audit appends an entry to the audit log. Failures are logged, rather than failing the action.
+func (s *server) audit(r *http.Request, action, domain string, testID uint64, details auditDetails) {
+	nullable := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	var id *uint64
+	if testID != 0 {
+		id = &testID
+	}
+	if _, err := s.db.Exec(`INSERT INTO audit_log (action, actor_ip, api_key, domain, test_id, details) VALUES ($1, $2, $3, $4, $5, $6);`,
+		action, remoteIP(r), nullable(apiKeyFingerprint(r)), nullable(domain), id, details); err != nil {
+		log.Printf("Failed to record %s of %s in the audit log: %v", action, domain, err)
+	}
+}
+
+// apiKeyFingerprint identifies the bearer token presented with the request, if any, without
+// storing the token itself.
+func apiKeyFingerprint(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return tokenFingerprint(token)
+}
+
+// tokenFingerprint identifies a secret token without revealing it.
+func tokenFingerprint(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	return ip
+}
+
+// requireAdmin only lets through requests bearing LETSDEBUG_WEB_ADMIN_TOKEN. If it is not set,
+// the admin endpoints don't exist.
+func requireAdmin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if envOrDefault("ADMIN_TOKEN", "") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !isAdmin(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin is whether the request bears LETSDEBUG_WEB_ADMIN_TOKEN, which must be set.
+func isAdmin(r *http.Request) bool {
+	adminToken := envOrDefault("ADMIN_TOKEN", "")
+	if adminToken == "" {
+		return false
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func (s *server) httpExportAudit(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 10000 {
+		limit = 1000
+	}
+
+	query := `SELECT * FROM audit_log WHERE created_at >= $1 ORDER BY id ASC LIMIT $2;`
+	args := []interface{}{since, limit}
+	if domain := r.URL.Query().Get("domain"); domain != "" {
+		query = `SELECT * FROM audit_log WHERE created_at >= $1 AND domain = $3 ORDER BY id ASC LIMIT $2;`
+		args = append(args, normalizeDomain(domain))
+	}
+
+	entries := []auditEntry{}
+	if err := s.db.Select(&entries, query, args...); err != nil {
+		log.Printf("Failed to export audit log: %v", err)
+		http.Error(w, "An internal error occurred exporting the audit log.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Printf("Error encoding audit log export: %v", err)
+	}
+}
+
+func (s *server) httpAdminCancelTest(w http.ResponseWriter, r *http.Request) {
+	testID, err := strconv.ParseUint(chi.URLParam(r, "testID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid request parameters.", http.StatusBadRequest)
+		return
+	}
+
+	var domain string
+	if err := s.db.QueryRow(`UPDATE tests SET status = 'Cancelled' WHERE id = $1 AND status IN ('Queued','Processing') RETURNING domain;`,
+		testID).Scan(&domain); err != nil {
+		http.Error(w, "No such test is queued or processing.", http.StatusNotFound)
+		return
+	}
+	testsCancelled.Inc()
+
+	s.audit(r, auditCancel, domain, testID, auditDetails{"reason": r.URL.Query().Get("reason")})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// domainBan stops a domain, and its subdomains, from being tested, and hides their tests as though
+// they don't exist. The tests themselves are kept. Lifting the ban keeps its row, so that the bans
+// of a domain can be reviewed along with the audit log.
+type domainBan struct {
+	ID        int64      `db:"id" json:"id"`
+	Domain    string     `db:"domain" json:"domain"`
+	Reason    string     `db:"reason" json:"reason"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	LiftedAt  *time.Time `db:"lifted_at" json:"lifted_at,omitempty"`
+}
+
+// Whether the ban of a domain covers name, i.e. name is the domain or one of its subdomains.
+const banCoversDomain = `(domain = $1 OR substr($1, length($1) - length(domain)) = '.' || domain)`
+
+// findBan finds the ban in effect for domain, if any.
+func (s *server) findBan(domain string) (*domainBan, error) {
+	var b domainBan
+	if err := s.db.Get(&b, `SELECT * FROM domain_bans WHERE lifted_at IS NULL AND `+banCoversDomain+` LIMIT 1;`,
+		domain); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+// isBanned is whether domain may not be tested, or its tests viewed.
+func (s *server) isBanned(domain string) (bool, error) {
+	b, err := s.findBan(domain)
+	return b != nil, err
+}
+
+func (s *server) httpAdminListBans(w http.ResponseWriter, r *http.Request) {
+	query := `SELECT * FROM domain_bans WHERE lifted_at IS NULL ORDER BY id DESC;`
+	if r.URL.Query().Get("all") == "y" {
+		query = `SELECT * FROM domain_bans ORDER BY id DESC;`
+	}
+
+	bans := []domainBan{}
+	if err := s.db.Select(&bans, query); err != nil {
+		log.Printf("Failed to list bans: %v", err)
+		http.Error(w, "An internal error occurred listing the bans.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(bans); err != nil {
+		log.Printf("Error encoding bans: %v", err)
+	}
+}
+
+// banReason reads the reason for banning or lifting the ban of a domain from the JSON body of the
+// request ({"reason": ...}), or else from its reason query parameter.
+func banReason(w http.ResponseWriter, r *http.Request) (string, error) {
+	if r.Header.Get("content-type") == "application/json" && r.ContentLength != 0 {
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			return "", err
+		}
+		if req.Reason != "" {
+			return req.Reason, nil
+		}
+	}
+	return r.URL.Query().Get("reason"), nil
+}
+
+// httpAdminBanDomain bans the domain and cancels the tests of it which haven't completed yet.
+func (s *server) httpAdminBanDomain(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(chi.URLParam(r, "domain"))
+	if !isValidDomain(domain) {
+		http.Error(w, "Invalid request parameters.", http.StatusBadRequest)
+		return
+	}
+	reason, err := banReason(w, r)
+	if err != nil {
+		http.Error(w, "The request body is not valid JSON.", http.StatusBadRequest)
+		return
+	}
+
+	var b domainBan
+	if err := s.db.Get(&b, `INSERT INTO domain_bans (domain, reason) VALUES ($1, $2) RETURNING *;`, domain, reason); err != nil {
+		if existing, findErr := s.findBan(domain); findErr == nil && existing != nil && existing.Domain == domain {
+			http.Error(w, "The domain is already banned.", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to ban %s: %v", domain, err)
+		http.Error(w, "An internal error occurred banning the domain.", http.StatusInternalServerError)
+		return
+	}
+
+	var cancelled int64
+	if res, err := s.db.Exec(`UPDATE tests SET status = 'Cancelled' WHERE status IN ('Queued','Processing') AND `+banCoversDomain+`;`,
+		domain); err != nil {
+		log.Printf("Failed to cancel the tests of banned %s: %v", domain, err)
+	} else if cancelled, err = res.RowsAffected(); err == nil {
+		testsCancelled.Add(float64(cancelled))
+	}
+
+	s.audit(r, auditBan, domain, 0, auditDetails{"ban_id": b.ID, "reason": reason, "cancelled": cancelled})
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(b); err != nil {
+		log.Printf("Error encoding ban: %v", err)
+	}
+}
+
+func (s *server) httpAdminLiftBan(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(chi.URLParam(r, "domain"))
+	reason, err := banReason(w, r)
+	if err != nil {
+		http.Error(w, "The request body is not valid JSON.", http.StatusBadRequest)
+		return
+	}
+
+	var id int64
+	if err := s.db.QueryRow(`UPDATE domain_bans SET lifted_at = current_timestamp WHERE domain = $1 AND lifted_at IS NULL RETURNING id;`,
+		domain).Scan(&id); err != nil {
+		http.Error(w, "The domain is not banned.", http.StatusNotFound)
+		return
+	}
+
+	s.audit(r, auditLiftBan, domain, 0, auditDetails{"ban_id": id, "reason": reason})
+	w.WriteHeader(http.StatusNoContent)
+}
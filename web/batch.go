@@ -0,0 +1,360 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// batchMaxUploadBytes is the largest list of domains which may be uploaded.
+const batchMaxUploadBytes = 1 << 20
+
+var regexBatchID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// batchView is a list of domains uploaded together, and the test created for each of them.
+type batchView struct {
+	ID            string    `db:"id" json:"id"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	Method        string    `db:"method" json:"method"`
+	SubmittedByIP string    `db:"submitted_by_ip" json:"-"`
+	Token         *string   `db:"token" json:"-"`
+
+	Tests []testView `db:"-" json:"tests"`
+}
+
+// Completed is the number of tests of the batch which are no longer queued or running.
+func (b batchView) Completed() int {
+	var n int
+	for _, t := range b.Tests {
+		if t.Status == "Complete" || t.Status == "Cancelled" {
+			n++
+		}
+	}
+	return n
+}
+
+func (b batchView) Done() bool {
+	return b.Completed() == len(b.Tests)
+}
+
+// batchTokens are the tokens which allow domain lists to be uploaded, from LETSDEBUG_WEB_BATCH_TOKENS.
+// If there are none, batches are disabled.
+func batchTokens() []string {
+	var tokens []string
+	for _, t := range strings.Split(envOrDefault("BATCH_TOKENS", ""), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+func validBatchToken(token string) bool {
+	valid := false
+	for _, t := range batchTokens() {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// parseDomainList reads a newline-separated or CSV list of domains, taking the first column of each
+// row. Header rows, blank lines and #comments are skipped, URLs are reduced to their hostname, and
+// duplicates are removed. Rows which aren't valid domains are returned separately.
+func parseDomainList(r io.Reader, maxDomains int) (domains, invalid []string, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+
+	seen := map[string]bool{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		field := strings.TrimSpace(record[0])
+		switch strings.ToLower(field) {
+		case "", "domain", "domains", "hostname", "name":
+			continue
+		}
+		domain := field
+		if strings.HasPrefix(domain, "http:") || strings.HasPrefix(domain, "https:") {
+			if u, err := url.Parse(domain); err == nil {
+				domain = u.Hostname()
+			}
+		}
+		domain = normalizeDomain(domain)
+		if !isValidDomain(domain) {
+			invalid = append(invalid, field)
+			continue
+		}
+		if seen[domain] {
+			continue
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+		if len(domains) > maxDomains {
+			return nil, nil, fmt.Errorf("the list contains more than %d domains", maxDomains)
+		}
+	}
+	return domains, invalid, nil
+}
+
+func newBatchID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// createBatch creates the batch along with a test for each domain. The tests are only
+// picked up by the workers once all of them exist.
+func (s *server) createBatch(method, ip, token, requestID string, domains []string) (string, error) {
+	id, err := newBatchID()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`INSERT INTO batches (id, method, submitted_by_ip, token) VALUES ($1, $2, $3, $4);`,
+		id, method, ip, tokenFingerprint(token)); err != nil {
+		return "", err
+	}
+	for _, domain := range domains {
+		if _, err := tx.Exec(`INSERT INTO tests (domain, method, status, submitted_by_ip, options, batch_id, request_id) VALUES ($1, $2, 'Queued', $3, $4, $5, $6);`,
+			domain, method, ip, options{SuppressProblems: append(suppressedProblems(tokenFingerprint(token)), s.claimedSuppressedProblems(domain)...)}, id, requestID); err != nil {
+			return "", err
+		}
+	}
+	return id, tx.Commit()
+}
+
+func (s *server) findBatch(id string) (*batchView, error) {
+	var b batchView
+	if err := s.db.Get(&b, `SELECT * FROM batches WHERE id = $1;`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := s.db.Select(&b.Tests, `SELECT * FROM tests WHERE batch_id = $1 ORDER BY domain;`, id); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *server) httpBatchHome(w http.ResponseWriter, r *http.Request) {
+	if len(batchTokens()) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	s.render(w, http.StatusOK, "batch.tpl", map[string]interface{}{
+		"Method":     "http-01",
+		"MaxDomains": envOrDefaultInt("BATCH_MAX_DOMAINS", 250),
+		"CSRFToken":  s.csrfToken(w, r),
+	})
+}
+
+func (s *server) httpSubmitBatch(w http.ResponseWriter, r *http.Request) {
+	if len(batchTokens()) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	maxDomains := envOrDefaultInt("BATCH_MAX_DOMAINS", 250)
+	method := "http-01"
+
+	doError := func(msg string, code int) {
+		s.render(w, code, "batch.tpl", map[string]interface{}{
+			"Error":      msg,
+			"Method":     method,
+			"MaxDomains": maxDomains,
+			"CSRFToken":  s.csrfToken(w, r),
+		})
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, batchMaxUploadBytes+64*1024)
+	if err := r.ParseMultipartForm(batchMaxUploadBytes); err != nil {
+		doError(fmt.Sprintf("The upload could not be read. Lists may be at most %d KiB.", batchMaxUploadBytes/1024), http.StatusBadRequest)
+		return
+	}
+	method = r.PostFormValue("method")
+	if !s.checkCSRF(r) {
+		doError("Your session has expired, please submit the form again.", http.StatusForbidden)
+		return
+	}
+	token := r.PostFormValue("token")
+	if !validBatchToken(token) {
+		doError("The access token is not valid.", http.StatusUnauthorized)
+		return
+	}
+	if method == "" || len(method) > 200 {
+		doError("Please choose a validation method.", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("domains")
+	if err != nil {
+		doError("Please choose a file containing the list of domains.", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	domains, invalid, err := parseDomainList(file, maxDomains)
+	if err != nil {
+		doError(fmt.Sprintf("The list of domains could not be read: %v.", err), http.StatusBadRequest)
+		return
+	}
+	if len(invalid) > 0 {
+		if len(invalid) > 10 {
+			invalid = append(invalid[:10], fmt.Sprintf("and %d more", len(invalid)-10))
+		}
+		doError(fmt.Sprintf("The list contains invalid domains, please correct them: %s", strings.Join(invalid, ", ")), http.StatusBadRequest)
+		return
+	}
+	if len(domains) == 0 {
+		doError("The list does not contain any domains.", http.StatusBadRequest)
+		return
+	}
+
+	var banned []string
+	for _, domain := range domains {
+		isBanned, err := s.isBanned(domain)
+		if err != nil {
+			log.Printf("Failed to find the bans of %s: %v", domain, err)
+			doError(http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if isBanned {
+			banned = append(banned, domain)
+		}
+	}
+	if len(banned) > 0 {
+		if len(banned) > 10 {
+			banned = append(banned[:10], fmt.Sprintf("and %d more", len(banned)-10))
+		}
+		doError(fmt.Sprintf("Tests of some of the domains have been disabled, please remove them: %s", strings.Join(banned, ", ")), http.StatusForbidden)
+		return
+	}
+
+	ip := remoteIP(r)
+	id, err := s.createBatch(method, ip, token, requestID(r), domains)
+	if err != nil {
+		log.Printf("Failed to create batch of %d domains: %v", len(domains), err)
+		doError(http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[%s] [%s] Submitted batch %s of %d domains", requestID(r), ip, id, len(domains))
+	s.audit(r, auditSubmitBatch, "", 0, auditDetails{"batch_id": id, "method": method, "domains": len(domains),
+		"token": tokenFingerprint(token)})
+
+	http.Redirect(w, r, "/batch/"+id, http.StatusFound)
+}
+
+// batchFromRequest finds the batch named in the URL, writing an error response if there isn't one.
+func (s *server) batchFromRequest(w http.ResponseWriter, r *http.Request, doError func(string, int)) *batchView {
+	id := chi.URLParam(r, "batchID")
+	if !regexBatchID.MatchString(id) {
+		doError("Invalid request parameters.", http.StatusBadRequest)
+		return nil
+	}
+	b, err := s.findBatch(id)
+	if err != nil {
+		log.Printf("fetching batch %s: %v", id, err)
+		doError("An internal error occurred fetching that batch.", http.StatusInternalServerError)
+		return nil
+	}
+	if b == nil {
+		doError("No such batch exists. Old batches are deleted after 7 days.", http.StatusNotFound)
+		return nil
+	}
+	return b
+}
+
+func (s *server) httpViewBatch(w http.ResponseWriter, r *http.Request) {
+	isBrowser := r.Header.Get("accept") != "application/json"
+
+	doError := func(msg string, code int) {
+		if !isBrowser {
+			http.Error(w, msg, code)
+			return
+		}
+		s.render(w, code, "batch.tpl", map[string]interface{}{
+			"Error": msg,
+		})
+	}
+
+	b := s.batchFromRequest(w, r, doError)
+	if b == nil {
+		return
+	}
+
+	if !isBrowser {
+		w.Header().Set("content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(b); err != nil {
+			log.Printf("Error encoding batch response: %v", err)
+		}
+		return
+	}
+
+	if !b.Done() {
+		w.Header().Set("Refresh", fmt.Sprintf("5;url=%s", r.URL.String()))
+	}
+	s.render(w, http.StatusOK, "batch.tpl", map[string]interface{}{
+		"Batch": b,
+	})
+}
+
+// httpBatchReport consolidates the results of the batch into a single CSV file.
+func (s *server) httpBatchReport(w http.ResponseWriter, r *http.Request) {
+	b := s.batchFromRequest(w, r, func(msg string, code int) { http.Error(w, msg, code) })
+	if b == nil {
+		return
+	}
+
+	w.Header().Set("content-type", "text/csv; charset=utf-8")
+	w.Header().Set("content-disposition", fmt.Sprintf(`attachment; filename="letsdebug-batch-%s.csv"`, b.ID))
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"domain", "method", "test_id", "status", "severity", "summary", "result", "completed_at"})
+	for _, t := range b.Tests {
+		var completedAt string
+		if t.CompletedAt != nil {
+			completedAt = t.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		_ = cw.Write(csvRow([]string{t.Domain, t.Method, strconv.FormatUint(t.ID, 10), t.Status, t.Severity(), t.LongSummary(),
+			fmt.Sprintf("/%s/%d", t.Domain, t.ID), completedAt}))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("Error writing report for batch %s: %v", b.ID, err)
+	}
+}
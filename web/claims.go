@@ -0,0 +1,553 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/juju/ratelimit"
+	"github.com/letsdebug/letsdebug"
+)
+
+// A domain is claimed by proving control of it, either with a TXT record at claimTXTLabel or a file
+// at claimHTTPPath. The claim key, which is only shown once, is then presented in the claimKeyHeader
+// header (API clients) or the claimCookieName cookie (browsers) to use the features of the owner.
+const (
+	claimTXTLabel   = "_letsdebug-claim"
+	claimHTTPPath   = "/.well-known/letsdebug-claim/"
+	claimKeyHeader  = "X-Claim-Key"
+	claimCookieName = "letsdebug_claim"
+
+	// maxPendingClaims limits how many unverified claims a domain may have at once.
+	maxPendingClaims = 10
+)
+
+var errClaimNotFound = errors.New("no claim of the domain matches the claim key")
+
+// domainClaim is a claim to control a domain. Once verified, and for as long as the verification is
+// recent enough (LETSDEBUG_WEB_CLAIM_VALIDITY_DAYS), the holder of its key owns the domain: they may
+// make its test history private, suppress problems for all of its tests, and delete its tests.
+// A domain may have several owners.
+type domainClaim struct {
+	ID               int64      `db:"id" json:"-"`
+	Domain           string     `db:"domain" json:"domain"`
+	KeyHash          string     `db:"key_hash" json:"-"`
+	Token            string     `db:"token" json:"token"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+	VerifiedAt       *time.Time `db:"verified_at" json:"verified_at,omitempty"`
+	VerifiedBy       *string    `db:"verified_by" json:"verified_by,omitempty"`
+	Private          bool       `db:"private" json:"private"`
+	SuppressProblems string     `db:"suppress_problems" json:"-"`
+}
+
+// Verified is whether the claim was verified recently enough to be in effect.
+func (c domainClaim) Verified() bool {
+	return c.VerifiedAt != nil && time.Since(*c.VerifiedAt) < claimValidity()
+}
+
+// TXTName is where the TXT record containing the token proves control of the domain.
+func (c domainClaim) TXTName() string {
+	return claimTXTLabel + "." + c.Domain
+}
+
+// HTTPURL is where a file containing the token proves control of the domain.
+func (c domainClaim) HTTPURL() string {
+	return "http://" + c.Domain + claimHTTPPath + c.Token
+}
+
+func (c domainClaim) SuppressedProblems() []string {
+	var names []string
+	for _, name := range strings.Split(c.SuppressProblems, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (c domainClaim) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.withKey(""))
+}
+
+// withKey is the serialized form of the claim, which only includes its key when it was just created.
+func (c domainClaim) withKey(key string) interface{} {
+	type claim domainClaim
+	return struct {
+		claim
+		Key              string   `json:"key,omitempty"`
+		Verified         bool     `json:"verified"`
+		TXTName          string   `json:"txt_name"`
+		HTTPURL          string   `json:"http_url"`
+		SuppressProblems []string `json:"suppress_problems"`
+	}{claim(c), key, c.Verified(), c.TXTName(), c.HTTPURL(), c.SuppressedProblems()}
+}
+
+// claimValidity is how long a verification lasts, after which the claim must be verified again,
+// as control of domains changes hands.
+func claimValidity() time.Duration {
+	return time.Duration(envOrDefaultInt("CLAIM_VALIDITY_DAYS", 90)) * 24 * time.Hour
+}
+
+// claimKeyHash identifies a claim key. Unlike tokenFingerprint, it is the whole hash, as claims
+// are looked up by it.
+func claimKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// claimKey returns the claim key presented with the request, if any.
+func claimKey(r *http.Request) string {
+	if key := r.Header.Get(claimKeyHeader); key != "" {
+		return key
+	}
+	if c, err := r.Cookie(claimCookieName); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// setClaimCookie remembers the claim key in the browser. The cookie is scoped to the pages of
+// the domain, so that a visitor may hold a claim for each of several domains.
+func setClaimCookie(w http.ResponseWriter, r *http.Request, domain, key string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     claimCookieName,
+		Value:    key,
+		Path:     "/" + domain,
+		MaxAge:   int(claimValidity().Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func (s *server) createClaim(domain string) (domainClaim, string, error) {
+	var pending int
+	if err := s.db.Get(&pending, `SELECT count(*) FROM domain_claims WHERE domain = $1 AND verified_at IS NULL;`, domain); err != nil {
+		return domainClaim{}, "", err
+	}
+	if pending >= maxPendingClaims {
+		return domainClaim{}, "", fmt.Errorf("%s already has %d claims waiting to be verified, try again later", domain, pending)
+	}
+
+	key, err := randomHex(32)
+	if err != nil {
+		return domainClaim{}, "", err
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return domainClaim{}, "", err
+	}
+	var c domainClaim
+	if err := s.db.Get(&c, `INSERT INTO domain_claims (domain, key_hash, token) VALUES ($1, $2, $3) RETURNING *;`,
+		domain, claimKeyHash(key), token); err != nil {
+		return domainClaim{}, "", err
+	}
+	return c, key, nil
+}
+
+// findClaim returns the claim of domain with key, verified or not.
+func (s *server) findClaim(domain, key string) (*domainClaim, error) {
+	if key == "" {
+		return nil, errClaimNotFound
+	}
+	var c domainClaim
+	if err := s.db.Get(&c, `SELECT * FROM domain_claims WHERE domain = $1 AND key_hash = $2;`, domain, claimKeyHash(key)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errClaimNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// verifiedClaims are the claims of domain which are in effect.
+func (s *server) verifiedClaims(domain string) ([]domainClaim, error) {
+	var claims []domainClaim
+	if err := s.db.Select(&claims, `SELECT * FROM domain_claims WHERE domain = $1 AND verified_at > $2;`,
+		domain, time.Now().Add(-claimValidity())); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// canViewHistory is whether the request may list the tests of domain, which is the case unless the
+// domain is banned, or an owner made them private and the request doesn't come from an owner. The
+// tests of private domains are treated as though they don't exist, so that whether a domain is
+// private, or was ever tested, can't be found out either.
+func (s *server) canViewHistory(r *http.Request, domain string) (bool, error) {
+	if banned, err := s.isBanned(domain); err != nil || banned {
+		return false, err
+	}
+	claims, err := s.verifiedClaims(domain)
+	if err != nil {
+		return false, err
+	}
+	private := false
+	keyHash := claimKeyHash(claimKey(r))
+	for _, c := range claims {
+		if c.KeyHash == keyHash {
+			return true, nil
+		}
+		private = private || c.Private
+	}
+	return !private, nil
+}
+
+// canViewTest is whether the request may view the test, which is the case if it may list the tests
+// of its domain, or was made from the address the test was submitted from, unless the domain is banned.
+func (s *server) canViewTest(r *http.Request, t testView) (bool, error) {
+	if banned, err := s.isBanned(t.Domain); err != nil || banned {
+		return false, err
+	}
+	if t.SubmittedByIP == remoteIP(r) {
+		return true, nil
+	}
+	return s.canViewHistory(r, t.Domain)
+}
+
+// findViewableTest finds a test which the request may view, see canViewTest.
+func (s *server) findViewableTest(r *http.Request, domain string, id int) (*testView, error) {
+	t, err := s.findTest(domain, id)
+	if err != nil || t == nil {
+		return t, err
+	}
+	if ok, err := s.canViewTest(r, *t); err != nil || !ok {
+		return nil, err
+	}
+	return t, nil
+}
+
+// claimedSuppressedProblems are the problems which the owners of domain asked to suppress in all
+// of its tests.
+func (s *server) claimedSuppressedProblems(domain string) []string {
+	claims, err := s.verifiedClaims(domain)
+	if err != nil {
+		log.Printf("Failed to find the claims of %s: %v", domain, err)
+		return nil
+	}
+	var names []string
+	for _, c := range claims {
+		names = append(names, c.SuppressedProblems()...)
+	}
+	return names
+}
+
+// proveControl looks for the token of the claim in DNS, then over HTTP, and returns how it was found.
+func proveControl(ctx context.Context, c domainClaim) (string, error) {
+	var failures []string
+
+	txts, err := net.DefaultResolver.LookupTXT(ctx, c.TXTName())
+	for _, txt := range txts {
+		if strings.TrimSpace(txt) == c.Token {
+			return "dns", nil
+		}
+	}
+	if err != nil {
+		failures = append(failures, fmt.Sprintf("looking up the TXT record at %s: %v", c.TXTName(), err))
+	} else {
+		failures = append(failures, fmt.Sprintf("no TXT record at %s contains the token", c.TXTName()))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.HTTPURL(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Let's Debug domain claim verification")
+	resp, err := claimHTTPClient(c.Domain).Do(req)
+	if err != nil {
+		failures = append(failures, fmt.Sprintf("fetching %s: %v", c.HTTPURL(), err))
+		return "", errors.New(strings.Join(failures, "; "))
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == c.Token {
+		return "http", nil
+	}
+	failures = append(failures, fmt.Sprintf("%s responded with HTTP %d, without the token", c.HTTPURL(), resp.StatusCode))
+	return "", errors.New(strings.Join(failures, "; "))
+}
+
+// claimHTTPClient fetches the claim file of domain. Since anyone may create a claim, it only connects
+// to public addresses, and only follows redirects to the same domain over the usual ports.
+func claimHTTPClient(domain string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = letsdebug.NewPublicDialer(10 * time.Second).DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !strings.EqualFold(strings.TrimSuffix(req.URL.Hostname(), "."), domain) ||
+				(req.URL.Scheme != "http" && req.URL.Scheme != "https") ||
+				(req.URL.Port() != "" && req.URL.Port() != "80" && req.URL.Port() != "443") {
+				return fmt.Errorf("not following the redirect to %s, which leaves %s", req.URL.Redacted(), domain)
+			}
+			return nil
+		},
+	}
+}
+
+// claimRequest is the body of the requests made by the owners of a domain. Browsers submit it as a form.
+type claimRequest struct {
+	Private          bool     `json:"private"`
+	SuppressProblems []string `json:"suppress_problems"`
+}
+
+// parseClaimRequest reads the body of the request, which must come with a valid CSRF token if it
+// was submitted by a browser.
+func (s *server) parseClaimRequest(r *http.Request) (req claimRequest, isBrowser bool, err error) {
+	if r.Method == http.MethodGet {
+		return req, r.Header.Get("accept") != "application/json", nil
+	}
+	if r.Header.Get("content-type") == "application/json" {
+		if r.ContentLength != 0 {
+			err = json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&req)
+		}
+		return req, false, err
+	}
+	if !s.checkCSRF(r) {
+		return req, true, errors.New("your session has expired, please submit the form again")
+	}
+	req.Private = r.PostFormValue("private") == "on"
+	for _, name := range strings.Split(r.PostFormValue("suppress_problems"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.SuppressProblems = append(req.SuppressProblems, name)
+		}
+	}
+	return req, true, nil
+}
+
+// claimHandler resolves the domain of the request and the claim of the key presented with it, and
+// responds with the outcome of fn: the claim as JSON to API clients, or the claim page to browsers.
+// If verified is set, fn is only called for claims which are in effect. fn returns the status of
+// the response, and a message if it failed.
+func (s *server) claimHandler(verified bool, fn func(r *http.Request, c *domainClaim, req claimRequest) (int, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		domain := normalizeDomain(chi.URLParam(r, "domain"))
+		req, isBrowser, err := s.parseClaimRequest(r)
+
+		respond := func(c *domainClaim, msg string, code int) {
+			switch {
+			case isBrowser && code == http.StatusNoContent:
+				http.Redirect(w, r, "/"+domain+"/claim", http.StatusSeeOther)
+			case isBrowser:
+				s.render(w, code, "claim.tpl", map[string]interface{}{
+					"Domain":    domain,
+					"Claim":     c,
+					"Error":     msg,
+					"CSRFToken": s.csrfToken(w, r),
+				})
+			case msg != "":
+				http.Error(w, msg, code)
+			case code == http.StatusNoContent:
+				w.WriteHeader(code)
+			default:
+				w.Header().Set("content-type", "application/json")
+				w.WriteHeader(code)
+				if err := json.NewEncoder(w).Encode(c); err != nil {
+					log.Printf("Error encoding claim response: %v", err)
+				}
+			}
+		}
+
+		switch {
+		case err != nil:
+			respond(nil, fmt.Sprintf("The request was not valid: %v.", err), http.StatusBadRequest)
+			return
+		case !isValidDomain(domain) || strings.HasPrefix(domain, "*."):
+			respond(nil, "Please provide a valid domain name, without a wildcard.", http.StatusBadRequest)
+			return
+		case !validSuppressedProblems(req.SuppressProblems):
+			respond(nil, "Too many or invalid problems to suppress.", http.StatusBadRequest)
+			return
+		}
+
+		c, err := s.findClaim(domain, claimKey(r))
+		switch {
+		case errors.Is(err, errClaimNotFound) && isBrowser && r.Method == http.MethodGet:
+			// Browsers are shown how to claim the domain instead
+			respond(nil, "", http.StatusOK)
+			return
+		case errors.Is(err, errClaimNotFound):
+			respond(nil, "You do not hold a claim of this domain. Please claim it first.", http.StatusUnauthorized)
+			return
+		case err != nil:
+			log.Printf("Failed to find claim of %s: %v", domain, err)
+			respond(nil, "An internal error occurred finding your claim.", http.StatusInternalServerError)
+			return
+		case verified && !c.Verified():
+			respond(c, "The claim must be verified before it can be used.", http.StatusForbidden)
+			return
+		}
+
+		code, msg := fn(r, c, req)
+		respond(c, msg, code)
+	}
+}
+
+// httpViewClaim shows the claim of the key presented, or to browsers without one, how to claim the domain.
+func (s *server) httpViewClaim(w http.ResponseWriter, r *http.Request) {
+	s.claimHandler(false, func(*http.Request, *domainClaim, claimRequest) (int, string) {
+		return http.StatusOK, ""
+	})(w, r)
+}
+
+// takeClaimRateLimit takes a token from the claim rate limit of ip, and returns its bucket and whether
+// a token was available.
+func (s *server) takeClaimRateLimit(ip string) (*ratelimit.Bucket, bool) {
+	s.rateLimitClaimsMu.Lock()
+	bucket, ok := s.rateLimitClaimsByIP[ip]
+	if !ok {
+		bucket = ratelimit.NewBucket(
+			time.Duration(envOrDefaultInt("RATELIMIT_CLAIM_REGEN_SECS", 60))*time.Second,
+			int64(envOrDefaultInt("RATELIMIT_CLAIM_CAPACITY", 5)))
+		s.rateLimitClaimsByIP[ip] = bucket
+	}
+	s.rateLimitClaimsMu.Unlock()
+	return bucket, bucket.TakeAvailable(1) == 1
+}
+
+// httpCreateClaim starts a claim of the domain. Its key is only ever revealed in this response.
+func (s *server) httpCreateClaim(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(chi.URLParam(r, "domain"))
+	_, isBrowser, err := s.parseClaimRequest(r)
+
+	doError := func(msg string, code int) {
+		if !isBrowser {
+			http.Error(w, msg, code)
+			return
+		}
+		s.render(w, code, "claim.tpl", map[string]interface{}{
+			"Domain":    domain,
+			"Error":     msg,
+			"CSRFToken": s.csrfToken(w, r),
+		})
+	}
+
+	if err != nil {
+		doError(fmt.Sprintf("The request was not valid: %v.", err), http.StatusBadRequest)
+		return
+	}
+	if !isValidDomain(domain) || strings.HasPrefix(domain, "*.") {
+		doError("Please provide a valid domain name, without a wildcard.", http.StatusBadRequest)
+		return
+	}
+
+	// Per IP: 1 claim per minute, capacity 5
+	ip := remoteIP(r)
+	if bucket, ok := s.takeClaimRateLimit(ip); !ok {
+		msg := fmt.Sprintf("Too many claims from %s recently, try again soon.", ip)
+		retryAfter := setRateLimitHeaders(w, bucket)
+		if !isBrowser {
+			writeRateLimited(w, msg, retryAfter)
+			return
+		}
+		doError(msg, http.StatusTooManyRequests)
+		return
+	}
+
+	c, key, err := s.createClaim(domain)
+	if err != nil {
+		log.Printf("Failed to create claim of %s: %v", domain, err)
+		doError(fmt.Sprintf("The domain could not be claimed: %v.", err), http.StatusConflict)
+		return
+	}
+	s.audit(r, auditClaim, domain, 0, auditDetails{"claim_id": c.ID})
+
+	if isBrowser {
+		setClaimCookie(w, r, domain, key)
+		http.Redirect(w, r, "/"+domain+"/claim", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(c.withKey(key)); err != nil {
+		log.Printf("Error encoding claim response: %v", err)
+	}
+}
+
+func (s *server) httpVerifyClaim(w http.ResponseWriter, r *http.Request) {
+	s.claimHandler(false, func(r *http.Request, c *domainClaim, _ claimRequest) (int, string) {
+		ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+		defer cancel()
+		how, err := proveControl(ctx, *c)
+		if err != nil {
+			return http.StatusForbidden, fmt.Sprintf("Control of the domain could not be proven: %v.", err)
+		}
+		if err := s.db.Get(c, `UPDATE domain_claims SET verified_at = CURRENT_TIMESTAMP, verified_by = $2 WHERE id = $1 RETURNING *;`,
+			c.ID, how); err != nil {
+			log.Printf("Failed to verify claim %d: %v", c.ID, err)
+			return http.StatusInternalServerError, "An internal error occurred verifying the claim."
+		}
+		s.audit(r, auditVerifyClaim, c.Domain, 0, auditDetails{"claim_id": c.ID, "verified_by": how})
+		return http.StatusOK, ""
+	})(w, r)
+}
+
+func (s *server) httpUpdateClaim(w http.ResponseWriter, r *http.Request) {
+	s.claimHandler(true, func(r *http.Request, c *domainClaim, req claimRequest) (int, string) {
+		if err := s.db.Get(c, `UPDATE domain_claims SET private = $2, suppress_problems = $3 WHERE id = $1 RETURNING *;`,
+			c.ID, req.Private, strings.Join(req.SuppressProblems, ",")); err != nil {
+			log.Printf("Failed to update claim %d: %v", c.ID, err)
+			return http.StatusInternalServerError, "An internal error occurred updating the claim."
+		}
+		s.audit(r, auditUpdateClaim, c.Domain, 0, auditDetails{"claim_id": c.ID, "private": req.Private,
+			"suppress_problems": req.SuppressProblems})
+		return http.StatusOK, ""
+	})(w, r)
+}
+
+// httpDeleteDomainTests deletes every test of the domain. The audit log keeps its record of them.
+func (s *server) httpDeleteDomainTests(w http.ResponseWriter, r *http.Request) {
+	s.claimHandler(true, func(r *http.Request, c *domainClaim, _ claimRequest) (int, string) {
+		var methods []string
+		if err := s.db.Select(&methods, `DELETE FROM tests WHERE domain = $1 RETURNING method;`, c.Domain); err != nil {
+			log.Printf("Failed to delete the tests of %s: %v", c.Domain, err)
+			return http.StatusInternalServerError, "An internal error occurred deleting the tests."
+		}
+		seen := map[string]bool{}
+		for _, method := range methods {
+			if !seen[method] {
+				seen[method] = true
+				s.verdicts.Invalidate(c.Domain, method)
+			}
+		}
+		s.audit(r, auditDeleteTests, c.Domain, 0, auditDetails{"claim_id": c.ID, "tests": len(methods)})
+		return http.StatusOK, ""
+	})(w, r)
+}
+
+// httpReleaseClaim gives up the claim, whether or not it was verified.
+func (s *server) httpReleaseClaim(w http.ResponseWriter, r *http.Request) {
+	s.claimHandler(false, func(r *http.Request, c *domainClaim, _ claimRequest) (int, string) {
+		if _, err := s.db.Exec(`DELETE FROM domain_claims WHERE id = $1;`, c.ID); err != nil {
+			log.Printf("Failed to release claim %d: %v", c.ID, err)
+			return http.StatusInternalServerError, "An internal error occurred releasing the claim."
+		}
+		s.audit(r, auditReleaseClaim, c.Domain, 0, auditDetails{"claim_id": c.ID})
+		return http.StatusNoContent, ""
+	})(w, r)
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const corsMaxAge = 86400
+
+// corsAllowedHeaders are the request headers which browser-based integrations may send.
+var corsAllowedHeaders = []string{"Accept", "Authorization", "Content-Type"}
+
+// corsPolicy decides which cross-origin requests browsers may make to the API.
+type corsPolicy struct {
+	// A nil origins allows any origin
+	origins map[string]bool
+	methods map[string]bool
+	// The allowed methods, as sent to browsers
+	allowMethods string
+}
+
+// newCORSPolicy builds a policy from comma-separated lists of origins (or "*" for any origin)
+// and methods.
+func newCORSPolicy(origins, methods string) corsPolicy {
+	p := corsPolicy{methods: map[string]bool{}}
+	if strings.TrimSpace(origins) != "*" {
+		p.origins = map[string]bool{}
+		for _, o := range strings.Split(origins, ",") {
+			if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+				p.origins[strings.ToLower(o)] = true
+			}
+		}
+	}
+	var allowed []string
+	for _, m := range strings.Split(methods, ",") {
+		if m = strings.ToUpper(strings.TrimSpace(m)); m != "" && !p.methods[m] {
+			p.methods[m] = true
+			allowed = append(allowed, m)
+		}
+	}
+	p.allowMethods = strings.Join(allowed, ", ")
+	return p
+}
+
+func (p corsPolicy) allowsOrigin(origin string) bool {
+	return p.origins == nil || p.origins[strings.ToLower(origin)]
+}
+
+// Handler sets CORS headers on responses to allowed origins, and answers preflight requests
+// itself, since the routes only exist for the methods they implement.
+func (p corsPolicy) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("origin")
+		if p.origins != nil {
+			w.Header().Add("vary", "Origin")
+		}
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		requestedMethod := r.Header.Get("access-control-request-method")
+		isPreflight := r.Method == http.MethodOptions && requestedMethod != ""
+
+		if !p.allowsOrigin(origin) {
+			if isPreflight {
+				http.Error(w, "Origin not allowed.", http.StatusForbidden)
+				return
+			}
+			// The browser will withhold the response from the page
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// Credentials are never allowed, so any origin can be allowed without reflecting it
+		if p.origins == nil {
+			w.Header().Set("access-control-allow-origin", "*")
+		} else {
+			w.Header().Set("access-control-allow-origin", origin)
+		}
+
+		if !isPreflight {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !p.methods[strings.ToUpper(requestedMethod)] {
+			http.Error(w, "Method not allowed.", http.StatusForbidden)
+			return
+		}
+		w.Header().Add("vary", "Access-Control-Request-Method")
+		w.Header().Add("vary", "Access-Control-Request-Headers")
+		w.Header().Set("access-control-allow-methods", p.allowMethods)
+		w.Header().Set("access-control-allow-headers", strings.Join(corsAllowedHeaders, ", "))
+		w.Header().Set("access-control-max-age", strconv.Itoa(corsMaxAge))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	anyOrigin := newCORSPolicy("*", "GET,POST")
+	listed := newCORSPolicy(" https://Dashboard.example.org/ ,https://other.example.org", "get, post, get")
+
+	if listed.allowMethods != "GET, POST" {
+		t.Errorf("unexpected methods: %q", listed.allowMethods)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		policy corsPolicy
+		origin string
+		method string
+		// The expected Access-Control-Allow-Origin, and status of a preflight request
+		wantOrigin string
+		wantStatus int
+	}{
+		{"any origin", anyOrigin, "https://evil.example", "POST", "*", http.StatusNoContent},
+		{"listed origin", listed, "https://dashboard.example.org", "POST", "https://dashboard.example.org", http.StatusNoContent},
+		{"listed origin in another case", listed, "HTTPS://DASHBOARD.EXAMPLE.ORG", "GET", "HTTPS://DASHBOARD.EXAMPLE.ORG", http.StatusNoContent},
+		{"unlisted origin", listed, "https://evil.example", "POST", "", http.StatusForbidden},
+		{"unlisted suffix of a listed origin", listed, "https://dashboard.example.org.evil.example", "POST", "", http.StatusForbidden},
+		{"method not allowed", listed, "https://other.example.org", "DELETE", "https://other.example.org", http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.policy.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("the preflight request should not reach the handler")
+			}))
+			r := httptest.NewRequest(http.MethodOptions, "/", nil)
+			r.Header.Set("origin", tt.origin)
+			r.Header.Set("access-control-request-method", tt.method)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got := w.Header().Get("access-control-allow-origin"); got != tt.wantOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// csrfCookieName holds the token which the browser form must echo back in its csrf_token field.
+// Tokens are signed, so that a cookie planted by a hostile page (e.g. from a sibling domain)
+// is rejected rather than trusted.
+const csrfCookieName = "letsdebug_csrf"
+
+func newCSRFSecret() []byte {
+	if secret := envOrDefault("CSRF_SECRET", ""); secret != "" {
+		return []byte(secret)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Failed to generate CSRF secret: %v", err)
+	}
+	return secret
+}
+
+func (s *server) signCSRFNonce(nonce string) string {
+	mac := hmac.New(sha256.New, s.csrfSecret)
+	mac.Write([]byte(nonce))
+	return nonce + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *server) validCSRFToken(token string) bool {
+	nonce, _, ok := strings.Cut(token, ".")
+	return ok && hmac.Equal([]byte(token), []byte(s.signCSRFNonce(nonce)))
+}
+
+// csrfToken returns the token to embed in forms rendered for this request, issuing a new
+// cookie if the visitor doesn't already have a valid one.
+func (s *server) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(csrfCookieName); err == nil && s.validCSRFToken(c.Value) {
+		return c.Value
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Failed to generate CSRF token: %v", err)
+		return ""
+	}
+	token := s.signCSRFNonce(hex.EncodeToString(buf))
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// checkCSRF verifies that a form submission carries the same valid token as its cookie.
+func (s *server) checkCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookieName)
+	if err != nil || !s.validCSRFToken(c.Value) {
+		return false
+	}
+	return hmac.Equal([]byte(c.Value), []byte(r.PostFormValue("csrf_token")))
+}
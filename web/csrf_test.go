@@ -0,0 +1,59 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFToken(t *testing.T) {
+	s := &server{csrfSecret: []byte("secret")}
+
+	w := httptest.NewRecorder()
+	token := s.csrfToken(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !s.validCSRFToken(token) {
+		t.Fatalf("expected a valid token, got %q", token)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookieName || cookies[0].Value != token {
+		t.Fatalf("expected the token to be set as a cookie, got %v", cookies)
+	}
+
+	// A visitor with a valid cookie keeps their token
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	if s.csrfToken(w, r) != token || len(w.Result().Cookies()) != 0 {
+		t.Error("expected the token of the cookie to be reused")
+	}
+
+	forged := (&server{csrfSecret: []byte("other secret")}).signCSRFNonce("0123456789abcdef")
+	nonce, _, _ := strings.Cut(token, ".")
+	for _, tt := range []struct {
+		name   string
+		cookie string
+		field  string
+		want   bool
+	}{
+		{"matching", token, token, true},
+		{"missing field", token, "", false},
+		{"different field", token, s.signCSRFNonce("0123456789abcdef"), false},
+		{"missing cookie", "", token, false},
+		{"signed with another secret", forged, forged, false},
+		{"unsigned", nonce, nonce, false},
+		{"tampered signature", nonce + ".00", nonce + ".00", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"csrf_token": {tt.field}}.Encode()))
+			r.Header.Set("content-type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.cookie})
+			}
+			if got := s.checkCSRF(r); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
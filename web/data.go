@@ -0,0 +1,36 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/letsdebug/letsdebug"
+)
+
+// refreshData periodically fetches up to date copies of the datasets bundled with Let's Debug.
+func (s *server) refreshData(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		if err := letsdebug.RefreshData(ctx); err != nil {
+			log.Printf("Failed to refresh some datasets: %v", err)
+		}
+		cancel()
+		time.Sleep(interval)
+	}
+}
+
+// httpDataVersions describes the copy of each bundled dataset in use, so that a deployment whose
+// results differ from letsdebug.net can be checked for stale data.
+func (s *server) httpDataVersions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"version":  letsdebug.NewResultMetadata().Version,
+		"pinned":   letsdebug.DataPinned(),
+		"datasets": letsdebug.DataVersions(),
+	}); err != nil {
+		log.Printf("Error encoding data versions: %v", err)
+	}
+}
@@ -8,10 +8,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"log"
-	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 
 	"encoding/json"
@@ -40,56 +41,42 @@ var (
 
 type problems []letsdebug.Problem
 
-func (probs problems) Len() int {
-	return len(probs)
-}
-
-func (probs problems) Swap(i, j int) {
-	probs[i], probs[j] = probs[j], probs[i]
+type resultView struct {
+	// Results stored before the schema was versioned have no SchemaVersion
+	SchemaVersion string                    `json:"schema_version,omitempty"`
+	Metadata      *letsdebug.ResultMetadata `json:"metadata,omitempty"`
+	Error         string                    `json:"error,omitempty"`
+	Problems      problems                  `json:"problems,omitempty"`
+	Skipped       []letsdebug.SkippedCheck  `json:"skipped,omitempty"`
 }
 
-// thedailywtf.com
-func (probs problems) Less(i, j int) bool {
-	p1 := probs[i]
-	p2 := probs[j]
-
-	naturalOrder := p1.Name < p2.Name
-
-	if p1.Severity == p2.Severity {
-		return naturalOrder
-	}
-	if p1.Severity == letsdebug.SeverityDebug {
-		return false
-	}
-	if p1.Severity == letsdebug.SeverityFatal {
-		return true
-	}
-	if p1.Severity == letsdebug.SeverityError &&
-		(p2.Severity != letsdebug.SeverityFatal) {
-		return true
-	}
-	if p1.Severity == letsdebug.SeverityWarning &&
-		(p2.Severity != letsdebug.SeverityError && p2.Severity != letsdebug.SeverityFatal) {
-		return true
+// jsonBytes is the JSON document src, which Postgres returns as []byte and SQLite as a string.
+func jsonBytes(src interface{}) ([]byte, bool) {
+	switch v := src.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
 	}
-
-	return naturalOrder
-}
-
-type resultView struct {
-	Error    string   `json:"error,omitempty"`
-	Problems problems `json:"problems,omitempty"`
+	return nil, false
 }
 
 func (rv *resultView) Scan(src interface{}) error {
-	buf, ok := src.([]byte)
+	buf, ok := jsonBytes(src)
 	if !ok {
 		return errors.New("bad type")
 	}
 	if err := json.Unmarshal(buf, &rv); err != nil {
 		return err
 	}
-	sort.Sort(rv.Problems)
+	// Results stored before problems were categorized have no categories to filter on
+	for i := range rv.Problems {
+		if rv.Problems[i].Category == "" {
+			rv.Problems[i].Category = letsdebug.CategoryOf(rv.Problems[i].Name)
+		}
+	}
+	// Results stored by older versions, or annotated by the web server, may not be in order
+	letsdebug.SortProblems(rv.Problems)
 	return nil
 }
 
@@ -103,7 +90,12 @@ type testView struct {
 	StartedAt     *time.Time  `db:"started_at,omitempty" json:"started_at,omitempty"`
 	CompletedAt   *time.Time  `db:"completed_at,omitempty" json:"completed_at,omitempty"`
 	SubmittedByIP string      `db:"submitted_by_ip,omitempty" json:"-"`
+	BatchID       *string     `db:"batch_id,omitempty" json:"-"`
+	RequestID     string      `db:"request_id" json:"request_id,omitempty"`
 	Result        *resultView `db:"result,omitempty" json:"result,omitempty"`
+
+	// Annotations are only loaded when viewing a single test
+	Annotations []annotation `db:"-" json:"annotations,omitempty"`
 }
 
 func (t testView) QueueDuration() string {
@@ -157,12 +149,12 @@ func (t testView) Severity() string {
 	}
 
 	// Since problems are sorted, the first is the worst
-	s := string(t.Result.Problems[0].Severity)
-	if s == "Debug" {
+	s := t.Result.Problems[0].Severity
+	if s == letsdebug.SeverityInfo || s == letsdebug.SeverityDebug {
 		return "OK"
 	}
 
-	return s
+	return string(s)
 }
 
 func (t testView) Summary() string {
@@ -221,8 +213,10 @@ func (t testView) LongSummary() string {
 }
 
 type options struct {
-	HTTPRequestPath    string `json:"http_request_path"`
-	HTTPExpectResponse string `json:"http_expect_response"`
+	HTTPRequestPath    string   `json:"http_request_path"`
+	HTTPExpectResponse string   `json:"http_expect_response"`
+	SuppressProblems   []string `json:"suppress_problems,omitempty"`
+	Standalone         bool     `json:"standalone,omitempty"`
 }
 
 func (o options) Value() (driver.Value, error) {
@@ -230,7 +224,7 @@ func (o options) Value() (driver.Value, error) {
 }
 
 func (o *options) Scan(src interface{}) error {
-	buf, ok := src.([]byte)
+	buf, ok := jsonBytes(src)
 	if !ok {
 		return nil
 	}
@@ -244,7 +238,29 @@ func (o *options) Scan(src interface{}) error {
 	return nil
 }
 
+// openSQLite and migrateSQLite are provided by sqlite.go, which is only built with -tags sqlite
+// because go-sqlite3 needs cgo.
+var (
+	openSQLite    func(dsn string) (*sqlx.DB, error)
+	migrateSQLite func(db *sqlx.DB) error
+)
+
+// openDB opens the database of LETSDEBUG_WEB_DB_DRIVER, which is postgres or sqlite.
+func openDB(driver, dsn string) (*sqlx.DB, error) {
+	if driver != "sqlite" {
+		return sqlx.Open(driver, dsn)
+	}
+	if openSQLite == nil {
+		return nil, errors.New("LETSDEBUG_WEB_DB_DRIVER=sqlite requires a build with -tags sqlite")
+	}
+	return openSQLite(dsn)
+}
+
 func (s *server) migrateUp() error {
+	if s.sqlite {
+		return migrateSQLite(s.db)
+	}
+
 	driver, err := postgres.WithInstance(s.db.DB, &postgres.Config{})
 	if err != nil {
 		return err
@@ -266,10 +282,10 @@ func (s *server) migrateUp() error {
 	return nil
 }
 
-func (s *server) createNewTest(domain, method, ip string, opts options) (uint64, error) {
+func (s *server) createNewTest(domain, method, ip, requestID string, opts options) (uint64, error) {
 	var newID uint64
-	if err := s.db.QueryRow(`INSERT INTO tests (domain, method, status, submitted_by_ip, options) VALUES ($1, $2, 'Queued', $3, $4) RETURNING id;`,
-		domain, method, ip, opts).Scan(&newID); err != nil {
+	if err := s.db.QueryRow(`INSERT INTO tests (domain, method, status, submitted_by_ip, options, request_id) VALUES ($1, $2, 'Queued', $3, $4, $5) RETURNING id;`,
+		domain, method, ip, opts, requestID).Scan(&newID); err != nil {
 		return 0, err
 	}
 	return newID, nil
@@ -302,8 +318,6 @@ func (s *server) listenForTests(dsn string) error {
 		return err
 	}
 
-	var notification workRequest
-
 	for {
 		select {
 		case n := <-listener.Notify:
@@ -312,6 +326,8 @@ func (s *server) listenForTests(dsn string) error {
 				continue
 			}
 
+			// A fresh value, so that nothing is left over from the previous test
+			var notification workRequest
 			if err := json.Unmarshal([]byte(n.Extra), &notification); err != nil {
 				log.Printf("Error unmarshalling notification: %v (%s)", err, n.Extra)
 				continue
@@ -324,6 +340,35 @@ func (s *server) listenForTests(dsn string) error {
 	}
 }
 
+// pollForTests hands the oldest queued test to a worker whenever one is idle, for databases
+// without LISTEN/NOTIFY. Claiming the test marks it as Processing, so it is only run once.
+func (s *server) pollForTests(interval time.Duration) {
+	defer func() {
+		log.Fatalln("pollForTests exited abnormally")
+	}()
+
+	for {
+		if int(atomic.LoadInt32(&s.busyWorkers)) >= s.workers {
+			time.Sleep(interval)
+			continue
+		}
+
+		var req workRequest
+		err := s.db.Get(&req, `UPDATE tests SET started_at = CURRENT_TIMESTAMP, status = 'Processing' `+
+			`WHERE id = (SELECT id FROM tests WHERE status = 'Queued' ORDER BY id LIMIT 1) AND status = 'Queued' `+
+			`RETURNING id, domain, method, options, request_id;`)
+		switch {
+		case err == sql.ErrNoRows:
+			time.Sleep(interval)
+		case err != nil:
+			log.Printf("Error claiming a queued test: %v", err)
+			time.Sleep(interval)
+		default:
+			s.workCh <- req
+		}
+	}
+}
+
 func (s *server) vacuumTests() {
 	for {
 		var res sql.Result
@@ -341,6 +386,12 @@ func (s *server) vacuumTests() {
 		if _, err := s.db.Exec(`DELETE FROM tests WHERE created_at < now() - interval '7 days';`); err != nil {
 			log.Printf("Failed to vacuum old tests: %v", err)
 		}
+		if _, err := s.db.Exec(`DELETE FROM batches WHERE created_at < now() - interval '7 days';`); err != nil {
+			log.Printf("Failed to vacuum old batches: %v", err)
+		}
+		if _, err := s.db.Exec(`DELETE FROM domain_claims WHERE verified_at IS NULL AND created_at < now() - interval '7 days';`); err != nil {
+			log.Printf("Failed to vacuum unverified claims: %v", err)
+		}
 		time.Sleep(10 * time.Second)
 	}
 }
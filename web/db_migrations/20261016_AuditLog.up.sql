@@ -0,0 +1,22 @@
+CREATE TABLE audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  action TEXT NOT NULL,
+  actor_ip TEXT NOT NULL,
+  api_key TEXT,
+  domain TEXT,
+  test_id INTEGER,
+  details jsonb
+);
+
+CREATE INDEX audit_log_created_idx ON audit_log (created_at);
+CREATE INDEX audit_log_domain_idx ON audit_log (domain);
+
+-- The audit log is append-only. Unlike tests, it is not vacuumed.
+CREATE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
@@ -0,0 +1,3 @@
+ALTER TABLE tests DROP COLUMN batch_id;
+
+DROP TABLE batches;
@@ -0,0 +1,13 @@
+CREATE TABLE batches (
+  id TEXT PRIMARY KEY,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  method TEXT NOT NULL,
+  submitted_by_ip TEXT NOT NULL,
+  token TEXT
+);
+
+CREATE INDEX batches_created_idx ON batches (created_at);
+
+ALTER TABLE tests ADD COLUMN batch_id TEXT REFERENCES batches (id) ON DELETE SET NULL;
+
+CREATE INDEX tests_batch_idx ON tests (batch_id);
@@ -0,0 +1,13 @@
+CREATE TABLE domain_claims (
+  id BIGSERIAL PRIMARY KEY,
+  domain TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  token TEXT NOT NULL,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  verified_at timestamp,
+  verified_by TEXT,
+  private BOOLEAN NOT NULL DEFAULT false,
+  suppress_problems TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX domain_claims_domain_idx ON domain_claims (domain);
@@ -0,0 +1,9 @@
+CREATE TABLE annotations (
+  id BIGSERIAL PRIMARY KEY,
+  test_id INTEGER NOT NULL REFERENCES tests (id) ON DELETE CASCADE,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  helper TEXT NOT NULL,
+  body TEXT NOT NULL
+);
+
+CREATE INDEX annotations_test_idx ON annotations (test_id);
@@ -0,0 +1,9 @@
+CREATE TABLE domain_bans (
+  id BIGSERIAL PRIMARY KEY,
+  domain TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  lifted_at timestamp
+);
+
+CREATE UNIQUE INDEX domain_bans_active_domain_idx ON domain_bans (domain) WHERE lifted_at IS NULL;
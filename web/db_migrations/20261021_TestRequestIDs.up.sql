@@ -0,0 +1,16 @@
+ALTER TABLE tests ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+
+CREATE OR REPLACE FUNCTION notify_tests() RETURNS TRIGGER AS $$
+DECLARE
+  notification json;
+BEGIN
+  notification = json_build_object(
+    'id', NEW.id,
+    'domain', NEW.domain,
+    'method', NEW.method,
+    'options', NEW.options,
+    'request_id', NEW.request_id);
+  PERFORM pg_notify('tests_events', notification::text);
+  return NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,6 @@
+DROP TABLE domain_bans;
+DROP TABLE annotations;
+DROP TABLE domain_claims;
+DROP TABLE audit_log;
+DROP TABLE tests;
+DROP TABLE batches;
@@ -0,0 +1,90 @@
+-- The schema of db_migrations as of 20261021_TestRequestIDs, for SQLite. Add new migrations to both.
+-- There is no LISTEN/NOTIFY: the web server polls for queued tests instead.
+CREATE TABLE batches (
+  id TEXT PRIMARY KEY,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  method TEXT NOT NULL,
+  submitted_by_ip TEXT NOT NULL,
+  token TEXT
+);
+
+CREATE INDEX batches_created_idx ON batches (created_at);
+
+-- Warning: update server.vacuumTests() if changing the statuses.
+CREATE TABLE tests (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  domain TEXT NOT NULL,
+  method TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('Queued', 'Processing', 'Complete', 'Cancelled')),
+  created_at timestamp DEFAULT current_timestamp,
+  started_at timestamp,
+  completed_at timestamp,
+  submitted_by_ip TEXT NOT NULL,
+  result TEXT,
+  options TEXT,
+  batch_id TEXT REFERENCES batches (id) ON DELETE SET NULL,
+  request_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX tests_lookup_idx ON tests (id, domain);
+CREATE INDEX tests_domain_idx ON tests (domain);
+CREATE INDEX tests_status_idx on tests (status);
+CREATE INDEX tests_created_idx on tests (created_at DESC);
+CREATE INDEX tests_batch_idx ON tests (batch_id);
+
+CREATE TABLE audit_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  action TEXT NOT NULL,
+  actor_ip TEXT NOT NULL,
+  api_key TEXT,
+  domain TEXT,
+  test_id INTEGER,
+  details TEXT
+);
+
+CREATE INDEX audit_log_created_idx ON audit_log (created_at);
+CREATE INDEX audit_log_domain_idx ON audit_log (domain);
+
+-- The audit log is append-only. Unlike tests, it is not vacuumed.
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN
+  SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN
+  SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TABLE domain_claims (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  domain TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  token TEXT NOT NULL,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  verified_at timestamp,
+  verified_by TEXT,
+  private BOOLEAN NOT NULL DEFAULT false,
+  suppress_problems TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX domain_claims_domain_idx ON domain_claims (domain);
+
+CREATE TABLE annotations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  test_id INTEGER NOT NULL REFERENCES tests (id) ON DELETE CASCADE,
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  helper TEXT NOT NULL,
+  body TEXT NOT NULL
+);
+
+CREATE INDEX annotations_test_idx ON annotations (test_id);
+
+CREATE TABLE domain_bans (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  domain TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL DEFAULT current_timestamp,
+  lifted_at timestamp
+);
+
+CREATE UNIQUE INDEX domain_bans_active_domain_idx ON domain_bans (domain) WHERE lifted_at IS NULL;
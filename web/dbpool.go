@@ -0,0 +1,161 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/letsdebug/letsdebug"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dbUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "letsdebug",
+			Name:      "db_up",
+			Help:      "Whether the last health check of the database succeeded",
+		},
+		[]string{"db"})
+)
+
+// dbStatsCollector exports the statistics of a connection pool, which is looked up on each scrape
+// because the certwatch pool is replaced whenever it reconnects.
+type dbStatsCollector struct {
+	stats func() sql.DBStats
+
+	maxOpen, open, inUse, idle, waitCount, waitDuration, closedMaxLifetime, closedMaxIdleTime *prometheus.Desc
+}
+
+func newDBStatsCollector(name string, stats func() sql.DBStats) *dbStatsCollector {
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("letsdebug_db_"+metric, help, nil, prometheus.Labels{"db": name})
+	}
+	return &dbStatsCollector{
+		stats:             stats,
+		maxOpen:           desc("max_open_connections", "The most connections the pool may open"),
+		open:              desc("open_connections", "The connections open, in use or idle"),
+		inUse:             desc("in_use_connections", "The connections in use"),
+		idle:              desc("idle_connections", "The idle connections"),
+		waitCount:         desc("wait_count_total", "The queries which waited for a connection"),
+		waitDuration:      desc("wait_duration_seconds_total", "How long queries waited for a connection"),
+		closedMaxLifetime: desc("closed_max_lifetime_total", "The connections closed because of their age"),
+		closedMaxIdleTime: desc("closed_max_idle_time_total", "The connections closed because they were idle"),
+	}
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.maxOpen, c.open, c.inUse, c.idle, c.waitCount, c.waitDuration,
+		c.closedMaxLifetime, c.closedMaxIdleTime} {
+		ch <- d
+	}
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle))
+	// The certwatch pool starts again from zero when it reconnects, which Prometheus treats as a counter reset
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.closedMaxLifetime, prometheus.CounterValue, float64(s.MaxLifetimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.closedMaxIdleTime, prometheus.CounterValue, float64(s.MaxIdleTimeClosed))
+}
+
+// configureDB sizes the pool of connections to the database from LETSDEBUG_WEB_DB_MAX_OPEN_CONNS,
+// LETSDEBUG_WEB_DB_MAX_IDLE_CONNS and LETSDEBUG_WEB_DB_CONN_MAX_LIFETIME_SECS.
+func configureDB(db *sqlx.DB) {
+	db.SetMaxOpenConns(envOrDefaultInt("DB_MAX_OPEN_CONNS", 0))
+	db.SetMaxIdleConns(envOrDefaultInt("DB_MAX_IDLE_CONNS", 2))
+	db.SetConnMaxLifetime(time.Duration(envOrDefaultInt("DB_CONN_MAX_LIFETIME_SECS", 0)) * time.Second)
+	prometheus.MustRegister(newDBStatsCollector("primary", db.Stats))
+}
+
+// configureCertwatch sizes the pool of connections to the certwatch database of crt.sh, which is shared
+// by the rate limit check and the certwatch gateway.
+func configureCertwatch() {
+	letsdebug.ConfigureCertwatch(letsdebug.CertwatchConfig{
+		MaxOpenConns:     envOrDefaultInt("CERTWATCH_MAX_OPEN_CONNS", 0),
+		MaxIdleConns:     envOrDefaultInt("CERTWATCH_MAX_IDLE_CONNS", 0),
+		ConnMaxLifetime:  time.Duration(envOrDefaultInt("CERTWATCH_CONN_MAX_LIFETIME_SECS", 0)) * time.Second,
+		StatementTimeout: time.Duration(envOrDefaultInt("CERTWATCH_STATEMENT_TIMEOUT_SECS", 0)) * time.Second,
+	})
+	prometheus.MustRegister(newDBStatsCollector("certwatch", letsdebug.CertwatchStats))
+}
+
+// withStatementTimeout asks Postgres to abandon the statements of every connection made with dsn which
+// run for longer than d. dsn may be a URL or a list of key=value settings.
+func withStatementTimeout(dsn string, d time.Duration) string {
+	if d <= 0 {
+		return dsn
+	}
+	ms := fmt.Sprint(d.Milliseconds())
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		q := u.Query()
+		q.Set("statement_timeout", ms)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return strings.TrimSpace(dsn + " statement_timeout=" + ms)
+}
+
+// waitForDB pings the database until it answers, backing off between attempts, so that the web
+// server can be started alongside the database. It gives up after timeout.
+func waitForDB(db *sqlx.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := time.Second
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			dbUp.WithLabelValues("primary").Set(1)
+			return nil
+		}
+		dbUp.WithLabelValues("primary").Set(0)
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("database unavailable after %v: %w", timeout, err)
+		}
+		log.Printf("Database unavailable, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// monitorDB pings the database periodically, and logs when it stops or starts answering. Broken
+// connections are replaced by the pool as they are used, so this only needs to report on it.
+func (s *server) monitorDB() {
+	up := true
+	for {
+		time.Sleep(30 * time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := s.db.PingContext(ctx)
+		cancel()
+		switch {
+		case err != nil && up:
+			log.Printf("Database health check failed: %v", err)
+		case err == nil && !up:
+			log.Printf("Database health check succeeded again")
+		}
+		up = err == nil
+		if up {
+			dbUp.WithLabelValues("primary").Set(1)
+		} else {
+			dbUp.WithLabelValues("primary").Set(0)
+		}
+	}
+}
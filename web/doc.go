@@ -1 +1,11 @@
+// Package web implements the letsdebug.net web frontend and its test workers.
+//
+// It is configured through environment variables prefixed with LETSDEBUG_WEB_, such as
+// LETSDEBUG_WEB_DB_DSN, LETSDEBUG_WEB_LISTEN_ADDR and LETSDEBUG_WEB_CONCURRENCY.
+// LETSDEBUG_WEB_STAGING_REUSE_SECS allows workers to reuse a Let's Encrypt staging
+// result for the same domain and method which is at most that many seconds old.
+// LETSDEBUG_WEB_VERDICT_CACHE_SECS sets how long the latest verdict of each domain and
+// method is cached for.
+// The library's own variables, such as LETSDEBUG_ACMESTAGING_ACCOUNTFILE (which accepts
+// a list of account files), also apply. See the README for the full list.
 package web
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/url"
+
+	"github.com/letsdebug/letsdebug"
+)
+
+// filterableSeverities are the severities which the results page can hide. Fatal problems and errors
+// are always shown.
+var filterableSeverities = []letsdebug.SeverityLevel{
+	letsdebug.SeverityWarning, letsdebug.SeverityInfo, letsdebug.SeverityDebug,
+}
+
+// resultFilter selects the problems of a test to show, from the query of the results page.
+type resultFilter struct {
+	Severities map[letsdebug.SeverityLevel]bool
+	// Categories to show, or all of them if empty
+	Categories map[letsdebug.Category]bool
+}
+
+// parseResultFilter reads the filter from repeated severity= and category= parameters. Without
+// them, every severity but Debug is shown, or all of them with debug=y. filter=y marks a submission
+// of the filter form, in which no severity= parameters means that none of them were ticked.
+func parseResultFilter(q url.Values) resultFilter {
+	f := resultFilter{
+		Severities: map[letsdebug.SeverityLevel]bool{},
+		Categories: map[letsdebug.Category]bool{},
+	}
+	if q.Get("filter") == "y" || len(q["severity"]) > 0 {
+		for _, s := range q["severity"] {
+			for _, level := range filterableSeverities {
+				if s == string(level) {
+					f.Severities[level] = true
+				}
+			}
+		}
+	} else {
+		f.Severities[letsdebug.SeverityWarning] = true
+		f.Severities[letsdebug.SeverityInfo] = true
+		f.Severities[letsdebug.SeverityDebug] = q.Get("debug") == "y"
+	}
+	for _, c := range q["category"] {
+		for _, category := range letsdebug.Categories {
+			if c == string(category) {
+				f.Categories[category] = true
+			}
+		}
+	}
+	return f
+}
+
+// ShowsSeverity is whether problems of the severity are shown.
+func (f resultFilter) ShowsSeverity(level letsdebug.SeverityLevel) bool {
+	if level == letsdebug.SeverityFatal || level == letsdebug.SeverityError {
+		return true
+	}
+	return f.Severities[level]
+}
+
+// ShowsCategory is whether problems in the category are shown.
+func (f resultFilter) ShowsCategory(category letsdebug.Category) bool {
+	return len(f.Categories) == 0 || f.Categories[category]
+}
+
+// apply returns the problems which the filter shows, and the number of problems other than debug
+// problems which it hides.
+func (f resultFilter) apply(probs problems) (problems, int) {
+	var shown problems
+	hidden := 0
+	for _, p := range probs {
+		if f.ShowsSeverity(p.Severity) && f.ShowsCategory(p.Category) {
+			shown = append(shown, p)
+		} else if p.Severity != letsdebug.SeverityDebug {
+			hidden++
+		}
+	}
+	return shown, hidden
+}
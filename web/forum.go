@@ -0,0 +1,142 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/letsdebug/letsdebug"
+)
+
+// forumMaxNewTopicURL is the longest new topic link offered. Beyond it, the post has to be copied
+// and pasted, as browsers and servers truncate or reject longer URLs.
+const forumMaxNewTopicURL = 6000
+
+var regexServerHeader = regexp.MustCompile(`Server=([^,\]]*)`)
+
+// forumPost is a completed test formatted as the Let's Encrypt community forum's help questionnaire.
+type forumPost struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// NewTopicURL opens the forum's composer with the post filled in, if it's short enough
+	NewTopicURL string `json:"new_topic_url,omitempty"`
+}
+
+// newForumPost fills in the parts of the questionnaire which the test knows the answer to, and
+// leaves the rest for the user.
+func newForumPost(t testView, publicURL, forumURL string) forumPost {
+	testURL := fmt.Sprintf("%s/%s/%d", strings.TrimRight(publicURL, "/"), t.Domain, t.ID)
+
+	var output []string
+	var webServer string
+	if t.Result != nil {
+		if t.Result.Error != "" {
+			output = append(output, "The test failed: "+t.Result.Error)
+		}
+		for _, p := range t.Result.Problems {
+			if p.Name == "HTTPCheck" && webServer == "" {
+				if m := regexServerHeader.FindStringSubmatch(p.Detail); m != nil {
+					webServer = strings.TrimSpace(m[1])
+				}
+			}
+			if p.Severity == letsdebug.SeverityDebug {
+				continue
+			}
+			output = append(output, fmt.Sprintf("%s (%s): %s", p.Name, p.Severity, p.Explanation))
+		}
+	}
+	if len(output) == 0 {
+		output = append(output, "No issues were found.")
+	}
+	if webServer != "" {
+		webServer = fmt.Sprintf("%s (as reported in the Server header)", webServer)
+	}
+
+	body := strings.Join([]string{
+		"My domain is: " + t.Domain,
+		"",
+		fmt.Sprintf("I ran this command: Let's Debug test using %s: %s", t.Method, testURL),
+		"",
+		"It produced this output:",
+		"```",
+		strings.Join(output, "\n\n"),
+		"```",
+		"",
+		"My web server is (include version): " + webServer,
+		"",
+		"The operating system my web server runs on is (include version): ",
+		"",
+		"My hosting provider, if applicable, is: ",
+		"",
+		"I can login to a root shell on my machine (yes or no, or I don't know): ",
+		"",
+		"I'm using a control panel to manage my site (no, or provide the name and version of the control panel): ",
+		"",
+		"The version of my client is (e.g. output of certbot --version): ",
+	}, "\n")
+
+	post := forumPost{
+		Title: fmt.Sprintf("Unable to issue a certificate for %s (%s): %s", t.Domain, t.Method, t.Severity()),
+		Body:  body,
+	}
+	newTopicURL := fmt.Sprintf("%s/new-topic?%s", strings.TrimRight(forumURL, "/"), url.Values{
+		"title":    {post.Title},
+		"body":     {post.Body},
+		"category": {"help"},
+	}.Encode())
+	if len(newTopicURL) <= forumMaxNewTopicURL {
+		post.NewTopicURL = newTopicURL
+	}
+	return post
+}
+
+// forumPostFor formats the test using the configured public address of Let's Debug and of the forum.
+func forumPostFor(t testView) forumPost {
+	return newForumPost(t, envOrDefault("PUBLIC_URL", "https://letsdebug.net"),
+		envOrDefault("FORUM_URL", "https://community.letsencrypt.org"))
+}
+
+// httpForumPost returns the completed test as a community forum post, as plain text for copying
+// and pasting, or as JSON.
+func (s *server) httpForumPost(w http.ResponseWriter, r *http.Request) {
+	domain := chi.URLParam(r, "domain")
+	testID, err := strconv.Atoi(chi.URLParam(r, "testID"))
+	if domain == "" || err != nil {
+		http.Error(w, "Invalid request parameters.", http.StatusBadRequest)
+		return
+	}
+
+	test, err := s.findViewableTest(r, domain, testID)
+	if err != nil {
+		log.Printf("fetching %s/%d: %v", domain, testID, err)
+		http.Error(w, "An internal error occurred fetching that test.", http.StatusInternalServerError)
+		return
+	}
+	if test == nil {
+		http.Error(w, "No such test exists. Old tests are deleted after 7 days.", http.StatusNotFound)
+		return
+	}
+	if test.Status != "Complete" {
+		http.Error(w, "The test has not completed yet.", http.StatusConflict)
+		return
+	}
+
+	post := forumPostFor(*test)
+
+	if r.Header.Get("accept") == "application/json" {
+		w.Header().Set("content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(post); err != nil {
+			log.Printf("Error encoding forum post response: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s\n\n%s\n", post.Title, post.Body)
+}
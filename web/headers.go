@@ -0,0 +1,29 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// securityHeaders sets the headers which keep browsers from sniffing the type of responses, leaking
+// the address of results (which name the tested domain) to other sites, and framing the pages.
+// frameAncestors is the frame-ancestors source list of the Content-Security-Policy, e.g. 'none'
+// or https://dashboard.example.org.
+func securityHeaders(frameAncestors string) func(http.Handler) http.Handler {
+	frameAncestors = strings.Join(strings.FieldsFunc(frameAncestors, func(r rune) bool { return r == ',' || r == ' ' }), " ")
+	if frameAncestors == "" {
+		frameAncestors = "'none'"
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("x-content-type-options", "nosniff")
+			w.Header().Set("referrer-policy", "same-origin")
+			w.Header().Set("content-security-policy", "frame-ancestors "+frameAncestors)
+			// For browsers which don't support frame-ancestors
+			if frameAncestors == "'none'" {
+				w.Header().Set("x-frame-options", "DENY")
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
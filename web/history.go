@@ -0,0 +1,173 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// problemHistory is when a problem was first and last reported for a domain and method, across
+// the tests which are still retained.
+type problemHistory struct {
+	Method string `db:"method" json:"method"`
+	Name   string `db:"name" json:"name"`
+	// Severity is that of the latest report of the problem
+	Severity  string    `db:"severity" json:"severity"`
+	FirstSeen time.Time `db:"first_seen" json:"first_seen"`
+	LastSeen  time.Time `db:"last_seen" json:"last_seen"`
+	Tests     int       `db:"tests" json:"tests"`
+	// Current is whether the problem was reported by the latest test of the method
+	Current bool `db:"current" json:"current"`
+}
+
+func (h problemHistory) Duration() string {
+	return h.LastSeen.Sub(h.FirstSeen).Truncate(time.Minute).String()
+}
+
+func (s *server) findProblemHistory(domain string) ([]problemHistory, error) {
+	if s.sqlite {
+		return s.findProblemHistorySQLite(domain)
+	}
+
+	var history []problemHistory
+	if err := s.db.Select(&history, `WITH complete AS (
+  SELECT id, method, completed_at, result FROM tests WHERE domain = $1 AND status = 'Complete'
+), latest AS (
+  SELECT method, max(completed_at) AS completed_at FROM complete GROUP BY method
+)
+SELECT c.method, p->>'name' AS name, (array_agg(p->>'severity' ORDER BY c.completed_at DESC))[1] AS severity,
+  min(c.completed_at) AS first_seen, max(c.completed_at) AS last_seen, count(DISTINCT c.id) AS tests,
+  bool_or(c.completed_at = l.completed_at) AS current
+FROM complete c JOIN latest l ON l.method = c.method, jsonb_array_elements(c.result->'problems') p
+WHERE p->>'severity' <> 'Debug'
+GROUP BY c.method, p->>'name'
+ORDER BY c.method, first_seen, name;`, domain); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// findProblemHistorySQLite is findProblemHistory without the aggregates which SQLite lacks, or which
+// would return timestamps as text.
+func (s *server) findProblemHistorySQLite(domain string) ([]problemHistory, error) {
+	var rows []struct {
+		ID          uint64    `db:"id"`
+		Method      string    `db:"method"`
+		CompletedAt time.Time `db:"completed_at"`
+		Name        string    `db:"name"`
+		Severity    string    `db:"severity"`
+	}
+	if err := s.db.Select(&rows, `SELECT t.id, t.method, t.completed_at, json_extract(p.value, '$.name') AS name,
+  json_extract(p.value, '$.severity') AS severity
+FROM tests t, json_each(t.result, '$.problems') p
+WHERE t.domain = $1 AND t.status = 'Complete' AND json_extract(p.value, '$.severity') <> 'Debug'
+ORDER BY t.completed_at;`, domain); err != nil {
+		return nil, err
+	}
+
+	// The latest test of a method may have found no problems at all
+	var tests []struct {
+		Method      string    `db:"method"`
+		CompletedAt time.Time `db:"completed_at"`
+	}
+	if err := s.db.Select(&tests, `SELECT method, completed_at FROM tests WHERE domain = $1 AND status = 'Complete';`, domain); err != nil {
+		return nil, err
+	}
+	latest := map[string]time.Time{}
+	for _, t := range tests {
+		if t.CompletedAt.After(latest[t.Method]) {
+			latest[t.Method] = t.CompletedAt
+		}
+	}
+
+	var history []problemHistory
+	index := map[[2]string]int{}
+	seen := map[[2]string]map[uint64]bool{}
+	for _, row := range rows {
+		key := [2]string{row.Method, row.Name}
+		i, ok := index[key]
+		if !ok {
+			i = len(history)
+			index[key] = i
+			seen[key] = map[uint64]bool{}
+			history = append(history, problemHistory{Method: row.Method, Name: row.Name, FirstSeen: row.CompletedAt})
+		}
+		h := &history[i]
+		// Rows are in order of completion, so the last one seen is the latest
+		h.Severity = row.Severity
+		h.LastSeen = row.CompletedAt
+		if !seen[key][row.ID] {
+			seen[key][row.ID] = true
+			h.Tests++
+		}
+		h.Current = h.Current || row.CompletedAt.Equal(latest[row.Method])
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		if !a.FirstSeen.Equal(b.FirstSeen) {
+			return a.FirstSeen.Before(b.FirstSeen)
+		}
+		return a.Name < b.Name
+	})
+	return history, nil
+}
+
+// httpViewDomainHistory shows when each problem was first and last seen for the domain, so that new
+// problems can be told apart from longstanding ones.
+func (s *server) httpViewDomainHistory(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(chi.URLParam(r, "domain"))
+
+	isBrowser := r.Header.Get("accept") != "application/json"
+
+	doError := func(msg string, code int) {
+		if !isBrowser {
+			http.Error(w, msg, code)
+			return
+		}
+		s.render(w, code, "history.tpl", map[string]interface{}{
+			"Error": msg,
+		})
+	}
+
+	if !isValidDomain(domain) {
+		doError("Invalid domain provided", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := s.canViewHistory(r, domain)
+	if err != nil {
+		log.Printf("couldn't find the claims of %s: %v", domain, err)
+		doError("Internal error occurred finding the problem history", http.StatusInternalServerError)
+		return
+	}
+
+	var history []problemHistory
+	if ok {
+		history, err = s.findProblemHistory(domain)
+	}
+	if err != nil {
+		log.Printf("couldn't find the problem history of %s: %v", domain, err)
+		doError("Internal error occurred finding the problem history", http.StatusInternalServerError)
+		return
+	}
+
+	if isBrowser {
+		s.render(w, http.StatusOK, "history.tpl", map[string]interface{}{
+			"Domain":  domain,
+			"History": history,
+		})
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		log.Printf("failed to marshal problem history: %v", err)
+	}
+}
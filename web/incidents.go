@@ -0,0 +1,154 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/letsdebug/letsdebug"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	// incidentBaselinePeriod is how far back the usual rate of each staging error is measured over
+	incidentBaselinePeriod = 24 * time.Hour
+	// incidentSurgeFactor is how many times more domains than usual must fail with the same
+	// staging error within the window for it to be considered an incident
+	incidentSurgeFactor = 3
+)
+
+var regexStagingURN = regexp.MustCompile(`urn:ietf:params:acme:error:([A-Za-z]+)`)
+
+// incident is a surge of unrelated domains failing with the same error from the staging service,
+// which suggests that the problem is on Let's Encrypt's side rather than with the domains.
+type incident struct {
+	URN     string
+	Domains int
+	Window  time.Duration
+}
+
+// incidentTracker holds the incidents found by the latest run of correlateIncidents.
+type incidentTracker struct {
+	mu     sync.Mutex
+	active map[string]incident
+}
+
+func (t *incidentTracker) Set(active map[string]incident) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active = active
+}
+
+// Annotate returns a problem explaining that the staging error in the results of a test is
+// currently being seen for many unrelated domains, if it is.
+func (t *incidentTracker) Annotate(probs []letsdebug.Problem) (letsdebug.Problem, bool) {
+	urn := stagingURN(probs)
+	if urn == "" {
+		return letsdebug.Problem{}, false
+	}
+	t.mu.Lock()
+	inc, ok := t.active[urn]
+	t.mu.Unlock()
+	if !ok {
+		return letsdebug.Problem{}, false
+	}
+	return possibleIncident(inc), true
+}
+
+// stagingURN returns the type of the error the staging service responded with, if any.
+func stagingURN(probs []letsdebug.Problem) string {
+	for _, p := range probs {
+		if p.Name != "LetsEncryptStaging" {
+			continue
+		}
+		if m := regexStagingURN.FindStringSubmatch(p.Detail); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// correlateIncidents periodically compares how many unrelated domains failed with each staging error
+// during the last window with how many usually do, and records the errors which are surging.
+func (s *server) correlateIncidents(window time.Duration, minDomains int) {
+	for {
+		active, err := s.findIncidents(time.Now(), window, minDomains)
+		if err != nil {
+			log.Printf("Failed to correlate staging errors: %v", err)
+		} else {
+			for _, inc := range active {
+				log.Printf("Possible Let's Encrypt incident: %d domains failed with %s in the last %v", inc.Domains, inc.URN, window)
+			}
+			s.incidents.Set(active)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+func (s *server) findIncidents(now time.Time, window time.Duration, minDomains int) (map[string]incident, error) {
+	var rows []struct {
+		Domain      string    `db:"domain"`
+		CompletedAt time.Time `db:"completed_at"`
+		Detail      string    `db:"detail"`
+	}
+	problems, name, detail := `jsonb_array_elements(result->'problems') p`, `p->>'name'`, `p->>'detail'`
+	if s.sqlite {
+		problems, name, detail = `json_each(result, '$.problems') p`, `json_extract(p.value, '$.name')`, `json_extract(p.value, '$.detail')`
+	}
+	if err := s.db.Select(&rows, `SELECT domain, completed_at, `+detail+` AS detail FROM tests, `+problems+` `+
+		`WHERE status = 'Complete' AND completed_at > $1 AND `+name+` = 'LetsEncryptStaging' AND `+detail+` LIKE '%urn:ietf:params:acme:error:%' `+
+		// Domains whose owners made them private don't count towards the incidents reported to others
+		`AND domain NOT IN (SELECT domain FROM domain_claims WHERE private AND verified_at > $2);`,
+		now.Add(-incidentBaselinePeriod), now.Add(-claimValidity())); err != nil {
+		return nil, err
+	}
+
+	// The number of distinct registered domains failing with each error, so that many tests of
+	// one domain (or of the subdomains of one domain) don't look like an incident
+	recent := map[string]map[string]bool{}
+	earlier := map[string]map[string]bool{}
+	for _, row := range rows {
+		m := regexStagingURN.FindStringSubmatch(row.Detail)
+		if m == nil {
+			continue
+		}
+		registered, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimPrefix(row.Domain, "*."))
+		if err != nil {
+			registered = row.Domain
+		}
+		counts := earlier
+		if row.CompletedAt.After(now.Add(-window)) {
+			counts = recent
+		}
+		if counts[m[1]] == nil {
+			counts[m[1]] = map[string]bool{}
+		}
+		counts[m[1]][registered] = true
+	}
+
+	active := map[string]incident{}
+	windows := float64(incidentBaselinePeriod-window) / float64(window)
+	for urn, domains := range recent {
+		baseline := float64(len(earlier[urn])) / windows
+		if len(domains) >= minDomains && float64(len(domains)) >= incidentSurgeFactor*baseline {
+			active[urn] = incident{URN: urn, Domains: len(domains), Window: window}
+		}
+	}
+	return active, nil
+}
+
+func possibleIncident(inc incident) letsdebug.Problem {
+	return letsdebug.Problem{
+		Name: "PossibleLetsEncryptIncident",
+		Explanation: fmt.Sprintf(`In the last %v, Let's Debug has seen %d unrelated domains fail with the same error from the `+
+			`Let's Encrypt staging service as this one (%s), which is far more than usual. This may be an incident on Let's `+
+			`Encrypt's side rather than a problem with your domain, so check the status page before making changes, and `+
+			`try again later.`, inc.Window, inc.Domains, inc.URN),
+		Detail:     "urn:ietf:params:acme:error:" + inc.URN,
+		Severity:   letsdebug.SeverityWarning,
+		References: []string{"https://letsencrypt.status.io/", "https://community.letsencrypt.org/c/help/13"},
+	}
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dataSourceQueryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "letsdebug",
+			Name:      "datasource_query_duration_seconds",
+			Help:      "How long the queries made to third-party data sources (e.g. crt.sh) took, by outcome",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 8),
+		},
+		[]string{"source", "outcome"})
+	dataSourceQueryRows = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "letsdebug",
+			Name:      "datasource_query_rows",
+			Help:      "How many rows the successful queries made to third-party data sources returned",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 6),
+		},
+		[]string{"source"})
+)
+
+// prometheusMetrics reports the measurements of the letsdebug package to Prometheus. Counters are
+// exported as letsdebug_{name}_total, and timers as summaries (in seconds) named letsdebug_{name}_summary.
+// Each is registered the first time it is reported.
+type prometheusMetrics struct {
+	mu       sync.Mutex
+	counters map[string]*prometheus.CounterVec
+	timers   map[string]*prometheus.SummaryVec
+}
+
+func newPrometheusMetrics() *prometheusMetrics {
+	return &prometheusMetrics{counters: map[string]*prometheus.CounterVec{}, timers: map[string]*prometheus.SummaryVec{}}
+}
+
+func labelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *prometheusMetrics) Count(name string, labels map[string]string, delta float64) {
+	m.mu.Lock()
+	c, ok := m.counters[name]
+	if !ok {
+		c = promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "letsdebug",
+			Name:      name + "_total",
+			Help:      "Reported by the letsdebug package as " + name,
+		}, labelNames(labels))
+		m.counters[name] = c
+	}
+	m.mu.Unlock()
+	c.With(labels).Add(delta)
+}
+
+func (m *prometheusMetrics) Time(name string, labels map[string]string, d time.Duration) {
+	m.mu.Lock()
+	t, ok := m.timers[name]
+	if !ok {
+		t = promauto.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace: "letsdebug",
+			Name:      name + "_summary",
+			Help:      "Reported by the letsdebug package as " + name + ", in seconds",
+		}, labelNames(labels))
+		m.timers[name] = t
+	}
+	m.mu.Unlock()
+	t.With(labels).Observe(d.Seconds())
+}
+
+func (m *prometheusMetrics) ObserveQuery(source string, duration time.Duration, rows int, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	} else {
+		dataSourceQueryRows.WithLabelValues(source).Observe(float64(rows))
+	}
+	dataSourceQueryDuration.WithLabelValues(source, outcome).Observe(duration.Seconds())
+}
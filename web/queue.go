@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queueStatusMaxAge limits how often the queue status is read from the database,
+// since it is shown on the home page.
+const queueStatusMaxAge = 5 * time.Second
+
+type queueStatus struct {
+	BusyWorkers int `json:"busy_workers"`
+	Workers     int `json:"workers"`
+	QueueLength int `json:"queue_length"`
+	// AverageWaitSeconds is how long tests started in the last 10 minutes spent queued
+	AverageWaitSeconds float64 `json:"average_wait_seconds"`
+}
+
+// Busy is whether new tests are likely to wait for a worker.
+func (q queueStatus) Busy() bool {
+	return q.QueueLength > 0 || (q.Workers > 0 && q.BusyWorkers >= q.Workers)
+}
+
+// AverageWait formats AverageWaitSeconds for display.
+func (q queueStatus) AverageWait() string {
+	return (time.Duration(q.AverageWaitSeconds * float64(time.Second))).Round(time.Second).String()
+}
+
+type queueStatusCache struct {
+	mu        sync.Mutex
+	status    queueStatus
+	fetchedAt time.Time
+}
+
+func (s *server) queueStatus() (queueStatus, error) {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	if time.Since(s.queue.fetchedAt) < queueStatusMaxAge {
+		return s.queue.status, nil
+	}
+
+	status := queueStatus{
+		BusyWorkers: int(atomic.LoadInt32(&s.busyWorkers)),
+		Workers:     s.workers,
+	}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM tests WHERE status = 'Queued';`).Scan(&status.QueueLength); err != nil {
+		return status, fmt.Errorf("counting queued tests: %w", err)
+	}
+	wait := `EXTRACT(EPOCH FROM AVG(started_at - created_at))`
+	if s.sqlite {
+		wait = `AVG((julianday(started_at) - julianday(created_at)) * 86400)`
+	}
+	if err := s.db.QueryRow(`SELECT COALESCE(` + wait + `, 0) FROM tests ` +
+		`WHERE created_at > now() - interval '10 minutes' AND started_at IS NOT NULL;`).Scan(&status.AverageWaitSeconds); err != nil {
+		return status, fmt.Errorf("measuring queue wait: %w", err)
+	}
+
+	s.queue.status = status
+	s.queue.fetchedAt = time.Now()
+	return status, nil
+}
+
+func (s *server) httpQueueStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.queueStatus()
+	if err != nil {
+		log.Printf("Error fetching queue status: %v", err)
+		http.Error(w, "An internal error occurred fetching the queue status.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Error encoding queue status response: %v", err)
+	}
+}
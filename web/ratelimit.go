@@ -0,0 +1,51 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// rateLimitResponse is the body of a 429 response to an API client.
+type rateLimitResponse struct {
+	Error      string    `json:"error"`
+	RetryAfter int       `json:"retry_after"`
+	ResetAt    time.Time `json:"reset_at"`
+}
+
+// rateLimitReset returns how long until bucket has a token available again.
+func rateLimitReset(bucket *ratelimit.Bucket) time.Duration {
+	missing := 1 - bucket.Available()
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(float64(missing) / bucket.Rate() * float64(time.Second))
+}
+
+// setRateLimitHeaders describes bucket using the RateLimit header fields, as well as Retry-After,
+// and returns the number of seconds until it may be retried.
+func setRateLimitHeaders(w http.ResponseWriter, bucket *ratelimit.Bucket) int {
+	retryAfter := int(math.Ceil(rateLimitReset(bucket).Seconds()))
+	w.Header().Set("ratelimit-limit", strconv.FormatInt(bucket.Capacity(), 10))
+	w.Header().Set("ratelimit-remaining", strconv.FormatInt(max(bucket.Available(), 0), 10))
+	w.Header().Set("ratelimit-reset", strconv.Itoa(retryAfter))
+	w.Header().Set("retry-after", strconv.Itoa(retryAfter))
+	return retryAfter
+}
+
+func writeRateLimited(w http.ResponseWriter, msg string, retryAfter int) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	if err := json.NewEncoder(w).Encode(rateLimitResponse{
+		Error:      msg,
+		RetryAfter: retryAfter,
+		ResetAt:    time.Now().Add(time.Duration(retryAfter) * time.Second).UTC().Truncate(time.Second),
+	}); err != nil {
+		log.Printf("Error encoding rate limit response: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// forwardedHeaders are the headers by which reverse proxies describe the original request.
+var forwardedHeaders = []string{"X-Forwarded-For", "X-Real-IP", "X-Forwarded-Proto", requestIDHeader}
+
+// trustedProxies are the networks of the reverse proxies in front of the web server, whose
+// forwarded headers are believed.
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies reads a comma-separated list of CIDRs or single addresses.
+func parseTrustedProxies(s string) (trustedProxies, error) {
+	var proxies trustedProxies
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an address or CIDR", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func (p trustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the address of the client which made the request through the trusted proxies. Every
+// proxy appends the address it received the request from to X-Forwarded-For, so the client is the
+// rightmost address which isn't one of the proxies; anything further left could have been made up by it.
+func (p trustedProxies) clientIP(r *http.Request) string {
+	peer := remoteIP(r)
+	if !p.contains(peer) {
+		return peer
+	}
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !p.contains(hop) {
+				return hop
+			}
+			peer = hop
+		}
+		return peer
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return peer
+}
+
+// Handler replaces the remote address of requests which came through the trusted proxies with the
+// address of the client, and drops the forwarded headers of requests which didn't, so that clients
+// can't choose the address which rate limits and the audit log see.
+func (p trustedProxies) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.contains(remoteIP(r)) {
+			r.RemoteAddr = p.clientIP(r)
+		} else {
+			for _, header := range forwardedHeaders {
+				r.Header.Del(header)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.1, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseTrustedProxies("10.0.0.0/8,not-an-address"); err == nil {
+		t.Error("expected an invalid entry to be rejected")
+	}
+
+	for _, tt := range []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		wantClientIP string
+	}{
+		{"direct client", "198.51.100.7:1234", "203.0.113.1", "", "198.51.100.7"},
+		{"through a proxy", "10.1.2.3:1234", "203.0.113.1", "", "203.0.113.1"},
+		{"through a chain of proxies", "10.1.2.3:1234", "203.0.113.1, 192.0.2.1, 10.9.9.9", "", "203.0.113.1"},
+		{"spoofed by the client", "10.1.2.3:1234", "198.51.100.99, 203.0.113.1", "", "203.0.113.1"},
+		{"garbage before the client", "10.1.2.3:1234", "junk, 203.0.113.1", "", "203.0.113.1"},
+		{"garbage from the client", "10.1.2.3:1234", "203.0.113.1, junk", "", "10.1.2.3"},
+		{"only proxies", "10.1.2.3:1234", "10.4.5.6", "", "10.4.5.6"},
+		{"X-Real-IP", "[2001:db8::1]:1234", "", "203.0.113.2", "203.0.113.2"},
+		{"invalid X-Real-IP", "10.1.2.3:1234", "", "junk", "10.1.2.3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := proxies.clientIP(r); got != tt.wantClientIP {
+				t.Errorf("expected %s, got %s", tt.wantClientIP, got)
+			}
+		})
+	}
+}
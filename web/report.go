@@ -0,0 +1,159 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The formats in which the results of a set of tests can be downloaded.
+const (
+	reportCSV   = "csv"
+	reportJSONL = "jsonl"
+)
+
+var reportContentTypes = map[string]string{
+	reportCSV:   "text/csv; charset=utf-8",
+	reportJSONL: "application/x-ndjson",
+}
+
+// parseTestRef reads a test as named in its URL, e.g. example.com/674477 or
+// https://letsdebug.net/example.com/674477.
+func parseTestRef(s string) (string, int, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(s), "/"), "/")
+	if len(parts) < 2 {
+		return "", 0, false
+	}
+	domain := normalizeDomain(parts[len(parts)-2])
+	id, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || id <= 0 || !isValidDomain(domain) {
+		return "", 0, false
+	}
+	return domain, id, true
+}
+
+// writeResultsReport writes the results of the tests, with the problems which the filter shows. In
+// CSV, there is a row for each problem, or a single row for a test with none. In JSONL, there is a
+// line for each test, in the same format as the API.
+func writeResultsReport(w io.Writer, format string, tests []testView, filter resultFilter) error {
+	for i := range tests {
+		if tests[i].Status == "Complete" && tests[i].Result != nil {
+			tests[i].Result.Problems, _ = filter.apply(tests[i].Result.Problems)
+		}
+	}
+
+	if format == reportJSONL {
+		enc := json.NewEncoder(w)
+		for _, t := range tests {
+			if err := enc.Encode(t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"domain", "method", "test_id", "status", "completed_at", "severity", "category", "problem",
+		"explanation", "detail", "references", "result"})
+	for _, t := range tests {
+		var completedAt string
+		if t.CompletedAt != nil {
+			completedAt = t.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		row := []string{t.Domain, t.Method, strconv.FormatUint(t.ID, 10), t.Status, completedAt}
+		result := fmt.Sprintf("/%s/%d", t.Domain, t.ID)
+
+		if t.Result == nil || len(t.Result.Problems) == 0 {
+			var explanation string
+			if t.Result != nil {
+				explanation = t.Result.Error
+			}
+			_ = cw.Write(csvRow(append(row, t.Severity(), "", "", explanation, "", "", result)))
+			continue
+		}
+		for _, p := range t.Result.Problems {
+			_ = cw.Write(csvRow(append(row, string(p.Severity), string(p.Category), p.Name, p.Explanation, p.Detail,
+				strings.Join(p.References, " "), result)))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvRow neutralizes the cells of a CSV row which a spreadsheet would evaluate as a formula, by prefixing
+// them with a quote. Explanations and details quote the responses of servers which anyone may control.
+func csvRow(cells []string) []string {
+	row := make([]string, len(cells))
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cell = "'" + cell
+		}
+		row[i] = cell
+	}
+	return row
+}
+
+// serveResultsReport responds with the report of the tests as an attachment named after name, in the
+// format given by the extension of the request path.
+func serveResultsReport(w http.ResponseWriter, r *http.Request, name string, tests []testView) {
+	format := strings.TrimPrefix(path.Ext(r.URL.Path), ".")
+	contentType, ok := reportContentTypes[format]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("content-disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	if err := writeResultsReport(w, format, tests, parseResultFilter(r.URL.Query())); err != nil {
+		log.Printf("Error writing report %s: %v", name, err)
+	}
+}
+
+// httpTestsReport downloads the results of the tests named by repeated test= parameters, e.g.
+// test=example.com/674477. Tests which don't exist, or can't be viewed, are reported as Not found.
+func (s *server) httpTestsReport(w http.ResponseWriter, r *http.Request) {
+	refs := r.URL.Query()["test"]
+	maxTests := envOrDefaultInt("BATCH_MAX_DOMAINS", 250)
+	if len(refs) == 0 || len(refs) > maxTests {
+		http.Error(w, fmt.Sprintf("Please name between 1 and %d tests, like test=example.com/1234.", maxTests), http.StatusBadRequest)
+		return
+	}
+
+	var tests []testView
+	for _, ref := range refs {
+		domain, id, ok := parseTestRef(ref)
+		if !ok {
+			http.Error(w, fmt.Sprintf("%q does not name a test, it should look like example.com/1234.", ref), http.StatusBadRequest)
+			return
+		}
+		t, err := s.findViewableTest(r, domain, id)
+		if err != nil {
+			log.Printf("fetching %s/%d: %v", domain, id, err)
+			http.Error(w, "An internal error occurred fetching the tests.", http.StatusInternalServerError)
+			return
+		}
+		if t == nil {
+			t = &testView{ID: uint64(id), Domain: domain, Status: "Not found"}
+		}
+		tests = append(tests, *t)
+	}
+
+	serveResultsReport(w, r, "letsdebug-results", tests)
+}
+
+// httpBatchResults downloads the results of every test of the batch.
+func (s *server) httpBatchResults(w http.ResponseWriter, r *http.Request) {
+	b := s.batchFromRequest(w, r, func(msg string, code int) { http.Error(w, msg, code) })
+	if b == nil {
+		return
+	}
+	serveResultsReport(w, r, "letsdebug-batch-"+b.ID+"-results", b.Tests)
+}
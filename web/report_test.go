@@ -0,0 +1,38 @@
+package web
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTestRef(t *testing.T) {
+	for _, tt := range []struct {
+		ref        string
+		wantDomain string
+		wantID     int
+		wantOK     bool
+	}{
+		{"example.com/674477", "example.com", 674477, true},
+		{"https://letsdebug.net/example.com/674477", "example.com", 674477, true},
+		{" /Example.COM/674477/ ", "example.com", 674477, true},
+		{"*.example.com/12", "*.example.com", 12, true},
+		{"example.com", "", 0, false},
+		{"example.com/abc", "", 0, false},
+		{"example.com/0", "", 0, false},
+		{"example.com/-3", "", 0, false},
+		{"exa mple.com/12", "", 0, false},
+	} {
+		domain, id, ok := parseTestRef(tt.ref)
+		if domain != tt.wantDomain || id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("%q: expected (%q, %d, %t), got (%q, %d, %t)", tt.ref, tt.wantDomain, tt.wantID, tt.wantOK, domain, id, ok)
+		}
+	}
+}
+
+func TestCSVRow(t *testing.T) {
+	got := csvRow([]string{"example.com", "=HYPERLINK(\"http://evil\")", "+1", "-1", "@SUM(A1)", "\tx", "\rx", "", "a=b"})
+	want := []string{"example.com", "'=HYPERLINK(\"http://evil\")", "'+1", "'-1", "'@SUM(A1)", "'\tx", "'\rx", "", "a=b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// requestIDHeader carries the ID which correlates a request with the logs of the web server, of the
+// worker which runs the test it submits, and of the test itself. A reverse proxy in front of the web
+// server may set it, e.g. to nginx's $request_id.
+const requestIDHeader = "X-Request-ID"
+
+var regexRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// withRequestID makes sure every request has an ID, generating one unless a trusted proxy already
+// chose it, and returns it in the response.
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !regexRequestID.MatchString(id) {
+			var err error
+			if id, err = randomHex(8); err != nil {
+				log.Printf("Failed to generate a request ID: %v", err)
+				id = ""
+			}
+			r.Header.Set(requestIDHeader, id)
+		}
+		w.Header().Set(requestIDHeader, id)
+		h.ServeHTTP(w, r)
+	})
+}
+
+func requestID(r *http.Request) string {
+	return r.Header.Get(requestIDHeader)
+}
+
+// checkerFailureLogger logs the checkers of a test which failed, along with the request ID of the
+// test, so that they can be found from the request which submitted it.
+type checkerFailureLogger struct{}
+
+func (checkerFailureLogger) CheckerStarted(string, string) {}
+
+func (checkerFailureLogger) CheckerFinished(requestID, checker string, d time.Duration, problems int, err error) {
+	if err != nil {
+		log.Printf("[%s] %s failed after %v: %v", requestID, checker, d.Round(time.Millisecond), err)
+	}
+}
@@ -0,0 +1,143 @@
+//go:build sqlite
+
+package web
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	//go:embed db_migrations_sqlite
+	resSQLiteMigrations embed.FS
+
+	regexPlaceholder = regexp.MustCompile(`\$(\d+)`)
+	regexInterval    = regexp.MustCompile(`now\(\) - interval '(\d+) (\w+)'`)
+)
+
+func init() {
+	sql.Register("letsdebug-sqlite", &sqliteDriver{})
+	openSQLite = func(dsn string) (*sqlx.DB, error) {
+		db, err := sql.Open("letsdebug-sqlite", withSQLiteDefaults(dsn))
+		if err != nil {
+			return nil, err
+		}
+		return sqlx.NewDb(db, "sqlite3"), nil
+	}
+	migrateSQLite = func(db *sqlx.DB) error {
+		driver, err := migratesqlite.WithInstance(db.DB, &migratesqlite.Config{})
+		if err != nil {
+			return err
+		}
+		src, err := iofs.New(resSQLiteMigrations, "db_migrations_sqlite")
+		if err != nil {
+			return err
+		}
+		m, err := migrate.NewWithInstance("iofs", src, "sqlite3", driver)
+		if err != nil {
+			return err
+		}
+		if e := m.Up(); e != nil && e != migrate.ErrNoChange {
+			return e
+		}
+		return nil
+	}
+}
+
+// withSQLiteDefaults waits for locks rather than failing, lets tests be read while a worker writes
+// and enforces foreign keys, unless the DSN says otherwise. An empty DSN is letsdebug.db.
+func withSQLiteDefaults(dsn string) string {
+	if dsn == "" {
+		dsn = "letsdebug.db"
+	}
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return dsn
+	}
+	for k, v := range map[string]string{"_busy_timeout": "5000", "_journal_mode": "WAL", "_foreign_keys": "1"} {
+		if !q.Has(k) {
+			q.Set(k, v)
+		}
+	}
+	return path + "?" + q.Encode()
+}
+
+// sqliteQuery translates the Postgres dialect used by the web server's queries where SQLite differs:
+// $1 placeholders, now() and intervals.
+func sqliteQuery(query string) string {
+	query = regexPlaceholder.ReplaceAllString(query, "?$1")
+	query = regexInterval.ReplaceAllString(query, "datetime('now', '-$1 $2')")
+	return strings.ReplaceAll(query, "now()", "CURRENT_TIMESTAMP")
+}
+
+// sqliteDriver is go-sqlite3, with queries translated by sqliteQuery.
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type sqliteConn struct {
+	c *sqlite3.SQLiteConn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.c.Prepare(sqliteQuery(query))
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.c.PrepareContext(ctx, sqliteQuery(query))
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.c.ExecContext(ctx, sqliteQuery(query), args)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.c.QueryContext(ctx, sqliteQuery(query), args)
+}
+
+func (c *sqliteConn) Begin() (driver.Tx, error) {
+	return c.c.Begin() //nolint:staticcheck
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.c.BeginTx(ctx, opts)
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	return c.c.Ping(ctx)
+}
+
+func (c *sqliteConn) Close() error {
+	return c.c.Close()
+}
+
+// CheckNamedValue stores times in UTC and in the format of CURRENT_TIMESTAMP, so that they compare
+// correctly with the timestamps set by SQLite, which are text.
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if t, ok := nv.Value.(time.Time); ok {
+		nv.Value = t.UTC().Format("2006-01-02 15:04:05.999999999")
+		return nil
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,64 @@
+//go:build sqlite
+
+package web
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteQuery(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{`SELECT * FROM tests WHERE domain = $1 AND method = $2;`, `SELECT * FROM tests WHERE domain = ?1 AND method = ?2;`},
+		{`UPDATE tests SET status = $10 WHERE id = $1;`, `UPDATE tests SET status = ?10 WHERE id = ?1;`},
+		{`UPDATE tests SET completed_at = now() WHERE id = $1;`, `UPDATE tests SET completed_at = CURRENT_TIMESTAMP WHERE id = ?1;`},
+		{`DELETE FROM tests WHERE created_at < now() - interval '7 days';`, `DELETE FROM tests WHERE created_at < datetime('now', '-7 days');`},
+		{`SELECT COUNT(*) FROM tests WHERE created_at > now() - interval '10 minutes' AND started_at < now();`,
+			`SELECT COUNT(*) FROM tests WHERE created_at > datetime('now', '-10 minutes') AND started_at < CURRENT_TIMESTAMP;`},
+	} {
+		if got := sqliteQuery(tt.query); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestBanCoversDomain(t *testing.T) {
+	db, err := openSQLite(filepath.Join(t.TempDir(), "letsdebug.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateSQLite(db); err != nil {
+		t.Fatal(err)
+	}
+	s := &server{db: db, sqlite: true}
+	if _, err := db.Exec(`INSERT INTO domain_bans (domain, reason) VALUES ('example.com', 'abuse'), ('lifted.org', '');`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE domain_bans SET lifted_at = now() WHERE domain = 'lifted.org';`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		domain string
+		want   bool
+	}{
+		{"example.com", true},
+		{"www.example.com", true},
+		{"a.b.example.com", true},
+		{"*.example.com", true},
+		{"notexample.com", false},
+		{"example.com.evil.org", false},
+		{"com", false},
+		{"lifted.org", false},
+	} {
+		if got, err := s.isBanned(tt.domain); err != nil {
+			t.Fatal(err)
+		} else if got != tt.want {
+			t.Errorf("%s: expected banned=%t, got %t", tt.domain, tt.want, got)
+		}
+	}
+}
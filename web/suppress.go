@@ -0,0 +1,41 @@
+package web
+
+import "strings"
+
+// maxSuppressedProblems limits how many problem names a test may suppress.
+const maxSuppressedProblems = 50
+
+// suppressedProblems returns the names of the problems which the API key with the fingerprint
+// (as recorded in the audit log) has asked to suppress, from LETSDEBUG_WEB_SUPPRESSED_PROBLEMS.
+// It holds entries like "<fingerprint>=CloudflareCDN,OtherProblem" separated by semicolons.
+func suppressedProblems(fingerprint string) []string {
+	if fingerprint == "" {
+		return nil
+	}
+	var names []string
+	for _, entry := range strings.Split(envOrDefault("SUPPRESSED_PROBLEMS", ""), ";") {
+		key, list, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(key) != fingerprint {
+			continue
+		}
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// validSuppressedProblems checks the names of problems which a test submission asked to suppress.
+func validSuppressedProblems(names []string) bool {
+	if len(names) > maxSuppressedProblems {
+		return false
+	}
+	for _, name := range names {
+		if name == "" || len(name) > 255 {
+			return false
+		}
+	}
+	return true
+}
@@ -51,10 +51,12 @@
     }
   </style>
   {{ template "head" . }}
+  {{ block "theme_head" . }}{{ end }}
 </head>
 <body>
   {{ template "body" . }}
   <footer>
+    {{ block "theme_footer" . }}{{ end }}
     <p>We also have open-source
       <a href="https://github.com/letsdebug/letsdebug" target="_blank" rel="noopener noreferrer">API and CLI tools</a>,
       as well as
@@ -0,0 +1,102 @@
+{{ define "head" }}
+<meta name="robots" content="noindex" />
+<style>
+form, form input, form select {
+  font-size: 1rem;
+  min-width: auto;
+}
+input, select {
+  padding: 0.5rem;
+}
+label {
+  display: block;
+  margin: 1rem 0 0.25rem;
+}
+.submit {
+  display: block;
+  margin: 1rem 0;
+}
+.tests {
+  width: 100%;
+}
+.tests td {
+  padding: 1rem;
+  vertical-align: middle;
+}
+tr.test:nth-child(odd) {
+  background: whitesmoke;
+}
+.severity-Warning {
+  color: rgba(255, 166, 0, 0.657);
+}
+.severity-Error {
+  color: rgb(155, 41, 0);
+}
+.severity-Fatal {
+  color: darkred;
+}
+.severity-OK {
+  color: rgb(0, 77, 0);
+}
+</style>
+{{ end }}
+{{ define "body" }}
+<div class="container">
+  <a href="/"><h1>Let's Debug</h1></a>
+
+  {{ if .Error }}
+  <section class="error">{{ .Error }}</section>
+  {{ end }}
+
+  {{ with .Batch }}
+  <h2>Batch of {{ len .Tests }} domains ({{ .Method }})</h2>
+  <section class="description">
+    {{ if .Done }}
+    <p>All tests are complete. <a href="/batch/{{ .ID }}/report.csv">Download the consolidated report (CSV).</a>
+      Every problem found: <a href="/batch/{{ .ID }}/results.csv">CSV</a>, <a href="/batch/{{ .ID }}/results.jsonl">JSONL</a>.</p>
+    {{ else }}
+    <p>{{ .Completed }} of {{ len .Tests }} tests are complete ... please wait, this page will refresh automatically ...</p>
+    <p><a href="/batch/{{ .ID }}/report.csv">Download the report so far (CSV).</a></p>
+    {{ end }}
+    <p>Keep the address of this page to come back to it. Batches are deleted after 7 days.</p>
+  </section>
+  <section class="results">
+    <table class="tests">
+      {{ range .Tests }}
+      <tr class="test">
+        <td style="width: 30%" class="test-id"><a href="/{{ .Domain }}/{{ .ID }}">{{ .Domain }}</a></td>
+        <td style="width: 20%" class="test-severity severity-{{ .Severity }}">{{ .Severity }}</td>
+        <td style="width: 50%" class="test-summary">{{ .Summary }}</td>
+      </tr>
+      {{ end }}
+    </table>
+  </section>
+  {{ else }}
+  {{ if .CSRFToken }}
+  <section class="form">
+    <p>Upload a list of domains to test them all at once, one per line, or as a CSV file with the domain in the first column.
+      At most {{ .MaxDomains }} domains may be tested in one batch. An access token is required.</p>
+    <form action="/batch" method="POST" enctype="multipart/form-data">
+      <input type="hidden" name="csrf_token" value="{{ .CSRFToken }}">
+      <label for="domains">List of domains</label>
+      <input type="file" id="domains" name="domains" accept=".csv,.txt,text/csv,text/plain" required>
+      <label for="method">Validation method</label>
+      <select id="method" name="method">
+        <option value="http-01" {{ if eq "http-01" .Method }} selected {{ end }} >HTTP-01</option>
+        <option value="dns-01" {{ if eq "dns-01" .Method }} selected {{ end }} >DNS-01</option>
+        <option value="tls-alpn-01" {{ if eq "tls-alpn-01" .Method }} selected {{ end }} >TLS-ALPN-01</option>
+      </select>
+      <label for="token">Access token</label>
+      <input type="password" id="token" name="token" autocomplete="off" required>
+      <input class="submit" type="submit" value="Run Tests">
+    </form>
+  </section>
+  {{ else }}
+  <section class="description">
+    <p><a href="/">Go back to the start.</a></p>
+  </section>
+  {{ end }}
+  {{ end }}
+</div>
+{{ end }}
+{{ template "base" . }}
@@ -0,0 +1,96 @@
+{{ define "head" }}
+<meta name="robots" content="noindex" />
+<style>
+form, form input {
+  font-size: 1rem;
+  min-width: auto;
+}
+input {
+  padding: 0.5rem;
+}
+label {
+  display: block;
+  margin: 1rem 0 0.25rem;
+}
+.submit {
+  display: block;
+  margin: 1rem 0;
+}
+code {
+  word-break: break-all;
+}
+</style>
+{{ end }}
+{{ define "body" }}
+<div class="container">
+  <a href="/"><h1>Let's Debug</h1></a>
+
+  {{ if .Error }}
+  <section class="error">{{ .Error }}</section>
+  {{ end }}
+
+  {{ if .Domain }}
+  <h2>Claim {{ .Domain }}</h2>
+  {{ with .Claim }}
+  {{ if .Verified }}
+  <section class="description">
+    <p>You own {{ .Domain }}, as verified by {{ .VerifiedBy }}. Verifications expire after a while, as control of domains changes hands, and this page will then ask you to verify your claim again.</p>
+  </section>
+  <section class="form">
+    <form action="/{{ .Domain }}/claim/settings" method="POST">
+      <input type="hidden" name="csrf_token" value="{{ $.CSRFToken }}">
+      <label><input type="checkbox" name="private" {{ if .Private }} checked {{ end }}> Only show the tests of {{ .Domain }} to its owners, and to whoever submitted each of them</label>
+      <label for="suppress_problems">Problems to suppress in every test of {{ .Domain }}, comma-separated (e.g. CloudflareCDN)</label>
+      <input type="text" id="suppress_problems" name="suppress_problems" value="{{ range $i, $name := .SuppressedProblems }}{{ if $i }},{{ end }}{{ $name }}{{ end }}">
+      <input class="submit" type="submit" value="Save Settings">
+    </form>
+    <form action="/{{ .Domain }}/claim/delete-tests" method="POST">
+      <input type="hidden" name="csrf_token" value="{{ $.CSRFToken }}">
+      <input class="submit" type="submit" value="Delete All Tests of {{ .Domain }}">
+    </form>
+  </section>
+  {{ else }}
+  <section class="description">
+    <p>To prove that you control {{ .Domain }}, do either of the following, then verify your claim:</p>
+    <ul>
+      <li>Create a TXT record at <code>{{ .TXTName }}</code> containing <code>{{ .Token }}</code>.</li>
+      <li>Serve a file at <code>{{ .HTTPURL }}</code> containing <code>{{ .Token }}</code>.</li>
+    </ul>
+    <p>Unverified claims are deleted after 7 days.</p>
+  </section>
+  <section class="form">
+    <form action="/{{ .Domain }}/claim/verify" method="POST">
+      <input type="hidden" name="csrf_token" value="{{ $.CSRFToken }}">
+      <input class="submit" type="submit" value="Verify">
+    </form>
+  </section>
+  {{ end }}
+  <section class="form">
+    <form action="/{{ .Domain }}/claim/release" method="POST">
+      <input type="hidden" name="csrf_token" value="{{ $.CSRFToken }}">
+      <input class="submit" type="submit" value="Give Up This Claim">
+    </form>
+  </section>
+  {{ else }}
+  <section class="description">
+    <p>Owners of {{ .Domain }} can make its previous tests private, suppress problems in all of its tests, and delete its tests.
+      Claim the domain, then prove that you control it with a DNS record or a file on its web server.</p>
+  </section>
+  <section class="form">
+    <form action="/{{ .Domain }}/claim" method="POST">
+      <input type="hidden" name="csrf_token" value="{{ .CSRFToken }}">
+      <input class="submit" type="submit" value="Claim {{ .Domain }}">
+    </form>
+  </section>
+  {{ end }}
+  <section class="description">
+    <p><a href="/{{ .Domain }}">View the previous tests of {{ .Domain }}.</a></p>
+  </section>
+  {{ else }}
+  <section class="description">
+    <p><a href="/">Go back to the start.</a></p>
+  </section>
+  {{ end }}
+</div>
+{{ end }}
+{{ template "base" . }}
@@ -0,0 +1,75 @@
+{{ define "head" }}
+<meta name="robots" content="noindex" />
+<style>
+.results {
+  padding: 1rem 0;
+}
+.history {
+  width: 100%;
+}
+.history th {
+  text-align: left;
+  padding: 0 1rem;
+}
+.history td {
+  padding: 1rem;
+  vertical-align: middle;
+}
+tr.problem:nth-child(odd) {
+  background: whitesmoke;
+}
+.severity-Warning {
+  color: rgba(255, 166, 0, 0.657);
+}
+.severity-Error {
+  color: rgb(155, 41, 0);
+}
+.severity-Fatal {
+  color: darkred;
+}
+</style>
+{{ end }}
+{{ define "body" }}
+<div class="container">
+  <a href="/"><h1>Let's Debug</h1></a>
+
+  {{ if .Error }}
+  <section class="error">{{ .Error }}</section>
+  <section class="description">
+    <p><a href="/">Go back to the start.</a></p>
+  </section>
+  {{ else }}
+
+  <h2>Problem history for {{ .Domain }}</h2>
+  <section class="description">
+    <p>When each problem was first and last found by the tests of {{ .Domain }} which are still retained (up to 7 days).
+      <a href="/{{ .Domain }}">View the tests.</a></p>
+  </section>
+  <section class="results">
+    {{ if .History }}
+    <table class="history">
+      <tr>
+        <th>Method</th>
+        <th>Problem</th>
+        <th>First seen</th>
+        <th>Last seen</th>
+        <th>Tests</th>
+      </tr>
+      {{ range .History }}
+      <tr class="problem">
+        <td>{{ .Method }}</td>
+        <td class="severity-{{ .Severity }}">{{ .Name }}{{ if .Current }} (still present){{ else }} (resolved){{ end }}</td>
+        <td><abbr title="lasted {{ .Duration }}">{{ .FirstSeen.Format "2006-01-02 15:04:05 MST" }}</abbr></td>
+        <td>{{ .LastSeen.Format "2006-01-02 15:04:05 MST" }}</td>
+        <td>{{ .Tests }}</td>
+      </tr>
+      {{ end }}
+    </table>
+    {{ else }}
+    <p>No problems have been found by the retained tests of this domain.</p>
+    {{ end }}
+  </section>
+  {{ end }}
+</div>
+{{ end }}
+{{ template "base" . }}
@@ -1,5 +1,4 @@
 {{ define "head" }}
-{{ .WorkerCount }}
 <style>
 form, form input, form select {
   font-size: 1rem;
@@ -22,6 +21,31 @@ input, select {
   display: block;
   margin: 1rem 0;
 }
+.wildcard {
+  display: block;
+  margin-top: 0.5rem;
+  font-size: 0.9rem;
+}
+.field-error {
+  margin-top: 0.5rem;
+  font-size: 0.9rem;
+}
+.advanced {
+  margin-top: 1rem;
+  font-size: 0.9rem;
+}
+.advanced label {
+  display: block;
+  margin-top: 0.5rem;
+}
+.advanced input {
+  width: 100%;
+  box-sizing: border-box;
+}
+.queue {
+  font-size: 0.9rem;
+  color: #666;
+}
 </style>
 {{ end }}
 {{ define "body" }}
@@ -42,13 +66,14 @@ input, select {
      common website misconfigurations.</p>
   </section>
 
-  {{ if .Error }}
+  {{ if and .Error (not .ErrorField) }}
   <section class="error">{{ .Error }}</section>
   {{ end }}
 
   <section class="form">
     <p>Enter the domain and validation method you are having trouble issuing a certificate with. <small>(Choose HTTP-01 if unsure)</small>.</p>
     <form action="/" method="POST">
+      <input type="hidden" name="csrf_token" value="{{ .CSRFToken }}">
       <div class="fieldset">
         <input type="text" autofocus tabindex="1" class="domain" name="domain" placeholder="example.org" value="{{ .Domain }}" required>
         <select name="method" tabindex="2" class="validation-method">
@@ -57,9 +82,33 @@ input, select {
           <option value="tls-alpn-01" {{ if eq "tls-alpn-01" .Method }} selected {{ end }} >TLS-ALPN-01</option>
         </select>
       </div>
+      <label class="wildcard"><input type="checkbox" name="wildcard" value="y"{{ if .Wildcard }} checked{{ end }}>
+        Wildcard certificate, like <code>*.example.org</code> (DNS-01 only)</label>
+      {{ if and .ErrorField (eq .ErrorField "wildcard") }}<div class="error field-error">{{ .Error }}</div>{{ end }}
+      <details class="advanced"{{ if or (and .Options (or .Options.HTTPRequestPath .Options.HTTPExpectResponse)) (and .ErrorField (ne .ErrorField "wildcard")) }} open{{ end }}>
+        <summary>Advanced options <small>(HTTP-01 only)</small></summary>
+        <label for="http_request_path">Name of the challenge file to request, instead of <code>letsdebug-test</code></label>
+        <input type="text" id="http_request_path" name="http_request_path" maxlength="255"
+          placeholder="letsdebug-test" value="{{ with .Options }}{{ .HTTPRequestPath }}{{ end }}">
+        {{ if and .ErrorField (eq .ErrorField "http_request_path") }}<div class="error field-error">{{ .Error }}</div>{{ end }}
+        <label for="http_expect_response">Exact response to expect from each server (e.g. the contents of a file you placed there)</label>
+        <input type="text" id="http_expect_response" name="http_expect_response" maxlength="255"
+          value="{{ with .Options }}{{ .HTTPExpectResponse }}{{ end }}">
+        {{ if and .ErrorField (eq .ErrorField "http_expect_response") }}<div class="error field-error">{{ .Error }}</div>{{ end }}
+      </details>
       <input class="submit" tabindex="3" type="submit" value="Run Test">
     </form>
+    {{ if .Batches }}
+    <p><small>Testing many domains at once? <a href="/batch">Upload a list of domains.</a></small></p>
+    {{ end }}
   </section>
+
+  {{ with .Queue }}
+  <section class="queue {{ if .Busy }}warning{{ end }}">
+    {{ .BusyWorkers }} of {{ .Workers }} test workers are busy, and {{ .QueueLength }} test(s) are waiting.
+    {{ if .Busy }}Tests are currently waiting an average of {{ .AverageWait }} to start, so your results may be delayed.{{ end }}
+  </section>
+  {{ end }}
 </div>
 {{ end }}
 {{ template "base" . }}
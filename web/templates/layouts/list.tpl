@@ -41,6 +41,8 @@ tr.test:nth-child(odd) {
   {{ else }}
 
   <h2>Previous tests for {{ .Domain }}</h2>
+  <p><a href="/{{ .Domain }}/history">When was each problem first and last seen?</a></p>
+  <p><a href="/{{ .Domain }}/claim">Own this domain? Claim it to make its tests private, suppress problems or delete its tests.</a></p>
   <section class="results">
     <table class="tests">
       {{ range $index, $test := .Tests }}
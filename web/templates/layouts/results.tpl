@@ -32,6 +32,10 @@
   color: #eee;
   background: rgb(0, 77, 0);
 }
+.problem-Info, .problem-Info a, .problem-Info a:visited {
+  background: lightcyan;
+  color: black;
+}
 .problem-Debug, .problem-Debug a, .problem-Debug a:visited {
   background: lightskyblue;
   color: black;
@@ -55,6 +59,10 @@
 .problem-detail {
   font-size: 0.9rem;  
 }
+.problem-references {
+  margin-top: 1rem;
+  font-size: 0.9rem;
+}
 .problem-severity {
   text-transform: uppercase;
   font-size: 0.8em;
@@ -63,6 +71,32 @@
   font-size: 0.75rem;
   color: #333;
 }
+.annotation {
+  border-left: 4px solid #2c3c69;
+  padding: 0.5rem 1rem;
+  margin: 1rem 0;
+}
+.annotation-helper {
+  font-weight: bold;
+  font-size: 0.9rem;
+}
+.annotate-form textarea {
+  display: block;
+  width: 100%;
+  margin: 0.5rem 0;
+}
+.filter-form fieldset {
+  display: inline-block;
+  border: none;
+  padding: 0;
+  margin: 0 1rem 0.5rem 0;
+  font-size: 0.9rem;
+}
+.filter-form legend {
+  font-weight: bold;
+  font-size: 0.8rem;
+  text-transform: uppercase;
+}
 .recheck-form {
   display: inline;
 }
@@ -89,6 +123,7 @@
   <h2>Test result for <a href="/{{ .Test.Domain}}">{{ .Test.Domain }}</a> using {{ .Test.Method }}
     {{ if eq .Test.Status "Complete" }}
     <form action="/" method="POST" class="recheck-form">
+      <input type="hidden" name="csrf_token" value="{{ .CSRFToken }}">
       <input type="hidden" name="domain" value="{{ .Test.Domain }}">
       <input type="hidden" name="method" value="{{ .Test.Method }}">
       <input type="submit" value="(Rerun test)">
@@ -96,6 +131,22 @@
     {{ end }}
   </h2>
 
+  {{ if and (eq .Test.Status "Complete") (not .Test.Result.Error) }}
+  <form class="filter-form" action="/{{ .Test.Domain }}/{{ .Test.ID }}" method="GET">
+    <input type="hidden" name="filter" value="y">
+    <fieldset>
+      <legend>Show</legend>
+      {{ range .Severities }}<label><input type="checkbox" name="severity" value="{{ . }}"{{ if $.Filter.ShowsSeverity . }} checked{{ end }}> {{ . }}</label> {{ end }}
+    </fieldset>
+    <fieldset>
+      <legend>Categories</legend>
+      {{ range .Categories }}<label><input type="checkbox" name="category" value="{{ . }}"{{ if index $.Filter.Categories . }} checked{{ end }}> {{ . }}</label> {{ end }}
+    </fieldset>
+    <input type="submit" value="Filter">
+    <a class="times" href="/{{ .Test.Domain }}/{{ .Test.ID }}">Reset</a>
+  </form>
+  {{ end }}
+
   {{ if eq .Test.Status "Cancelled" }}
   <section class="error">
     This test was cancelled by the server, sorry! You may try again. <a href="/">Go back to the start.</a>
@@ -115,7 +166,7 @@
     <p>Unfortunately something went wrong when running the test.</p>
     <div class="error">{{ .Test.Result.Error }}</div>
   </section>
-  {{ else if not .Test.Result.Problems }}
+  {{ else if and (not .Test.Result.Problems) (not .Hidden) }}
   <section class="results">
     <div class="problem problem-OK">
       <div class="problem-header">
@@ -127,21 +178,48 @@
           please visit the <a href="https://community.letsencrypt.org/" target="_blank" rel="noopener noreferrer">
           Let's Encrypt Community forums</a> and post a question there.
         </p>
+        {{ with .Test.Result.Skipped }}
+        <p>{{ len . }} checks did not apply to this test or were skipped, so the problems they look for were not examined.
+          <a href="/{{ $.Test.Domain }}/{{ $.Test.ID }}?debug=y">Show verbose information</a> to see which, and why.
+        </p>
+        {{ end }}
       </div>
     </div>
   </section>
   {{ else }}
   <section class="results">
+    {{ if .Hidden }}
+    <p class="times">Problems hidden by the filters: {{ .Hidden }}. <a href="/{{ .Test.Domain }}/{{ .Test.ID }}?debug=y">Show all problems.</a></p>
+    {{ end }}
     {{ range $index, $problem := .Test.Result.Problems }}
     <div class="problem problem-{{ $problem.Severity }}" id="{{ $problem.Name }}-{{ $problem.Severity }}">
       <div class="problem-header">
           <div class="problem-name"><a href="#{{ $problem.Name }}-{{ $problem.Severity }}">{{ $problem.Name }}</a></div>
-          <div class="problem-severity">{{ $problem.Severity }}</div>    
+          <div class="problem-severity">{{ with $problem.Category }}{{ . }} &middot; {{ end }}{{ $problem.Severity }}</div>
       </div>
       <div class="problem-description">{{ $problem.Explanation }} </div>
       <div class="problem-detail">
         {{ range $dIndex, $detail := $problem.DetailLines }}{{ $detail }} <br/>{{ end }}
       </div>
+      {{ if $problem.References }}
+      <div class="problem-references">
+        Further reading:
+        <ul>
+          {{ range $rIndex, $ref := $problem.References }}<li><a href="{{ $ref }}" target="_blank" rel="noopener noreferrer">{{ $ref }}</a></li>{{ end }}
+        </ul>
+      </div>
+      {{ end }}
+    </div>
+    {{ end }}
+  </section>
+  {{ end }}
+  {{ if .Test.Annotations }}
+  <section class="results" id="annotations">
+    <h3>Notes from helpers</h3>
+    {{ range .Test.Annotations }}
+    <div class="annotation">
+      <div class="annotation-helper">{{ .Helper }} <abbr class="times" title="{{ .CreatedTimestamp }}">{{ .CreatedAt.Format "Jan 2 15:04 2006" }}</abbr></div>
+      <div class="annotation-body">{{ range .BodyLines }}{{ . }} <br/>{{ end }}</div>
     </div>
     {{ end }}
   </section>
@@ -150,11 +228,24 @@
     <p class="times">Submitted <abbr title="{{ .Test.CreatedTimestamp }}">{{ .Test.SubmitTime }}</abbr>.
     {{ if .Test.QueueDuration }}Sat in queue for {{ .Test.QueueDuration }}.{{ end }}
     {{ if .Test.TestDuration }}Completed in {{ .Test.TestDuration }}.{{ end }}
-    {{ if eq .Test.Status "Complete" }}
-    {{ if .Debug }} <a href="/{{ .Test.Domain }}/{{ .Test.ID}}">Hide verbose information.</a>
-    {{ else }} <a href="/{{ .Test.Domain }}/{{ .Test.ID}}?debug=y">Show verbose information.</a> {{ end }}
-    {{ end }}
   </p>
+  {{ with .Forum }}
+  <p class="forum">Still stuck? Ask for help on the community forum:
+    {{ if .NewTopicURL }}<a href="{{ .NewTopicURL }}" target="_blank" rel="noopener noreferrer">start a topic with these results filled in</a>, or{{ end }}
+    <a href="/{{ $.Test.Domain }}/{{ $.Test.ID }}/forum">copy the results as a forum post</a>.
+  </p>
+  {{ end }}
+  {{ if .Helpers }}
+  <details class="annotate-form">
+    <summary class="times">Helping with this test? Add a note.</summary>
+    <form action="/{{ .Test.Domain }}/{{ .Test.ID }}/annotations" method="POST">
+      <input type="hidden" name="csrf_token" value="{{ .CSRFToken }}">
+      <textarea name="body" rows="4" maxlength="2000" required></textarea>
+      <input type="password" name="token" placeholder="Helper token" autocomplete="off" required>
+      <input type="submit" value="Add Note">
+    </form>
+  </details>
+  {{ end }}
   </section>        
   {{ end }}
 </div>
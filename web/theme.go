@@ -0,0 +1,138 @@
+package web
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+)
+
+// themeFS overlays the files of an operator's theme directory over the embedded templates, so that
+// an instance can be branded without recompiling. A theme directory mirrors the embedded layout
+// (templates/includes, templates/layouts), and may also have a static directory served at /static/.
+type themeFS struct {
+	// dir is the theme directory, or nil if LETSDEBUG_WEB_THEME_DIR is not set
+	dir fs.FS
+}
+
+// Open opens the file from the theme directory if it has it, or else the embedded one.
+func (t themeFS) Open(name string) (fs.File, error) {
+	if t.dir != nil {
+		f, err := t.dir.Open(name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return resTemplates.Open(name)
+}
+
+// ReadDir lists the embedded files of a directory, followed by the files which only the theme
+// directory has, so that includes added by a theme are parsed after (and may redefine) the embedded ones.
+func (t themeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := resTemplates.ReadDir(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if t.dir == nil {
+		return entries, err
+	}
+	themed, themeErr := fs.ReadDir(t.dir, name)
+	if themeErr != nil {
+		if errors.Is(themeErr, fs.ErrNotExist) {
+			return entries, err
+		}
+		return nil, themeErr
+	}
+
+	seen := map[string]bool{}
+	for _, e := range entries {
+		seen[e.Name()] = true
+	}
+	var added []fs.DirEntry
+	for _, e := range themed {
+		if !seen[e.Name()] && !e.IsDir() {
+			added = append(added, e)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Name() < added[j].Name() })
+	return append(entries, added...), nil
+}
+
+// loadTemplates parses each layout together with every include.
+func (t themeFS) loadTemplates() (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+
+	templateFiles, err := t.ReadDir("templates/layouts")
+	if err != nil {
+		return nil, err
+	}
+	includeFiles, err := t.ReadDir("templates/includes")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tplFile := range templateFiles {
+		name := tplFile.Name()
+		tpl := template.New(name)
+
+		for _, incFile := range includeFiles {
+			incData, err := fs.ReadFile(t, "templates/includes/"+incFile.Name())
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tpl.Parse(string(incData)); err != nil {
+				return nil, err
+			}
+		}
+
+		tplData, err := fs.ReadFile(t, "templates/layouts/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tpl.Parse(string(tplData)); err != nil {
+			return nil, err
+		}
+
+		templates[name] = tpl
+	}
+
+	return templates, nil
+}
+
+// staticFile returns a file from the static directory of the theme, if there is one.
+func (t themeFS) staticFile(name string) ([]byte, bool) {
+	if t.dir == nil {
+		return nil, false
+	}
+	data, err := fs.ReadFile(t.dir, path.Join("static", name))
+	return data, err == nil
+}
+
+// staticHandler serves the static directory of the theme, for its stylesheets and images.
+func (t themeFS) staticHandler() http.Handler {
+	if t.dir == nil {
+		return http.NotFoundHandler()
+	}
+	static, err := fs.Sub(t.dir, "static")
+	if err != nil {
+		return http.NotFoundHandler()
+	}
+	return http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+}
+
+// newThemeFS returns the templates overlaid by the directory named by LETSDEBUG_WEB_THEME_DIR, if it is set.
+func newThemeFS() (themeFS, error) {
+	dir := envOrDefault("THEME_DIR", "")
+	if dir == "" {
+		return themeFS{}, nil
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return themeFS{}, err
+	} else if !info.IsDir() {
+		return themeFS{}, errors.New(dir + " is not a directory")
+	}
+	return themeFS{dir: os.DirFS(dir)}, nil
+}
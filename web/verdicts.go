@@ -0,0 +1,242 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/letsdebug/letsdebug"
+)
+
+// verdict is the outcome of the most recent complete test of a domain and method,
+// cheap enough to be read on every request.
+type verdict struct {
+	Domain      string    `json:"domain"`
+	Method      string    `json:"method"`
+	TestID      uint64    `json:"test_id"`
+	Severity    string    `json:"severity"`
+	Summary     string    `json:"summary"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+type verdictKey struct {
+	domain, method string
+}
+
+type verdictEntry struct {
+	verdict verdict
+	expires time.Time
+}
+
+// verdictCache holds the latest verdict for each (domain, method) for a short time, so that
+// it can be looked up without querying the tests table. Once invalidated, tests which completed
+// before the invalidation are no longer considered for the verdict.
+type verdictCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	entries     map[verdictKey]verdictEntry
+	invalidated map[verdictKey]time.Time
+}
+
+const (
+	// verdictInvalidationRetention matches how long tests are kept for.
+	verdictInvalidationRetention = 7 * 24 * time.Hour
+	// maxVerdictInvalidations bounds the invalidations remembered, the oldest of which are forgotten first.
+	maxVerdictInvalidations = 10000
+)
+
+func newVerdictCache(ttl time.Duration) *verdictCache {
+	return &verdictCache{ttl: ttl, entries: map[verdictKey]verdictEntry{}, invalidated: map[verdictKey]time.Time{}}
+}
+
+func (c *verdictCache) Get(domain, method string) (verdict, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := verdictKey{domain, method}
+	entry, ok := c.entries[key]
+	if !ok {
+		return verdict{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return verdict{}, false
+	}
+	return entry.verdict, true
+}
+
+func (c *verdictCache) Set(v verdict) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[verdictKey{v.Domain, v.Method}] = verdictEntry{verdict: v, expires: time.Now().Add(c.ttl)}
+}
+
+// Invalidate forgets the verdict for domain and method, along with every test completed so far.
+func (c *verdictCache) Invalidate(domain, method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := verdictKey{domain, method}
+	delete(c.entries, key)
+	if _, ok := c.invalidated[key]; !ok && len(c.invalidated) >= maxVerdictInvalidations {
+		var oldest verdictKey
+		var oldestAt time.Time
+		for k, at := range c.invalidated {
+			if oldestAt.IsZero() || at.Before(oldestAt) {
+				oldest, oldestAt = k, at
+			}
+		}
+		delete(c.invalidated, oldest)
+	}
+	c.invalidated[key] = time.Now()
+}
+
+// InvalidatedAt returns when the verdict for domain and method was last invalidated, or the zero time.
+func (c *verdictCache) InvalidatedAt(domain, method string) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.invalidated[verdictKey{domain, method}]
+}
+
+// Vacuum removes expired verdicts, and invalidations older than any remaining test.
+func (c *verdictCache) Vacuum() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for key, at := range c.invalidated {
+		if now.Sub(at) > verdictInvalidationRetention {
+			delete(c.invalidated, key)
+		}
+	}
+}
+
+func newVerdict(t testView) verdict {
+	if t.Result != nil {
+		// Severity relies on the worst problem being first
+		letsdebug.SortProblems(t.Result.Problems)
+	}
+	v := verdict{
+		Domain:   t.Domain,
+		Method:   t.Method,
+		TestID:   t.ID,
+		Severity: t.Severity(),
+		Summary:  t.LongSummary(),
+	}
+	if t.CompletedAt != nil {
+		v.CompletedAt = *t.CompletedAt
+	}
+	return v
+}
+
+// latestVerdict returns the verdict of the most recent complete test of domain and method,
+// from the cache if possible.
+func (s *server) latestVerdict(domain, method string) (*verdict, error) {
+	if v, ok := s.verdicts.Get(domain, method); ok {
+		return &v, nil
+	}
+
+	t, err := s.findLatestCompletedTest(domain, method, s.verdicts.InvalidatedAt(domain, method))
+	if err != nil || t == nil {
+		return nil, err
+	}
+	v := newVerdict(*t)
+	s.verdicts.Set(v)
+	return &v, nil
+}
+
+func (s *server) findLatestCompletedTest(domain, method string, completedAfter time.Time) (*testView, error) {
+	var t testView
+	if err := s.db.Get(&t, `SELECT * FROM tests WHERE domain = $1 AND method = $2 AND status = 'Complete' AND completed_at > $3 `+
+		`ORDER BY created_at DESC LIMIT 1;`, domain, method, completedAfter); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *server) vacuumVerdicts() {
+	for {
+		time.Sleep(time.Minute)
+		s.verdicts.Vacuum()
+	}
+}
+
+func (s *server) httpViewVerdict(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(chi.URLParam(r, "domain"))
+	method := chi.URLParam(r, "method")
+	if !isValidDomain(domain) || method == "" || len(method) > 200 {
+		http.Error(w, "Invalid request parameters.", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := s.canViewHistory(r, domain)
+	if err != nil {
+		log.Printf("finding the claims of %s: %v", domain, err)
+		http.Error(w, "An internal error occurred fetching the verdict.", http.StatusInternalServerError)
+		return
+	}
+
+	var v *verdict
+	if ok {
+		v, err = s.latestVerdict(domain, method)
+	}
+	if err != nil {
+		log.Printf("finding latest verdict for %s/%s: %v", domain, method, err)
+		http.Error(w, "An internal error occurred fetching the verdict.", http.StatusInternalServerError)
+		return
+	}
+	if v == nil {
+		http.Error(w, "No complete test exists for that domain and method.", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding verdict response: %v", err)
+	}
+}
+
+func (s *server) httpInvalidateVerdict(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(chi.URLParam(r, "domain"))
+	method := chi.URLParam(r, "method")
+	if !isValidDomain(domain) || method == "" || len(method) > 200 {
+		http.Error(w, "Invalid request parameters.", http.StatusBadRequest)
+		return
+	}
+
+	// Only the operator, or the verified owners of the domain, may hide its earlier tests
+	if !isAdmin(r) {
+		c, err := s.findClaim(domain, claimKey(r))
+		switch {
+		case errors.Is(err, errClaimNotFound):
+			http.Error(w, "Invalidating a verdict requires a verified claim of the domain.", http.StatusUnauthorized)
+			return
+		case err != nil:
+			log.Printf("Failed to find claim of %s: %v", domain, err)
+			http.Error(w, "An internal error occurred finding your claim.", http.StatusInternalServerError)
+			return
+		case !c.Verified():
+			http.Error(w, "The claim must be verified before it can be used.", http.StatusForbidden)
+			return
+		}
+	}
+
+	s.verdicts.Invalidate(domain, method)
+	s.audit(r, auditInvalidateVerdict, domain, 0, auditDetails{"method": method})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,18 +5,18 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"html/template"
 	"log"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -34,38 +34,94 @@ var (
 	//go:embed templates
 	resTemplates embed.FS
 
-	regexDNSName = regexp.MustCompile(`^[\w\-.]+$`) // Very basic test, further validation later
+	regexDNSName = regexp.MustCompile(`^[\w\-.]+$`)  // Very basic test, further validation later
+	regexToken   = regexp.MustCompile(`^[\w\-.~]*$`) // Characters of a file name in /.well-known/acme-challenge/
 )
 
 type server struct {
 	templates   map[string]*template.Template
+	theme       themeFS
 	db          *sqlx.DB
+	sqlite      bool // Whether db is SQLite rather than Postgres, for the few queries which differ
 	workCh      chan workRequest
 	busyWorkers int32
 
 	rateLimitByIP     map[string]*ratelimit.Bucket
 	rateLimitByDomain map[string]*ratelimit.Bucket
 
+	rateLimitClaimsMu   sync.Mutex
+	rateLimitClaimsByIP map[string]*ratelimit.Bucket
+
 	rateLimitCertwatch *ratelimit.Bucket
+
+	verdicts  *verdictCache
+	incidents incidentTracker
+
+	workers int
+	queue   queueStatusCache
+
+	csrfSecret []byte
+
+	// Severities of problems reclassified for every test, from LETSDEBUG_WEB_SEVERITY_OVERRIDES
+	severityOverrides map[string]letsdebug.SeverityLevel
+	// Probe agents which repeat every test from other network locations, from LETSDEBUG_WEB_PERSPECTIVES_FILE
+	perspectives []letsdebug.Perspective
 }
 
 // Serve begins serving the web application over LETSDEBUG_WEB_LISTEN_ADDR,
 // default 127.0.0.1:9150.
 func Serve() error {
-	s := &server{}
+	s := &server{csrfSecret: newCSRFSecret()}
 	r := chi.NewMux()
 
+	proxies, err := parseTrustedProxies(envOrDefault("TRUSTED_PROXIES", "127.0.0.0/8,::1"))
+	if err != nil {
+		return fmt.Errorf("LETSDEBUG_WEB_TRUSTED_PROXIES: %w", err)
+	}
+
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
-	r.Use(cors)
+	r.Use(proxies.Handler)
+	r.Use(withRequestID)
+	r.Use(newCORSPolicy(envOrDefault("CORS_ORIGINS", "*"), envOrDefault("CORS_METHODS", "GET,HEAD,POST,DELETE")).Handler)
+	r.Use(securityHeaders(envOrDefault("FRAME_ANCESTORS", "'none'")))
+	r.Use(middleware.GetHead)
+
+	overrides, err := letsdebug.ParseSeverityOverrides(envOrDefault("SEVERITY_OVERRIDES", ""))
+	if err != nil {
+		return fmt.Errorf("LETSDEBUG_WEB_SEVERITY_OVERRIDES: %w", err)
+	}
+	s.severityOverrides = overrides
+	if path := envOrDefault("PERSPECTIVES_FILE", ""); path != "" {
+		buf, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(buf, &s.perspectives)
+		}
+		if err != nil {
+			return fmt.Errorf("LETSDEBUG_WEB_PERSPECTIVES_FILE: %w", err)
+		}
+	}
+	metrics := newPrometheusMetrics()
+	letsdebug.SetMetrics(metrics)
+	letsdebug.SetDataSourceMetrics(metrics)
 
 	// Bring up the database
+	driver := envOrDefault("DB_DRIVER", "postgres")
 	dsn := envOrDefault("DB_DSN", "")
-	db, err := sqlx.Open(envOrDefault("DB_DRIVER", "postgres"), dsn)
+	if driver == "postgres" {
+		dsn = withStatementTimeout(dsn, time.Duration(envOrDefaultInt("DB_STATEMENT_TIMEOUT_SECS", 0))*time.Second)
+	}
+	s.sqlite = driver == "sqlite"
+	db, err := openDB(driver, dsn)
 	if err != nil {
 		return err
 	}
+	configureDB(db)
+	if err := waitForDB(db, time.Duration(envOrDefaultInt("DB_CONNECT_TIMEOUT_SECS", 60))*time.Second); err != nil {
+		return err
+	}
 	s.db = db
+	go s.monitorDB()
+	configureCertwatch()
 	// and update the schema
 	log.Printf("Running migrations ...")
 	if err := s.migrateUp(); err != nil {
@@ -75,41 +131,44 @@ func Serve() error {
 	// Create the channel early to avoid a race
 	// between listenForTests and runWorkers
 	s.workCh = make(chan workRequest)
+	s.workers = envOrDefaultInt("CONCURRENCY", 10)
+
+	// Listen for test inserts, or poll for them if the database can't notify us
+	if s.sqlite {
+		go s.pollForTests(time.Duration(envOrDefaultInt("POLL_INTERVAL_MS", 1000)) * time.Millisecond)
+	} else {
+		go func() {
+			if err := s.listenForTests(dsn); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
 
-	// Listen for test inserts
-	go func() {
-		if err := s.listenForTests(dsn); err != nil {
-			log.Fatal(err)
-		}
-	}()
+	s.verdicts = newVerdictCache(time.Duration(envOrDefaultInt("VERDICT_CACHE_SECS", 300)) * time.Second)
 
-	go s.runWorkers(envOrDefaultInt("CONCURRENCY", 10))
+	go s.runWorkers(s.workers)
 	go s.vacuumTests()
+	go s.vacuumVerdicts()
+	if minDomains := envOrDefaultInt("INCIDENT_MIN_DOMAINS", 5); minDomains > 0 {
+		go s.correlateIncidents(time.Duration(envOrDefaultInt("INCIDENT_WINDOW_MINS", 15))*time.Minute, minDomains)
+	}
+	if hours := envOrDefaultInt("DATA_REFRESH_HOURS", 0); hours > 0 {
+		go s.refreshData(time.Duration(hours) * time.Hour)
+	}
+	// The Public Suffix List changes more often than the other datasets, so it may be refreshed on its own
+	if hours := envOrDefaultInt("PSL_REFRESH_HOURS", 0); hours > 0 && !letsdebug.DataPinned() {
+		letsdebug.StartPublicSuffixListRefresher(context.Background(), time.Duration(hours)*time.Hour)
+	}
 
-	// Load templates
+	// Load templates, overlaid by the theme directory if there is one
 	log.Printf("Loading templates ...")
-	s.templates = map[string]*template.Template{}
-
-	templateFiles, _ := resTemplates.ReadDir("templates/layouts")
-	includeFiles, _ := resTemplates.ReadDir("templates/includes")
-
-	for _, tplFile := range templateFiles {
-		name := tplFile.Name()
-		tpl := template.New(name)
-
-		for _, incFile := range includeFiles {
-			incData, _ := resTemplates.ReadFile("templates/includes/" + incFile.Name())
-			if _, err := tpl.Parse(string(incData)); err != nil {
-				return err
-			}
-		}
-
-		tplData, _ := resTemplates.ReadFile("templates/layouts/" + name)
-		if _, err := tpl.Parse(string(tplData)); err != nil {
-			return err
-		}
-
-		s.templates[name] = tpl
+	theme, err := newThemeFS()
+	if err != nil {
+		return err
+	}
+	s.theme = theme
+	if s.templates, err = theme.loadTemplates(); err != nil {
+		return err
 	}
 
 	// Routes
@@ -119,17 +178,62 @@ func Serve() error {
 	r.Post("/", s.httpSubmitTest)
 	// - View test results (or test loading page)
 	r.Get("/{domain}/{testID}", s.httpViewTestResult)
+	// - Test result as a community forum help post
+	r.Get("/{domain}/{testID}/forum", s.httpForumPost)
+	// - Annotations of a test result by helpers
+	r.Post("/{domain}/{testID}/annotations", s.httpAnnotateTest)
+	r.Delete("/{domain}/{testID}/annotations/{annotationID}", s.httpDeleteAnnotation)
 	// - View all tests for domain
 	r.Get("/{domain}", s.httpViewDomain)
+	// - When each problem was first and last seen for domain
+	r.Get("/{domain}/history", s.httpViewDomainHistory)
+	// - Claiming a domain, and the features of its owners
+	r.Get("/{domain}/claim", s.httpViewClaim)
+	r.Post("/{domain}/claim", s.httpCreateClaim)
+	r.Post("/{domain}/claim/verify", s.httpVerifyClaim)
+	r.Post("/{domain}/claim/settings", s.httpUpdateClaim)
+	r.Post("/{domain}/claim/delete-tests", s.httpDeleteDomainTests)
+	r.Post("/{domain}/claim/release", s.httpReleaseClaim)
 	// Certwatch query gateway
 	r.Get("/certwatch-query", s.httpCertwatchQuery)
 	// Favicon
 	r.Get("/favicon.ico", s.httpServeFavicon)
 	// Robots.txt
 	r.Get("/robots.txt", s.httpServeRobots)
+	// Stylesheets and images of the theme
+	r.Handle("/static/*", theme.staticHandler())
+	// JSON Schema for test results
+	r.Get("/schema/result.json", s.httpServeResultSchema)
+	// The versions of the bundled datasets in use
+	r.Get("/data/versions", s.httpDataVersions)
+	// Latest verdict for a domain and method, and its invalidation
+	r.Get("/verdict/{domain}/{method}", s.httpViewVerdict)
+	r.Delete("/verdict/{domain}/{method}", s.httpInvalidateVerdict)
+	// Worker and queue status
+	r.Get("/queue", s.httpQueueStatus)
+	// Batches of domains, uploaded by authenticated users, and their consolidated report
+	r.Get("/batch", s.httpBatchHome)
+	r.Post("/batch", s.httpSubmitBatch)
+	r.Get("/batch/{batchID}", s.httpViewBatch)
+	r.Get("/batch/{batchID}/report.csv", s.httpBatchReport)
+	r.Get("/batch/{batchID}/results.csv", s.httpBatchResults)
+	r.Get("/batch/{batchID}/results.jsonl", s.httpBatchResults)
+	// The results of any set of tests, problem by problem
+	r.Get("/report.csv", s.httpTestsReport)
+	r.Get("/report.jsonl", s.httpTestsReport)
+	// Operator endpoints
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(requireAdmin)
+		r.Get("/audit", s.httpExportAudit)
+		r.Post("/tests/{testID}/cancel", s.httpAdminCancelTest)
+		r.Get("/bans", s.httpAdminListBans)
+		r.Post("/bans/{domain}", s.httpAdminBanDomain)
+		r.Delete("/bans/{domain}", s.httpAdminLiftBan)
+	})
 
 	s.rateLimitByDomain = map[string]*ratelimit.Bucket{}
 	s.rateLimitByIP = map[string]*ratelimit.Bucket{}
+	s.rateLimitClaimsByIP = map[string]*ratelimit.Bucket{}
 
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
@@ -159,20 +263,25 @@ func (s *server) httpCertwatchQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sqlx.Open("postgres", "user=guest dbname=certwatch host=crt.sh sslmode=disable connect_timeout=5")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pool, err := letsdebug.CertwatchDB(ctx)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to connect to Certwatch: %v", err), http.StatusGatewayTimeout)
 		return
 	}
-	defer db.Close()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	db := sqlx.NewDb(pool, "postgres")
 
 	var out []map[string]interface{}
 	rows, err := db.QueryxContext(ctx, q)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Query failed: %v", err), http.StatusInternalServerError)
+		letsdebug.CertwatchQueryFailed(pool, err)
+		code := http.StatusInternalServerError
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+		}
+		http.Error(w, fmt.Sprintf("Query failed: %v", err), code)
 		return
 	}
 	defer rows.Close()
@@ -187,6 +296,7 @@ func (s *server) httpCertwatchQuery(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := rows.Err(); err != nil {
+		letsdebug.CertwatchQueryFailed(pool, err)
 		http.Error(w, fmt.Sprintf("Reading rows failed: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -220,7 +330,17 @@ func (s *server) httpViewDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tests, err := s.findTests(domain)
+	ok, err := s.canViewHistory(r, domain)
+	if err != nil {
+		log.Printf("couldn't find the claims of %s: %v", domain, err)
+		doError("Internal error occurred finding tests", http.StatusInternalServerError)
+		return
+	}
+
+	var tests []testView
+	if ok {
+		tests, err = s.findTests(domain)
+	}
 	if err != nil {
 		log.Printf("couldn't find tests for %s: %v", domain, err)
 		doError("Internal error occurred finding tests", http.StatusInternalServerError)
@@ -262,7 +382,7 @@ func (s *server) httpViewTestResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	test, err := s.findTest(domain, testID)
+	test, err := s.findViewableTest(r, domain, testID)
 	if err != nil {
 		log.Printf("fetching %s/%d: %v", domain, testID, err)
 		doError("An internal error occurred fetching that test.", http.StatusInternalServerError)
@@ -278,23 +398,33 @@ func (s *server) httpViewTestResult(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Refresh", fmt.Sprintf("3;url=%s", r.URL.String()))
 	}
 
-	isDebug := r.URL.Query().Get("debug") == "y"
-	// Filter out debug
-	if test.Status == "Complete" && test.Result != nil && len(test.Result.Problems) > 0 && !isDebug {
-		deleted := 0
-		for i := range test.Result.Problems {
-			j := i - deleted
-			if test.Result.Problems[j].Severity == letsdebug.SeverityDebug {
-				test.Result.Problems = test.Result.Problems[:j+copy(test.Result.Problems[j:], test.Result.Problems[j+1:])]
-				deleted++
-			}
-		}
+	if test.Annotations, err = s.findAnnotations(test.ID); err != nil {
+		log.Printf("fetching the annotations of %s/%d: %v", domain, testID, err)
+	}
+
+	// Prepared before problems are filtered out, as they hold some of the answers
+	var forum *forumPost
+	if isBrowser && test.Status == "Complete" {
+		post := forumPostFor(*test)
+		forum = &post
+	}
+
+	filter := parseResultFilter(r.URL.Query())
+	hidden := 0
+	if test.Status == "Complete" && test.Result != nil && len(test.Result.Problems) > 0 {
+		test.Result.Problems, hidden = filter.apply(test.Result.Problems)
 	}
 
 	if isBrowser {
 		s.render(w, http.StatusOK, "results.tpl", map[string]interface{}{
-			"Test":  test,
-			"Debug": isDebug,
+			"Test":       test,
+			"Filter":     filter,
+			"Severities": filterableSeverities,
+			"Categories": letsdebug.Categories,
+			"Hidden":     hidden,
+			"Forum":      forum,
+			"Helpers":    envOrDefault("HELPER_TOKENS", "") != "",
+			"CSRFToken":  s.csrfToken(w, r),
 		})
 		return
 	}
@@ -309,7 +439,11 @@ func (s *server) httpSubmitTest(w http.ResponseWriter, r *http.Request) {
 	var domain, method string
 	var opts options
 
+	var wildcard bool
+
 	isBrowser := true
+	// The field of the browser form which an error is about, to show it next to the field
+	var errorField string
 
 	doError := func(msg string, code int) {
 		if !isBrowser {
@@ -317,32 +451,67 @@ func (s *server) httpSubmitTest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		s.render(w, code, "home.tpl", map[string]interface{}{
-			"Error": msg,
+			"Error":      msg,
+			"ErrorField": errorField,
+			"Domain":     domain,
+			"Method":     method,
+			"Wildcard":   wildcard,
+			"Options":    opts,
+			"CSRFToken":  s.csrfToken(w, r),
 		})
 	}
 
+	doRateLimited := func(msg string, bucket *ratelimit.Bucket) {
+		retryAfter := setRateLimitHeaders(w, bucket)
+		if !isBrowser {
+			writeRateLimited(w, msg, retryAfter)
+			return
+		}
+		doError(msg, http.StatusTooManyRequests)
+	}
+
 	switch r.Header.Get("content-type") {
 	case "application/x-www-form-urlencoded":
 		domain = r.PostFormValue("domain")
 		method = r.PostFormValue("method")
+		wildcard = r.PostFormValue("wildcard") == "y"
+		// People tend to paste the whole path, or URL, of the challenge file
+		opts.HTTPRequestPath = strings.TrimSpace(r.PostFormValue("http_request_path"))
+		if i := strings.Index(opts.HTTPRequestPath, "/.well-known/acme-challenge/"); i >= 0 {
+			opts.HTTPRequestPath = opts.HTTPRequestPath[i+len("/.well-known/acme-challenge/"):]
+		}
+		opts.HTTPExpectResponse = strings.TrimSpace(r.PostFormValue("http_expect_response"))
+		// Only the browser form is protected; JSON submissions come from API clients
+		if !s.checkCSRF(r) {
+			doError("Your session has expired, please submit the form again.", http.StatusForbidden)
+			return
+		}
+		if field, msg := validateFormOptions(opts); msg != "" {
+			errorField = field
+			doError(msg, http.StatusBadRequest)
+			return
+		}
 	case "application/json":
 		isBrowser = false
 		var testRequest struct {
-			Domain  string  `json:"domain"`
-			Method  string  `json:"method"`
-			Options options `json:"options"`
+			Domain   string  `json:"domain"`
+			Method   string  `json:"method"`
+			Wildcard bool    `json:"wildcard"`
+			Options  options `json:"options"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&testRequest); err != nil {
 			log.Printf("Error decoding request: %v", err)
 			doError("Request body was not valid JSON", http.StatusBadRequest)
 			return
 		}
-		if len(testRequest.Options.HTTPRequestPath) > 255 || len(testRequest.Options.HTTPExpectResponse) > 255 {
+		if len(testRequest.Options.HTTPRequestPath) > 255 || len(testRequest.Options.HTTPExpectResponse) > 255 ||
+			!validSuppressedProblems(testRequest.Options.SuppressProblems) {
 			doError("Test options were not valid", http.StatusBadRequest)
 			return
 		}
 		domain = testRequest.Domain
 		method = testRequest.Method
+		wildcard = testRequest.Wildcard
 		opts = testRequest.Options
 	default:
 		doError(http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
@@ -360,16 +529,41 @@ func (s *server) httpSubmitTest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	domain = normalizeDomain(domain)
+
+	// The wildcard checkbox of the browser form chooses dns-01 by itself, and typing a wildcard
+	// domain without ticking it is taken as asking for one
+	wildcardMethod := method
+	if isBrowser && wildcard {
+		wildcardMethod = ""
+	}
+	wildcardDomain, wildcardMethod, msg := normalizeWildcard(domain, wildcardMethod,
+		wildcard || (isBrowser && strings.HasPrefix(domain, "*.")))
+	if msg != "" {
+		errorField = "wildcard"
+		doError(msg, http.StatusBadRequest)
+		return
+	}
+	domain, method = wildcardDomain, wildcardMethod
+
 	if !isValidDomain(domain) || method == "" || len(method) > 200 {
 		doError("Please provide a valid domain name and validation method.", http.StatusBadRequest)
 		return
 	}
 
-	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-	if ip == "" {
-		ip = r.RemoteAddr
+	if banned, err := s.isBanned(domain); err != nil {
+		log.Printf("Failed to find the bans of %s: %v", domain, err)
+		doError(http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if banned {
+		doError("Tests of this domain have been disabled.", http.StatusForbidden)
+		return
 	}
 
+	opts.SuppressProblems = append(opts.SuppressProblems, suppressedProblems(apiKeyFingerprint(r))...)
+	opts.SuppressProblems = append(opts.SuppressProblems, s.claimedSuppressedProblems(domain)...)
+
+	ip := remoteIP(r)
+
 	// Enforce rate limits here.
 	// - Per IP: 1 test per 10s, capacity 3
 	ipLimit, ok := s.rateLimitByIP[ip]
@@ -380,7 +574,7 @@ func (s *server) httpSubmitTest(w http.ResponseWriter, r *http.Request) {
 		s.rateLimitByIP[ip] = ipLimit
 	}
 	if _, takeOk := ipLimit.TakeMaxDuration(1, time.Second); !takeOk {
-		doError(fmt.Sprintf("Too many tests from %s recently, try again soon.", ip), http.StatusTooManyRequests)
+		doRateLimited(fmt.Sprintf("Too many tests from %s recently, try again soon.", ip), ipLimit)
 		return
 	}
 	// - Per domain: 3 tests per minute, capacity 3.
@@ -392,18 +586,19 @@ func (s *server) httpSubmitTest(w http.ResponseWriter, r *http.Request) {
 		s.rateLimitByDomain[domain] = domainLimit
 	}
 	if _, takeOk := domainLimit.TakeMaxDuration(1, time.Second); !takeOk {
-		doError(fmt.Sprintf("Too many tests for %s recently, try again soon.", domain), http.StatusTooManyRequests)
+		doRateLimited(fmt.Sprintf("Too many tests for %s recently, try again soon.", domain), domainLimit)
 		return
 	}
 
-	log.Printf("[%s] Submitted test for %s/%s", ip, domain, method)
+	log.Printf("[%s] [%s] Submitted test for %s/%s", requestID(r), ip, domain, method)
 
-	id, err := s.createNewTest(domain, method, ip, opts)
+	id, err := s.createNewTest(domain, method, ip, requestID(r), opts)
 	if err != nil {
 		log.Printf("Failed to create test for %s/%s: %v\n", domain, method, err)
 		doError(http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, auditSubmit, domain, id, auditDetails{"method": method, "options": opts})
 
 	if isBrowser {
 		http.Redirect(w, r, fmt.Sprintf("/%s/%d", domain, id), http.StatusFound)
@@ -411,9 +606,10 @@ func (s *server) httpSubmitTest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	testResponse := struct {
-		Domain string
-		ID     uint64
-	}{domain, id}
+		Domain    string
+		ID        uint64
+		RequestID string
+	}{domain, id, requestID(r)}
 	w.Header().Set("content-type", "application/json")
 	if err := json.NewEncoder(w).Encode(testResponse); err != nil {
 		log.Printf("Error encoding submit test response: %v", err)
@@ -424,10 +620,20 @@ func (s *server) httpHome(w http.ResponseWriter, r *http.Request) {
 	domain := r.URL.Query().Get("domain")
 	method := r.URL.Query().Get("method")
 
+	// The indicator is left out if the status is unavailable
+	var queue *queueStatus
+	if status, err := s.queueStatus(); err == nil {
+		queue = &status
+	} else {
+		log.Printf("Error fetching queue status: %v", err)
+	}
+
 	s.render(w, http.StatusOK, "home.tpl", map[string]interface{}{
-		"WorkerCount": template.HTML(fmt.Sprintf("<!-- Busy Workers: %d -->", atomic.LoadInt32(&s.busyWorkers))),
-		"Domain":      domain,
-		"Method":      method,
+		"Queue":     queue,
+		"Batches":   len(batchTokens()) > 0,
+		"Domain":    domain,
+		"Method":    method,
+		"CSRFToken": s.csrfToken(w, r),
 	})
 }
 
@@ -473,24 +679,27 @@ func isValidDomain(domain string) bool {
 	return domain != "" && len(domain) <= 230 && regexDNSName.MatchString(domain)
 }
 
-func cors(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		origin := "*"
-		if o := r.Header.Get("origin"); o != "" {
-			origin = o
-		}
-		w.Header().Set("access-control-allow-origin", origin)
-		w.Header().Set("access-control-allowed-methods", "GET,HEAD,POST")
-		w.Header().Set("access-control-max-age", "86400")
-		w.Header().Set("access-control-allow-headers", r.Header.Get("access-control-request-headers"))
-
-		h.ServeHTTP(w, r)
-	})
+// validateFormOptions checks the advanced options of the browser form, which are stricter than those
+// accepted by the API, to catch mistakes. If they are not valid, it returns the field at fault and
+// the message to show next to it.
+func validateFormOptions(opts options) (string, string) {
+	if len(opts.HTTPRequestPath) > 255 || !regexToken.MatchString(opts.HTTPRequestPath) {
+		return "http_request_path", "The challenge file name may only contain letters, digits, '-', '_', '.' and '~', and be up to 255 characters long."
+	}
+	if len(opts.HTTPExpectResponse) > 255 {
+		return "http_expect_response", "The expected response may be up to 255 characters long."
+	}
+	return "", ""
 }
 
 var favicon = []byte("GIF89a@\x00@\x00\xf3\x0e\x00+;h+;i,;h+<h+<i+=i,<h-<h,<i-<i,=i-=i,<j,=j\x00\x00\x00\x00\x00\x00!\xf9\x04\x01\x00\x00\x0e\x00,\x00\x00\x00\x00@\x00@\x00\x00\x04\xfe\xd0\xc9I\xab\xbd8\xeb\u037b\xff`(!di\x8a\xa8\u05d8l\x9b\xbe\x96\t`,\f\x13eg&\xb6\x98\x83\xa7\x9e\xe7g9\xach\xa4\x81\x90\xa3 Y\x18-\x04#\xe6\\j\x88\xa3(6\x8b\xb0\"\xbb\x94\xa8\xc1\x10\x9c@\xc1\xde\n\x89715\xa8\xd56\x82\x9d\xe6\xaaI\x86\xef{\x12X\xd7%ga[whvuQ\tZ-M\x8a\x85=\x8d\x90\x915=g\x92\x96\x8aB\x97\x9aZS6\x83\x19\x9f\xa0\b{0\xa1\x17d\x8e\x1b$\xa4/\xa6\x16\xa8@\xa3\x8fq\x1c\xb0\x1f\xab\xb3\xa9\x19\xb6C\xb20\x95\x04:e\x1b\x8ct\xb7\x9b\u0217yW\xc9\u0356\xa2%\x06\x89$P\t\xd6\xd7\xd8\xd7&c\xd9\u0749\x95\xd6\x06\x8c\xb4\x82\x8e\xae\x84\xcb\u00ac\x0e\x9f\u0444\x1f\xbc\ua129\x83\xe7\x14\xf1\x1c\xb8\xef\xfb\xe5\xf0\u4abe\xfa\xf1\x93\xa3\v\x03>\x80\xeb\xd8\xfd\xab\xf7oWCQ\t\x19\xd2{x\x8a\xe2\x05}\x023\x12\xf4W\x10ID\x8b\xcf\x037\x1cd\x96\xd0\a\xc8{'\x05\x95\fao\xc2H\x88\xb98\xc6Z\x19\xab\u3ad4\x1bYj\x91y\xacE'hQx\xf6\xda\xf9\x05\xc1\xb8\x96\x12^\x16%q\xf4\x82\x80-H\x1d(\xbd\x88 \x1d \x89\xe6pJ\xd5Z\xa9B\xd7;\xc6\fu\x98\x1aRl\xce;?Ejm\xb7\xd0(U\x9b(\xe1\xf2\xc1s\x81\x11\x15\x05\x15S\x92=[\xc1\xee\x1d\xbco\u01e6\\\xe1\xb6n[\xc0p\xe4\xeeU\xd8\xd1/\x05\x028\xa0\xa5\u035b\xcf\x14\x02\xc8&\x99j ;\ue3c3iR\x1c\xda\x1c\x96f\xc1\xa4\x9b\x05QE\xd5\x19\xb4\xc2\xc1F^$\x1d\xc5\xc3@Z$\x05\x05\x968\u06d4)\x94&\f\x8c\x10\xb7J\x99\x80\x01d\xc8c:\x04\xf2\xa4\x15\xc5\xea/\x9e\x936\x8fN\xbd:\x8c\b\x00;")
 
 func (s *server) httpServeFavicon(w http.ResponseWriter, r *http.Request) {
+	if data, ok := s.theme.staticFile("favicon.ico"); ok {
+		w.Header().Set("Content-Type", http.DetectContentType(data))
+		_, _ = w.Write(data)
+		return
+	}
 	w.Header().Set("Content-Type", "image/gif")
 	_, _ = w.Write(favicon)
 }
@@ -501,5 +710,14 @@ Allow: /
 
 func (s *server) httpServeRobots(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
+	if data, ok := s.theme.staticFile("robots.txt"); ok {
+		_, _ = w.Write(data)
+		return
+	}
 	fmt.Fprint(w, robotsTxt)
 }
+
+func (s *server) httpServeResultSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(letsdebug.ResultSchema)
+}
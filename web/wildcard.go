@@ -0,0 +1,25 @@
+package web
+
+import "strings"
+
+// normalizeWildcard prepares the domain and method of a test which asked for a wildcard certificate,
+// by prefixing the domain with "*." (unless it already has it) and choosing dns-01, the only method
+// wildcards can be validated with, if no method was given. It returns a message explaining the
+// problem if the domain or method can't be used for a wildcard.
+func normalizeWildcard(domain, method string, wildcard bool) (string, string, string) {
+	name := strings.TrimPrefix(domain, "*.")
+	if strings.Contains(name, "*") {
+		return domain, method, "A wildcard can only replace the leftmost label of the domain, like *.example.com."
+	}
+	if !wildcard {
+		return domain, method, ""
+	}
+	if name == "" {
+		return domain, method, "Please provide the domain to test a wildcard certificate for, like example.com for *.example.com."
+	}
+	if method != "" && method != "dns-01" {
+		return domain, method, "A wildcard certificate can only be validated with the DNS-01 method. " +
+			"Choose DNS-01, or test the domain without the wildcard."
+	}
+	return "*." + name, "dns-01", ""
+}
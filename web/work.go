@@ -6,6 +6,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"log"
 	"sync/atomic"
+	"time"
 
 	"github.com/letsdebug/letsdebug"
 )
@@ -28,10 +29,11 @@ var (
 )
 
 type workRequest struct {
-	ID      int
-	Domain  string
-	Method  string
-	Options options
+	ID        int
+	Domain    string
+	Method    string
+	Options   options
+	RequestID string `json:"request_id" db:"request_id"`
 }
 
 func (s *server) runWorkers(numWorkers int) {
@@ -55,22 +57,41 @@ func (s *server) work() {
 		res, err := letsdebug.CheckWithOptions(req.Domain, method, letsdebug.Options{
 			HTTPExpectResponse: req.Options.HTTPExpectResponse,
 			HTTPRequestPath:    req.Options.HTTPRequestPath,
+			// Reuse staging results for domains that are being repeatedly re-tested
+			StagingResultsMaxAge: time.Duration(envOrDefaultInt("STAGING_REUSE_SECS", 0)) * time.Second,
+			Offline:              envOrDefault("OFFLINE", "") == "1",
+			SuppressProblems:     req.Options.SuppressProblems,
+			SeverityOverrides:    s.severityOverrides,
+			Perspectives:         s.perspectives,
+			Standalone:           req.Options.Standalone,
+			UserAgent:            envOrDefault("USER_AGENT", ""),
+			ContactURL:           envOrDefault("CONTACT_URL", envOrDefault("PUBLIC_URL", "")),
+			RequestID:            req.RequestID,
+			Tracer:               checkerFailureLogger{},
 		})
 		testsRun.With(prometheus.Labels{"method": string(method)}).Inc()
-		result := resultView{Problems: res}
+		if p, ok := s.incidents.Annotate(res); ok {
+			res = append(res, p)
+		}
+		result := resultView{SchemaVersion: letsdebug.ResultSchemaVersion, Metadata: letsdebug.NewResultMetadata(), Problems: res, Skipped: letsdebug.SkippedChecks(res)}
 		if err != nil {
 			testsFailed.With(prometheus.Labels{"method": string(method)}).Inc()
 			result.Error = err.Error()
 		}
 
+		atomic.AddInt32(&s.busyWorkers, -1)
+
 		strResult, _ := json.Marshal(result)
 		if _, err := s.db.Exec(`UPDATE tests SET completed_at = CURRENT_TIMESTAMP, status = 'Complete', result = $2 WHERE id = $1;`,
 			req.ID, string(strResult)); err != nil {
-			log.Printf("Error storing test %d result: %v", req.ID, err)
+			log.Printf("[%s] Error storing test %d result: %v", req.RequestID, req.ID, err)
 			continue
 		}
 
-		atomic.AddInt32(&s.busyWorkers, -1)
-		log.Printf("Test %d complete", req.ID)
+		now := time.Now()
+		s.verdicts.Set(newVerdict(testView{ID: uint64(req.ID), Domain: req.Domain, Method: req.Method,
+			Status: "Complete", CompletedAt: &now, Result: &result}))
+
+		log.Printf("[%s] Test %d complete", req.RequestID, req.ID)
 	}
 }
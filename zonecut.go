@@ -0,0 +1,91 @@
+package letsdebug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneCut describes the DNS zone which a name belongs to.
+type ZoneCut struct {
+	// Zone is the apex of the zone, without a trailing period.
+	Zone string `json:"zone"`
+	// Nameservers are the authoritative nameservers of the zone, sorted.
+	Nameservers []string `json:"nameservers"`
+}
+
+var errNoZoneCut = errors.New("no enclosing zone was found")
+
+// maxCNAMEChain is how many CNAMEs are followed before the chain is considered broken.
+const maxCNAMEChain = 8
+
+// followCNAMEs resolves the chain of CNAMEs starting at name, such as an _acme-challenge record
+// delegated to acme-dns or to a zone set aside for validation. It returns every name in the chain,
+// from name to the final target, and fails if the chain loops or is longer than maxCNAMEChain.
+func followCNAMEs(ctx *scanContext, name string) ([]string, error) {
+	chain := []string{name}
+	seen := map[string]bool{name: true}
+	for {
+		rrs, err := ctx.Lookup(chain[len(chain)-1], dns.TypeCNAME)
+		if err != nil {
+			return chain, fmt.Errorf("looking up the CNAME record of %s: %w", chain[len(chain)-1], err)
+		}
+		var target string
+		for _, rr := range rrs {
+			if cname, ok := rr.(*dns.CNAME); ok && normalizeFqdn(cname.Hdr.Name) == chain[len(chain)-1] {
+				target = normalizeFqdn(cname.Target)
+			}
+		}
+		if target == "" {
+			return chain, nil
+		}
+		chain = append(chain, target)
+		if seen[target] {
+			return chain, fmt.Errorf("the CNAME records form a loop: %s", strings.Join(chain, " -> "))
+		}
+		if len(chain) > maxCNAMEChain+1 {
+			return chain, fmt.Errorf("the chain of CNAME records is longer than %d: %s", maxCNAMEChain, strings.Join(chain, " -> "))
+		}
+		seen[target] = true
+	}
+}
+
+// FindZoneCut discovers the zone which name belongs to, by walking up from name until a name with
+// an SOA record (which only the apex of a zone has) is found. A record for name, such as a dns-01
+// TXT record, must be created in that zone, and its nameservers are the ones to query to find out
+// whether the record has propagated. CNAMEs at name are not followed.
+func FindZoneCut(name string) (ZoneCut, error) {
+	return findZoneCut(newScanContext(), normalizeFqdn(name))
+}
+
+func findZoneCut(ctx *scanContext, name string) (ZoneCut, error) {
+	for candidate := name; candidate != ""; {
+		rrs, err := ctx.Lookup(candidate, dns.TypeSOA)
+		if err != nil {
+			return ZoneCut{}, fmt.Errorf("looking up the SOA record of %s: %w", candidate, err)
+		}
+		for _, rr := range rrs {
+			// A CNAME at the candidate would otherwise lead to the SOA of its target's zone
+			if soa, ok := rr.(*dns.SOA); ok && normalizeFqdn(soa.Hdr.Name) == candidate {
+				return ZoneCut{Zone: candidate, Nameservers: zoneNameservers(ctx, candidate)}, nil
+			}
+		}
+		_, candidate, _ = strings.Cut(candidate, ".")
+	}
+	return ZoneCut{}, errNoZoneCut
+}
+
+func zoneNameservers(ctx *scanContext, zone string) []string {
+	var nameservers []string
+	rrs, _ := ctx.Lookup(zone, dns.TypeNS)
+	for _, rr := range rrs {
+		if ns, ok := rr.(*dns.NS); ok {
+			nameservers = append(nameservers, normalizeFqdn(ns.Ns))
+		}
+	}
+	sort.Strings(nameservers)
+	return nameservers
+}